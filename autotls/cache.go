@@ -0,0 +1,86 @@
+package autotls
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrCacheMiss is returned when a certificate isn't in the cache
+var ErrCacheMiss = errors.New("Certificate not found in cache")
+
+// accountKeyName is the file in the cache holding the ACME account key
+const accountKeyName = "acme_account.key"
+
+// certSuffix is appended to the hostname of cached certificates
+const certSuffix = ".pem"
+
+// DirCache stores the ACME account key and the certificate of each hostname
+// as PEM files in a directory
+type DirCache string
+
+// path returns the path of a file in the cache
+func (d DirCache) path(name string) string {
+	return filepath.Join(string(d), name)
+}
+
+// Get returns the contents of a file in the cache
+func (d DirCache) Get(name string) ([]byte, error) {
+	data, err := ioutil.ReadFile(d.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrCacheMiss
+	}
+	return data, err
+}
+
+// Put writes a file to the cache, creating the directory if needed. Files
+// are only readable by the current user since they contain private keys.
+func (d DirCache) Put(name string, data []byte) error {
+	err := os.MkdirAll(string(d), 0700)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(string(d), name+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), d.path(name))
+}
+
+// Delete removes a file from the cache
+func (d DirCache) Delete(name string) error {
+	err := os.Remove(d.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Hostnames returns the hostnames which have a cached certificate
+func (d DirCache) Hostnames() ([]string, error) {
+	fs, err := ioutil.ReadDir(string(d))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hosts := []string{}
+	for _, f := range fs {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), certSuffix) {
+			continue
+		}
+		hosts = append(hosts, strings.TrimSuffix(f.Name(), certSuffix))
+	}
+	return hosts, nil
+}
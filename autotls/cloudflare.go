@@ -0,0 +1,86 @@
+package autotls
+
+import (
+	"context"
+	"sync"
+
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/cloudflare"
+)
+
+// challengeRecordPrefix is prepended to a hostname to get the name of its
+// DNS-01 challenge record
+const challengeRecordPrefix = "_acme-challenge."
+
+// ErrCloudflareTokenNotSpecified is returned when DNS-01 challenges are used
+// without a Cloudflare token
+var ErrCloudflareTokenNotSpecified = cloudflare.ErrTokenNotSpecified
+
+// DNSProvider creates and removes the TXT records used to solve DNS-01
+// challenges
+type DNSProvider interface {
+	// Present creates the challenge record for the hostname
+	Present(ctx context.Context, hostname string, value string) error
+	// CleanUp removes the challenge record created by Present
+	CleanUp(ctx context.Context, hostname string, value string) error
+}
+
+// Cloudflare is a DNSProvider which creates challenge records in the
+// Cloudflare zone containing the hostname
+type Cloudflare struct {
+	*cloudflare.Client
+
+	mu sync.Mutex
+	// records maps the name and value of the challenge records which have
+	// been created to their zone and record id
+	records map[string][2]string
+}
+
+// NewCloudflare returns a Cloudflare DNS provider using the API token
+func NewCloudflare(token string) (*Cloudflare, error) {
+	client, err := cloudflare.NewClient(token)
+	if err != nil {
+		return nil, err
+	}
+	return &Cloudflare{
+		Client:  client,
+		records: make(map[string][2]string),
+	}, nil
+}
+
+// Present creates the TXT record for a DNS-01 challenge
+func (cf *Cloudflare) Present(ctx context.Context, hostname string, value string) error {
+	zone, err := cf.FindZone(ctx, hostname)
+	if err != nil {
+		return err
+	}
+	name := challengeRecordPrefix + hostname
+	record, err := cf.CreateDNSRecord(ctx, zone.ID, cloudflare.DNSRecord{
+		Type:    "TXT",
+		Name:    name,
+		Content: value,
+		TTL:     120,
+	})
+	if err != nil {
+		return err
+	}
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	if cf.records == nil {
+		cf.records = make(map[string][2]string)
+	}
+	cf.records[name+" "+value] = [2]string{zone.ID, record.ID}
+	return nil
+}
+
+// CleanUp removes the TXT record created for a DNS-01 challenge
+func (cf *Cloudflare) CleanUp(ctx context.Context, hostname string, value string) error {
+	key := challengeRecordPrefix + hostname + " " + value
+	cf.mu.Lock()
+	ids, ok := cf.records[key]
+	delete(cf.records, key)
+	cf.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return cf.DeleteDNSRecord(ctx, ids[0], ids[1])
+}
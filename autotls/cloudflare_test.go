@@ -0,0 +1,101 @@
+package autotls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/cloudflare"
+)
+
+// fakeCloudflare implements the parts of the Cloudflare API used to create
+// challenge records
+type fakeCloudflare struct {
+	zones   map[string]string
+	records map[string]map[string]interface{}
+	token   string
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"success":false,"errors":[{"message":"Invalid API Token"}]}`)
+		return
+	}
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/client/v4/zones":
+		result := []cloudflare.Zone{}
+		if id, ok := f.zones[r.URL.Query().Get("name")]; ok {
+			result = append(result, cloudflare.Zone{ID: id, Name: r.URL.Query().Get("name")})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "result": result})
+	case r.Method == "POST" && r.URL.Path == "/client/v4/zones/zone-1/dns_records":
+		record := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&record)
+		id := fmt.Sprintf("record-%d", len(f.records)+1)
+		f.records[id] = record
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "result": cloudflare.DNSRecord{ID: id}})
+	case r.Method == "DELETE":
+		var id string
+		fmt.Sscanf(r.URL.Path, "/client/v4/zones/zone-1/dns_records/%s", &id)
+		delete(f.records, id)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "result": cloudflare.DNSRecord{ID: id}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"success":false,"errors":[{"message":"Not found"}]}`)
+	}
+}
+
+func TestCloudflare(t *testing.T) {
+	fake := &fakeCloudflare{
+		zones:   map[string]string{"example.com": "zone-1"},
+		records: make(map[string]map[string]interface{}),
+		token:   "token",
+	}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	_, err := NewCloudflare("")
+	if err != ErrCloudflareTokenNotSpecified {
+		t.Fatalf("unexpected error. expected %v got %v", ErrCloudflareTokenNotSpecified, err)
+	}
+	cf, err := NewCloudflare("token")
+	if err != nil {
+		t.Fatalf("error creating Cloudflare provider: %v", err)
+	}
+	cf.BaseURL = ts.URL
+
+	ctx := context.Background()
+	err = cf.Present(ctx, "login.phish.example.com", "challenge-value")
+	if err != nil {
+		t.Fatalf("error creating challenge record: %v", err)
+	}
+	record, ok := fake.records["record-1"]
+	if !ok {
+		t.Fatalf("challenge record wasn't created")
+	}
+	if record["type"] != "TXT" || record["name"] != "_acme-challenge.login.phish.example.com" ||
+		record["content"] != "challenge-value" {
+		t.Fatalf("unexpected challenge record: %v", record)
+	}
+	err = cf.CleanUp(ctx, "login.phish.example.com", "challenge-value")
+	if err != nil {
+		t.Fatalf("error removing challenge record: %v", err)
+	}
+	if len(fake.records) != 0 {
+		t.Fatalf("challenge record wasn't removed: %v", fake.records)
+	}
+
+	err = cf.Present(ctx, "phish.example.org", "challenge-value")
+	if err == nil {
+		t.Fatalf("expected error for hostname without a zone")
+	}
+	cf.Token = "invalid"
+	err = cf.Present(ctx, "login.phish.example.com", "challenge-value")
+	if err == nil || err.Error() != "Cloudflare API error: Invalid API Token" {
+		t.Fatalf("unexpected error for invalid token: %v", err)
+	}
+}
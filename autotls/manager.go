@@ -0,0 +1,637 @@
+package autotls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"golang.org/x/crypto/acme"
+)
+
+const (
+	// ChallengeHTTP01 proves control of a hostname by serving a token over
+	// HTTP on port 80
+	ChallengeHTTP01 = "http-01"
+
+	// ChallengeDNS01 proves control of a hostname by creating a TXT record
+	// in its DNS zone
+	ChallengeDNS01 = "dns-01"
+
+	// DefaultRenewBefore is how long before they expire certificates are
+	// renewed
+	DefaultRenewBefore = 30 * 24 * time.Hour
+
+	// DefaultRenewInterval is how often certificates are checked for renewal
+	DefaultRenewInterval = 12 * time.Hour
+
+	// obtainTimeout is the time allowed to obtain a certificate
+	obtainTimeout = 5 * time.Minute
+
+	// challengePathPrefix is the path HTTP-01 challenge tokens are served
+	// from
+	challengePathPrefix = "/.well-known/acme-challenge/"
+)
+
+// ErrMissingServerName is returned when a TLS client doesn't send the
+// hostname it's connecting to
+var ErrMissingServerName = errors.New("TLS client didn't send a server name")
+
+// ErrHostNotAllowed is returned when a certificate is requested for a
+// hostname which isn't allowed by the host policy
+var ErrHostNotAllowed = errors.New("Certificates can't be requested for this hostname")
+
+// ErrUnsupportedChallenge is returned when the configured challenge isn't
+// http-01 or dns-01
+var ErrUnsupportedChallenge = errors.New("Challenge must be http-01 or dns-01")
+
+// ErrDNSProviderNotSpecified is returned when DNS-01 challenges are used
+// without a DNS provider
+var ErrDNSProviderNotSpecified = errors.New("DNS provider not specified for dns-01 challenges")
+
+// HostPolicy decides whether a certificate can be requested for a hostname
+type HostPolicy func(hostname string) error
+
+// HostWhitelist returns a HostPolicy which only allows the given hostnames
+func HostWhitelist(hostnames ...string) HostPolicy {
+	allowed := make(map[string]bool)
+	for _, h := range hostnames {
+		allowed[normalizeHostname(h)] = true
+	}
+	return func(hostname string) error {
+		if !allowed[hostname] {
+			return ErrHostNotAllowed
+		}
+		return nil
+	}
+}
+
+// Manager obtains a certificate for each hostname the phishing server is
+// accessed on from an ACME certificate authority, caching them on disk and
+// renewing them before they expire. Its GetCertificate method is used as
+// the GetCertificate function of a tls.Config.
+type Manager struct {
+	// DirectoryURL is the ACME directory of the certificate authority.
+	// Let's Encrypt is used if it's empty.
+	DirectoryURL string
+	Email        string
+	Cache        DirCache
+	// HostPolicy restricts the hostnames certificates are requested for.
+	// Every hostname is allowed if it's nil.
+	HostPolicy HostPolicy
+	// Challenge is ChallengeHTTP01 or ChallengeDNS01, defaulting to
+	// ChallengeHTTP01
+	Challenge   string
+	DNSProvider DNSProvider
+	// DNSPropagationDelay is the time to wait after creating a DNS-01
+	// challenge record before the certificate authority checks it
+	DNSPropagationDelay time.Duration
+	// RenewBefore defaults to DefaultRenewBefore
+	RenewBefore time.Duration
+	HTTPClient  *http.Client
+
+	clientMu sync.Mutex
+	client   *acme.Client
+
+	setupOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+
+	mu        sync.Mutex
+	certs     map[string]*tls.Certificate
+	hostLocks map[string]*sync.Mutex
+	renewing  map[string]bool
+
+	tokensMu sync.RWMutex
+	tokens   map[string]string
+}
+
+// setup initializes the internal state of the manager
+func (m *Manager) setup() {
+	m.setupOnce.Do(func() {
+		m.stop = make(chan struct{})
+		m.certs = make(map[string]*tls.Certificate)
+		m.hostLocks = make(map[string]*sync.Mutex)
+		m.renewing = make(map[string]bool)
+		m.tokens = make(map[string]string)
+	})
+}
+
+// Validate ensures the manager is able to solve the configured challenge
+func (m *Manager) Validate() error {
+	switch m.challenge() {
+	case ChallengeHTTP01:
+		return nil
+	case ChallengeDNS01:
+		if m.DNSProvider == nil {
+			return ErrDNSProviderNotSpecified
+		}
+		return nil
+	}
+	return ErrUnsupportedChallenge
+}
+
+func (m *Manager) challenge() string {
+	if m.Challenge == "" {
+		return ChallengeHTTP01
+	}
+	return m.Challenge
+}
+
+func (m *Manager) renewBefore() time.Duration {
+	if m.RenewBefore <= 0 {
+		return DefaultRenewBefore
+	}
+	return m.RenewBefore
+}
+
+// normalizeHostname returns the hostname in lowercase without a trailing dot
+func normalizeHostname(hostname string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(hostname)), ".")
+}
+
+// validHostname returns whether a certificate can be issued for the
+// hostname. Since hostnames are used as file names in the cache, anything
+// other than letters, digits, dots, hyphens and underscores is rejected.
+func validHostname(hostname string) bool {
+	if hostname == "" || strings.HasPrefix(hostname, ".") || strings.Contains(hostname, "..") ||
+		net.ParseIP(hostname) != nil {
+		return false
+	}
+	for _, c := range hostname {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '.' && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// GetCertificate returns the certificate for the hostname requested by the
+// TLS client, obtaining one from the certificate authority if it isn't
+// cached.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.setup()
+	name := normalizeHostname(hello.ServerName)
+	if name == "" {
+		return nil, ErrMissingServerName
+	}
+	if !validHostname(name) {
+		return nil, ErrHostNotAllowed
+	}
+	m.mu.Lock()
+	cert, ok := m.certs[name]
+	m.mu.Unlock()
+	if ok {
+		m.renewIfNeeded(name, cert)
+		return cert, nil
+	}
+	if m.HostPolicy != nil {
+		if err := m.HostPolicy(name); err != nil {
+			return nil, err
+		}
+	}
+	parent := hello.Context()
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, obtainTimeout)
+	defer cancel()
+	return m.certificate(ctx, name)
+}
+
+// hostLock returns the lock held while loading or obtaining the certificate
+// of a hostname
+func (m *Manager) hostLock(name string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.hostLocks[name]
+	if !ok {
+		l = &sync.Mutex{}
+		m.hostLocks[name] = l
+	}
+	return l
+}
+
+// certificate returns the certificate of a hostname from the cache, or
+// obtains one if there's no valid certificate cached
+func (m *Manager) certificate(ctx context.Context, name string) (*tls.Certificate, error) {
+	l := m.hostLock(name)
+	l.Lock()
+	defer l.Unlock()
+	m.mu.Lock()
+	cert, ok := m.certs[name]
+	m.mu.Unlock()
+	if ok {
+		return cert, nil
+	}
+	cert, err := m.load(name)
+	if err == nil {
+		m.renewIfNeeded(name, cert)
+		return cert, nil
+	}
+	if err != ErrCacheMiss {
+		log.Warnf("ignoring cached certificate for %s: %v", name, err)
+	}
+	return m.obtain(ctx, name)
+}
+
+// load reads the certificate of a hostname from the cache, returning an
+// error if it isn't valid for the hostname or has expired
+func (m *Manager) load(name string) (*tls.Certificate, error) {
+	data, err := m.Cache.Get(name + certSuffix)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := decodeCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := cert.Leaf.VerifyHostname(name); err != nil {
+		return nil, err
+	}
+	if time.Now().After(cert.Leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate expired on %s", cert.Leaf.NotAfter)
+	}
+	m.mu.Lock()
+	m.certs[name] = cert
+	m.mu.Unlock()
+	return cert, nil
+}
+
+// needsRenewal returns whether a certificate expires within RenewBefore
+func (m *Manager) needsRenewal(cert *tls.Certificate) bool {
+	return time.Until(cert.Leaf.NotAfter) < m.renewBefore()
+}
+
+// renewIfNeeded renews the certificate in the background if it's about to
+// expire, so that handshakes continue to use the current certificate
+func (m *Manager) renewIfNeeded(name string, cert *tls.Certificate) {
+	if !m.needsRenewal(cert) {
+		return
+	}
+	m.mu.Lock()
+	if m.renewing[name] {
+		m.mu.Unlock()
+		return
+	}
+	m.renewing[name] = true
+	m.mu.Unlock()
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			delete(m.renewing, name)
+			m.mu.Unlock()
+		}()
+		err := m.renew(name)
+		if err != nil {
+			log.Errorf("error renewing certificate for %s: %v", name, err)
+		}
+	}()
+}
+
+// renew obtains a new certificate for the hostname
+func (m *Manager) renew(name string) error {
+	l := m.hostLock(name)
+	l.Lock()
+	defer l.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), obtainTimeout)
+	defer cancel()
+	_, err := m.obtain(ctx, name)
+	return err
+}
+
+// RenewCertificates renews the cached certificates which expire within
+// RenewBefore
+func (m *Manager) RenewCertificates() error {
+	m.setup()
+	hosts, err := m.Cache.Hostnames()
+	if err != nil {
+		return err
+	}
+	for _, name := range hosts {
+		if !validHostname(name) {
+			continue
+		}
+		m.mu.Lock()
+		cert, ok := m.certs[name]
+		m.mu.Unlock()
+		var err error
+		if !ok {
+			cert, err = m.load(name)
+		}
+		if err == nil && !m.needsRenewal(cert) {
+			continue
+		}
+		if m.HostPolicy != nil && m.HostPolicy(name) != nil {
+			continue
+		}
+		err = m.renew(name)
+		if err != nil {
+			log.Errorf("error renewing certificate for %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// StartRenewal checks the cached certificates for renewal every interval
+// until Stop is called
+func (m *Manager) StartRenewal(interval time.Duration) {
+	m.setup()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			err := m.RenewCertificates()
+			if err != nil {
+				log.Error(err)
+			}
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops renewing certificates in the background
+func (m *Manager) Stop() {
+	m.setup()
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+}
+
+// acmeClient returns the client used to talk to the certificate authority,
+// registering the account the first time it's used
+func (m *Manager) acmeClient(ctx context.Context) (*acme.Client, error) {
+	m.clientMu.Lock()
+	defer m.clientMu.Unlock()
+	if m.client != nil {
+		return m.client, nil
+	}
+	key, err := m.accountKey()
+	if err != nil {
+		return nil, err
+	}
+	client := &acme.Client{
+		Key:          key,
+		DirectoryURL: m.DirectoryURL,
+		HTTPClient:   m.HTTPClient,
+		UserAgent:    "trust_strike",
+	}
+	account := &acme.Account{}
+	if m.Email != "" {
+		account.Contact = []string{"mailto:" + m.Email}
+	}
+	_, err = client.Register(ctx, account, acme.AcceptTOS)
+	if err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, err
+	}
+	m.client = client
+	return client, nil
+}
+
+// accountKey returns the cached ACME account key, generating one if it
+// doesn't exist
+func (m *Manager) accountKey() (*ecdsa.PrivateKey, error) {
+	data, err := m.Cache.Get(accountKeyName)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("invalid ACME account key")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if err != ErrCacheMiss {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	err = m.Cache.Put(accountKeyName, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	return key, err
+}
+
+// obtain requests a new certificate for the hostname, solving the
+// configured challenge, and caches it
+func (m *Manager) obtain(ctx context.Context, name string) (*tls.Certificate, error) {
+	client, err := m.acmeClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(name))
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range order.AuthzURLs {
+		z, err := client.GetAuthorization(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		if z.Status == acme.StatusValid {
+			continue
+		}
+		err = m.authorize(ctx, client, z)
+		if err != nil {
+			return nil, err
+		}
+	}
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: name},
+		DNSNames: []string{name},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	der, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodeCertificate(key, der)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := decodeCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+	err = m.Cache.Put(name+certSuffix, data)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.certs[name] = cert
+	m.mu.Unlock()
+	log.Infof("Obtained certificate for %s, valid until %s", name, cert.Leaf.NotAfter)
+	return cert, nil
+}
+
+// authorize solves the configured challenge of an authorization and waits
+// for the certificate authority to validate it
+func (m *Manager) authorize(ctx context.Context, client *acme.Client, z *acme.Authorization) error {
+	var chal *acme.Challenge
+	for _, c := range z.Challenges {
+		if c.Type == m.challenge() {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("%s challenge not offered for %s", m.challenge(), z.Identifier.Value)
+	}
+	switch chal.Type {
+	case ChallengeHTTP01:
+		resp, err := client.HTTP01ChallengeResponse(chal.Token)
+		if err != nil {
+			return err
+		}
+		p := client.HTTP01ChallengePath(chal.Token)
+		m.tokensMu.Lock()
+		m.tokens[p] = resp
+		m.tokensMu.Unlock()
+		defer func() {
+			m.tokensMu.Lock()
+			delete(m.tokens, p)
+			m.tokensMu.Unlock()
+		}()
+	case ChallengeDNS01:
+		if m.DNSProvider == nil {
+			return ErrDNSProviderNotSpecified
+		}
+		value, err := client.DNS01ChallengeRecord(chal.Token)
+		if err != nil {
+			return err
+		}
+		host := z.Identifier.Value
+		err = m.DNSProvider.Present(ctx, host, value)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err := m.DNSProvider.CleanUp(context.Background(), host, value)
+			if err != nil {
+				log.Errorf("error removing challenge record for %s: %v", host, err)
+			}
+		}()
+		select {
+		case <-time.After(m.DNSPropagationDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	_, err := client.Accept(ctx, chal)
+	if err != nil {
+		return err
+	}
+	_, err = client.WaitAuthorization(ctx, z.URI)
+	return err
+}
+
+// HTTPHandler answers HTTP-01 challenges, passing every other request to
+// the fallback handler. If fallback is nil, other requests are redirected to
+// HTTPS.
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	m.setup()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, challengePathPrefix) {
+			if fallback != nil {
+				fallback.ServeHTTP(w, r)
+				return
+			}
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusFound)
+			return
+		}
+		m.tokensMu.RLock()
+		resp, ok := m.tokens[r.URL.Path]
+		m.tokensMu.RUnlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(resp))
+	})
+}
+
+// encodeCertificate returns the PEM encoded private key followed by the
+// certificate chain
+func encodeCertificate(key *ecdsa.PrivateKey, chain [][]byte) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	for _, c := range chain {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c})...)
+	}
+	return data, nil
+}
+
+// decodeCertificate parses a certificate encoded by encodeCertificate
+func decodeCertificate(data []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf == nil {
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &cert, nil
+}
+
+// NewHTTPClient returns the client used to connect to the certificate
+// authority. If caCertPath is set, the directory's certificate must be
+// signed by the certificates in the file, as with test servers like Pebble.
+func NewHTTPClient(caCertPath string) (*http.Client, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if caCertPath == "" {
+		return client, nil
+	}
+	data, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", caCertPath)
+	}
+	client.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	}
+	return client, nil
+}
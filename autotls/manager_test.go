@@ -0,0 +1,214 @@
+package autotls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestCertificate returns a self-signed certificate for the hostname
+// encoded as it's stored in the cache
+func newTestCertificate(t *testing.T, hostname string, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hostname},
+		DNSNames:     []string{hostname},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	data, err := encodeCertificate(key, [][]byte{der})
+	if err != nil {
+		t.Fatalf("error encoding certificate: %v", err)
+	}
+	return data
+}
+
+func newTestManager(t *testing.T) *Manager {
+	dir, err := ioutil.TempDir("", "trust_strike-autotls")
+	if err != nil {
+		t.Fatalf("error creating cache directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	m := &Manager{
+		Cache:      DirCache(dir),
+		HostPolicy: HostWhitelist("phish.example.com", "Login.Example.com."),
+		// Certificates can't be obtained in unit tests
+		DirectoryURL: "http://127.0.0.1:1/directory",
+	}
+	m.setup()
+	return m
+}
+
+func TestDirCache(t *testing.T) {
+	m := newTestManager(t)
+	_, err := m.Cache.Get("missing.example.com.pem")
+	if err != ErrCacheMiss {
+		t.Fatalf("unexpected error for missing file. expected %v got %v", ErrCacheMiss, err)
+	}
+	err = m.Cache.Put("phish.example.com.pem", []byte("data"))
+	if err != nil {
+		t.Fatalf("error writing to cache: %v", err)
+	}
+	err = m.Cache.Put(accountKeyName, []byte("key"))
+	if err != nil {
+		t.Fatalf("error writing to cache: %v", err)
+	}
+	hosts, err := m.Cache.Hostnames()
+	if err != nil {
+		t.Fatalf("error listing cache: %v", err)
+	}
+	if len(hosts) != 1 || hosts[0] != "phish.example.com" {
+		t.Fatalf("unexpected cached hostnames: %v", hosts)
+	}
+	err = m.Cache.Delete("phish.example.com.pem")
+	if err != nil {
+		t.Fatalf("error deleting from cache: %v", err)
+	}
+	_, err = m.Cache.Get("phish.example.com.pem")
+	if err != ErrCacheMiss {
+		t.Fatalf("unexpected error for deleted file. expected %v got %v", ErrCacheMiss, err)
+	}
+}
+
+func TestGetCertificate(t *testing.T) {
+	m := newTestManager(t)
+	expires := time.Now().Add(60 * 24 * time.Hour).Truncate(time.Second)
+	err := m.Cache.Put("phish.example.com"+certSuffix, newTestCertificate(t, "phish.example.com", expires))
+	if err != nil {
+		t.Fatalf("error writing to cache: %v", err)
+	}
+
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "PHISH.example.com"})
+	if err != nil {
+		t.Fatalf("error getting cached certificate: %v", err)
+	}
+	if !cert.Leaf.NotAfter.Equal(expires) {
+		t.Fatalf("unexpected certificate expiry. expected %s got %s", expires, cert.Leaf.NotAfter)
+	}
+	if m.needsRenewal(cert) {
+		t.Fatalf("certificate expiring on %s shouldn't need renewal", expires)
+	}
+
+	tests := map[string]error{
+		"":                   ErrMissingServerName,
+		"other.example.com":  ErrHostNotAllowed,
+		"../phish.example":   ErrHostNotAllowed,
+		"127.0.0.1":          ErrHostNotAllowed,
+		"phish.example.com/": ErrHostNotAllowed,
+	}
+	for name, expected := range tests {
+		_, err = m.GetCertificate(&tls.ClientHelloInfo{ServerName: name})
+		if err != expected {
+			t.Fatalf("unexpected error for %q. expected %v got %v", name, expected, err)
+		}
+	}
+}
+
+func TestRenewCertificates(t *testing.T) {
+	m := newTestManager(t)
+	m.RenewBefore = 30 * 24 * time.Hour
+	err := m.Cache.Put("login.example.com"+certSuffix, newTestCertificate(t, "login.example.com", time.Now().Add(24*time.Hour)))
+	if err != nil {
+		t.Fatalf("error writing to cache: %v", err)
+	}
+	cert, err := m.load("login.example.com")
+	if err != nil {
+		t.Fatalf("error loading certificate: %v", err)
+	}
+	if !m.needsRenewal(cert) {
+		t.Fatalf("certificate expiring within RenewBefore should need renewal")
+	}
+	// Renewal fails since there's no certificate authority, but the
+	// existing certificate is kept
+	err = m.RenewCertificates()
+	if err != nil {
+		t.Fatalf("unexpected error renewing certificates: %v", err)
+	}
+	_, err = m.Cache.Get("login.example.com" + certSuffix)
+	if err != nil {
+		t.Fatalf("existing certificate was removed: %v", err)
+	}
+
+	// A certificate for another hostname isn't loaded
+	err = m.Cache.Put("other.example.com"+certSuffix, newTestCertificate(t, "login.example.com", time.Now().Add(60*24*time.Hour)))
+	if err != nil {
+		t.Fatalf("error writing to cache: %v", err)
+	}
+	_, err = m.load("other.example.com")
+	if err == nil {
+		t.Fatalf("expected error loading certificate for the wrong hostname")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	m := &Manager{}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("unexpected error for default challenge: %v", err)
+	}
+	m.Challenge = ChallengeDNS01
+	if err := m.Validate(); err != ErrDNSProviderNotSpecified {
+		t.Fatalf("unexpected error. expected %v got %v", ErrDNSProviderNotSpecified, err)
+	}
+	m.Challenge = "tls-alpn-01"
+	if err := m.Validate(); err != ErrUnsupportedChallenge {
+		t.Fatalf("unexpected error. expected %v got %v", ErrUnsupportedChallenge, err)
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	m := newTestManager(t)
+	m.tokens[challengePathPrefix+"token"] = "token.thumbprint"
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		handler  http.Handler
+		path     string
+		expected int
+	}{
+		{m.HTTPHandler(fallback), challengePathPrefix + "token", http.StatusOK},
+		{m.HTTPHandler(fallback), challengePathPrefix + "missing", http.StatusNotFound},
+		{m.HTTPHandler(fallback), "/login", http.StatusTeapot},
+		{m.HTTPHandler(nil), "/login?rid=1", http.StatusFound},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "http://phish.example.com:80"+test.path, nil)
+		w := httptest.NewRecorder()
+		test.handler.ServeHTTP(w, r)
+		if w.Code != test.expected {
+			t.Fatalf("unexpected status for %s. expected %d got %d", test.path, test.expected, w.Code)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "http://phish.example.com"+challengePathPrefix+"token", nil)
+	w := httptest.NewRecorder()
+	m.HTTPHandler(nil).ServeHTTP(w, r)
+	if w.Body.String() != "token.thumbprint" {
+		t.Fatalf("unexpected challenge response. expected %q got %q", "token.thumbprint", w.Body.String())
+	}
+	r = httptest.NewRequest("GET", "http://phish.example.com/login?rid=1", nil)
+	w = httptest.NewRecorder()
+	m.HTTPHandler(nil).ServeHTTP(w, r)
+	if w.Header().Get("Location") != "https://phish.example.com/login?rid=1" {
+		t.Fatalf("unexpected redirect: %s", w.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,79 @@
+package autotls
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestPebble obtains a certificate from a local Pebble test server using the
+// HTTP-01 challenge. It's skipped unless PEBBLE_DIRECTORY_URL is set, e.g.
+//
+//	pebble-challtestsrv -defaultIPv4 127.0.0.1 &
+//	pebble -config test/config/pebble-config.json -dnsserver 127.0.0.1:8053 &
+//	PEBBLE_DIRECTORY_URL=https://127.0.0.1:14000/dir \
+//	PEBBLE_CA_CERT=test/certs/pebble.minica.pem go test ./autotls -run Pebble
+//
+// Pebble must resolve PEBBLE_HOSTNAME (default "test.example.com") to this
+// machine and validate HTTP-01 challenges on PEBBLE_HTTP_ADDR (default
+// ":5002", Pebble's httpPort).
+func TestPebble(t *testing.T) {
+	directory := os.Getenv("PEBBLE_DIRECTORY_URL")
+	if directory == "" {
+		t.Skip("PEBBLE_DIRECTORY_URL not set")
+	}
+	hostname := os.Getenv("PEBBLE_HOSTNAME")
+	if hostname == "" {
+		hostname = "test.example.com"
+	}
+	addr := os.Getenv("PEBBLE_HTTP_ADDR")
+	if addr == "" {
+		addr = ":5002"
+	}
+	client, err := NewHTTPClient(os.Getenv("PEBBLE_CA_CERT"))
+	if err != nil {
+		t.Fatalf("error creating HTTP client: %v", err)
+	}
+	m := newTestManager(t)
+	m.DirectoryURL = directory
+	m.HTTPClient = client
+	m.HostPolicy = HostWhitelist(hostname)
+
+	server := &http.Server{Addr: addr, Handler: m.HTTPHandler(nil)}
+	go server.ListenAndServe()
+	defer server.Close()
+
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: hostname})
+	if err != nil {
+		t.Fatalf("error obtaining certificate: %v", err)
+	}
+	if err := cert.Leaf.VerifyHostname(hostname); err != nil {
+		t.Fatalf("certificate isn't valid for %s: %v", hostname, err)
+	}
+
+	// The certificate is cached on disk and used by new managers
+	cached := &Manager{Cache: m.Cache, HostPolicy: m.HostPolicy}
+	cert2, err := cached.GetCertificate(&tls.ClientHelloInfo{ServerName: hostname})
+	if err != nil {
+		t.Fatalf("error getting cached certificate: %v", err)
+	}
+	if cert2.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) != 0 {
+		t.Fatalf("certificate wasn't read from the cache")
+	}
+
+	// Renewing replaces the cached certificate
+	m.RenewBefore = time.Until(cert.Leaf.NotAfter) + time.Hour
+	err = m.RenewCertificates()
+	if err != nil {
+		t.Fatalf("error renewing certificates: %v", err)
+	}
+	renewed, err := m.load(hostname)
+	if err != nil {
+		t.Fatalf("error loading renewed certificate: %v", err)
+	}
+	if renewed.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) == 0 {
+		t.Fatalf("certificate wasn't renewed")
+	}
+}
@@ -0,0 +1,237 @@
+package cloudflare
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+)
+
+// DefaultURL is the base URL of the Cloudflare API
+const DefaultURL = "https://api.cloudflare.com"
+
+// ErrTokenNotSpecified is returned when a client is created without an API
+// token
+var ErrTokenNotSpecified = errors.New("Cloudflare token not specified")
+
+// Zone is a domain hosted by Cloudflare
+type Zone struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Status      string   `json:"status"`
+	NameServers []string `json:"name_servers"`
+}
+
+// DNSRecord is a DNS record in a Cloudflare zone
+type DNSRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+// Client sends requests to the Cloudflare API using an API token
+type Client struct {
+	Token string
+	// BaseURL overrides DefaultURL
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client using the API token
+func NewClient(token string) (*Client, error) {
+	if token == "" {
+		return nil, ErrTokenNotSpecified
+	}
+	return &Client{
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// response is the envelope of Cloudflare API responses
+type response struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// do sends a request to the Cloudflare API and decodes its result into v
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, v interface{}) error {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultURL
+	}
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, base+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	cfResp := response{}
+	err = json.NewDecoder(resp.Body).Decode(&cfResp)
+	if err != nil {
+		return fmt.Errorf("invalid response from Cloudflare: %s", resp.Status)
+	}
+	if !cfResp.Success {
+		messages := []string{}
+		for _, e := range cfResp.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("Cloudflare API error: %s", strings.Join(messages, ", "))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(cfResp.Result, v)
+}
+
+// Zones returns the zones the token has access to
+func (c *Client) Zones(ctx context.Context) ([]Zone, error) {
+	zones := []Zone{}
+	err := c.do(ctx, "GET", "/client/v4/zones", nil, &zones)
+	return zones, err
+}
+
+// ZonesByName returns the zones with the given name
+func (c *Client) ZonesByName(ctx context.Context, name string) ([]Zone, error) {
+	zones := []Zone{}
+	err := c.do(ctx, "GET", "/client/v4/zones?name="+url.QueryEscape(name), nil, &zones)
+	return zones, err
+}
+
+// FindZone returns the closest zone containing the hostname
+func (c *Client) FindZone(ctx context.Context, hostname string) (Zone, error) {
+	labels := strings.Split(hostname, ".")
+	for i := 0; i < len(labels)-1; i++ {
+		zones, err := c.ZonesByName(ctx, strings.Join(labels[i:], "."))
+		if err != nil {
+			return Zone{}, err
+		}
+		if len(zones) > 0 {
+			return zones[0], nil
+		}
+	}
+	return Zone{}, fmt.Errorf("no Cloudflare zone found for %s", hostname)
+}
+
+// AccountID returns the id of the first account the token has access to
+func (c *Client) AccountID(ctx context.Context) (string, error) {
+	accounts := []struct {
+		ID string `json:"id"`
+	}{}
+	err := c.do(ctx, "GET", "/client/v4/accounts", nil, &accounts)
+	if err != nil {
+		return "", err
+	}
+	if len(accounts) == 0 {
+		return "", fmt.Errorf("no accounts found")
+	}
+	return accounts[0].ID, nil
+}
+
+// CreateZone adds the domain to the account as a new zone
+func (c *Client) CreateZone(ctx context.Context, name string, accountID string) (Zone, error) {
+	zone := Zone{}
+	err := c.do(ctx, "POST", "/client/v4/zones", map[string]interface{}{
+		"name":       name,
+		"account":    map[string]string{"id": accountID},
+		"jump_start": true,
+	}, &zone)
+	return zone, err
+}
+
+// DNSRecords returns the records in the zone. The records can be filtered by
+// type and name, which are ignored if empty.
+func (c *Client) DNSRecords(ctx context.Context, zoneID string, recordType string, name string) ([]DNSRecord, error) {
+	q := url.Values{}
+	if recordType != "" {
+		q.Set("type", recordType)
+	}
+	if name != "" {
+		q.Set("name", name)
+	}
+	path := fmt.Sprintf("/client/v4/zones/%s/dns_records", zoneID)
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	records := []DNSRecord{}
+	err := c.do(ctx, "GET", path, nil, &records)
+	return records, err
+}
+
+// CreateDNSRecord creates a record in the zone, returning the record
+// created by Cloudflare
+func (c *Client) CreateDNSRecord(ctx context.Context, zoneID string, record DNSRecord) (DNSRecord, error) {
+	created := DNSRecord{}
+	err := c.do(ctx, "POST", fmt.Sprintf("/client/v4/zones/%s/dns_records", zoneID), record, &created)
+	return created, err
+}
+
+// UpdateDNSRecord replaces an existing record in the zone
+func (c *Client) UpdateDNSRecord(ctx context.Context, zoneID string, recordID string, record DNSRecord) error {
+	return c.do(ctx, "PUT", fmt.Sprintf("/client/v4/zones/%s/dns_records/%s", zoneID, recordID), record, nil)
+}
+
+// DeleteDNSRecord removes a record from the zone
+func (c *Client) DeleteDNSRecord(ctx context.Context, zoneID string, recordID string) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/client/v4/zones/%s/dns_records/%s", zoneID, recordID), nil, nil)
+}
+
+// SyncARecord ensures the proxied A record for the hostname points to the
+// IP address, creating the record if it doesn't exist
+func (c *Client) SyncARecord(ctx context.Context, hostname string, ip string) error {
+	zone, err := c.FindZone(ctx, hostname)
+	if err != nil {
+		return err
+	}
+	records, err := c.DNSRecords(ctx, zone.ID, "A", hostname)
+	if err != nil {
+		return fmt.Errorf("failed to get DNS records: %v", err)
+	}
+	// TTL 1 lets Cloudflare choose the TTL
+	record := DNSRecord{Type: "A", Name: hostname, Content: ip, TTL: 1, Proxied: true}
+	if len(records) == 0 {
+		log.Infof("Creating new DNS record for %s -> %s", hostname, ip)
+		_, err = c.CreateDNSRecord(ctx, zone.ID, record)
+		return err
+	}
+	if len(records) > 1 {
+		log.Infof("Found %d records for %s, updating the first one.", len(records), hostname)
+	}
+	if records[0].Content == ip {
+		log.Infof("DNS record for %s already points to %s", hostname, ip)
+		return nil
+	}
+	log.Infof("Updating DNS record for %s: %s -> %s", hostname, records[0].Content, ip)
+	return c.UpdateDNSRecord(ctx, zone.ID, records[0].ID, record)
+}
@@ -0,0 +1,117 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeCloudflare implements the parts of the Cloudflare API used to manage
+// the records of a single zone
+type fakeCloudflare struct {
+	zones   map[string]string
+	records map[string]DNSRecord
+	nextID  int
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"success":false,"errors":[{"message":"Invalid API Token"}]}`)
+		return
+	}
+	var result interface{}
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/client/v4/zones":
+		zones := []Zone{}
+		name := r.URL.Query().Get("name")
+		if id, ok := f.zones[name]; ok {
+			zones = append(zones, Zone{ID: id, Name: name})
+		}
+		result = zones
+	case r.Method == "GET" && r.URL.Path == "/client/v4/zones/zone-1/dns_records":
+		records := []DNSRecord{}
+		for _, record := range f.records {
+			if record.Type == r.URL.Query().Get("type") && record.Name == r.URL.Query().Get("name") {
+				records = append(records, record)
+			}
+		}
+		result = records
+	case r.Method == "POST" && r.URL.Path == "/client/v4/zones/zone-1/dns_records":
+		record := DNSRecord{}
+		json.NewDecoder(r.Body).Decode(&record)
+		f.nextID++
+		record.ID = fmt.Sprintf("record-%d", f.nextID)
+		f.records[record.ID] = record
+		result = record
+	case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/client/v4/zones/zone-1/dns_records/"):
+		record := DNSRecord{}
+		json.NewDecoder(r.Body).Decode(&record)
+		record.ID = strings.TrimPrefix(r.URL.Path, "/client/v4/zones/zone-1/dns_records/")
+		f.records[record.ID] = record
+		result = record
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"success":false,"errors":[{"message":"Not found"}]}`)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "result": result})
+}
+
+func TestSyncARecord(t *testing.T) {
+	fake := &fakeCloudflare{
+		zones:   map[string]string{"example.com": "zone-1"},
+		records: make(map[string]DNSRecord),
+	}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	_, err := NewClient("")
+	if err != ErrTokenNotSpecified {
+		t.Fatalf("unexpected error. expected %v got %v", ErrTokenNotSpecified, err)
+	}
+	c, err := NewClient("token")
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	c.BaseURL = ts.URL
+	ctx := context.Background()
+
+	// The record is created in the zone containing the hostname
+	err = c.SyncARecord(ctx, "login.example.com", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("error creating record: %v", err)
+	}
+	expected := DNSRecord{ID: "record-1", Type: "A", Name: "login.example.com", Content: "192.0.2.1", TTL: 1, Proxied: true}
+	if len(fake.records) != 1 || fake.records["record-1"] != expected {
+		t.Fatalf("unexpected records after create: %v", fake.records)
+	}
+
+	// Existing records are updated in place, or left alone if they're current
+	err = c.SyncARecord(ctx, "login.example.com", "192.0.2.2")
+	if err != nil {
+		t.Fatalf("error updating record: %v", err)
+	}
+	err = c.SyncARecord(ctx, "login.example.com", "192.0.2.2")
+	if err != nil {
+		t.Fatalf("error syncing current record: %v", err)
+	}
+	expected.Content = "192.0.2.2"
+	if len(fake.records) != 1 || fake.records["record-1"] != expected {
+		t.Fatalf("unexpected records after update: %v", fake.records)
+	}
+
+	err = c.SyncARecord(ctx, "login.example.org", "192.0.2.1")
+	if err == nil {
+		t.Fatalf("expected error for hostname without a zone")
+	}
+	c.Token = "invalid"
+	err = c.SyncARecord(ctx, "login.example.com", "192.0.2.1")
+	if err == nil || err.Error() != "Cloudflare API error: Invalid API Token" {
+		t.Fatalf("unexpected error for invalid token: %v", err)
+	}
+}
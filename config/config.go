@@ -24,6 +24,33 @@ type PhishServer struct {
 	UseTLS    bool   `json:"use_tls"`
 	CertPath  string `json:"cert_path"`
 	KeyPath   string `json:"key_path"`
+
+	// ACME obtains certificates automatically instead of using CertPath and
+	// KeyPath
+	ACME ACMEConfig `json:"acme"`
+}
+
+// ACMEConfig represents the settings used to obtain the phishing server's
+// certificates from an ACME certificate authority, such as Let's Encrypt
+type ACMEConfig struct {
+	Enabled      bool   `json:"enabled"`
+	DirectoryURL string `json:"directory_url"`
+	Email        string `json:"email"`
+	CacheDir     string `json:"cache_dir"`
+	// Challenge is either "http-01" or "dns-01". DNS-01 challenges are
+	// solved using the configured Cloudflare token.
+	Challenge string `json:"challenge"`
+	// Hostnames restricts the certificates which can be requested. When
+	// empty, certificates are requested for the configured phishing hosts.
+	Hostnames []string `json:"hostnames"`
+	// CACertPath is the root certificate used to connect to the directory,
+	// for test servers which don't have a publicly trusted certificate
+	CACertPath string `json:"ca_cert_path"`
+	// HTTPListenURL is the address HTTP-01 challenges are answered on
+	HTTPListenURL string `json:"http_listen_url"`
+	// DNSPropagationDelay is the number of seconds to wait after creating a
+	// DNS-01 challenge record before asking the CA to validate it
+	DNSPropagationDelay int `json:"dns_propagation_delay"`
 }
 
 // EC2Config represents the AWS EC2 configuration details
@@ -53,6 +80,49 @@ type Config struct {
 	CloudflareToken     string      `json:"cloudflare_token"`
 	SimulationServerURL string      `json:"simulation_server_url"`
 	EC2                 EC2Config   `json:"ec2"`
+	DefaultCountryCode  string      `json:"default_country_code"`
+	EncryptionKey       string      `json:"encryption_key"`
+
+	// Transparency is returned to people investigating a simulation
+	Transparency Transparency `json:"transparency"`
+
+	// EventClassification tunes how automated opens and clicks are detected
+	EventClassification EventClassification `json:"event_classification"`
+}
+
+// Transparency represents the details returned by the phishing server when
+// a transparency request is made, so that employees and IT staff
+// investigating a lure can verify it's part of an authorized simulation
+type Transparency struct {
+	// ServerName overrides the default ServerName
+	ServerName   string `json:"server_name"`
+	Organization string `json:"organization"`
+	// ContactAddress overrides the global contact address
+	ContactAddress string `json:"contact_address"`
+	Description    string `json:"description"`
+	PolicyURL      string `json:"policy_url"`
+	// HTMLPage returns a human-readable page to browsers instead of JSON
+	HTMLPage bool `json:"html_page"`
+}
+
+// EventClassification represents the settings used to tell opens and clicks
+// made by recipients apart from those made by mail security scanners and
+// image proxies
+type EventClassification struct {
+	// Enabled classifies opens and clicks, so that only those made by
+	// recipients update their status. When disabled, every open and click
+	// is counted and HEAD requests are ignored.
+	Enabled bool `json:"enabled"`
+	// ScannerNetworks are CIDR ranges used by link scanners, in addition to
+	// those detected by user agent
+	ScannerNetworks []string `json:"scanner_networks"`
+	// ImageProxyNetworks are CIDR ranges that fetch images on behalf of mail
+	// clients. If empty, the default ranges are used.
+	ImageProxyNetworks []string `json:"image_proxy_networks"`
+	// ScannerWindow is the number of seconds after an email is sent during
+	// which opens and clicks are attributed to scanners. If zero, the default
+	// window is used.
+	ScannerWindow int `json:"scanner_window"`
 }
 
 // Keycloak represents the Keycloak configuration details
@@ -67,7 +137,8 @@ type Keycloak struct {
 // Version contains the current trust_strike version
 var Version = ""
 
-// ServerName is the server type that is returned in the transparency response.
+// ServerName is the server type that is returned in the transparency response
+// and X-Server header, unless one is configured in the transparency settings.
 const ServerName = "IGNORE"
 
 // LoadConfig loads the configuration from the specified filepath
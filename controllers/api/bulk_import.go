@@ -74,25 +74,9 @@ func (as *Server) UploadBulkCSV(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Identify columns
-	cols := map[string]int{"first_name": -1, "last_name": -1, "email": -1, "position": -1}
-	for i, h := range header {
-		h = strings.ToLower(strings.TrimSpace(h))
-		if strings.Contains(h, "first") && strings.Contains(h, "name") {
-			cols["first_name"] = i
-		}
-		if strings.Contains(h, "last") && strings.Contains(h, "name") {
-			cols["last_name"] = i
-		}
-		if strings.Contains(h, "email") {
-			cols["email"] = i
-		}
-		if strings.Contains(h, "position") {
-			cols["position"] = i
-		}
-	}
-
-	if cols["email"] == -1 {
-		JSONResponse(w, models.Response{Success: false, Message: "CSV missing required 'Email' column"}, http.StatusBadRequest)
+	cols := identifyBulkColumns(header)
+	if cols["email"] == -1 && cols["phone"] == -1 {
+		JSONResponse(w, models.Response{Success: false, Message: "CSV missing required 'Email' or 'Phone' column"}, http.StatusBadRequest)
 		return
 	}
 
@@ -122,6 +106,9 @@ func (as *Server) UploadBulkCSV(w http.ResponseWriter, r *http.Request) {
 			if idx := cols["position"]; idx != -1 && len(record) > idx {
 				t.Position = record[idx]
 			}
+			if idx := cols["phone"]; idx != -1 && len(record) > idx {
+				t.Phone = record[idx]
+			}
 			preview = append(preview, t)
 		}
 	}
@@ -138,10 +125,11 @@ func (as *Server) UploadBulkCSV(w http.ResponseWriter, r *http.Request) {
 // CommitBulkImport starts the background import job
 func (as *Server) CommitBulkImport(w http.ResponseWriter, r *http.Request) {
 	req := struct {
-		Name      string `json:"name"`
-		GroupType string `json:"group_type"`
-		GroupId   int64  `json:"group_id"`
-		FileToken string `json:"file_token"`
+		Name        string `json:"name"`
+		GroupType   string `json:"group_type"`
+		GroupId     int64  `json:"group_id"`
+		FileToken   string `json:"file_token"`
+		CountryCode string `json:"country_code"`
 	}{}
 	if err := util.ParseJSON(r, &req); err != nil {
 		JSONResponse(w, models.Response{Success: false, Message: "Invalid request format"}, http.StatusBadRequest)
@@ -152,6 +140,13 @@ func (as *Server) CommitBulkImport(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, models.Response{Success: false, Message: "File token is required"}, http.StatusBadRequest)
 		return
 	}
+	if req.CountryCode == "" {
+		req.CountryCode = models.GetDefaultCountryCode()
+	}
+	if err := models.ValidateCountryCode(req.CountryCode); err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
 
 	tempFilename := os.TempDir() + string(os.PathSeparator) + "import_" + req.FileToken + ".csv"
 	if _, err := os.Stat(tempFilename); os.IsNotExist(err) {
@@ -205,7 +200,11 @@ func (as *Server) CommitBulkImport(w http.ResponseWriter, r *http.Request) {
 
 	// Start Background Worker
 	isNewGroup := req.GroupId == 0 && groupID != 0
-	go processBulkImport(job, tempFilename, groupID, isNewGroup)
+	opts := bulkImportOptions{
+		GroupType:   req.GroupType,
+		CountryCode: req.CountryCode,
+	}
+	go processBulkImport(job, tempFilename, groupID, isNewGroup, opts)
 
 	JSONResponse(w, map[string]interface{}{
 		"success":  true,
@@ -214,7 +213,45 @@ func (as *Server) CommitBulkImport(w http.ResponseWriter, r *http.Request) {
 	}, http.StatusOK)
 }
 
-func processBulkImport(job *models.Job, filename string, groupID int64, isNewGroup bool) {
+// bulkImportOptions holds the settings that affect how each row of a bulk
+// import is validated.
+type bulkImportOptions struct {
+	GroupType   string
+	CountryCode string
+}
+
+// identifyBulkColumns returns the index of each supported column in the
+// CSV header, or -1 if the column isn't present.
+func identifyBulkColumns(header []string) map[string]int {
+	cols := map[string]int{
+		"first_name": -1,
+		"last_name":  -1,
+		"email":      -1,
+		"position":   -1,
+		"phone":      -1,
+	}
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if strings.Contains(h, "first") && strings.Contains(h, "name") {
+			cols["first_name"] = i
+		}
+		if strings.Contains(h, "last") && strings.Contains(h, "name") {
+			cols["last_name"] = i
+		}
+		if strings.Contains(h, "email") {
+			cols["email"] = i
+		}
+		if strings.Contains(h, "position") {
+			cols["position"] = i
+		}
+		if strings.Contains(h, "phone") || strings.Contains(h, "mobile") {
+			cols["phone"] = i
+		}
+	}
+	return cols
+}
+
+func processBulkImport(job *models.Job, filename string, groupID int64, isNewGroup bool, opts bulkImportOptions) {
 	defer os.Remove(filename) // Cleanup after done
 
 	file, err := os.Open(filename)
@@ -265,31 +302,14 @@ func processBulkImport(job *models.Job, filename string, groupID int64, isNewGro
 	// Update job total immediately
 	job.UpdateProgress(0, totalRecords)
 
-	cols := map[string]int{
-		"first_name": -1,
-		"last_name":  -1,
-		"email":      -1,
-		"position":   -1,
-	}
-
-	for i, h := range header {
-		h = strings.ToLower(strings.TrimSpace(h))
-		if strings.Contains(h, "first") && strings.Contains(h, "name") {
-			cols["first_name"] = i
-		}
-		if strings.Contains(h, "last") && strings.Contains(h, "name") {
-			cols["last_name"] = i
-		}
-		if strings.Contains(h, "email") {
-			cols["email"] = i
-		}
-		if strings.Contains(h, "position") {
-			cols["position"] = i
-		}
+	cols := identifyBulkColumns(header)
+	// SMS groups are addressed by phone number, every other group by email
+	requiredCol, requiredName := "email", "Email"
+	if opts.GroupType == "sms" {
+		requiredCol, requiredName = "phone", "Phone"
 	}
-
-	if cols["email"] == -1 {
-		job.Fail("CSV missing required 'Email' column")
+	if cols[requiredCol] == -1 {
+		job.Fail(fmt.Sprintf("CSV missing required '%s' column", requiredName))
 		return
 	}
 
@@ -303,8 +323,10 @@ func processBulkImport(job *models.Job, filename string, groupID int64, isNewGro
 	allAddedTargets := []int64{}
 	allAddedLinks := []int64{}
 
+	var rejectedCount int64 = 0
+
 	// Map to track duplicates within the file
-	seenEmails := make(map[string]bool)
+	seenRecipients := make(map[string]bool)
 
 	startTime := time.Now()
 
@@ -331,6 +353,8 @@ func processBulkImport(job *models.Job, filename string, groupID int64, isNewGro
 		}
 
 		processedRecords++
+		// The header occupies the first line of the file
+		row := processedRecords + 1
 
 		// Extract data
 		t := models.Target{}
@@ -340,30 +364,47 @@ func processBulkImport(job *models.Job, filename string, groupID int64, isNewGro
 		if idx := cols["last_name"]; idx != -1 && len(record) > idx {
 			t.LastName = record[idx]
 		}
-		if idx := cols["email"]; idx != -1 && len(record) > idx {
+		if idx := cols["email"]; idx != -1 && len(record) > idx && record[idx] != "" {
 			// Parse email to ensure valid
 			e, err := mail.ParseAddress(record[idx])
-			if err == nil {
-				t.Email = e.Address
-			} else {
-				continue // Skip invalid emails silently or log?
+			if err != nil {
+				job.AddError(fmt.Sprintf("Row %d: invalid email address %q", row, record[idx]))
+				rejectedCount++
+				continue
 			}
-		} else {
-			continue // No email
+			t.Email = e.Address
+		}
+		if idx := cols["phone"]; idx != -1 && len(record) > idx && record[idx] != "" {
+			phone, err := models.NormalizePhoneNumber(record[idx], opts.CountryCode)
+			if err != nil {
+				job.AddError(fmt.Sprintf("Row %d: invalid phone number %q", row, record[idx]))
+				rejectedCount++
+				continue
+			}
+			t.Phone = phone
 		}
 		if idx := cols["position"]; idx != -1 && len(record) > idx {
 			t.Position = record[idx]
 		}
+		if (requiredCol == "email" && t.Email == "") || (requiredCol == "phone" && t.Phone == "") {
+			job.AddError(fmt.Sprintf("Row %d: missing %s", row, strings.ToLower(requiredName)))
+			rejectedCount++
+			continue
+		}
 
 		// Check for duplicate in this file
-		if seenEmails[t.Email] {
+		key := t.Email
+		if requiredCol == "phone" {
+			key = t.Phone
+		}
+		if seenRecipients[key] {
 			duplicateCount++
 			if processedRecords%100 == 0 {
 				job.UpdateProgress(processedRecords, totalRecords)
 			}
 			continue
 		}
-		seenEmails[t.Email] = true
+		seenRecipients[key] = true
 
 		batch = append(batch, t)
 
@@ -416,6 +457,9 @@ func processBulkImport(job *models.Job, filename string, groupID int64, isNewGro
 
 	duration := time.Since(startTime)
 	resultMsg := fmt.Sprintf("Imported %d targets in %s", importedCount, duration)
+	if rejectedCount > 0 {
+		resultMsg = fmt.Sprintf("%s (%d rows rejected)", resultMsg, rejectedCount)
+	}
 	job.Complete(resultMsg)
 	log.Infof("Bulk import job %s completed: %s", job.ID, resultMsg)
 }
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	ctx "github.com/7nikhilkamboj/TrustStrike-Simulation/context"
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
+	"github.com/gorilla/mux"
+)
+
+// maxBundleUploadSize is the largest bundle accepted for import
+const maxBundleUploadSize = 100 << 20
+
+// unsafeFilenameRegex matches characters which shouldn't be used in the
+// filename of a downloaded bundle
+var unsafeFilenameRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// sendBundle exports the items as a zip bundle download
+func sendBundle(w http.ResponseWriter, name string, e models.BundleExport) {
+	bundle, err := models.ExportBundle(e)
+	if err != nil {
+		log.Error(err)
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	filename := unsafeFilenameRegex.ReplaceAllString(name, "_") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(bundle)
+}
+
+// requestUid returns the user id to scope lookups to, allowing admins to
+// access every user's items
+func requestUid(r *http.Request) int64 {
+	u := ctx.Get(r, "user").(models.User)
+	if u.Role.Slug == models.RoleAdmin {
+		return 0
+	}
+	return u.Id
+}
+
+// TemplateExport exports the template as a bundle. If the page_id query
+// parameter is given, the landing page used with the template is included.
+func (as *Server) TemplateExport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := requestUid(r)
+	t, err := models.GetTemplate(id, uid)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Template not found"}, http.StatusNotFound)
+		return
+	}
+	e := models.BundleExport{Templates: []models.Template{t}}
+	if pid := r.URL.Query().Get("page_id"); pid != "" {
+		pageId, _ := strconv.ParseInt(pid, 0, 64)
+		p, err := models.GetPage(pageId, uid)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Page not found"}, http.StatusNotFound)
+			return
+		}
+		e.Pages = []models.Page{p}
+		e.TemplatePages = map[int64]string{t.Id: p.Name}
+	}
+	sendBundle(w, t.Name, e)
+}
+
+// PageExport exports the landing page, including its redirect settings, as
+// a bundle
+func (as *Server) PageExport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	p, err := models.GetPage(id, requestUid(r))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Page not found"}, http.StatusNotFound)
+		return
+	}
+	sendBundle(w, p.Name, models.BundleExport{Pages: []models.Page{p}})
+}
+
+// SMSExport exports the SMS profile as a bundle. The auth token is only
+// included if the include_credentials query parameter is set to true.
+func (as *Server) SMSExport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	s, err := models.GetSMS(id, requestUid(r))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "SMS profile not found"}, http.StatusNotFound)
+		return
+	}
+	includeCredentials, _ := strconv.ParseBool(r.URL.Query().Get("include_credentials"))
+	sendBundle(w, s.Name, models.BundleExport{
+		SMS:                []models.SMS{s},
+		IncludeCredentials: includeCredentials,
+	})
+}
+
+// ImportBundle imports the templates, pages and SMS profiles in a bundle.
+// The bundle may be uploaded as the "file" field of a multipart form or as
+// the request body. Name collisions are resolved according to the conflict
+// query parameter, which may be rename (the default), skip or overwrite.
+func (as *Server) ImportBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		JSONResponse(w, models.Response{Success: false, Message: "Method not allowed"}, http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBundleUploadSize)
+	var body io.Reader = r.Body
+	if err := r.ParseMultipartForm(32 << 20); err == nil {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Error retrieving file"}, http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Error reading bundle"}, http.StatusBadRequest)
+		return
+	}
+	result, err := models.ImportBundle(data, ctx.Get(r, "user_id").(int64), r.URL.Query().Get("conflict"))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	JSONResponse(w, result, http.StatusOK)
+}
@@ -3,7 +3,9 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	ctx "github.com/7nikhilkamboj/TrustStrike-Simulation/context"
 	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
@@ -49,6 +51,34 @@ func (as *Server) Campaigns(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// CampaignPreflight renders the emails for a new campaign without creating
+// or sending it, returning any problems found. The number of recipients
+// rendered can be limited with the "sample" query parameter, and the rendered
+// MIME message for the recipient given in the "preview" query parameter is
+// included in the response.
+func (as *Server) CampaignPreflight(w http.ResponseWriter, r *http.Request) {
+	c := models.Campaign{}
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+		return
+	}
+	sample := 0
+	if s := r.URL.Query().Get("sample"); s != "" {
+		sample, err = strconv.Atoi(s)
+		if err != nil || sample < 0 {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid sample size"}, http.StatusBadRequest)
+			return
+		}
+	}
+	report, err := models.PreflightCampaign(&c, ctx.Get(r, "user_id").(int64), sample, r.URL.Query().Get("preview"))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	JSONResponse(w, report, http.StatusOK)
+}
+
 // CampaignsSummary returns the summary for the current user's campaigns
 func (as *Server) CampaignsSummary(w http.ResponseWriter, r *http.Request) {
 	switch {
@@ -131,6 +161,48 @@ func (as *Server) CampaignSummary(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// CampaignQRExport exports the QR code of every recipient in the campaign
+// as a printable PDF or a zip of images, for physical simulations such as
+// posters or desk drops.
+func (as *Server) CampaignQRExport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	rid := vars["id"]
+	c, err := models.GetCampaignByRid(rid, 0)
+	if err != nil {
+		log.Error(err)
+		JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+		return
+	}
+	e := models.QRExport{
+		Format:      r.URL.Query().Get("format"),
+		ImageFormat: r.URL.Query().Get("image"),
+	}
+	if e.Format == "" {
+		e.Format = models.QRExportPDF
+	}
+	if size := r.URL.Query().Get("size"); size != "" {
+		e.Size, err = strconv.Atoi(size)
+		if err != nil || e.Size <= 0 {
+			JSONResponse(w, models.Response{Success: false, Message: models.ErrInvalidQRExportSize.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	export, err := models.ExportCampaignQRCodes(&c, e)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	contentType := "application/zip"
+	if e.Format == models.QRExportPDF {
+		contentType = "application/pdf"
+	}
+	filename := unsafeFilenameRegex.ReplaceAllString(c.Name, "_") + "_qr_codes." + e.Format
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(export)
+}
+
 // CampaignComplete effectively "ends" a campaign.
 // Future phishing emails clicked will return a simple "404" page.
 func (as *Server) CampaignComplete(w http.ResponseWriter, r *http.Request) {
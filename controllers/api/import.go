@@ -39,15 +39,27 @@ type emailResponse struct {
 	Subject string `json:"subject"`
 }
 
-// ImportGroup imports a CSV of group members
+// groupImportResponse is the result of importing a CSV of group members.
+// Errors lists the rows which were skipped because they were invalid.
+type groupImportResponse struct {
+	Targets []models.Target `json:"targets"`
+	Errors  []string        `json:"errors"`
+}
+
+// ImportGroup imports a CSV of group members. Invalid rows are skipped and
+// reported alongside the imported targets.
 func (as *Server) ImportGroup(w http.ResponseWriter, r *http.Request) {
 	ts, err := util.ParseCSV(r)
-	fmt.Println(ts)
-	if err != nil {
+	resp := groupImportResponse{Targets: ts, Errors: []string{}}
+	if rowErrors, ok := err.(util.CSVErrors); ok {
+		for _, rowError := range rowErrors {
+			resp.Errors = append(resp.Errors, rowError.Error())
+		}
+	} else if err != nil {
 		JSONResponse(w, models.Response{Success: false, Message: "Error parsing CSV"}, http.StatusInternalServerError)
 		return
 	}
-	JSONResponse(w, ts, http.StatusOK)
+	JSONResponse(w, resp, http.StatusOK)
 }
 
 // ImportEmail allows for the importing of email.
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -82,3 +83,33 @@ func TestCustomDeniedImport(t *testing.T) {
 		t.Fatalf("incorrect response error provided: %s", got.Message)
 	}
 }
+
+func TestImportGroupSkipsInvalidRows(t *testing.T) {
+	ctx := setupTest(t)
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("files[]", "example.csv")
+	if err != nil {
+		t.Fatalf("error creating form file: %v", err)
+	}
+	part.Write([]byte("First Name,Last Name,Email\nJohn,Doe,johndoe@example.com\nBad,Row,not an email\n"))
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/import/group", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	response := httptest.NewRecorder()
+	ctx.apiServer.ImportGroup(response, req)
+	if response.Code != http.StatusOK {
+		t.Fatalf("incorrect status code received. expected %d got %d", http.StatusOK, response.Code)
+	}
+	got := groupImportResponse{}
+	err = json.NewDecoder(response.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("error decoding body: %v", err)
+	}
+	if len(got.Targets) != 1 || got.Targets[0].Email != "johndoe@example.com" {
+		t.Fatalf("incorrect targets received: %v", got.Targets)
+	}
+	if len(got.Errors) != 1 || !strings.HasPrefix(got.Errors[0], "row 3:") {
+		t.Fatalf("incorrect errors received: %v", got.Errors)
+	}
+}
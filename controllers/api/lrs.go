@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
+	"github.com/gorilla/mux"
+)
+
+// LRSs returns a list of the Learning Record Stores which xAPI statements
+// are sent to, both active and disabled
+func (as *Server) LRSs(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		ls, err := models.GetLRSs()
+		if err != nil {
+			log.Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, ls, http.StatusOK)
+
+	case r.Method == "POST":
+		l := models.LRS{}
+		err := json.NewDecoder(r.Body).Decode(&l)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		err = models.PostLRS(&l)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, l, http.StatusCreated)
+	}
+}
+
+// LRS returns details of a single Learning Record Store specified by "id"
+// parameter
+func (as *Server) LRS(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	l, err := models.GetLRS(id)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "LRS not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		JSONResponse(w, l, http.StatusOK)
+
+	case r.Method == "DELETE":
+		err = models.DeleteLRS(id)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		log.Infof("Deleted LRS with id: %d", id)
+		JSONResponse(w, models.Response{Success: true, Message: "LRS deleted Successfully!"}, http.StatusOK)
+
+	case r.Method == "PUT":
+		l = models.LRS{}
+		err = json.NewDecoder(r.Body).Decode(&l)
+		if err != nil {
+			log.Errorf("error decoding LRS: %v", err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		l.Id = id
+		err = models.PutLRS(&l)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, l, http.StatusOK)
+	}
+}
+
+// LRSStatements returns the xAPI statements waiting to be sent to a Learning
+// Record Store, along with the error from their last attempt
+func (as *Server) LRSStatements(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	_, err := models.GetLRS(id)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "LRS not found"}, http.StatusNotFound)
+		return
+	}
+	ss, err := models.GetXAPIStatements(id)
+	if err != nil {
+		log.Error(err)
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	JSONResponse(w, ss, http.StatusOK)
+}
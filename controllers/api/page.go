@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -22,7 +23,7 @@ func (as *Server) Pages(w http.ResponseWriter, r *http.Request) {
 		if u.Role.Slug == models.RoleAdmin {
 			uid = 0
 		}
-		ps, err := models.GetPages(uid)
+		ps, err := models.GetPages(uid, libraryFilter(r))
 		if err != nil {
 			log.Error(err)
 		}
@@ -99,3 +100,89 @@ func (as *Server) Page(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, p, http.StatusOK)
 	}
 }
+
+// PageAssets handles the assets uploaded for a landing page. A zip bundle
+// may be POSTed as the "file" field of a multipart form or as the request
+// body, replacing the page's assets. If the bundle contains an index.html,
+// it's used as the page's HTML.
+func (as *Server) PageAssets(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	p, err := models.GetPage(id, requestUid(r))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Page not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		pas, err := models.GetPageAssets(p.Id)
+		if err != nil {
+			log.Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, pas, http.StatusOK)
+	case r.Method == "POST":
+		r.Body = http.MaxBytesReader(w, r.Body, maxBundleUploadSize)
+		var body io.Reader = r.Body
+		if err := r.ParseMultipartForm(32 << 20); err == nil {
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Error retrieving file"}, http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Error reading page bundle"}, http.StatusBadRequest)
+			return
+		}
+		result, err := models.ImportPageAssets(&p, data)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, result, http.StatusOK)
+	case r.Method == "DELETE":
+		err = models.DeletePageAssets(p.Id)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Error deleting page assets"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, models.Response{Success: true, Message: "Page Assets Deleted Successfully"}, http.StatusOK)
+	}
+}
+
+// PagePreview renders a landing page for a chosen or sample recipient,
+// without sending an email. Saved pages are previewed using
+// /api/pages/:id/preview, while unsaved HTML can be previewed using
+// /api/pages/preview. The step and group_id fields of the request, which
+// the page editor doesn't set, preview a later step of a saved page or a
+// recipient from a group.
+func (as *Server) PagePreview(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	p := models.Page{}
+	if vars["id"] != "" {
+		id, _ := strconv.ParseInt(vars["id"], 0, 64)
+		var err error
+		p, err = models.GetPage(id, requestUid(r))
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Page not found"}, http.StatusNotFound)
+			return
+		}
+	}
+	pp := models.PagePreview{}
+	err := json.NewDecoder(r.Body).Decode(&pp)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Invalid request"}, http.StatusBadRequest)
+		return
+	}
+	result, err := pp.Render(&p, requestUid(r))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	JSONResponse(w, result, http.StatusOK)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
+	"github.com/gorilla/mux"
+)
+
+// PhishingHosts returns a list of the hostnames served by the phishing
+// server, or creates a new one
+func (as *Server) PhishingHosts(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		hs, err := models.GetPhishingHosts()
+		if err != nil {
+			log.Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, hs, http.StatusOK)
+
+	case r.Method == "POST":
+		h := models.PhishingHost{}
+		err := json.NewDecoder(r.Body).Decode(&h)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		err = models.PostPhishingHost(&h)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, h, http.StatusCreated)
+	}
+}
+
+// PhishingHost returns details of a single phishing host specified by "id"
+// parameter
+func (as *Server) PhishingHost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	h, err := models.GetPhishingHost(id)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Phishing host not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		JSONResponse(w, h, http.StatusOK)
+
+	case r.Method == "DELETE":
+		err = models.DeletePhishingHost(id)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		log.Infof("Deleted phishing host with id: %d", id)
+		JSONResponse(w, models.Response{Success: true, Message: "Phishing host deleted Successfully!"}, http.StatusOK)
+
+	case r.Method == "PUT":
+		h = models.PhishingHost{}
+		err = json.NewDecoder(r.Body).Decode(&h)
+		if err != nil {
+			log.Errorf("error decoding phishing host: %v", err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		h.Id = id
+		err = models.PutPhishingHost(&h)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, h, http.StatusOK)
+	}
+}
@@ -20,6 +20,8 @@ type resultData struct {
 	IP        string     `json:"address"`
 	UserAgent string     `json:"user-agent"`
 	Payload   url.Values `json:"payload"`
+	// Method is the HTTP method of the request, used to detect scanners
+	Method string `json:"method"`
 }
 
 func (as *Server) ResultOpen(w http.ResponseWriter, r *http.Request) {
@@ -54,6 +56,9 @@ func (as *Server) handleResult(action int, w http.ResponseWriter, r *http.Reques
 		}
 		d.Browser["address"] = c.IP
 		d.Browser["user-agent"] = c.UserAgent
+		if c.Method != "" {
+			d.Browser["method"] = c.Method
+		}
 		d.Payload = c.Payload
 
 		rs, err := models.GetResult(id)
@@ -73,10 +73,12 @@ func (as *Server) registerRoutes() {
 	router.HandleFunc("/imap/validate", mid.Use(as.IMAPServerValidate, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/campaigns/", as.Campaigns)
 	router.HandleFunc("/campaigns/summary", as.CampaignsSummary)
+	router.HandleFunc("/campaigns/preflight", as.CampaignPreflight).Methods("POST")
 	router.HandleFunc("/campaigns/{id:[a-zA-Z0-9]+}", as.Campaign).Methods("GET")
 	router.HandleFunc("/campaigns/{id:[a-zA-Z0-9]+}", mid.Use(as.Campaign, mid.RequirePermission(models.PermissionModifySystem))).Methods("DELETE")
 	router.HandleFunc("/campaigns/{id:[a-zA-Z0-9]+}/results", as.CampaignResults)
 	router.HandleFunc("/campaigns/{id:[a-zA-Z0-9]+}/summary", as.CampaignSummary)
+	router.HandleFunc("/campaigns/{id:[a-zA-Z0-9]+}/qr", as.CampaignQRExport).Methods("GET")
 	router.HandleFunc("/campaigns/{id:[a-zA-Z0-9]+}/complete", mid.Use(as.CampaignComplete, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/groups/", mid.Use(as.Groups, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/groups/summary", mid.Use(as.GroupsSummary, mid.RequirePermission(models.PermissionModifySystem)))
@@ -84,12 +86,27 @@ func (as *Server) registerRoutes() {
 	router.HandleFunc("/groups/{id:[0-9]+}/summary", mid.Use(as.GroupSummary, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/templates/", mid.Use(as.Templates, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/templates/{id:[0-9]+}", mid.Use(as.Template, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/templates/{id:[0-9]+}/export", mid.Use(as.TemplateExport, mid.RequirePermission(models.PermissionModifySystem))).Methods("GET")
+	router.HandleFunc("/templates/{id:[0-9]+}/versions", mid.Use(as.TemplateVersions, mid.RequirePermission(models.PermissionModifySystem))).Methods("GET")
+	router.HandleFunc("/templates/{id:[0-9]+}/versions/{version:[0-9]+}", mid.Use(as.TemplateVersion, mid.RequirePermission(models.PermissionModifySystem))).Methods("GET")
+	router.HandleFunc("/templates/{id:[0-9]+}/versions/{version:[0-9]+}/rollback", mid.Use(as.TemplateRollback, mid.RequirePermission(models.PermissionModifySystem))).Methods("POST")
 	router.HandleFunc("/pages/", mid.Use(as.Pages, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/pages/{id:[0-9]+}", mid.Use(as.Page, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/pages/{id:[0-9]+}/assets", mid.Use(as.PageAssets, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/pages/preview", mid.Use(as.PagePreview, mid.RequirePermission(models.PermissionModifySystem))).Methods("POST")
+	router.HandleFunc("/pages/{id:[0-9]+}/preview", mid.Use(as.PagePreview, mid.RequirePermission(models.PermissionModifySystem))).Methods("POST")
+	router.HandleFunc("/pages/{id:[0-9]+}/export", mid.Use(as.PageExport, mid.RequirePermission(models.PermissionModifySystem))).Methods("GET")
+	router.HandleFunc("/training_pages/", mid.Use(as.TrainingPages, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/training_pages/{id:[0-9]+}", mid.Use(as.TrainingPage, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/training_modules/", mid.Use(as.TrainingModules, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/training_modules/compliance", as.TrainingCompliance).Methods("GET")
+	router.HandleFunc("/training_modules/{id:[0-9]+}", mid.Use(as.TrainingModule, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/smtp/", mid.Use(as.SendingProfiles, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/smtp/{id:[0-9]+}", mid.Use(as.SendingProfile, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/smtp/{id:[0-9]+}/dkim", mid.Use(as.SendingProfileDKIM, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/sms/", mid.Use(as.SMSProfiles, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/sms/{id:[0-9]+}", mid.Use(as.SMSProfile, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/sms/{id:[0-9]+}/export", mid.Use(as.SMSExport, mid.RequirePermission(models.PermissionModifySystem))).Methods("GET")
 	router.HandleFunc("/sms_campaigns/", as.SMSCampaigns)
 	router.HandleFunc("/users/", mid.Use(as.Users, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/users/{id:[0-9]+}", mid.Use(as.User))
@@ -104,10 +121,16 @@ func (as *Server) registerRoutes() {
 	router.HandleFunc("/import/job/{id}", mid.Use(as.GetJobStatus, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/import/job/{id}/cancel", mid.Use(as.CancelBulkImport, mid.RequirePermission(models.PermissionModifySystem))).Methods("POST")
 	router.HandleFunc("/import/email", mid.Use(as.ImportEmail, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/import/bundle", mid.Use(as.ImportBundle, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/import/site", mid.Use(as.ImportSite, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/webhooks/", mid.Use(as.Webhooks, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/webhooks/{id:[0-9]+}/validate", mid.Use(as.ValidateWebhook, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/webhooks/{id:[0-9]+}", mid.Use(as.Webhook, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/phishing_hosts/", mid.Use(as.PhishingHosts, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/phishing_hosts/{id:[0-9]+}", mid.Use(as.PhishingHost, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/lrs/", mid.Use(as.LRSs, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/lrs/{id:[0-9]+}/statements", mid.Use(as.LRSStatements, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/lrs/{id:[0-9]+}", mid.Use(as.LRS, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/results/{id:[a-zA-Z0-9]+}/open", as.ResultOpen)
 	router.HandleFunc("/results/{id:[a-zA-Z0-9]+}/click", as.ResultClick)
 	router.HandleFunc("/results/{id:[a-zA-Z0-9]+}/submit", as.ResultSubmit)
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -17,6 +18,7 @@ import (
 	"time"
 
 	"github.com/7nikhilkamboj/TrustStrike-Simulation/auth"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/cloudflare"
 	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
 	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
 	"github.com/gorilla/mux"
@@ -24,7 +26,7 @@ import (
 )
 
 const (
-	CLOUDFLARE_URL = "https://api.cloudflare.com"
+	CLOUDFLARE_URL = cloudflare.DefaultURL
 )
 
 // Cache type constants
@@ -154,40 +156,11 @@ type CloudflareConfig struct {
 	Status string `json:"status"`
 }
 
-type CloudflareZone struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Status string `json:"status"`
-	// Paused      bool     `json:"paused"`
-	// Type        string   `json:"type"`
-	NameServers []string `json:"name_servers"`
-}
-
-type CloudflareZonesResponse struct {
-	Result  []CloudflareZone `json:"result"`
-	Success bool             `json:"success"`
-}
+// CloudflareZone is a domain hosted by Cloudflare
+type CloudflareZone = cloudflare.Zone
 
-type CloudflareAccountResponse struct {
-	Result []struct {
-		ID string `json:"id"`
-	} `json:"result"`
-	Success bool `json:"success"`
-}
-
-type CloudflareDNSRecord struct {
-	ID      string `json:"id"`
-	Type    string `json:"type"`
-	Name    string `json:"name"`
-	Content string `json:"content"`
-	TTL     int    `json:"ttl"`
-	Proxied bool   `json:"proxied"`
-}
-
-type CloudflareDNSRecordsResponse struct {
-	Result  []CloudflareDNSRecord `json:"result"`
-	Success bool                  `json:"success"`
-}
+// CloudflareDNSRecord is a DNS record in a Cloudflare zone
+type CloudflareDNSRecord = cloudflare.DNSRecord
 
 // GetStrikes proxies the request to get all strikes
 func (as *Server) GetStrikes(w http.ResponseWriter, r *http.Request) {
@@ -448,15 +421,6 @@ func (as *Server) fetchStrikes() ([]Strike, error) {
 	return strikes, nil
 }
 
-type CloudflareSingleZoneResponse struct {
-	Result []struct {
-		ID          string   `json:"id"`
-		NameServers []string `json:"name_servers"`
-		Status      string   `json:"status"`
-	} `json:"result"`
-	Success bool `json:"success"`
-}
-
 func (as *Server) GetCloudflareConfig(w http.ResponseWriter, r *http.Request) {
 	domain := r.URL.Query().Get("domain")
 	config, err := as.fetchCloudflareConfig(domain)
@@ -476,46 +440,27 @@ func (as *Server) GetCloudflareConfig(w http.ResponseWriter, r *http.Request) {
 }
 
 func (as *Server) GetDNSRecords(domain string, token string) (*CloudflareConfig, error) {
-	url := fmt.Sprintf("%s/client/v4/zones?name=%s", CLOUDFLARE_URL, domain)
-	client := &http.Client{}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
+	client, err := cloudflare.NewClient(token)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var cfResp CloudflareSingleZoneResponse
-	if err := json.NewDecoder(resp.Body).Decode(&cfResp); err != nil {
-		return nil, err
-	}
-
-	if !cfResp.Success || len(cfResp.Result) == 0 {
+	zones, err := client.ZonesByName(context.Background(), domain)
+	if err != nil || len(zones) == 0 {
 		cfConfig := &CloudflareConfig{
 			Status: "error",
-			// CloudflareToken: token,
 		}
 		return cfConfig, nil
 	}
 
 	cfConfig := &CloudflareConfig{
-		Status: cfResp.Result[0].Status,
+		Status: zones[0].Status,
 	}
-	if len(cfResp.Result[0].NameServers) > 0 {
-		cfConfig.NS1 = cfResp.Result[0].NameServers[0]
-		// cfConfig.CloudflareToken = token
-		if len(cfResp.Result[0].NameServers) > 1 {
-			cfConfig.NS2 = cfResp.Result[0].NameServers[1]
+	if len(zones[0].NameServers) > 0 {
+		cfConfig.NS1 = zones[0].NameServers[0]
+		if len(zones[0].NameServers) > 1 {
+			cfConfig.NS2 = zones[0].NameServers[1]
 		}
-		cfConfig.DNSRecords = cfResp.Result[0].NameServers
+		cfConfig.DNSRecords = zones[0].NameServers
 	}
 
 	return cfConfig, nil
@@ -524,33 +469,11 @@ func (as *Server) GetDNSRecords(domain string, token string) (*CloudflareConfig,
 // LIST ALL DOMAINS LISTS
 
 func (as *Server) GetDomainsList(token string) ([]CloudflareZone, error) {
-	url := CLOUDFLARE_URL + "/client/v4/zones"
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	req, err := http.NewRequest("GET", url, nil)
+	client, err := cloudflare.NewClient(token)
 	if err != nil {
 		return nil, err
 	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var zonesResp CloudflareZonesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&zonesResp); err != nil {
-		return nil, err
-	}
-
-	if !zonesResp.Success {
-		return nil, fmt.Errorf("Cloudflare API error")
-	}
-
-	return zonesResp.Result, nil
+	return client.Zones(context.Background())
 }
 
 func (as *Server) FetchDNSRecords(w http.ResponseWriter, r *http.Request) {
@@ -580,40 +503,11 @@ func (as *Server) FetchDNSRecords(w http.ResponseWriter, r *http.Request) {
 }
 
 func (as *Server) GetCloudflareDNSRecords(zoneID string, token string) ([]CloudflareDNSRecord, error) {
-	url := fmt.Sprintf("%s/client/v4/zones/%s/dns_records", CLOUDFLARE_URL, zoneID)
-	client := &http.Client{}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
+	client, err := cloudflare.NewClient(token)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var recordsResp CloudflareDNSRecordsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&recordsResp); err != nil {
-		return nil, err
-	}
-
-	if !recordsResp.Success {
-		return nil, fmt.Errorf("Cloudflare API error")
-	}
-
-	var filtered []CloudflareDNSRecord
-	for _, r := range recordsResp.Result {
-		if r.Type == "A" {
-			filtered = append(filtered, r)
-		}
-	}
-
-	return filtered, nil
+	return client.DNSRecords(context.Background(), zoneID, "A", "")
 }
 
 func (as *Server) CreateDNSRecord(w http.ResponseWriter, r *http.Request) {
@@ -645,53 +539,6 @@ func (as *Server) CreateDNSRecord(w http.ResponseWriter, r *http.Request) {
 	JSONResponse(w, models.Response{Success: true, Message: "DNS record synced successfully"}, http.StatusOK)
 }
 
-func (as *Server) CreateCloudflareDNSRecord(zoneID, name, content, token string) error {
-	url := fmt.Sprintf("%s/client/v4/zones/%s/dns_records", CLOUDFLARE_URL, zoneID)
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	data := map[string]interface{}{
-		"type":    "A",
-		"name":    name,
-		"content": content,
-		"ttl":     1, // 1 for automatic
-		"proxied": true,
-	}
-	payload, _ := json.Marshal(data)
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Success bool `json:"success"`
-		Errors  []struct {
-			Message string `json:"message"`
-		} `json:"errors"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
-	}
-
-	if !result.Success {
-		if len(result.Errors) > 0 {
-			return fmt.Errorf(result.Errors[0].Message)
-		}
-		return fmt.Errorf("Cloudflare API error")
-	}
-
-	return nil
-}
-
 func (as *Server) DeleteDNSRecord(w http.ResponseWriter, r *http.Request) {
 	zoneID := r.URL.Query().Get("zone_id")
 	recordID := r.URL.Query().Get("record_id")
@@ -717,41 +564,11 @@ func (as *Server) DeleteDNSRecord(w http.ResponseWriter, r *http.Request) {
 }
 
 func (as *Server) DeleteCloudflareDNSRecord(zoneID, recordID, token string) error {
-	url := fmt.Sprintf("%s/client/v4/zones/%s/dns_records/%s", CLOUDFLARE_URL, zoneID, recordID)
-	client := &http.Client{}
-
-	req, err := http.NewRequest("DELETE", url, nil)
+	client, err := cloudflare.NewClient(token)
 	if err != nil {
 		return err
 	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Success bool `json:"success"`
-		Errors  []struct {
-			Message string `json:"message"`
-		} `json:"errors"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
-	}
-
-	if !result.Success {
-		if len(result.Errors) > 0 {
-			return fmt.Errorf(result.Errors[0].Message)
-		}
-		return fmt.Errorf("Cloudflare API error")
-	}
-
-	return nil
+	return client.DeleteDNSRecord(context.Background(), zoneID, recordID)
 }
 
 func (as *Server) SetupCloudflare(w http.ResponseWriter, r *http.Request) {
@@ -794,65 +611,20 @@ func (as *Server) SetupCloudflare(w http.ResponseWriter, r *http.Request) {
 }
 
 func (as *Server) GetCloudflareAccountID(token string) (string, error) {
-	url := CLOUDFLARE_URL + "/client/v4/accounts"
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	client, err := cloudflare.NewClient(token)
 	if err != nil {
 		return "", err
 	}
-
-	var accResp CloudflareAccountResponse
-	if err := json.Unmarshal(body, &accResp); err != nil {
-		return "", err
-	}
-
-	if !accResp.Success || len(accResp.Result) == 0 {
-		return "", fmt.Errorf("no accounts found")
-	}
-
-	return accResp.Result[0].ID, nil
+	return client.AccountID(context.Background())
 }
 
 func (as *Server) CreateCloudflareZone(domain, accountID, token string) error {
-	url := CLOUDFLARE_URL + "/client/v4/zones"
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	payload := map[string]interface{}{
-		"name":       domain,
-		"account":    map[string]string{"id": accountID},
-		"jump_start": true,
-	}
-	jsonBody, _ := json.Marshal(payload)
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+	client, err := cloudflare.NewClient(token)
 	if err != nil {
 		return err
 	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	return nil
+	_, err = client.CreateZone(context.Background(), domain, accountID)
+	return err
 }
 
 func (as *Server) fetchConfig() (*Config, error) {
@@ -930,142 +702,11 @@ func (as *Server) SetPhishletHostname(w http.ResponseWriter, r *http.Request) {
 
 // SyncCloudflareDNS ensures the A record for the domain points to the given IP
 func (as *Server) SyncCloudflareDNS(domain, ip, token string) error {
-	// 1. Find the Zone ID
-	zones, err := as.GetDomainsList(token)
-	if err != nil {
-		return fmt.Errorf("failed to list zones: %v", err)
-	}
-
-	var bestZone CloudflareZone
-	var maxLen int
-
-	for _, z := range zones {
-		if strings.HasSuffix(domain, z.Name) {
-			if len(z.Name) > maxLen {
-				maxLen = len(z.Name)
-				bestZone = z
-			}
-		}
-	}
-
-	if bestZone.ID == "" {
-		return fmt.Errorf("no matching zone found for domain %s", domain)
-	}
-
-	fmt.Printf("DEBUG: Found Zone ID %s for domain %s\n", bestZone.ID, domain)
-
-	// 2. Find existing A record for the exact domain using server-side filter
-	records, err := as.getCloudflareARecordsByName(bestZone.ID, domain, token)
-	if err != nil {
-		return fmt.Errorf("failed to get DNS records: %v", err)
-	}
-
-	var existingRecord *CloudflareDNSRecord
-	if len(records) > 0 {
-		existingRecord = &records[0]
-		// Determine if there are duplicates
-		if len(records) > 1 {
-			log.Info(fmt.Sprintf("Found %d records for %s, updating the first one.", len(records), domain))
-		}
-	}
-
-	// 3. Update or Create
-	if existingRecord != nil {
-		if existingRecord.Content != ip {
-			// Update (Req 2)
-			log.Info(fmt.Sprintf("Updating DNS record for %s: %s -> %s", domain, existingRecord.Content, ip))
-			return as.UpdateCloudflareDNSRecord(bestZone.ID, existingRecord.ID, domain, ip, token)
-		} else {
-			// Skip (Req 3)
-			log.Info(fmt.Sprintf("DNS record for %s already points to %s", domain, ip))
-			return nil
-		}
-	} else {
-		// Create (Req 1)
-		log.Info(fmt.Sprintf("Creating new DNS record for %s -> %s", domain, ip))
-		return as.CreateCloudflareDNSRecord(bestZone.ID, domain, ip, token)
-	}
-}
-
-func (as *Server) getCloudflareARecordsByName(zoneID, name, token string) ([]CloudflareDNSRecord, error) {
-	url := fmt.Sprintf("%s/client/v4/zones/%s/dns_records?type=A&name=%s", CLOUDFLARE_URL, zoneID, name)
-	fmt.Println("DEBUG: Querying Cloudflare:", url)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var recordsResp CloudflareDNSRecordsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&recordsResp); err != nil {
-		return nil, err
-	}
-
-	if !recordsResp.Success {
-		return nil, fmt.Errorf("Cloudflare API error")
-	}
-
-	fmt.Printf("DEBUG: Found %d records for %s in zone %s\n", len(recordsResp.Result), name, zoneID)
-	return recordsResp.Result, nil
-}
-
-// UpdateCloudflareDNSRecord updates an existing DNS record
-func (as *Server) UpdateCloudflareDNSRecord(zoneID, recordID, name, content, token string) error {
-	url := fmt.Sprintf("%s/client/v4/zones/%s/dns_records/%s", CLOUDFLARE_URL, zoneID, recordID)
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	data := map[string]interface{}{
-		"type":    "A",
-		"name":    name,
-		"content": content,
-		"ttl":     1, // 1 for automatic
-		"proxied": true,
-	}
-	payload, _ := json.Marshal(data)
-
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(payload))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
+	client, err := cloudflare.NewClient(token)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Success bool `json:"success"`
-		Errors  []struct {
-			Message string `json:"message"`
-		} `json:"errors"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
-	}
-
-	if !result.Success {
-		if len(result.Errors) > 0 {
-			return fmt.Errorf(result.Errors[0].Message)
-		}
-		return fmt.Errorf("Cloudflare API error")
-	}
-
-	return nil
+	return client.SyncARecord(context.Background(), domain, ip)
 }
 
 // TogglePhishlet proxies the request to toggle a phishlet
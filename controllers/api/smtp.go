@@ -104,3 +104,27 @@ func (as *Server) SendingProfile(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, s, http.StatusOK)
 	}
 }
+
+// SendingProfileDKIM handles requests for the /api/smtp/:id/dkim endpoint,
+// checking that the DKIM record published in DNS matches the sending
+// profile's private key
+func (as *Server) SendingProfileDKIM(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	u := ctx.Get(r, "user").(models.User)
+	uid := u.Id
+	if u.Role.Slug == models.RoleAdmin {
+		uid = 0
+	}
+	s, err := models.GetSMTP(id, uid)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "SMTP not found"}, http.StatusNotFound)
+		return
+	}
+	v, err := s.ValidateDKIMRecord()
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	JSONResponse(w, v, http.StatusOK)
+}
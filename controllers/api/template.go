@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	ctx "github.com/7nikhilkamboj/TrustStrike-Simulation/context"
@@ -13,6 +14,23 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
+// libraryFilter parses the search and filter query parameters used when
+// listing templates and pages. Tags may be given as repeated tag parameters
+// or as a comma separated list.
+func libraryFilter(r *http.Request) models.LibraryFilter {
+	q := r.URL.Query()
+	f := models.LibraryFilter{
+		Search:     q.Get("search"),
+		Difficulty: q.Get("difficulty"),
+		Language:   q.Get("language"),
+		Category:   q.Get("category"),
+	}
+	for _, tags := range q["tag"] {
+		f.Tags = append(f.Tags, strings.Split(tags, ",")...)
+	}
+	return f
+}
+
 // Templates handles the functionality for the /api/templates endpoint
 func (as *Server) Templates(w http.ResponseWriter, r *http.Request) {
 	switch {
@@ -22,7 +40,7 @@ func (as *Server) Templates(w http.ResponseWriter, r *http.Request) {
 		if u.Role.Slug == models.RoleAdmin {
 			uid = 0
 		}
-		ts, err := models.GetTemplates(uid)
+		ts, err := models.GetTemplates(uid, libraryFilter(r))
 		if err != nil {
 			log.Error(err)
 		}
@@ -105,3 +123,70 @@ func (as *Server) Template(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, t, http.StatusOK)
 	}
 }
+
+// TemplateVersions returns the version history of the template, including
+// the changes made in each version
+func (as *Server) TemplateVersions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	u := ctx.Get(r, "user").(models.User)
+	uid := u.Id
+	if u.Role.Slug == models.RoleAdmin {
+		uid = 0
+	}
+	tvs, err := models.GetTemplateVersions(id, uid)
+	if err == gorm.ErrRecordNotFound {
+		JSONResponse(w, models.Response{Success: false, Message: "Template not found"}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	JSONResponse(w, tvs, http.StatusOK)
+}
+
+// TemplateVersion returns a single version of the template
+func (as *Server) TemplateVersion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	version, _ := strconv.Atoi(vars["version"])
+	u := ctx.Get(r, "user").(models.User)
+	uid := u.Id
+	if u.Role.Slug == models.RoleAdmin {
+		uid = 0
+	}
+	tv, err := models.GetTemplateVersion(id, version, uid)
+	if err == gorm.ErrRecordNotFound || err == models.ErrTemplateVersionNotFound {
+		JSONResponse(w, models.Response{Success: false, Message: "Template version not found"}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	JSONResponse(w, tv, http.StatusOK)
+}
+
+// TemplateRollback restores the template to a previous version, saving it as
+// a new version
+func (as *Server) TemplateRollback(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	version, _ := strconv.Atoi(vars["version"])
+	u := ctx.Get(r, "user").(models.User)
+	uid := u.Id
+	if u.Role.Slug == models.RoleAdmin {
+		uid = 0
+	}
+	t, err := models.RollbackTemplate(id, version, uid)
+	if err == gorm.ErrRecordNotFound || err == models.ErrTemplateVersionNotFound {
+		JSONResponse(w, models.Response{Success: false, Message: "Template version not found"}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	JSONResponse(w, t, http.StatusOK)
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	ctx "github.com/7nikhilkamboj/TrustStrike-Simulation/context"
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+)
+
+// TrainingPages handles requests for the /api/training_pages/ endpoint
+func (as *Server) TrainingPages(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		tps, err := models.GetTrainingPages(requestUid(r))
+		if err != nil {
+			log.Error(err)
+		}
+		JSONResponse(w, tps, http.StatusOK)
+	//POST: Create a new training page and return it as JSON
+	case r.Method == "POST":
+		tp := models.TrainingPage{}
+		err := json.NewDecoder(r.Body).Decode(&tp)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid request"}, http.StatusBadRequest)
+			return
+		}
+		// Check to make sure the name is unique
+		_, err = models.GetTrainingPageByName(tp.Name, ctx.Get(r, "user_id").(int64))
+		if err != gorm.ErrRecordNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: "Training page name already in use"}, http.StatusConflict)
+			log.Error(err)
+			return
+		}
+		tp.ModifiedDate = time.Now().UTC()
+		tp.UserId = ctx.Get(r, "user_id").(int64)
+		err = models.PostTrainingPage(&tp)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, tp, http.StatusCreated)
+	}
+}
+
+// TrainingPage contains functions to handle the GET'ing, DELETE'ing, and
+// PUT'ing of a TrainingPage object
+func (as *Server) TrainingPage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	tp, err := models.GetTrainingPage(id, requestUid(r))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Training page not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		JSONResponse(w, tp, http.StatusOK)
+	case r.Method == "DELETE":
+		err = models.DeleteTrainingPage(id, requestUid(r))
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Error deleting training page"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, models.Response{Success: true, Message: "Training Page Deleted Successfully"}, http.StatusOK)
+	case r.Method == "PUT":
+		existing := tp
+		tp = models.TrainingPage{}
+		err = json.NewDecoder(r.Body).Decode(&tp)
+		if err != nil {
+			log.Error(err)
+		}
+		if tp.Id != id {
+			JSONResponse(w, models.Response{Success: false, Message: "/:id and /:training_page_id mismatch"}, http.StatusBadRequest)
+			return
+		}
+		tp.ModifiedDate = time.Now().UTC()
+		tp.UserId = existing.UserId
+		err = models.PutTrainingPage(&tp)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Error updating training page: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, tp, http.StatusOK)
+	}
+}
+
+// TrainingModules handles requests for the /api/training_modules/ endpoint
+func (as *Server) TrainingModules(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		tms, err := models.GetTrainingModules(requestUid(r))
+		if err != nil {
+			log.Error(err)
+		}
+		JSONResponse(w, tms, http.StatusOK)
+	//POST: Create a new training module and return it as JSON
+	case r.Method == "POST":
+		tm := models.TrainingModule{}
+		err := json.NewDecoder(r.Body).Decode(&tm)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid request"}, http.StatusBadRequest)
+			return
+		}
+		// Check to make sure the name is unique
+		_, err = models.GetTrainingModuleByName(tm.Name, ctx.Get(r, "user_id").(int64))
+		if err != gorm.ErrRecordNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: "Training module name already in use"}, http.StatusConflict)
+			log.Error(err)
+			return
+		}
+		tm.ModifiedDate = time.Now().UTC()
+		tm.UserId = ctx.Get(r, "user_id").(int64)
+		err = models.PostTrainingModule(&tm)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, tm, http.StatusCreated)
+	}
+}
+
+// TrainingModule contains functions to handle the GET'ing, DELETE'ing, and
+// PUT'ing of a TrainingModule object
+func (as *Server) TrainingModule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	tm, err := models.GetTrainingModule(id, requestUid(r))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Training module not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		JSONResponse(w, tm, http.StatusOK)
+	case r.Method == "DELETE":
+		err = models.DeleteTrainingModule(id, requestUid(r))
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Error deleting training module"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, models.Response{Success: true, Message: "Training Module Deleted Successfully"}, http.StatusOK)
+	case r.Method == "PUT":
+		existing := tm
+		tm = models.TrainingModule{}
+		err = json.NewDecoder(r.Body).Decode(&tm)
+		if err != nil {
+			log.Error(err)
+		}
+		if tm.Id != id {
+			JSONResponse(w, models.Response{Success: false, Message: "/:id and /:training_module_id mismatch"}, http.StatusBadRequest)
+			return
+		}
+		tm.ModifiedDate = time.Now().UTC()
+		tm.UserId = existing.UserId
+		err = models.PutTrainingModule(&tm)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Error updating training module: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, tm, http.StatusOK)
+	}
+}
+
+// TrainingCompliance returns the compliance report for the training modules
+// assigned to campaign recipients, listing the overdue assignments. The
+// report can be limited to a single campaign using the campaign parameter.
+func (as *Server) TrainingCompliance(w http.ResponseWriter, r *http.Request) {
+	uid := requestUid(r)
+	cid := int64(0)
+	if rid := r.URL.Query().Get("campaign"); rid != "" {
+		c, err := models.GetCampaignByRid(rid, uid)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			return
+		}
+		cid = c.Id
+	}
+	report, err := models.GetTrainingComplianceReport(uid, cid, time.Now().UTC())
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	JSONResponse(w, report, http.StatusOK)
+}
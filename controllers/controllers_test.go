@@ -25,9 +25,10 @@ func setupTest(t *testing.T) *testContext {
 	wd, _ := os.Getwd()
 	fmt.Println(wd)
 	conf := &config.Config{
-		DBName:         "sqlite3",
-		DBPath:         ":memory:",
-		MigrationsPath: "../db/db_sqlite3/migrations/",
+		DBName:              "sqlite3",
+		DBPath:              ":memory:",
+		MigrationsPath:      "../db/db_sqlite3/migrations/",
+		EventClassification: config.EventClassification{Enabled: true},
 	}
 	abs, _ := filepath.Abs("../db/db_sqlite3/migrations/")
 	fmt.Printf("in controllers_test.go: %s\n", abs)
@@ -1,15 +1,21 @@
 package controllers
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
+	"html/template"
 	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/autotls"
 	"github.com/7nikhilkamboj/TrustStrike-Simulation/config"
 	ctx "github.com/7nikhilkamboj/TrustStrike-Simulation/context"
 	"github.com/7nikhilkamboj/TrustStrike-Simulation/controllers/api"
@@ -36,6 +42,9 @@ type TransparencyResponse struct {
 	Server         string    `json:"server"`
 	ContactAddress string    `json:"contact_address"`
 	SendDate       time.Time `json:"send_date"`
+	Organization   string    `json:"organization,omitempty"`
+	Description    string    `json:"description,omitempty"`
+	PolicyURL      string    `json:"policy_url,omitempty"`
 }
 
 const TransparencySuffix = "+"
@@ -50,6 +59,10 @@ type PhishingServer struct {
 	server         *http.Server
 	config         config.PhishServer
 	contactAddress string
+	transparency   config.Transparency
+	cfToken        string
+	certManager    *autotls.Manager
+	httpServer     *http.Server
 }
 
 // NewPhishingServer returns a new instance of the phishing server with
@@ -79,8 +92,30 @@ func WithContactAddress(addr string) PhishingServerOption {
 	}
 }
 
+// WithTransparency sets the details returned by the transparency handler
+func WithTransparency(t config.Transparency) PhishingServerOption {
+	return func(ps *PhishingServer) {
+		ps.transparency = t
+	}
+}
+
+// PhishingServerOptions returns the options which apply the phishing server
+// settings from the global config, such as the transparency response and
+// the Cloudflare token used to solve DNS-01 challenges
+func PhishingServerOptions(conf *config.Config) []PhishingServerOption {
+	return []PhishingServerOption{
+		WithContactAddress(conf.ContactAddress),
+		WithTransparency(conf.Transparency),
+		WithCloudflareToken(conf.CloudflareToken),
+	}
+}
+
 // Start launches the phishing server, listening on the configured address.
 func (ps *PhishingServer) Start() {
+	if ps.config.ACME.Enabled {
+		ps.startACME()
+		return
+	}
 	if ps.config.UseTLS {
 		// Only support TLS 1.2 and above - ref #1691, #1689
 		ps.server.TLSConfig = defaultTLSConfig
@@ -89,17 +124,30 @@ func (ps *PhishingServer) Start() {
 			log.Fatal(err)
 		}
 		log.Infof("Starting phishing server at https://%s", ps.config.ListenURL)
-		log.Fatal(ps.server.ListenAndServeTLS(ps.config.CertPath, ps.config.KeyPath))
+		err = ps.server.ListenAndServeTLS(ps.config.CertPath, ps.config.KeyPath)
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+		return
 	}
 	// If TLS isn't configured, just listen on HTTP
 	log.Infof("Starting phishing server at http://%s", ps.config.ListenURL)
-	log.Fatal(ps.server.ListenAndServe())
+	err := ps.server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
 
 // Shutdown attempts to gracefully shutdown the server.
 func (ps *PhishingServer) Shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
+	if ps.certManager != nil {
+		ps.certManager.Stop()
+	}
+	if ps.httpServer != nil {
+		ps.httpServer.Shutdown(ctx)
+	}
 	return ps.server.Shutdown(ctx)
 }
 
@@ -113,6 +161,11 @@ func (ps *PhishingServer) registerRoutes() {
 	router.HandleFunc("/{path:.*}/track", ps.TrackHandler)
 	router.HandleFunc("/{path:.*}/report", ps.ReportHandler)
 	router.HandleFunc("/report", ps.ReportHandler)
+	router.HandleFunc("/"+models.PageAssetPath+"/{id:[0-9]+}/{path:.+}", ps.PageAssetHandler)
+	router.HandleFunc("/"+models.TrainingModulePath, ps.TrainingModuleHandler)
+	router.HandleFunc("/{path:.*}/"+models.TrainingModulePath, ps.TrainingModuleHandler)
+	router.HandleFunc("/"+models.TrainingPath, ps.TrainingHandler)
+	router.HandleFunc("/{path:.*}/"+models.TrainingPath, ps.TrainingHandler)
 	router.HandleFunc("/{path:.*}", ps.PhishHandler)
 
 	// Setup GZIP compression
@@ -207,7 +260,7 @@ func (ps *PhishingServer) PhishHandler(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	w.Header().Set("X-Server", config.ServerName) // Useful for checking if this is a trust_strike server (e.g. for campaign reporting plugins)
+	w.Header().Set("X-Server", ps.serverName()) // Useful for checking if this is a trust_strike server (e.g. for campaign reporting plugins)
 	var ptx models.PhishingTemplateContext
 	// Check for a preview
 	if preview, ok := ctx.Get(r, "result").(models.EmailRequest); ok {
@@ -237,35 +290,87 @@ func (ps *PhishingServer) PhishHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p, err := models.GetPage(c.PageId, c.UserId)
-	if err != nil {
-		log.Error(err)
-		http.NotFound(w, r)
-		return
+	// Campaigns with a training page show it instead of the landing page,
+	// or instead of redirecting once the form is submitted
+	training := r.Method == "GET" && c.ShowsTraining(models.TrainingTriggerClick)
+	var p models.Page
+	if !training {
+		p, err = models.GetPage(c.PageId, c.UserId)
+		if err != nil {
+			log.Error(err)
+			http.NotFound(w, r)
+			return
+		}
+	}
+	// Recipients are shown the furthest step of the page they've reached,
+	// advancing to the next step each time they submit one
+	step := rs.PageStep
+	if step > p.LastStep() {
+		step = p.LastStep()
 	}
+	advanced := false
 	switch {
-	case r.Method == "GET":
+	// Link scanners often check a link with a HEAD request, which is
+	// recorded as an automated click when events are classified
+	case r.Method == "GET" || (r.Method == "HEAD" && models.EventClassificationEnabled()):
 		err = rs.HandleClickedLink(d)
 		if err != nil {
 			log.Error(err)
 		}
 	case r.Method == "POST":
+		if p.StepCount() > 1 {
+			d.Step = p.StepName(step)
+		}
 		err = rs.HandleFormSubmit(d)
 		if err != nil {
 			log.Error(err)
 		}
+		if step < p.LastStep() {
+			step++
+			advanced = true
+			err = rs.HandlePageStep(step)
+			if err != nil {
+				log.Error(err)
+			}
+		} else {
+			training = c.ShowsTraining(models.TrainingTriggerSubmit)
+		}
+	}
+	// The last step may show the training page. Campaigns without one
+	// finish the page at the step before it, redirecting the recipient
+	// once they submit it.
+	if p.IsTrainingStep(step) {
+		if c.TrainingPageId != 0 {
+			training = true
+		} else {
+			step--
+			advanced = false
+		}
+	}
+	if training {
+		http.Redirect(w, r, trainingURL(r, rs.RId), http.StatusFound)
+		return
+	}
+	sp := p.Step(step)
+	// Only submitting the last step redirects the recipient
+	if advanced {
+		sp.RedirectURL = ""
 	}
 	ptx, err = models.NewPhishingTemplateContext(&c, rs.BaseRecipient, rs.RId)
 	if err != nil {
 		log.Error(err)
 		http.NotFound(w, r)
 	}
-	renderPhishResponse(w, r, ptx, p)
+	if !rs.SendDate.IsZero() {
+		ptx.SendDate = rs.SendDate
+	}
+	renderPhishResponse(w, r, ptx, sp)
 }
 
 // renderPhishResponse handles rendering the correct response to the phishing
 // connection. This usually involves writing out the page HTML or redirecting
-// the user to the correct URL.
+// the user to the correct URL. Pages are rendered with the same execution
+// limits as email templates, so a page which exceeds them results in a 404.
 func renderPhishResponse(w http.ResponseWriter, r *http.Request, ptx models.PhishingTemplateContext, p models.Page) {
 	// If the request was a form submit and a redirect URL was specified, we
 	// should send the user to that URL
@@ -284,30 +389,149 @@ func renderPhishResponse(w http.ResponseWriter, r *http.Request, ptx models.Phis
 	// Otherwise, we just need to write out the templated HTML
 	html, err := models.ExecuteTemplate(p.HTML, ptx)
 	if err != nil {
-		log.Error(err)
+		log.Errorf("unable to render landing page %d: %s", p.Id, err)
 		http.NotFound(w, r)
 		return
 	}
+	html, err = p.RewriteAssetURLs(html)
+	if err != nil {
+		log.Errorf("unable to rewrite asset references for landing page %d: %s", p.Id, err)
+	}
 	w.Write([]byte(html))
 }
 
+// PageAssetHandler serves the files uploaded for a landing page. Assets
+// don't require a recipient id, since they're loaded by the browser after
+// the landing page.
+func (ps *PhishingServer) PageAssetHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	a, err := models.GetPageAsset(id, vars["path"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, path.Base(a.Path), a.ModifiedDate, bytes.NewReader(a.Content))
+}
+
+// TrainingHandler shows the campaign's training page to the recipient. When
+// the page is submitted, the training is recorded as completed and the page
+// is shown again with .Completed set.
+func (ps *PhishingServer) TrainingHandler(w http.ResponseWriter, r *http.Request) {
+	r, err := setupContext(r)
+	if err != nil {
+		// Log the error if it wasn't something we can safely ignore
+		if err != ErrInvalidRequest && err != ErrCampaignComplete {
+			log.Error(err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	// Previews don't have a training page
+	if _, ok := ctx.Get(r, "result").(models.EmailRequest); ok {
+		http.NotFound(w, r)
+		return
+	}
+	rs := ctx.Get(r, "result").(models.Result)
+	rid := ctx.Get(r, "rid").(string)
+	c := ctx.Get(r, "campaign").(models.Campaign)
+	d := ctx.Get(r, "details").(models.EventDetails)
+
+	// Check for a transparency request
+	if strings.HasSuffix(rid, TransparencySuffix) {
+		ps.TransparencyHandler(w, r)
+		return
+	}
+	if c.TrainingPage.Id == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	tc, err := models.NewTrainingTemplateContext(&c, rs)
+	if err != nil {
+		log.Errorf("unable to render training page %d: %s", c.TrainingPage.Id, err)
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method == "POST" {
+		err = rs.HandleTrainingCompleted(d)
+		if err != nil {
+			log.Error(err)
+		}
+		tc.Completed = true
+	}
+	html, err := models.ExecuteTemplate(c.TrainingPage.HTML, tc)
+	if err != nil {
+		log.Errorf("unable to render training page %d: %s", c.TrainingPage.Id, err)
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte(html))
+}
+
+// trainingURL returns the URL of the training page alongside the landing
+// page that was requested
+func trainingURL(r *http.Request, rid string) string {
+	u := url.URL{
+		Path:     strings.TrimSuffix(r.URL.Path, "/") + "/" + models.TrainingPath,
+		RawQuery: url.Values{models.RecipientParameter: {rid}}.Encode(),
+	}
+	return u.String()
+}
+
 // RobotsHandler prevents search engines, etc. from indexing phishing materials
 func (ps *PhishingServer) RobotsHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "User-agent: *\nDisallow: /")
 }
 
+// serverName returns the server type returned in transparency responses
+func (ps *PhishingServer) serverName() string {
+	if ps.transparency.ServerName != "" {
+		return ps.transparency.ServerName
+	}
+	return config.ServerName
+}
+
 // TransparencyHandler returns a TransparencyResponse for the provided result
-// and campaign.
+// and campaign. If the HTML page is enabled, browsers are shown a page
+// describing the simulation instead.
 func (ps *PhishingServer) TransparencyHandler(w http.ResponseWriter, r *http.Request) {
 	rs := ctx.Get(r, "result").(models.Result)
 	tr := &TransparencyResponse{
-		Server:         config.ServerName,
+		Server:         ps.serverName(),
 		SendDate:       rs.SendDate,
 		ContactAddress: ps.contactAddress,
+		Organization:   ps.transparency.Organization,
+		Description:    ps.transparency.Description,
+		PolicyURL:      ps.transparency.PolicyURL,
+	}
+	if ps.transparency.ContactAddress != "" {
+		tr.ContactAddress = ps.transparency.ContactAddress
+	}
+	if ps.transparency.HTMLPage && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		renderTransparencyPage(w, r, tr)
+		return
 	}
 	api.JSONResponse(w, tr, http.StatusOK)
 }
 
+// renderTransparencyPage writes the human-readable version of a
+// TransparencyResponse
+func renderTransparencyPage(w http.ResponseWriter, r *http.Request, tr *TransparencyResponse) {
+	tmpl, err := template.ParseFiles("templates/transparency.html")
+	if err != nil {
+		log.Error(err)
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = tmpl.Execute(w, tr)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
 // setupContext handles some of the administrative work around receiving a new
 // request, such as checking the result ID, the campaign, etc.
 func setupContext(r *http.Request) (*http.Request, error) {
@@ -356,6 +580,15 @@ func setupContext(r *http.Request) (*http.Request, error) {
 	if c.Status == models.CampaignComplete {
 		return r, ErrCampaignComplete
 	}
+	// Only handle requests on the campaign's own host and path
+	ok, err := c.ServesRequest(r.Host, r.URL.Path)
+	if err != nil {
+		log.Error(err)
+		return r, err
+	}
+	if !ok {
+		return r, ErrInvalidRequest
+	}
 	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		ip = r.RemoteAddr
@@ -371,6 +604,7 @@ func setupContext(r *http.Request) (*http.Request, error) {
 	}
 	d.Browser["address"] = ip
 	d.Browser["user-agent"] = r.Header.Get("User-Agent")
+	d.Browser["method"] = r.Method
 
 	r = ctx.Set(r, "rid", rid)
 	r = ctx.Set(r, "result", rs)
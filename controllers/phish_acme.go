@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/autotls"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/config"
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
+)
+
+// defaultACMECacheDir is the directory certificates obtained for the
+// phishing server are stored in
+const defaultACMECacheDir = "acme"
+
+// defaultACMEHTTPListenURL is the address HTTP-01 challenges are answered on
+const defaultACMEHTTPListenURL = "0.0.0.0:80"
+
+// defaultDNSPropagationDelay is the number of seconds to wait for DNS-01
+// challenge records to reach Cloudflare's nameservers
+const defaultDNSPropagationDelay = 10
+
+// WithCloudflareToken sets the Cloudflare token used to solve DNS-01
+// challenges when the phishing server obtains certificates using ACME
+func WithCloudflareToken(token string) PhishingServerOption {
+	return func(ps *PhishingServer) {
+		ps.cfToken = token
+	}
+}
+
+// phishingHostPolicy allows certificates to be requested for the configured
+// hostnames or, if there are none, for the configured phishing hosts
+func phishingHostPolicy(hostnames []string) autotls.HostPolicy {
+	if len(hostnames) > 0 {
+		return autotls.HostWhitelist(hostnames...)
+	}
+	return func(hostname string) error {
+		_, err := models.GetPhishingHostByName(hostname)
+		if err != nil {
+			return autotls.ErrHostNotAllowed
+		}
+		return nil
+	}
+}
+
+// newCertificateManager returns the manager which obtains the phishing
+// server's certificates using the ACME configuration
+func newCertificateManager(conf config.ACMEConfig, cloudflareToken string) (*autotls.Manager, error) {
+	client, err := autotls.NewHTTPClient(conf.CACertPath)
+	if err != nil {
+		return nil, err
+	}
+	cacheDir := conf.CacheDir
+	if cacheDir == "" {
+		cacheDir = defaultACMECacheDir
+	}
+	delay := conf.DNSPropagationDelay
+	if delay == 0 {
+		delay = defaultDNSPropagationDelay
+	}
+	m := &autotls.Manager{
+		DirectoryURL:        conf.DirectoryURL,
+		Email:               conf.Email,
+		Cache:               autotls.DirCache(cacheDir),
+		HostPolicy:          phishingHostPolicy(conf.Hostnames),
+		Challenge:           conf.Challenge,
+		DNSPropagationDelay: time.Duration(delay) * time.Second,
+		HTTPClient:          client,
+	}
+	if m.Challenge == autotls.ChallengeDNS01 {
+		m.DNSProvider, err = autotls.NewCloudflare(cloudflareToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, m.Validate()
+}
+
+// startACME launches the phishing server using certificates obtained from
+// the configured ACME certificate authority. Requests over HTTP are answered
+// by the phishing handlers, along with the HTTP-01 challenges.
+func (ps *PhishingServer) startACME() {
+	m, err := newCertificateManager(ps.config.ACME, ps.cfToken)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ps.certManager = m
+	tlsConfig := defaultTLSConfig.Clone()
+	tlsConfig.GetCertificate = m.GetCertificate
+	ps.server.TLSConfig = tlsConfig
+	m.StartRenewal(autotls.DefaultRenewInterval)
+
+	httpAddr := ps.config.ACME.HTTPListenURL
+	if httpAddr == "" && ps.config.ACME.Challenge != autotls.ChallengeDNS01 {
+		httpAddr = defaultACMEHTTPListenURL
+	}
+	if httpAddr != "" {
+		ps.httpServer = &http.Server{
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			Addr:         httpAddr,
+			Handler:      m.HTTPHandler(ps.server.Handler),
+		}
+		go func() {
+			log.Infof("Starting phishing server at http://%s", httpAddr)
+			err := ps.httpServer.ListenAndServe()
+			if err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+	}
+	log.Infof("Starting phishing server at https://%s using ACME certificates", ps.config.ListenURL)
+	err = ps.server.ListenAndServeTLS("", "")
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
@@ -1,15 +1,20 @@
 package controllers
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/autotls"
 	"github.com/7nikhilkamboj/TrustStrike-Simulation/config"
 	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
 )
@@ -41,8 +46,23 @@ func getFirstEmailRequest(t *testing.T) models.EmailRequest {
 	return req
 }
 
+// browserUserAgent is sent by tests acting as a recipient, since requests
+// made by HTTP libraries are classified as automated
+const browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+// browserRequest makes a request to the phishing server as a recipient's
+// browser would
+func browserRequest(method, u string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", browserUserAgent)
+	return http.DefaultClient.Do(req)
+}
+
 func openEmail(t *testing.T, ctx *testContext, rid string) {
-	resp, err := http.Get(fmt.Sprintf("%s/track?%s=%s", ctx.phishServer.URL, models.RecipientParameter, rid))
+	resp, err := browserRequest("GET", fmt.Sprintf("%s/track?%s=%s", ctx.phishServer.URL, models.RecipientParameter, rid))
 	if err != nil {
 		t.Fatalf("error requesting /track endpoint: %v", err)
 	}
@@ -98,7 +118,7 @@ func reportEmail404(t *testing.T, ctx *testContext, rid string) {
 }
 
 func clickLink(t *testing.T, ctx *testContext, rid string, expectedHTML string) {
-	resp, err := http.Get(fmt.Sprintf("%s/?%s=%s", ctx.phishServer.URL, models.RecipientParameter, rid))
+	resp, err := browserRequest("GET", fmt.Sprintf("%s/?%s=%s", ctx.phishServer.URL, models.RecipientParameter, rid))
 	if err != nil {
 		t.Fatalf("error requesting / endpoint: %v", err)
 	}
@@ -251,6 +271,37 @@ func TestNoRecipientID(t *testing.T) {
 	}
 }
 
+func TestEventClassificationDisabled(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	ctx.config.EventClassification.Enabled = false
+	campaign := getFirstCampaign(t)
+	result := campaign.Results[0]
+
+	// HEAD requests are ignored, and every click is counted
+	u := fmt.Sprintf("%s/?%s=%s", ctx.phishServer.URL, models.RecipientParameter, result.RId)
+	resp, err := browserRequest("HEAD", u)
+	if err != nil {
+		t.Fatalf("error requesting / endpoint: %v", err)
+	}
+	resp.Body.Close()
+	campaign = getFirstCampaign(t)
+	for _, e := range campaign.Events {
+		if e.Message == models.EventClicked {
+			t.Fatalf("unexpected click event recorded for HEAD request")
+		}
+	}
+	resp, err = http.Get(u)
+	if err != nil {
+		t.Fatalf("error requesting / endpoint: %v", err)
+	}
+	resp.Body.Close()
+	campaign = getFirstCampaign(t)
+	if campaign.Results[0].Status != models.EventClicked {
+		t.Fatalf("unexpected result status received. expected %s got %s", models.EventClicked, campaign.Results[0].Status)
+	}
+}
+
 func TestInvalidRecipientID(t *testing.T) {
 	ctx := setupTest(t)
 	defer tearDown(t, ctx)
@@ -260,6 +311,44 @@ func TestInvalidRecipientID(t *testing.T) {
 	reportEmail404(t, ctx, rid)
 }
 
+func TestAutomatedClick(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	campaign := getFirstCampaign(t)
+	result := campaign.Results[0]
+
+	// A link scanner checking the link doesn't count as a click
+	u := fmt.Sprintf("%s/?%s=%s", ctx.phishServer.URL, models.RecipientParameter, result.RId)
+	resp, err := browserRequest("HEAD", u)
+	if err != nil {
+		t.Fatalf("error requesting / endpoint: %v", err)
+	}
+	resp.Body.Close()
+	resp, err = http.Get(u)
+	if err != nil {
+		t.Fatalf("error requesting / endpoint: %v", err)
+	}
+	resp.Body.Close()
+
+	campaign = getFirstCampaign(t)
+	result = campaign.Results[0]
+	if result.Status != models.StatusSending {
+		t.Fatalf("unexpected result status received. expected %s got %s", models.StatusSending, result.Status)
+	}
+	for _, e := range campaign.Events {
+		if e.Message == models.EventClicked && e.Classification != models.ClassificationScanner {
+			t.Fatalf("unexpected click classification. expected %s got %s", models.ClassificationScanner, e.Classification)
+		}
+	}
+	summary, err := models.GetCampaignSummary(campaign.Id, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign summary: %v", err)
+	}
+	if summary.Stats.ClickedLink != 0 || summary.Stats.Raw.ClickedLink != 1 {
+		t.Fatalf("unexpected click counts. expected 0 clicks and 1 raw click got %d and %d", summary.Stats.ClickedLink, summary.Stats.Raw.ClickedLink)
+	}
+}
+
 func TestCompletedCampaignClick(t *testing.T) {
 	ctx := setupTest(t)
 	defer tearDown(t, ctx)
@@ -406,3 +495,616 @@ func TestRedirectTemplating(t *testing.T) {
 		t.Fatalf("invalid redirect received. expected %s got %s", expectedURL, gotURL)
 	}
 }
+
+func TestTrainingPage(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	tp := models.TrainingPage{
+		Name:       "Training Page",
+		UserId:     1,
+		HTML:       `{{if .Completed}}Done{{else}}<form method="post" action="{{.CompleteURL}}"></form>{{.Lure.Subject}}{{end}}`,
+		Indicators: models.TrainingIndicators{{Text: "subject", Explanation: "Generic subject"}},
+	}
+	err := models.PostTrainingPage(&tp)
+	if err != nil {
+		t.Fatalf("error posting training page: %v", err)
+	}
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	group, _ := models.GetGroup(1, 1)
+	p, _ := models.GetPageByName("Test Page", 1)
+
+	campaign := models.Campaign{Name: "Training campaign"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = p
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	campaign.TrainingPage = models.TrainingPage{Name: tp.Name}
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	if campaign.TrainingTrigger != models.TrainingTriggerClick {
+		t.Fatalf("unexpected training trigger. expected %s got %s", models.TrainingTriggerClick, campaign.TrainingTrigger)
+	}
+
+	client := http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	result := campaign.Results[0]
+	resp, err := client.Get(fmt.Sprintf("%s/login?%s=%s", ctx.phishServer.URL, models.RecipientParameter, result.RId))
+	if err != nil {
+		t.Fatalf("error requesting landing page: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("invalid status code received for landing page. expected %d got %d", http.StatusFound, resp.StatusCode)
+	}
+	expectedURL := fmt.Sprintf("/login/training?%s=%s", models.RecipientParameter, result.RId)
+	if got := resp.Header.Get("Location"); got != expectedURL {
+		t.Fatalf("invalid redirect received. expected %s got %s", expectedURL, got)
+	}
+
+	resp, err = http.Get(ctx.phishServer.URL + expectedURL)
+	if err != nil {
+		t.Fatalf("error requesting training page: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	expected := `<form method="post" action="training?rid=` + result.RId + `"></form>Test <mark class="phish-indicator" title="Generic subject">subject</mark>`
+	if string(body) != expected {
+		t.Fatalf("unexpected training page. expected %s got %s", expected, body)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err = http.PostForm(ctx.phishServer.URL+expectedURL, url.Values{})
+		if err != nil {
+			t.Fatalf("error completing training: %v", err)
+		}
+		body, _ = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "Done" {
+			t.Fatalf("unexpected completed training page. expected Done got %s", body)
+		}
+	}
+	campaign, err = models.GetCampaign(campaign.Id, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	completed := 0
+	for _, e := range campaign.Events {
+		if e.Message == models.EventTraining && e.Email == result.Email {
+			completed++
+		}
+	}
+	if completed != 1 {
+		t.Fatalf("unexpected number of training events. expected 1 got %d", completed)
+	}
+}
+
+func TestTrainingModule(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	tm := models.TrainingModule{
+		Name:      "Training Module",
+		UserId:    1,
+		Steps:     models.TrainingSteps{{Title: "Check the sender", HTML: "<p>Look closely</p>"}},
+		Questions: models.QuizQuestions{{Question: "Is this a phish?", Options: []string{"Yes", "No"}, Answer: 0}},
+	}
+	err := models.PostTrainingModule(&tm)
+	if err != nil {
+		t.Fatalf("error posting training module: %v", err)
+	}
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	group, _ := models.GetGroup(1, 1)
+	p, _ := models.GetPageByName("Test Page", 1)
+
+	campaign := models.Campaign{Name: "Training module campaign"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = p
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	campaign.TrainingModule = models.TrainingModule{Name: tm.Name}
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	result := campaign.Results[0]
+	moduleURL := fmt.Sprintf("%s/%s?%s=%s", ctx.phishServer.URL, models.TrainingModulePath, models.RecipientParameter, result.RId)
+
+	// The module isn't assigned until the recipient clicks
+	resp, err := http.Get(moduleURL)
+	if err != nil {
+		t.Fatalf("error requesting training module: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("invalid status code received for unassigned module. expected %d got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	clickLink(t, ctx, result.RId, "<html><head></head><body>Test</body></html>")
+
+	pages := []struct {
+		method   string
+		url      string
+		expected string
+	}{
+		{"GET", moduleURL, "Look closely"},
+		{"GET", moduleURL + "&step=1", "Is this a phish?"},
+		{"POST", moduleURL, "Training complete"},
+	}
+	for _, page := range pages {
+		if page.method == "POST" {
+			resp, err = http.PostForm(page.url, url.Values{"q0": {"0"}})
+		} else {
+			resp, err = http.Get(page.url)
+		}
+		if err != nil {
+			t.Fatalf("error requesting training module: %v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !bytes.Contains(body, []byte(page.expected)) {
+			t.Fatalf("training module page %s doesn't contain %q: %s", page.url, page.expected, body)
+		}
+	}
+	a, err := models.GetTrainingAssignment(result.RId)
+	if err != nil {
+		t.Fatalf("error getting training assignment: %v", err)
+	}
+	if a.CompletedDate.IsZero() || a.Score != 100 {
+		t.Fatalf("training module wasn't completed. got score %d", a.Score)
+	}
+}
+
+func TestPageAssets(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	p := models.Page{Name: "Asset Page", UserId: 1, HTML: `<html><body><img src="img/logo.png"></body></html>`}
+	err := models.PostPage(&p)
+	if err != nil {
+		t.Fatalf("error posting page: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	f, _ := zw.Create("img/logo.png")
+	f.Write([]byte("\x89PNG\r\n\x1a\n"))
+	zw.Close()
+	_, err = models.ImportPageAssets(&p, buf.Bytes())
+	if err != nil {
+		t.Fatalf("error importing page assets: %v", err)
+	}
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	group, _ := models.GetGroup(1, 1)
+
+	campaign := models.Campaign{Name: "Asset campaign"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = p
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	assetURL := models.PageAssetURL(p.Id, "img/logo.png")
+	expected := `<html><head></head><body><img src="` + assetURL + `"/></body></html>`
+	clickLink(t, ctx, campaign.Results[0].RId, expected)
+
+	resp, err := http.Get(ctx.phishServer.URL + assetURL)
+	if err != nil {
+		t.Fatalf("error requesting page asset: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("invalid status code received for page asset. expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "image/png" {
+		t.Fatalf("invalid content type received for page asset. expected image/png got %s", got)
+	}
+	if string(body) != "\x89PNG\r\n\x1a\n" {
+		t.Fatalf("unexpected page asset received: %q", body)
+	}
+
+	resp, err = http.Get(ctx.phishServer.URL + models.PageAssetURL(p.Id, "missing.png"))
+	if err != nil {
+		t.Fatalf("error requesting page asset: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("invalid status code received for missing page asset. expected %d got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestMultiStepPage(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	tp := models.TrainingPage{Name: "Step Training", UserId: 1, HTML: "Training"}
+	err := models.PostTrainingPage(&tp)
+	if err != nil {
+		t.Fatalf("error posting training page: %v", err)
+	}
+	p := models.Page{
+		Name:        "Multi-step Page",
+		UserId:      1,
+		HTML:        "Login",
+		RedirectURL: "https://example.com",
+		Steps: models.PageSteps{
+			{Name: "MFA", HTML: "MFA"},
+			{Name: "Training", Training: true},
+		},
+	}
+	err = models.PostPage(&p)
+	if err != nil {
+		t.Fatalf("error posting page: %v", err)
+	}
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	group, _ := models.GetGroup(1, 1)
+
+	campaign := models.Campaign{Name: "Multi-step campaign"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = p
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	campaign.TrainingPage = models.TrainingPage{Name: tp.Name}
+	campaign.TrainingTrigger = models.TrainingTriggerSubmit
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	result := campaign.Results[0]
+	pageURL := fmt.Sprintf("%s/login?%s=%s", ctx.phishServer.URL, models.RecipientParameter, result.RId)
+	client := http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	expected := []struct {
+		method string
+		status int
+		body   string
+	}{
+		{"GET", http.StatusOK, "<html><head></head><body>Login</body></html>"},
+		{"POST", http.StatusOK, "<html><head></head><body>MFA</body></html>"},
+		{"GET", http.StatusOK, "<html><head></head><body>MFA</body></html>"},
+		{"POST", http.StatusFound, ""},
+	}
+	for i, e := range expected {
+		var resp *http.Response
+		if e.method == "GET" {
+			resp, err = client.Get(pageURL)
+		} else {
+			resp, err = client.PostForm(pageURL, url.Values{"username": {"user"}})
+		}
+		if err != nil {
+			t.Fatalf("error requesting step %d: %v", i, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != e.status {
+			t.Fatalf("invalid status code received for request %d. expected %d got %d", i, e.status, resp.StatusCode)
+		}
+		if e.status == http.StatusOK && string(body) != e.body {
+			t.Fatalf("unexpected page received for request %d. expected %s got %s", i, e.body, body)
+		}
+		if e.status == http.StatusFound && !strings.HasPrefix(resp.Header.Get("Location"), "/login/training?") {
+			t.Fatalf("expected a redirect to the training page. got %s", resp.Header.Get("Location"))
+		}
+	}
+	rs, err := models.GetResult(result.RId)
+	if err != nil {
+		t.Fatalf("error getting result: %v", err)
+	}
+	if rs.PageStep != 2 {
+		t.Fatalf("invalid page step recorded. expected 2 got %d", rs.PageStep)
+	}
+	campaign, err = models.GetCampaign(campaign.Id, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	steps := []string{}
+	for _, e := range campaign.Events {
+		if e.Message != models.EventDataSubmit {
+			continue
+		}
+		d := models.EventDetails{}
+		json.Unmarshal([]byte(e.Details), &d)
+		steps = append(steps, d.Step)
+	}
+	if !reflect.DeepEqual(steps, []string{"Step 1", "MFA"}) {
+		t.Fatalf("invalid steps recorded for submitted data. got %v", steps)
+	}
+}
+
+func TestTrainingStepWithoutTrainingPage(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	p := models.Page{
+		Name:        "Training Step Page",
+		UserId:      1,
+		HTML:        "Login",
+		RedirectURL: "https://example.com",
+		Steps:       models.PageSteps{{Name: "Training", Training: true}},
+	}
+	err := models.PostPage(&p)
+	if err != nil {
+		t.Fatalf("error posting page: %v", err)
+	}
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	group, _ := models.GetGroup(1, 1)
+
+	campaign := models.Campaign{Name: "Training step campaign"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = p
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	pageURL := fmt.Sprintf("%s/login?%s=%s", ctx.phishServer.URL, models.RecipientParameter, campaign.Results[0].RId)
+	client := http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	// Without a training page, submitting the step before the training step
+	// redirects the recipient, and the page stays on that step
+	resp, err := client.PostForm(pageURL, url.Values{"username": {"user"}})
+	if err != nil {
+		t.Fatalf("error submitting page: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != p.RedirectURL {
+		t.Fatalf("expected a redirect to %s. got %d %s", p.RedirectURL, resp.StatusCode, resp.Header.Get("Location"))
+	}
+	resp, err = client.Get(pageURL)
+	if err != nil {
+		t.Fatalf("error requesting page: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "<html><head></head><body>Login</body></html>" {
+		t.Fatalf("unexpected page received. got %s", body)
+	}
+}
+
+func TestPhishingHostRouting(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	h := models.PhishingHost{Hostname: "login.example.com", Paths: models.HostPaths{"/hr"}}
+	err := models.PostPhishingHost(&h)
+	if err != nil {
+		t.Fatalf("error posting phishing host: %v", err)
+	}
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	group, _ := models.GetGroup(1, 1)
+	p, _ := models.GetPageByName("Test Page", 1)
+
+	campaign := models.Campaign{Name: "Host campaign", URL: "http://localhost.localdomain/hr"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = p
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != models.ErrPhishingHostNotConfigured {
+		t.Fatalf("unexpected error creating campaign. expected %v got %v", models.ErrPhishingHostNotConfigured, err)
+	}
+	campaign.URL = "https://login.example.com/hr/benefits"
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	rid := campaign.Results[0].RId
+	requests := []struct {
+		host   string
+		path   string
+		status int
+	}{
+		{"other.example.com", "/hr/benefits", http.StatusNotFound},
+		{"login.example.com", "/it", http.StatusNotFound},
+		{"login.example.com", "/hr/benefits", http.StatusOK},
+		{"other.example.com", "/track", http.StatusNotFound},
+		{"login.example.com", "/track", http.StatusOK},
+		{"login.example.com", "/report", http.StatusNoContent},
+	}
+	for _, e := range requests {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("%s%s?%s=%s", ctx.phishServer.URL, e.path, models.RecipientParameter, rid), nil)
+		req.Host = e.host
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error requesting %s%s: %v", e.host, e.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != e.status {
+			t.Fatalf("invalid status code received for %s%s. expected %d got %d", e.host, e.path, e.status, resp.StatusCode)
+		}
+	}
+}
+
+func TestPhishingHostRoutingExistingCampaign(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	campaign := getFirstCampaign(t)
+	rid := campaign.Results[0].RId
+
+	// Campaigns launched on other hosts keep working once phishing hosts are
+	// configured
+	h := models.PhishingHost{Hostname: "login.example.com"}
+	err := models.PostPhishingHost(&h)
+	if err != nil {
+		t.Fatalf("error posting phishing host: %v", err)
+	}
+	for _, path := range []string{"/", "/track"} {
+		resp, err := http.Get(fmt.Sprintf("%s%s?%s=%s", ctx.phishServer.URL, path, models.RecipientParameter, rid))
+		if err != nil {
+			t.Fatalf("error requesting %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("invalid status code received for %s. expected %d got %d", path, http.StatusOK, resp.StatusCode)
+		}
+	}
+}
+
+func TestCertificateManager(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	h := models.PhishingHost{Hostname: "login.example.com"}
+	err := models.PostPhishingHost(&h)
+	if err != nil {
+		t.Fatalf("error posting phishing host: %v", err)
+	}
+
+	m, err := newCertificateManager(config.ACMEConfig{Enabled: true}, "")
+	if err != nil {
+		t.Fatalf("error creating certificate manager: %v", err)
+	}
+	if m.Challenge != "" || m.DNSProvider != nil {
+		t.Fatalf("expected HTTP-01 challenges by default")
+	}
+	if err := m.HostPolicy("login.example.com"); err != nil {
+		t.Fatalf("unexpected error for configured phishing host: %v", err)
+	}
+	if err := m.HostPolicy("other.example.com"); err != autotls.ErrHostNotAllowed {
+		t.Fatalf("unexpected error for unknown host. expected %v got %v", autotls.ErrHostNotAllowed, err)
+	}
+
+	// Configured hostnames replace the phishing hosts
+	m, err = newCertificateManager(config.ACMEConfig{Enabled: true, Hostnames: []string{"other.example.com"}}, "")
+	if err != nil {
+		t.Fatalf("error creating certificate manager: %v", err)
+	}
+	if err := m.HostPolicy("login.example.com"); err != autotls.ErrHostNotAllowed {
+		t.Fatalf("unexpected error for unlisted host. expected %v got %v", autotls.ErrHostNotAllowed, err)
+	}
+
+	_, err = newCertificateManager(config.ACMEConfig{Enabled: true, Challenge: autotls.ChallengeDNS01}, "")
+	if err != autotls.ErrCloudflareTokenNotSpecified {
+		t.Fatalf("unexpected error without a Cloudflare token. expected %v got %v", autotls.ErrCloudflareTokenNotSpecified, err)
+	}
+	m, err = newCertificateManager(config.ACMEConfig{Enabled: true, Challenge: autotls.ChallengeDNS01}, "token")
+	if err != nil {
+		t.Fatalf("error creating certificate manager: %v", err)
+	}
+	if m.DNSProvider == nil {
+		t.Fatalf("expected the Cloudflare DNS provider for DNS-01 challenges")
+	}
+	_, err = newCertificateManager(config.ACMEConfig{Enabled: true, Challenge: "tls-alpn-01"}, "")
+	if err != autotls.ErrUnsupportedChallenge {
+		t.Fatalf("unexpected error for unsupported challenge. expected %v got %v", autotls.ErrUnsupportedChallenge, err)
+	}
+}
+
+func TestConfiguredTransparencyRequest(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	transparency := config.Transparency{
+		ServerName:     "Awareness",
+		Organization:   "Example Corp Security",
+		ContactAddress: "security@example.com",
+		Description:    "Quarterly phishing awareness exercise",
+		PolicyURL:      "https://intranet.example.com/policies/phishing",
+		HTMLPage:       true,
+	}
+	ps := httptest.NewServer(NewPhishingServer(ctx.config.PhishConf, WithContactAddress("fallback@example.com"),
+		WithTransparency(transparency)).server.Handler)
+	defer ps.Close()
+	campaign := getFirstCampaign(t)
+	result := campaign.Results[0]
+	u := fmt.Sprintf("%s/?%s=%s%s", ps.URL, models.RecipientParameter, result.RId, TransparencySuffix)
+
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatalf("error requesting transparency endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+	tr := &TransparencyResponse{}
+	err = json.NewDecoder(resp.Body).Decode(tr)
+	if err != nil {
+		t.Fatalf("error unmarshaling transparency request: %v", err)
+	}
+	expected := &TransparencyResponse{
+		Server:         transparency.ServerName,
+		ContactAddress: transparency.ContactAddress,
+		SendDate:       result.SendDate,
+		Organization:   transparency.Organization,
+		Description:    transparency.Description,
+		PolicyURL:      transparency.PolicyURL,
+	}
+	if !reflect.DeepEqual(tr, expected) {
+		t.Fatalf("unexpected transparency response received. expected %v got %v", expected, tr)
+	}
+
+	// Browsers are shown the HTML page
+	req, _ := http.NewRequest("GET", u, nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error requesting transparency page: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("unexpected content type for transparency page: %s", resp.Header.Get("Content-Type"))
+	}
+	for _, s := range []string{transparency.Organization, transparency.Description, transparency.PolicyURL, "mailto:" + transparency.ContactAddress} {
+		if !strings.Contains(string(body), s) {
+			t.Fatalf("transparency page doesn't contain %q: %s", s, body)
+		}
+	}
+}
+
+func TestPhishingServerOptions(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	f, err := ioutil.TempFile("", "trust_strike-config")
+	if err != nil {
+		t.Fatalf("error creating config file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{
+		"contact_address": "fallback@example.com",
+		"cloudflare_token": "token",
+		"transparency": {"organization": "Example Corp Security", "html_page": true}
+	}`)
+	f.Close()
+	conf, err := config.LoadConfig(f.Name())
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	phishServer := NewPhishingServer(conf.PhishConf, PhishingServerOptions(conf)...)
+	if phishServer.cfToken != "token" {
+		t.Fatalf("unexpected Cloudflare token. expected %q got %q", "token", phishServer.cfToken)
+	}
+	ps := httptest.NewServer(phishServer.server.Handler)
+	defer ps.Close()
+	result := getFirstCampaign(t).Results[0]
+	u := fmt.Sprintf("%s/?%s=%s%s", ps.URL, models.RecipientParameter, result.RId, TransparencySuffix)
+	req, _ := http.NewRequest("GET", u, nil)
+	req.Header.Set("Accept", "text/html")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error requesting transparency page: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	for _, s := range []string{"Example Corp Security", "mailto:fallback@example.com"} {
+		if !strings.Contains(string(body), s) {
+			t.Fatalf("transparency page doesn't contain %q: %s", s, body)
+		}
+	}
+}
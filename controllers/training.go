@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
+)
+
+// trainingModuleFuncs are the functions available to the training module
+// template
+var trainingModuleFuncs = template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+	// Steps are written by administrators, so their HTML is trusted
+	"safe": func(s string) template.HTML { return template.HTML(s) },
+}
+
+// trainingModuleView is the data used to render the training module page
+type trainingModuleView struct {
+	Module    models.TrainingModule
+	RId       string
+	Step      int
+	LastStep  bool
+	Quiz      bool
+	Submitted bool
+	Passed    bool
+	Score     int
+	PassScore int
+}
+
+// TrainingModuleHandler serves the training module assigned to a recipient.
+// The module's steps are shown one at a time using the step parameter,
+// followed by the quiz. Modules can be completed after the campaign ends.
+func (ps *PhishingServer) TrainingModuleHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	rid := r.Form.Get(models.RecipientParameter)
+	a, err := models.GetTrainingAssignment(rid)
+	if err != nil {
+		if err != models.ErrTrainingAssignmentNotFound {
+			log.Error(err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	rs, err := models.GetResult(rid)
+	if err != nil {
+		log.Error(err)
+		http.NotFound(w, r)
+		return
+	}
+	tm, err := models.GetTrainingModule(a.ModuleId, 0)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = a.Start(&rs)
+	if err != nil {
+		log.Error(err)
+	}
+	view := trainingModuleView{
+		Module:    tm,
+		RId:       rid,
+		PassScore: tm.PassingScore(),
+	}
+	switch {
+	case r.Method == "POST":
+		answers := make([]int, len(tm.Questions))
+		for i := range answers {
+			answers[i], err = strconv.Atoi(r.Form.Get(fmt.Sprintf("q%d", i)))
+			if err != nil {
+				answers[i] = -1
+			}
+		}
+		view.Score, view.Passed, err = a.SubmitQuiz(&rs, &tm, answers)
+		if err != nil {
+			log.Error(err)
+		}
+		view.Submitted = true
+	default:
+		view.Step, _ = strconv.Atoi(r.Form.Get("step"))
+		if view.Step < 0 {
+			view.Step = 0
+		}
+		if view.Step >= len(tm.Steps) {
+			view.Step = len(tm.Steps)
+			view.Quiz = true
+			// Modules without a quiz are complete once every step is read
+			if len(tm.Questions) == 0 {
+				view.Score, view.Passed = 100, true
+				if a.CompletedDate.IsZero() {
+					view.Score, view.Passed, err = a.SubmitQuiz(&rs, &tm, nil)
+					if err != nil {
+						log.Error(err)
+					}
+				}
+				view.Submitted = true
+			}
+		}
+		view.LastStep = view.Step == len(tm.Steps)-1
+	}
+	tmpl, err := template.New("training_module.html").Funcs(trainingModuleFuncs).ParseFiles("templates/training_module.html")
+	if err != nil {
+		log.Error(err)
+		http.NotFound(w, r)
+		return
+	}
+	err = tmpl.Execute(w, view)
+	if err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,14 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE targets ADD COLUMN phone VARCHAR(255) DEFAULT '';
+ALTER TABLE results ADD COLUMN phone VARCHAR(255) DEFAULT '';
+ALTER TABLE email_requests ADD COLUMN phone VARCHAR(255) DEFAULT '';
+-- SMS targets previously stored their phone number in the email column
+UPDATE targets SET phone=email WHERE email NOT LIKE '%@%';
+UPDATE results SET phone=email WHERE email NOT LIKE '%@%';
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+ALTER TABLE targets DROP COLUMN phone;
+ALTER TABLE results DROP COLUMN phone;
+ALTER TABLE email_requests DROP COLUMN phone;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE smtp ADD COLUMN messages_per_minute INTEGER DEFAULT 0;
+ALTER TABLE smtp ADD COLUMN messages_per_hour INTEGER DEFAULT 0;
+ALTER TABLE smtp ADD COLUMN max_connections INTEGER DEFAULT 0;
+ALTER TABLE smtp ADD COLUMN max_messages_per_connection INTEGER DEFAULT 0;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+ALTER TABLE smtp DROP COLUMN messages_per_minute;
+ALTER TABLE smtp DROP COLUMN messages_per_hour;
+ALTER TABLE smtp DROP COLUMN max_connections;
+ALTER TABLE smtp DROP COLUMN max_messages_per_connection;
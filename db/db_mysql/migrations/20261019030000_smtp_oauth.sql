@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE smtp ADD COLUMN oauth_tenant_id VARCHAR(255) DEFAULT '';
+ALTER TABLE smtp ADD COLUMN oauth_client_id VARCHAR(255) DEFAULT '';
+ALTER TABLE smtp ADD COLUMN oauth_client_secret TEXT;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+ALTER TABLE smtp DROP COLUMN oauth_tenant_id;
+ALTER TABLE smtp DROP COLUMN oauth_client_id;
+ALTER TABLE smtp DROP COLUMN oauth_client_secret;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE smtp ADD COLUMN mailbox_tls BOOLEAN DEFAULT 0;
+ALTER TABLE smtp ADD COLUMN mailbox_folder VARCHAR(255) DEFAULT '';
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+ALTER TABLE smtp DROP COLUMN mailbox_tls;
+ALTER TABLE smtp DROP COLUMN mailbox_folder;
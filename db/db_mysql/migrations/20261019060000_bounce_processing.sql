@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE imap ADD COLUMN bounce_folder VARCHAR(255) DEFAULT '';
+ALTER TABLE results ADD COLUMN message_id VARCHAR(255) DEFAULT '';
+CREATE INDEX results_message_id ON results(message_id);
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+DROP INDEX results_message_id ON results;
+ALTER TABLE results DROP COLUMN message_id;
+ALTER TABLE imap DROP COLUMN bounce_folder;
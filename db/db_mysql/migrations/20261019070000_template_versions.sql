@@ -0,0 +1,24 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS `template_versions` (
+    `id` integer primary key auto_increment,
+    `template_id` integer,
+    `version` integer,
+    `user_id` integer,
+    `type` varchar(255),
+    `name` varchar(255),
+    `envelope_sender` varchar(255),
+    `subject` varchar(255),
+    `text` mediumtext,
+    `html` mediumtext,
+    `attachments` longtext,
+    `created_date` datetime);
+CREATE UNIQUE INDEX template_versions_template_id_version ON template_versions(template_id, version);
+ALTER TABLE templates ADD COLUMN version INTEGER DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN template_version INTEGER DEFAULT 0;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+DROP TABLE `template_versions`;
+ALTER TABLE templates DROP COLUMN version;
+ALTER TABLE campaigns DROP COLUMN template_version;
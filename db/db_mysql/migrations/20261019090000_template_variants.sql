@@ -0,0 +1,25 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS `template_variants` (
+    `id` integer primary key auto_increment,
+    `template_id` integer,
+    `language` varchar(255),
+    `subject` varchar(255),
+    `text` mediumtext,
+    `html` mediumtext);
+CREATE INDEX template_variants_template_id ON template_variants(template_id);
+ALTER TABLE templates ADD COLUMN fallback_language VARCHAR(255) DEFAULT '';
+ALTER TABLE template_versions ADD COLUMN fallback_language VARCHAR(255) DEFAULT '';
+ALTER TABLE template_versions ADD COLUMN variants longtext;
+ALTER TABLE targets ADD COLUMN language VARCHAR(255) DEFAULT '';
+ALTER TABLE results ADD COLUMN language VARCHAR(255) DEFAULT '';
+ALTER TABLE email_requests ADD COLUMN language VARCHAR(255) DEFAULT '';
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+DROP TABLE `template_variants`;
+ALTER TABLE templates DROP COLUMN fallback_language;
+ALTER TABLE template_versions DROP COLUMN fallback_language, DROP COLUMN variants;
+ALTER TABLE targets DROP COLUMN language;
+ALTER TABLE results DROP COLUMN language;
+ALTER TABLE email_requests DROP COLUMN language;
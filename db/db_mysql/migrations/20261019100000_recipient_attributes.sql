@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE targets ADD COLUMN attributes TEXT;
+ALTER TABLE results ADD COLUMN attributes TEXT;
+ALTER TABLE email_requests ADD COLUMN attributes TEXT;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+ALTER TABLE targets DROP COLUMN attributes;
+ALTER TABLE results DROP COLUMN attributes;
+ALTER TABLE email_requests DROP COLUMN attributes;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE campaigns ADD COLUMN qr_error_correction VARCHAR(255) DEFAULT '';
+ALTER TABLE campaigns ADD COLUMN qr_foreground VARCHAR(255) DEFAULT '';
+ALTER TABLE campaigns ADD COLUMN qr_background VARCHAR(255) DEFAULT '';
+ALTER TABLE campaigns ADD COLUMN qr_quiet_zone INTEGER DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN qr_logo TEXT;
+ALTER TABLE email_requests ADD COLUMN qr_error_correction VARCHAR(255) DEFAULT '';
+ALTER TABLE email_requests ADD COLUMN qr_foreground VARCHAR(255) DEFAULT '';
+ALTER TABLE email_requests ADD COLUMN qr_background VARCHAR(255) DEFAULT '';
+ALTER TABLE email_requests ADD COLUMN qr_quiet_zone INTEGER DEFAULT 0;
+ALTER TABLE email_requests ADD COLUMN qr_logo TEXT;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+ALTER TABLE campaigns DROP COLUMN qr_error_correction, DROP COLUMN qr_foreground, DROP COLUMN qr_background, DROP COLUMN qr_quiet_zone, DROP COLUMN qr_logo;
+ALTER TABLE email_requests DROP COLUMN qr_error_correction, DROP COLUMN qr_foreground, DROP COLUMN qr_background, DROP COLUMN qr_quiet_zone, DROP COLUMN qr_logo;
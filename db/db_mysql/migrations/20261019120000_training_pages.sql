@@ -0,0 +1,16 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS `training_pages` (
+    `id` integer primary key auto_increment,
+    `user_id` integer,
+    `name` varchar(255),
+    `html` mediumtext,
+    `indicators` text,
+    `modified_date` datetime);
+ALTER TABLE campaigns ADD COLUMN training_page_id INTEGER DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN training_trigger VARCHAR(255) DEFAULT '';
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+DROP TABLE `training_pages`;
+ALTER TABLE campaigns DROP COLUMN training_page_id, DROP COLUMN training_trigger;
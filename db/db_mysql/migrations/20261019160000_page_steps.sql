@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE pages ADD COLUMN steps MEDIUMTEXT;
+ALTER TABLE results ADD COLUMN page_step INTEGER DEFAULT 0;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+ALTER TABLE pages DROP COLUMN steps;
+ALTER TABLE results DROP COLUMN page_step;
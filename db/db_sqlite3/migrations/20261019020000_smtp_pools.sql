@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "campaign_smtp" (
+    "id" integer primary key autoincrement,
+    "campaign_id" integer,
+    "smtp_id" integer,
+    "position" integer,
+    "weight" integer);
+ALTER TABLE mail_logs ADD COLUMN smtp_id INTEGER DEFAULT 0;
+ALTER TABLE mail_logs ADD COLUMN failover_attempt INTEGER DEFAULT 0;
+ALTER TABLE results ADD COLUMN smtp_id INTEGER DEFAULT 0;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+DROP TABLE "campaign_smtp";
@@ -0,0 +1,8 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE smtp ADD COLUMN dkim_domain VARCHAR(255) DEFAULT '';
+ALTER TABLE smtp ADD COLUMN dkim_selector VARCHAR(255) DEFAULT '';
+ALTER TABLE smtp ADD COLUMN dkim_private_key TEXT;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
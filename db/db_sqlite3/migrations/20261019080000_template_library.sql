@@ -0,0 +1,17 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE templates ADD COLUMN tags VARCHAR(255) DEFAULT '';
+ALTER TABLE templates ADD COLUMN difficulty VARCHAR(255) DEFAULT '';
+ALTER TABLE templates ADD COLUMN language VARCHAR(255) DEFAULT '';
+ALTER TABLE templates ADD COLUMN category VARCHAR(255) DEFAULT '';
+ALTER TABLE pages ADD COLUMN tags VARCHAR(255) DEFAULT '';
+ALTER TABLE pages ADD COLUMN difficulty VARCHAR(255) DEFAULT '';
+ALTER TABLE pages ADD COLUMN language VARCHAR(255) DEFAULT '';
+ALTER TABLE pages ADD COLUMN category VARCHAR(255) DEFAULT '';
+ALTER TABLE template_versions ADD COLUMN tags VARCHAR(255) DEFAULT '';
+ALTER TABLE template_versions ADD COLUMN difficulty VARCHAR(255) DEFAULT '';
+ALTER TABLE template_versions ADD COLUMN language VARCHAR(255) DEFAULT '';
+ALTER TABLE template_versions ADD COLUMN category VARCHAR(255) DEFAULT '';
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
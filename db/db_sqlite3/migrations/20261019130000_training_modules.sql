@@ -0,0 +1,35 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "training_modules" (
+    "id" integer primary key autoincrement,
+    "user_id" integer,
+    "name" varchar(255),
+    "description" text,
+    "steps" text,
+    "questions" text,
+    "pass_score" integer DEFAULT 0,
+    "due_days" integer DEFAULT 0,
+    "modified_date" datetime);
+CREATE TABLE IF NOT EXISTS "training_assignments" (
+    "id" integer primary key autoincrement,
+    "user_id" integer,
+    "campaign_id" integer,
+    "module_id" integer,
+    "r_id" varchar(255),
+    "email" varchar(255),
+    "first_name" varchar(255),
+    "last_name" varchar(255),
+    "assigned_date" datetime,
+    "due_date" datetime,
+    "started_date" datetime,
+    "completed_date" datetime,
+    "score" integer DEFAULT 0,
+    "attempts" integer DEFAULT 0);
+CREATE UNIQUE INDEX training_assignments_r_id ON training_assignments(r_id);
+ALTER TABLE campaigns ADD COLUMN training_module_id INTEGER DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN training_assign_on VARCHAR(255) DEFAULT '';
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+DROP TABLE "training_assignments";
+DROP TABLE "training_modules";
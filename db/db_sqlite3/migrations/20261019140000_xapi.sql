@@ -0,0 +1,25 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "learning_record_stores" (
+    "id" integer primary key autoincrement,
+    "name" varchar(255),
+    "url" varchar(1000),
+    "username" varchar(255),
+    "password" varchar(255),
+    "activity_iri" varchar(1000),
+    "is_active" boolean default 0);
+CREATE TABLE IF NOT EXISTS "xapi_statements" (
+    "id" integer primary key autoincrement,
+    "lrs_id" integer,
+    "statement_id" varchar(255),
+    "statement" text,
+    "attempts" integer DEFAULT 0,
+    "next_attempt" datetime,
+    "last_error" text,
+    "created_date" datetime);
+CREATE INDEX xapi_statements_lrs_id_next_attempt ON xapi_statements(lrs_id, next_attempt);
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+DROP TABLE "xapi_statements";
+DROP TABLE "learning_record_stores";
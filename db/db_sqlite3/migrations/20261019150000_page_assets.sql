@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "page_assets" (
+    "id" integer primary key autoincrement,
+    "page_id" integer,
+    "path" varchar(1000),
+    "content_type" varchar(255),
+    "size" integer DEFAULT 0,
+    "content" blob,
+    "modified_date" datetime);
+CREATE INDEX page_assets_page_id ON page_assets(page_id);
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+DROP TABLE "page_assets";
@@ -0,0 +1,12 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "phishing_hosts" (
+    "id" integer primary key autoincrement,
+    "hostname" varchar(255),
+    "paths" text,
+    "modified_date" datetime);
+CREATE UNIQUE INDEX phishing_hosts_hostname ON phishing_hosts(hostname);
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+DROP TABLE "phishing_hosts";
@@ -0,0 +1,195 @@
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/textproto"
+	"strings"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
+	"github.com/emersion/go-message"
+	"github.com/jinzhu/gorm"
+)
+
+// bounce is a failed delivery reported in an RFC 3464 delivery status
+// notification, along with the identifiers of the original email
+type bounce struct {
+	Recipient  string
+	Status     string
+	Diagnostic string
+	MessageID  string
+	RIDs       map[string]bool
+}
+
+// parseBounce parses a delivery status notification. If the message isn't a
+// delivery status notification, or doesn't report a failed delivery, nil is
+// returned.
+func parseBounce(raw []byte) (*bounce, error) {
+	e, err := message.Read(bytes.NewReader(raw))
+	if err != nil && !message.IsUnknownCharset(err) {
+		return nil, err
+	}
+	mediaType, params, err := e.Header.ContentType()
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "delivery-status") {
+		return nil, nil
+	}
+	mr := e.MultipartReader()
+	if mr == nil {
+		return nil, nil
+	}
+	b := &bounce{RIDs: make(map[string]bool)}
+	failed := false
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !message.IsUnknownCharset(err) {
+			return nil, err
+		}
+		ct, _, _ := p.Header.ContentType()
+		switch ct {
+		case "message/delivery-status", "message/global-delivery-status":
+			failed, err = b.parseStatus(p.Body)
+		case "message/rfc822", "message/global", "text/rfc822-headers", "message/global-headers":
+			err = b.parseOriginal(p.Body)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !failed {
+		return nil, nil
+	}
+	return b, nil
+}
+
+// parseStatus reads the per-recipient fields of a delivery status report,
+// returning true if delivery to a recipient failed
+func (b *bounce) parseStatus(r io.Reader) (bool, error) {
+	tp := textproto.NewReader(bufio.NewReader(r))
+	for {
+		// The first group of fields describes the message, and each
+		// following group describes a recipient
+		fields, err := tp.ReadMIMEHeader()
+		if len(fields) > 0 && strings.EqualFold(fields.Get("Action"), "failed") {
+			b.Recipient = dsnValue(fields.Get("Final-Recipient"))
+			if b.Recipient == "" {
+				b.Recipient = dsnValue(fields.Get("Original-Recipient"))
+			}
+			b.Status = fields.Get("Status")
+			b.Diagnostic = dsnValue(fields.Get("Diagnostic-Code"))
+			return true, nil
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// parseOriginal extracts the Message-Id and any rids from the original
+// message (or its headers) returned with the notification
+func (b *bounce) parseOriginal(r io.Reader) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(content)))
+	headers, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	b.MessageID = strings.TrimSpace(headers.Get("Message-Id"))
+	for _, r := range trust_strikeRegex.FindAllStringSubmatch(string(content), -1) {
+		b.RIDs[r[len(r)-1]] = true
+	}
+	return nil
+}
+
+// dsnValue strips the type from a typed DSN field, e.g. "rfc822; a@b.com"
+func dsnValue(field string) string {
+	if i := strings.Index(field, ";"); i != -1 {
+		field = field[i+1:]
+	}
+	return strings.TrimSpace(field)
+}
+
+// getBounceResult finds the campaign result a bounce refers to, first by the
+// Message-Id of the original email and then by any rids it contained.
+func getBounceResult(b *bounce) (models.Result, error) {
+	if b.MessageID != "" {
+		result, err := models.GetResultByMessageId(b.MessageID)
+		if err != gorm.ErrRecordNotFound {
+			return result, err
+		}
+	}
+	for rid := range b.RIDs {
+		result, err := models.GetResult(rid)
+		if err != gorm.ErrRecordNotFound {
+			return result, err
+		}
+	}
+	return models.Result{}, gorm.ErrRecordNotFound
+}
+
+// checkBounce records a bounced event if the email is a delivery status
+// notification for a campaign email. It returns true if the email is a
+// delivery status notification, so that it isn't treated as a report.
+func checkBounce(m Email) (bool, error) {
+	b, err := parseBounce(m.Raw)
+	if err != nil {
+		// Malformed emails may still be reports, so they're left for the
+		// usual rid matching
+		log.Debugf("Unable to parse email with subject '%s' as a bounce: %s", m.Email.Subject, err)
+		return false, nil
+	}
+	if b == nil {
+		return false, nil
+	}
+	result, err := getBounceResult(b)
+	if err == gorm.ErrRecordNotFound {
+		log.Infof("Received bounce for %s with subject '%s'. This is not a trust_strike campaign email.", b.Recipient, m.Email.Subject)
+		return true, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	if result.Status == models.EventBounced {
+		return true, nil
+	}
+	log.Infof("Email to %s with rid %s bounced: %s %s", result.Email, result.RId, b.Status, b.Diagnostic)
+	return true, result.HandleEmailBounce(models.EventBounce{
+		Recipient:  b.Recipient,
+		Status:     b.Status,
+		Diagnostic: b.Diagnostic,
+	})
+}
+
+// checkForBounces checks the unread emails in the mailbox's folder for
+// delivery status notifications
+func checkForBounces(mailServer Mailbox) {
+	msgs, err := mailServer.GetUnread(true, false)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	var failed []uint32
+	for _, m := range msgs {
+		_, err := checkBounce(m)
+		if err != nil {
+			log.Error("Error processing bounce: ", err.Error())
+			failed = append(failed, m.SeqNum)
+		}
+	}
+	if len(failed) > 0 {
+		err := mailServer.MarkAsUnread(failed)
+		if err != nil {
+			log.Error("Unable to mark emails as unread: ", err.Error())
+		}
+	}
+}
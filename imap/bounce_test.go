@@ -0,0 +1,93 @@
+package imap
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDSN = `From: Mail Delivery System <MAILER-DAEMON@mx.example.com>
+To: sender@example.com
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="BOUNDARY"
+
+--BOUNDARY
+Content-Type: text/plain
+
+This is the mail system at host mx.example.com.
+Your message could not be delivered to one or more recipients.
+
+--BOUNDARY
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.com
+Arrival-Date: Mon, 19 Oct 2026 10:00:00 +0000
+
+Final-Recipient: rfc822; target@example.com
+Original-Recipient: rfc822;target@example.com
+Action: failed
+Status: 5.1.1
+Diagnostic-Code: smtp; 550 5.1.1 <target@example.com>: User unknown
+
+--BOUNDARY
+Content-Type: message/rfc822
+
+From: sender@example.com
+To: target@example.com
+Subject: Please reset your password
+Message-Id: <1444789264909237300.3464.1819418242800517193@example.com>
+Content-Type: text/html
+
+<a href="https://phish.example.com/?rid=AbC1234">Reset</a>
+
+--BOUNDARY--
+`
+
+func TestParseBounce(t *testing.T) {
+	b, err := parseBounce([]byte(testDSN))
+	if err != nil {
+		t.Fatalf("unexpected error parsing bounce: %v", err)
+	}
+	if b == nil {
+		t.Fatal("expected a bounce, got nil")
+	}
+	if b.Recipient != "target@example.com" {
+		t.Fatalf("unexpected recipient. expected %s got %s", "target@example.com", b.Recipient)
+	}
+	if b.Status != "5.1.1" {
+		t.Fatalf("unexpected status. expected %s got %s", "5.1.1", b.Status)
+	}
+	expectedDiagnostic := "550 5.1.1 <target@example.com>: User unknown"
+	if b.Diagnostic != expectedDiagnostic {
+		t.Fatalf("unexpected diagnostic. expected %s got %s", expectedDiagnostic, b.Diagnostic)
+	}
+	expectedID := "<1444789264909237300.3464.1819418242800517193@example.com>"
+	if b.MessageID != expectedID {
+		t.Fatalf("unexpected message id. expected %s got %s", expectedID, b.MessageID)
+	}
+	if !b.RIDs["AbC1234"] || len(b.RIDs) != 1 {
+		t.Fatalf("unexpected rids. expected AbC1234 got %v", b.RIDs)
+	}
+}
+
+func TestParseBounceDelayed(t *testing.T) {
+	delayed := strings.Replace(testDSN, "Action: failed", "Action: delayed", 1)
+	b, err := parseBounce([]byte(delayed))
+	if err != nil {
+		t.Fatalf("unexpected error parsing bounce: %v", err)
+	}
+	if b != nil {
+		t.Fatalf("expected delayed delivery to be ignored, got %v", b)
+	}
+}
+
+func TestParseBounceNotDSN(t *testing.T) {
+	report := "From: user@example.com\nSubject: Phishing?\nContent-Type: text/plain\n\nhttps://phish.example.com/?rid=AbC1234\n"
+	b, err := parseBounce([]byte(report))
+	if err != nil {
+		t.Fatalf("unexpected error parsing bounce: %v", err)
+	}
+	if b != nil {
+		t.Fatalf("expected a report not to be treated as a bounce, got %v", b)
+	}
+}
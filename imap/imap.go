@@ -31,6 +31,9 @@ type Client interface {
 type Email struct {
 	SeqNum uint32 `json:"seqnum"`
 	*email.Email
+	// Raw is the unparsed message, which is needed for MIME parts that
+	// email.Email discards, such as delivery status reports
+	Raw []byte `json:"-"`
 }
 
 // Mailbox holds onto the credentials and other information
@@ -174,25 +177,27 @@ func (mbox *Mailbox) GetUnread(markAsRead, delete bool) ([]Email, error) {
 			return emails, err
 		}
 
-		emtmp := Email{Email: em, SeqNum: msg.SeqNum} // Not sure why msg.Uid is always 0, so swapped to sequence numbers
+		emtmp := Email{Email: em, SeqNum: msg.SeqNum, Raw: buf} // Not sure why msg.Uid is always 0, so swapped to sequence numbers
 		emails = append(emails, emtmp)
 
 	}
 	return emails, nil
 }
 
-// newClient will initiate a new IMAP connection with the given creds.
-func (mbox *Mailbox) newClient() (*client.Client, error) {
-	var imapClient *client.Client
-	var err error
+// dial opens a new IMAP connection to the server, without logging in.
+func (mbox *Mailbox) dial() (*client.Client, error) {
 	restrictedDialer := dialer.Dialer()
 	if mbox.TLS {
 		config := new(tls.Config)
 		config.InsecureSkipVerify = mbox.IgnoreCertErrors
-		imapClient, err = client.DialWithDialerTLS(restrictedDialer, mbox.Host, config)
-	} else {
-		imapClient, err = client.DialWithDialer(restrictedDialer, mbox.Host)
+		return client.DialWithDialerTLS(restrictedDialer, mbox.Host, config)
 	}
+	return client.DialWithDialer(restrictedDialer, mbox.Host)
+}
+
+// newClient will initiate a new IMAP connection with the given creds.
+func (mbox *Mailbox) newClient() (*client.Client, error) {
+	imapClient, err := mbox.dial()
 	if err != nil {
 		return imapClient, err
 	}
@@ -0,0 +1,132 @@
+package imap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"time"
+
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/mailer"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
+	"github.com/emersion/go-sasl"
+)
+
+// DefaultInjectionFolder is the folder messages are placed in when the
+// sending profile doesn't specify one
+const DefaultInjectionFolder = "INBOX"
+
+func init() {
+	models.NewMailboxInjectionDialer = NewInjectionDialer
+}
+
+// InjectionDialer is a mailer.Dialer which places messages directly into
+// the recipients' mailboxes using IMAP APPEND. It logs in with the sending
+// profile's admin credentials, using the SASL PLAIN authorization identity
+// to act on behalf of each recipient (e.g. Dovecot master users or
+// Exchange impersonation).
+type InjectionDialer struct {
+	Mailbox
+}
+
+// NewInjectionDialer returns an InjectionDialer for the given sending profile
+func NewInjectionDialer(s *models.SMTP) (mailer.Dialer, error) {
+	host := s.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		port := "143"
+		if s.MailboxTLS {
+			port = "993"
+		}
+		host = net.JoinHostPort(host, port)
+	}
+	folder := s.MailboxFolder
+	if folder == "" {
+		folder = DefaultInjectionFolder
+	}
+	return &InjectionDialer{
+		Mailbox: Mailbox{
+			Host:             host,
+			TLS:              s.MailboxTLS,
+			IgnoreCertErrors: s.IgnoreCertErrors,
+			User:             s.Username,
+			Pwd:              s.Password,
+			Folder:           folder,
+		},
+	}, nil
+}
+
+// Dial verifies the admin credentials and returns a Sender which injects
+// messages into the recipients' mailboxes.
+func (d *InjectionDialer) Dial() (mailer.Sender, error) {
+	imapClient, err := d.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer imapClient.Logout()
+	err = imapClient.Login(d.User, d.Pwd)
+	if err != nil {
+		return nil, err
+	}
+	return &injectionSender{mbox: d.Mailbox}, nil
+}
+
+// injectionSender appends messages to the recipients' mailboxes. Since the
+// authorization identity changes with each recipient, a new connection is
+// made for every message.
+type injectionSender struct {
+	mbox Mailbox
+}
+
+// Send places the message in the mailbox of each recipient
+func (s *injectionSender) Send(from string, to []string, msg io.WriterTo) error {
+	buf := &bytes.Buffer{}
+	_, err := msg.WriteTo(buf)
+	if err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		err = s.mbox.inject(rcpt, buf.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close is a no-op since connections are closed after each message
+func (s *injectionSender) Close() error {
+	return nil
+}
+
+// Reset is a no-op since connections are closed after each message
+func (s *injectionSender) Reset() error {
+	return nil
+}
+
+// inject appends the message to the folder in the recipient's mailbox,
+// leaving it unread.
+func (mbox *Mailbox) inject(recipient string, msg []byte) error {
+	imapClient, err := mbox.dial()
+	if err != nil {
+		return err
+	}
+	defer imapClient.Logout()
+	// If the server refuses to let us act on behalf of the recipient, it's
+	// likely that the mailbox doesn't exist, so there's no point in retrying.
+	err = imapClient.Authenticate(sasl.NewPlainClient(recipient, mbox.User, mbox.Pwd))
+	if err != nil {
+		return &textproto.Error{
+			Code: 550,
+			Msg:  fmt.Sprintf("unable to access mailbox for %s: %s", recipient, err),
+		}
+	}
+	err = imapClient.Append(mbox.Folder, nil, time.Now(), bytes.NewBuffer(msg))
+	if err != nil {
+		return &textproto.Error{
+			Code: 451,
+			Msg:  fmt.Sprintf("unable to append message for %s: %s", recipient, err),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package imap
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/textproto"
+	"testing"
+
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/server"
+	"github.com/emersion/go-sasl"
+)
+
+const testRecipient = "target@example.com"
+
+// newTestServer starts an in-memory IMAP server which allows the admin user
+// ("username") to act on behalf of testRecipient.
+func newTestServer(t *testing.T) (string, backend.User, func()) {
+	be := memory.New()
+	user, err := be.Login(nil, "username", "password")
+	if err != nil {
+		t.Fatalf("error logging in to the memory backend: %v", err)
+	}
+	s := server.New(be)
+	s.AllowInsecureAuth = true
+	s.EnableAuth(sasl.Plain, func(conn server.Conn) sasl.Server {
+		return sasl.NewPlainServer(func(identity, username, password string) error {
+			if username != "username" || password != "password" {
+				return errors.New("Bad username or password")
+			}
+			if identity != testRecipient {
+				return errors.New("Unknown mailbox")
+			}
+			ctx := conn.Context()
+			ctx.State = imap.AuthenticatedState
+			ctx.User = user
+			return nil
+		})
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error starting listener: %v", err)
+	}
+	go s.Serve(l)
+	return l.Addr().String(), user, func() { s.Close() }
+}
+
+func inboxCount(t *testing.T, user backend.User) uint32 {
+	mbox, err := user.GetMailbox(DefaultInjectionFolder)
+	if err != nil {
+		t.Fatalf("error getting inbox: %v", err)
+	}
+	status, err := mbox.Status([]imap.StatusItem{imap.StatusMessages})
+	if err != nil {
+		t.Fatalf("error getting inbox status: %v", err)
+	}
+	return status.Messages
+}
+
+func TestInjectionDialer(t *testing.T) {
+	host, user, closeServer := newTestServer(t)
+	defer closeServer()
+
+	d, err := NewInjectionDialer(&models.SMTP{
+		Interface: models.InterfaceIMAP,
+		Host:      host,
+		Username:  "username",
+		Password:  "password",
+	})
+	if err != nil {
+		t.Fatalf("error creating dialer: %v", err)
+	}
+	sender, err := d.Dial()
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	before := inboxCount(t, user)
+	msg := "From: sender@example.com\r\nTo: target@example.com\r\nSubject: Test\r\n\r\nHello"
+	err = sender.Send("sender@example.com", []string{testRecipient}, bytes.NewBufferString(msg))
+	if err != nil {
+		t.Fatalf("error injecting message: %v", err)
+	}
+	if got := inboxCount(t, user); got != before+1 {
+		t.Fatalf("Unexpected number of messages in inbox. Expected %d Got %d", before+1, got)
+	}
+
+	// Recipients we can't act on behalf of should be permanent errors
+	err = sender.Send("sender@example.com", []string{"unknown@example.com"}, bytes.NewBufferString(msg))
+	te, ok := err.(*textproto.Error)
+	if !ok || te.Code != 550 {
+		t.Fatalf("Expected a permanent error for an unknown mailbox. Got %#v", err)
+	}
+}
+
+func TestInjectionDialerInvalidCredentials(t *testing.T) {
+	host, _, closeServer := newTestServer(t)
+	defer closeServer()
+
+	d, err := NewInjectionDialer(&models.SMTP{
+		Host:     host,
+		Username: "username",
+		Password: "wrong",
+	})
+	if err != nil {
+		t.Fatalf("error creating dialer: %v", err)
+	}
+	_, err = d.Dial()
+	if err == nil {
+		t.Fatalf("Expected an error when dialing with invalid credentials")
+	}
+}
+
+func TestNewInjectionDialerDefaults(t *testing.T) {
+	d, err := NewInjectionDialer(&models.SMTP{Host: "mail.example.com", MailboxTLS: true})
+	if err != nil {
+		t.Fatalf("error creating dialer: %v", err)
+	}
+	mbox := d.(*InjectionDialer).Mailbox
+	if mbox.Host != "mail.example.com:993" {
+		t.Fatalf("Unexpected host. Expected mail.example.com:993 Got %s", mbox.Host)
+	}
+	if mbox.Folder != DefaultInjectionFolder {
+		t.Fatalf("Unexpected folder. Expected %s Got %s", DefaultInjectionFolder, mbox.Folder)
+	}
+}
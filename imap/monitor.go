@@ -117,7 +117,7 @@ func (im *Monitor) Shutdown() error {
 
 // checkForNewEmails logs into an IMAP account and checks unread emails
 //
-//	for the rid campaign identifier.
+//	for the rid campaign identifier and for bounced campaign emails.
 func checkForNewEmails(im models.IMAP) {
 	im.Host = im.Host + ":" + strconv.Itoa(int(im.Port)) // Append port
 	mailServer := Mailbox{
@@ -142,6 +142,17 @@ func checkForNewEmails(im models.IMAP) {
 		var reportingFailed []uint32 // SeqNums of emails that were unable to be reported to phishing server, mark as unread
 		var deleteEmails []uint32    // SeqNums of campaign emails. If DeleteReportedCampaignEmail is true, we will delete these
 		for _, m := range msgs {
+			// Delivery status notifications come from the recipient's mail
+			// server rather than a user, so check for them first
+			isBounce, err := checkBounce(m)
+			if err != nil {
+				log.Error("Error processing bounce: ", err.Error())
+				reportingFailed = append(reportingFailed, m.SeqNum)
+				continue
+			}
+			if isBounce {
+				continue
+			}
 			// Check if sender is from company's domain, if enabled. TODO: Make this an IMAP filter
 			if im.RestrictDomain != "" { // e.g domainResitct = widgets.com
 				splitEmail := strings.Split(m.Email.From, "@")
@@ -201,6 +212,13 @@ func checkForNewEmails(im models.IMAP) {
 	} else {
 		log.Debug("No new emails for ", im.Username)
 	}
+
+	// Bounces may also be delivered to, or filtered into, a separate folder
+	if im.BounceFolder != "" && im.BounceFolder != im.Folder {
+		bounceServer := mailServer
+		bounceServer.Folder = im.BounceFolder
+		checkForBounces(bounceServer)
+	}
 }
 
 func checkRIDs(em *email.Email, rids map[string]bool) {
@@ -0,0 +1,114 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+// ErrInvalidDKIMKey is thrown when a DKIM private key can't be parsed or
+// uses an unsupported algorithm
+var ErrInvalidDKIMKey = errors.New("Invalid DKIM private key. Keys must be PEM encoded RSA or Ed25519 keys")
+
+// dkimHeaderKeys are the headers included in the DKIM signature, as
+// recommended by RFC 6376 section 5.4.1
+var dkimHeaderKeys = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-Id",
+	"Content-Type", "Content-Transfer-Encoding", "Mime-Version",
+}
+
+// Signer signs messages before they are sent
+type Signer interface {
+	Sign(msg []byte) ([]byte, error)
+}
+
+// SigningDialer is a Dialer for a sending profile which signs messages
+// before they are sent. Signer returns nil if messages shouldn't be signed.
+type SigningDialer interface {
+	Dialer
+	Signer() Signer
+}
+
+// DKIMSigner signs messages with a DKIM-Signature header
+type DKIMSigner struct {
+	Domain   string
+	Selector string
+	Key      crypto.Signer
+}
+
+// NewDKIMSigner returns a DKIMSigner using the PEM encoded private key
+func NewDKIMSigner(domain, selector, privateKey string) (*DKIMSigner, error) {
+	key, err := ParseDKIMPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &DKIMSigner{
+		Domain:   domain,
+		Selector: selector,
+		Key:      key,
+	}, nil
+}
+
+// Sign returns the message with a DKIM-Signature header prepended
+func (s *DKIMSigner) Sign(msg []byte) ([]byte, error) {
+	signed := &bytes.Buffer{}
+	err := dkim.Sign(signed, bytes.NewReader(msg), &dkim.SignOptions{
+		Domain:                 s.Domain,
+		Selector:               s.Selector,
+		Signer:                 s.Key,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+		HeaderKeys:             dkimHeaderKeys,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signed.Bytes(), nil
+}
+
+// ParseDKIMPrivateKey parses a PEM encoded RSA (PKCS #1 or PKCS #8) or
+// Ed25519 (PKCS #8) private key
+func ParseDKIMPrivateKey(privateKey string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return nil, ErrInvalidDKIMKey
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidDKIMKey
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	}
+	return nil, ErrInvalidDKIMKey
+}
+
+// DKIMRecord returns the DNS TXT record value that publishes the public key
+// for the provided private key
+func DKIMRecord(key crypto.Signer) (string, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("v=DKIM1; k=rsa; p=%s", base64.StdEncoding.EncodeToString(der)), nil
+	case ed25519.PublicKey:
+		return fmt.Sprintf("v=DKIM1; k=ed25519; p=%s", base64.StdEncoding.EncodeToString(pub)), nil
+	}
+	return "", ErrInvalidDKIMKey
+}
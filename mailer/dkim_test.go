@@ -0,0 +1,126 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+func generateRSAKeyPEM(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+}
+
+// verifyDKIM verifies the message's DKIM signature using the public key
+// for the signer
+func verifyDKIM(t *testing.T, signer *DKIMSigner, msg []byte) {
+	record, err := DKIMRecord(signer.Key)
+	if err != nil {
+		t.Fatalf("error generating DKIM record: %v", err)
+	}
+	verifications, err := dkim.VerifyWithOptions(bytes.NewReader(msg), &dkim.VerifyOptions{
+		LookupTXT: func(domain string) ([]string, error) {
+			return []string{record}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("error verifying message: %v", err)
+	}
+	if len(verifications) != 1 {
+		t.Fatalf("Unexpected number of signatures. Expected 1 Got %d", len(verifications))
+	}
+	if verifications[0].Err != nil {
+		t.Fatalf("Invalid DKIM signature: %v", verifications[0].Err)
+	}
+	if verifications[0].Domain != signer.Domain {
+		t.Fatalf("Unexpected signing domain. Expected %s Got %s", signer.Domain, verifications[0].Domain)
+	}
+}
+
+func TestDKIMSigner(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("error marshaling key: %v", err)
+	}
+	edPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	msg := []byte("From: sender@example.com\r\nTo: to@example.com\r\nSubject: Test\r\n\r\nHello\r\n")
+	for _, key := range []string{generateRSAKeyPEM(t), edPEM} {
+		signer, err := NewDKIMSigner("example.com", "sim", key)
+		if err != nil {
+			t.Fatalf("error creating signer: %v", err)
+		}
+		signed, err := signer.Sign(msg)
+		if err != nil {
+			t.Fatalf("error signing message: %v", err)
+		}
+		verifyDKIM(t, signer, signed)
+	}
+}
+
+func TestParseDKIMPrivateKeyInvalid(t *testing.T) {
+	_, err := ParseDKIMPrivateKey("not a key")
+	if err != ErrInvalidDKIMKey {
+		t.Fatalf("Expected ErrInvalidDKIMKey. Got %v", err)
+	}
+}
+
+// mockSigningDialer is a mockDialer for a sending profile which signs
+// messages
+type mockSigningDialer struct {
+	*mockDialer
+	signer Signer
+}
+
+func (md *mockSigningDialer) Signer() Signer {
+	return md.signer
+}
+
+func TestSendMailSigned(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signer, err := NewDKIMSigner("example.com", "sim", generateRSAKeyPEM(t))
+	if err != nil {
+		t.Fatalf("error creating signer: %v", err)
+	}
+	dialer := &mockSigningDialer{mockDialer: newMockDialer(), signer: signer}
+	var sent [][]byte
+	dialer.setDial(func() (Sender, error) {
+		sender := newMockSender()
+		sender.setSend(func(mm *mockMessage) error {
+			sent = append(sent, mm.message)
+			return nil
+		})
+		return sender, nil
+	})
+	to := []string{"to@example.com"}
+	m := newMockMessage("sender@example.com", to, bytes.NewBufferString("Hello"))
+
+	sendMail(ctx, dialer, []Mail{m}, nil)
+
+	if len(sent) != 1 {
+		t.Fatalf("Unexpected number of messages sent. Expected 1 Got %d", len(sent))
+	}
+	if !bytes.HasPrefix(sent[0], []byte("DKIM-Signature:")) {
+		t.Fatalf("Expected the message to be signed. Got %q", sent[0])
+	}
+	verifyDKIM(t, signer, sent[0])
+}
@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// DefaultGraphEndpoint is the base URL of the Microsoft Graph API
+const DefaultGraphEndpoint = "https://graph.microsoft.com"
+
+// DefaultGraphTokenURL is the Microsoft identity platform token endpoint.
+// The tenant is substituted for the %s.
+const DefaultGraphTokenURL = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+
+// graphScope requests the application permissions granted to the client
+const graphScope = "https://graph.microsoft.com/.default"
+
+// GraphDialer is a Dialer which sends email using the Microsoft Graph
+// sendMail endpoint. It authenticates as an application registered in the
+// tenant using the OAuth2 client credentials flow, and requires the
+// Mail.Send application permission.
+type GraphDialer struct {
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// Endpoint and TokenURL may be overridden for testing. If empty, the
+	// Microsoft Graph defaults are used.
+	Endpoint string
+	TokenURL string
+}
+
+// Dial authenticates with the Microsoft identity platform and returns a
+// Sender for the Graph API.
+func (d *GraphDialer) Dial() (Sender, error) {
+	tokenURL := d.TokenURL
+	if tokenURL == "" {
+		tokenURL = fmt.Sprintf(DefaultGraphTokenURL, url.PathEscape(d.TenantID))
+	}
+	endpoint := d.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultGraphEndpoint
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	conf := &clientcredentials.Config{
+		ClientID:     d.ClientID,
+		ClientSecret: d.ClientSecret,
+		TokenURL:     tokenURL,
+		Scopes:       []string{graphScope},
+	}
+	ctx := context.Background()
+	s, err := newAPISender(ctx, conf.TokenSource(ctx))
+	if err != nil {
+		return nil, err
+	}
+	s.sentStatus = http.StatusAccepted
+	s.newRequest = func(from string, msg []byte) (*http.Request, error) {
+		// Graph accepts a base64 encoded MIME message in place of the
+		// usual JSON message resource
+		body := base64.StdEncoding.EncodeToString(msg)
+		u := fmt.Sprintf("%s/v1.0/users/%s/sendMail", endpoint, url.PathEscape(from))
+		req, err := http.NewRequest(http.MethodPost, u, bytes.NewBufferString(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "text/plain")
+		return req, nil
+	}
+	return s, nil
+}
@@ -0,0 +1,88 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+
+	"golang.org/x/oauth2"
+)
+
+// maxAPIErrorLength is the maximum number of bytes of an API error response
+// that are included in the returned error.
+const maxAPIErrorLength = 512
+
+// apiSender is a Sender which delivers messages by making authenticated
+// requests to an HTTP API, such as Microsoft Graph or Gmail.
+type apiSender struct {
+	client *http.Client
+	// newRequest builds the request used to send the provided MIME message
+	// on behalf of the given sender.
+	newRequest func(from string, msg []byte) (*http.Request, error)
+	// sentStatus is the status code the API returns for a sent message.
+	sentStatus int
+}
+
+// newAPISender fetches a token to make sure the provided credentials are
+// valid, returning a Sender that authenticates requests using the token.
+func newAPISender(ctx context.Context, ts oauth2.TokenSource) (*apiSender, error) {
+	ts = oauth2.ReuseTokenSource(nil, ts)
+	_, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return &apiSender{
+		client: oauth2.NewClient(ctx, ts),
+	}, nil
+}
+
+// Send delivers the MIME message through the API. The recipients are taken
+// from the message headers, so the to argument is unused.
+func (s *apiSender) Send(from string, to []string, msg io.WriterTo) error {
+	buf := &bytes.Buffer{}
+	_, err := msg.WriteTo(buf)
+	if err != nil {
+		return err
+	}
+	req, err := s.newRequest(from, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == s.sentStatus {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxAPIErrorLength))
+	return apiError(resp.StatusCode, body)
+}
+
+// Close is a no-op since API requests don't hold a connection open
+func (s *apiSender) Close() error {
+	return nil
+}
+
+// Reset is a no-op since API requests don't hold a connection open
+func (s *apiSender) Reset() error {
+	return nil
+}
+
+// apiError converts an unsuccessful API response to the equivalent SMTP
+// error, so that throttling and server errors are retried like temporary
+// SMTP failures while rejected messages are not.
+func apiError(status int, body []byte) error {
+	code := 550
+	if status == http.StatusTooManyRequests || status >= 500 {
+		code = 451
+	}
+	return &textproto.Error{
+		Code: code,
+		Msg:  fmt.Sprintf("API returned %d %s: %s", status, http.StatusText(status), bytes.TrimSpace(body)),
+	}
+}
@@ -0,0 +1,203 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+const mockAccessToken = "mock-access-token"
+
+// mockAPIServer emulates the token and send endpoints of an email API,
+// recording the messages it receives.
+type mockAPIServer struct {
+	*httptest.Server
+	// sendPath is the path messages are sent to
+	sendPath string
+	// status is the status code returned by the send endpoint
+	status int
+	// decode extracts the MIME message from a send request body
+	decode   func(body []byte) ([]byte, error)
+	tokens   int
+	messages [][]byte
+	// rejectToken causes the token endpoint to reject the credentials
+	rejectToken bool
+}
+
+func newMockAPIServer(t *testing.T, sendPath string, status int, decode func([]byte) ([]byte, error)) *mockAPIServer {
+	ms := &mockAPIServer{
+		sendPath: sendPath,
+		status:   status,
+		decode:   decode,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if ms.rejectToken {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		ms.tokens++
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": mockAccessToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != ms.sendPath {
+			t.Errorf("Unexpected request path. Expected %s Got %s", ms.sendPath, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer "+mockAccessToken {
+			t.Errorf("Unexpected authorization header %q", got)
+		}
+		body, _ := io.ReadAll(r.Body)
+		msg, err := ms.decode(body)
+		if err != nil {
+			t.Errorf("Error decoding message: %v", err)
+		}
+		ms.messages = append(ms.messages, msg)
+		w.WriteHeader(ms.status)
+		if ms.status >= 400 {
+			w.Write([]byte(`{"error":{"code":"ErrorMessage"}}`))
+		}
+	})
+	ms.Server = httptest.NewServer(mux)
+	return ms
+}
+
+func TestGraphDialer(t *testing.T) {
+	server := newMockAPIServer(t, "/v1.0/users/sender@example.com/sendMail", http.StatusAccepted, func(body []byte) ([]byte, error) {
+		return base64.StdEncoding.DecodeString(string(body))
+	})
+	defer server.Close()
+
+	dialer := &GraphDialer{
+		TenantID:     "tenant",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint:     server.URL,
+		TokenURL:     server.URL + "/token",
+	}
+	sender, err := dialer.Dial()
+	if err != nil {
+		t.Fatalf("Unexpected error dialing: %v", err)
+	}
+	msg := "Subject: Test\r\n\r\nHello"
+	for i := 0; i < 2; i++ {
+		err = sender.Send("sender@example.com", []string{"to@example.com"}, bytes.NewBufferString(msg))
+		if err != nil {
+			t.Fatalf("Unexpected error sending: %v", err)
+		}
+	}
+	if len(server.messages) != 2 || string(server.messages[0]) != msg {
+		t.Fatalf("Unexpected messages received: %q", server.messages)
+	}
+	// The token should be reused between messages
+	if server.tokens != 1 {
+		t.Fatalf("Unexpected number of token requests. Expected 1 Got %d", server.tokens)
+	}
+}
+
+func TestGraphDialerInvalidCredentials(t *testing.T) {
+	server := newMockAPIServer(t, "/", http.StatusAccepted, nil)
+	defer server.Close()
+	server.rejectToken = true
+	dialer := &GraphDialer{
+		ClientID: "client",
+		Endpoint: server.URL,
+		TokenURL: server.URL + "/token",
+	}
+	_, err := dialer.Dial()
+	if err == nil {
+		t.Fatalf("Expected an error when the credentials are rejected")
+	}
+}
+
+func TestAPISenderErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		code   int
+	}{
+		{status: http.StatusTooManyRequests, code: 451},
+		{status: http.StatusServiceUnavailable, code: 451},
+		{status: http.StatusBadRequest, code: 550},
+		{status: http.StatusForbidden, code: 550},
+	}
+	for _, test := range tests {
+		server := newMockAPIServer(t, "/v1.0/users/sender@example.com/sendMail", test.status, func(body []byte) ([]byte, error) {
+			return body, nil
+		})
+		dialer := &GraphDialer{
+			Endpoint: server.URL,
+			TokenURL: server.URL + "/token",
+		}
+		sender, err := dialer.Dial()
+		if err != nil {
+			t.Fatalf("Unexpected error dialing: %v", err)
+		}
+		err = sender.Send("sender@example.com", []string{"to@example.com"}, bytes.NewBufferString("Hello"))
+		te, ok := err.(*textproto.Error)
+		if !ok {
+			t.Fatalf("Expected a textproto.Error for status %d. Got %#v", test.status, err)
+		}
+		if te.Code != test.code {
+			t.Fatalf("Unexpected code for status %d. Expected %d Got %d", test.status, test.code, te.Code)
+		}
+		server.Close()
+	}
+}
+
+func TestGmailDialer(t *testing.T) {
+	server := newMockAPIServer(t, "/gmail/v1/users/me/messages/send", http.StatusOK, func(body []byte) ([]byte, error) {
+		m := gmailMessage{}
+		err := json.Unmarshal(body, &m)
+		if err != nil {
+			return nil, err
+		}
+		return base64.RawURLEncoding.DecodeString(m.Raw)
+	})
+	defer server.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	dialer := &GmailDialer{
+		ClientEmail: "sender@project.iam.gserviceaccount.com",
+		PrivateKey:  string(keyPEM),
+		Subject:     "sender@example.com",
+		Endpoint:    server.URL,
+		TokenURL:    server.URL + "/token",
+	}
+	sender, err := dialer.Dial()
+	if err != nil {
+		t.Fatalf("Unexpected error dialing: %v", err)
+	}
+	msg := "Subject: Test\r\n\r\nHello"
+	err = sender.Send("sender@example.com", []string{"to@example.com"}, bytes.NewBufferString(msg))
+	if err != nil {
+		t.Fatalf("Unexpected error sending: %v", err)
+	}
+	if len(server.messages) != 1 || !strings.Contains(string(server.messages[0]), "Hello") {
+		t.Fatalf("Unexpected messages received: %q", server.messages)
+	}
+}
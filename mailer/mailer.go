@@ -1,10 +1,12 @@
 package mailer
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net/textproto"
+	"time"
 
 	// Added by user instruction
 
@@ -74,18 +76,27 @@ type Mail interface {
 	GetTo() []string
 }
 
+// FailoverMail is Mail that can be sent through an alternate sending profile
+// when the server for its current profile can't be reached.
+type FailoverMail interface {
+	Mail
+	Failover(reason error) error
+}
+
 // MailWorker is the worker that receives slices of emails
 // on a channel to send. It's assumed that every slice of emails received is meant
 // to be sent to the same server.
 type MailWorker struct {
-	queue chan []Mail
+	queue     chan []Mail
+	throttles *throttles
 }
 
 // NewMailWorker returns an instance of MailWorker with the mail queue
 // initialized.
 func NewMailWorker() *MailWorker {
 	return &MailWorker{
-		queue: make(chan []Mail),
+		queue:     make(chan []Mail),
+		throttles: &throttles{},
 	}
 }
 
@@ -103,7 +114,7 @@ func (mw *MailWorker) Start(ctx context.Context) {
 					errorMail(err, ms)
 					return
 				}
-				sendMail(ctx, dialer, ms)
+				sendMail(ctx, dialer, ms, mw.throttles.get(dialer))
 			}(ctx, ms)
 		}
 	}
@@ -122,6 +133,20 @@ func errorMail(err error, ms []Mail) {
 	}
 }
 
+// failoverMail is a helper to move a slice of Mail instances to an alternate
+// sending profile after the server could not be reached. Mail without an
+// alternate profile is errored out.
+func failoverMail(err error, ms []Mail) {
+	for _, m := range ms {
+		if fm, ok := m.(FailoverMail); ok {
+			if ferr := fm.Failover(err); ferr == nil {
+				continue
+			}
+		}
+		m.Error(err)
+	}
+}
+
 // dialHost attempts to make a connection to the host specified by the Dialer.
 // It returns MaxReconnectAttempts if the number of connection attempts has been
 // exceeded.
@@ -151,17 +176,60 @@ func dialHost(ctx context.Context, dialer Dialer) (Sender, error) {
 	return sender, err
 }
 
+// signMessage renders the message and signs it using the provided Signer
+func signMessage(signer Signer, message *email.Email) (io.WriterTo, error) {
+	b, err := message.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	signed, err := signer.Sign(b)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(signed), nil
+}
+
+// backoffMail is a helper to defer a slice of Mail instances until the
+// sending profile's rate limit allows them to be sent.
+func backoffMail(err error, ms []Mail) {
+	for _, m := range ms {
+		m.Backoff(err)
+	}
+}
+
 // sendMail attempts to send the provided Mail instances.
 // If the context is cancelled before all of the mail are sent,
 // sendMail just returns and does not modify those emails.
-func sendMail(ctx context.Context, dialer Dialer, ms []Mail) {
+//
+// If a throttle is provided, sendMail respects the sending profile's limits,
+// deferring any mail that can't currently be sent rather than erroring it.
+func sendMail(ctx context.Context, dialer Dialer, ms []Mail, t *throttle) {
+	if t != nil {
+		release, ok := t.acquire(ctx)
+		if !ok {
+			return
+		}
+		defer release()
+	}
 	sender, err := dialHost(ctx, dialer)
 	if err != nil {
 		log.Warn(err)
-		errorMail(err, ms)
+		failoverMail(err, ms)
 		return
 	}
-	defer sender.Close()
+	// The sender may be replaced when we reconnect, so we make sure to
+	// close whichever one is in use when we're done.
+	defer func() {
+		if sender != nil {
+			sender.Close()
+		}
+	}()
+	// The number of messages sent over the current connection
+	connSent := 0
+	var signer Signer
+	if sd, ok := dialer.(SigningDialer); ok {
+		signer = sd.Signer()
+	}
 	message := email.NewEmail()
 	for i, m := range ms {
 		select {
@@ -188,7 +256,45 @@ func sendMail(ctx context.Context, dialer Dialer, ms []Mail) {
 			continue
 		}
 
-		err = sender.Send(smtp_from, m.GetTo(), &emailWriterTo{message})
+		var msg io.WriterTo = &emailWriterTo{message}
+		if signer != nil {
+			msg, err = signMessage(signer, message)
+			if err != nil {
+				log.Warn(err)
+				m.Error(err)
+				continue
+			}
+		}
+
+		if t != nil {
+			if wait := t.reserve(time.Now()); wait > 0 {
+				rlErr := &ErrRateLimited{RetryAfter: wait}
+				log.WithFields(logrus.Fields{
+					"retry_after": wait,
+					"remaining":   len(ms[i:]),
+				}).Info(rlErr)
+				backoffMail(rlErr, ms[i:])
+				return
+			}
+			// Some servers limit how many messages may be sent over a
+			// single connection, so we reconnect once we've reached it.
+			if limit := t.maxMessagesPerConnection(); limit > 0 && connSent >= limit {
+				sender.Close()
+				sender, err = dialHost(ctx, dialer)
+				if err != nil {
+					log.Warn(err)
+					failoverMail(err, ms[i:])
+					return
+				}
+				if sender == nil {
+					return
+				}
+				connSent = 0
+			}
+			connSent++
+		}
+
+		err = sender.Send(smtp_from, m.GetTo(), msg)
 		if err != nil {
 			if te, ok := err.(*textproto.Error); ok {
 				switch {
@@ -235,9 +341,10 @@ func sendMail(ctx context.Context, dialer Dialer, ms []Mail) {
 				origErr := err
 				sender, err = dialHost(ctx, dialer)
 				if err != nil {
-					errorMail(err, ms[i:])
+					failoverMail(err, ms[i:])
 					break
 				}
+				connSent = 0
 				m.Backoff(origErr)
 				continue
 			}
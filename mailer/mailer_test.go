@@ -279,3 +279,37 @@ func TestUnknownError(t *testing.T) {
 		t.Fatalf("Did not received expected error. Got %#v\nExpected %#v", message.err, expectedError)
 	}
 }
+
+func TestDialFailover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dialer := newMockDialer()
+	dialer.setDial(dialer.unreachableDial)
+	to := []string{"to@example.com"}
+	failover := &mockFailoverMessage{
+		mockMessage: newMockMessage("first@example.com", to, bytes.NewBufferString("First email")),
+		failover:    func(error) error { return nil },
+	}
+	exhausted := &mockFailoverMessage{
+		mockMessage: newMockMessage("second@example.com", to, bytes.NewBufferString("Second email")),
+		failover:    func(error) error { return errors.New("no profiles left") },
+	}
+	plain := newMockMessage("third@example.com", to, bytes.NewBufferString("Third email"))
+
+	sendMail(ctx, dialer, []Mail{failover, exhausted, plain}, nil)
+
+	// Mail that failed over should be left to be retried
+	if failover.failoverCount != 1 || failover.finished {
+		t.Fatalf("Expected the first message to fail over")
+	}
+	// Otherwise, the mail should be errored out
+	for i, m := range []*mockMessage{exhausted.mockMessage, plain} {
+		if !m.finished {
+			t.Fatalf("Expected message %d to be errored out", i+2)
+		}
+		if _, ok := m.err.(*ErrMaxConnectAttempts); !ok {
+			t.Fatalf("Expected ErrMaxConnectAttempts. Got %#v", m.err)
+		}
+	}
+}
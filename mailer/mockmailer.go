@@ -172,3 +172,41 @@ func (mm *mockMessage) Success() error {
 	mm.finished = true
 	return nil
 }
+
+// mockThrottledDialer is a mockDialer for a sending profile with limits
+type mockThrottledDialer struct {
+	*mockDialer
+	key    string
+	limits Limits
+}
+
+func newMockThrottledDialer(key string, limits Limits) *mockThrottledDialer {
+	return &mockThrottledDialer{
+		mockDialer: newMockDialer(),
+		key:        key,
+		limits:     limits,
+	}
+}
+
+// ThrottleKey returns the key identifying the mock sending profile
+func (md *mockThrottledDialer) ThrottleKey() string {
+	return md.key
+}
+
+// Limits returns the limits of the mock sending profile
+func (md *mockThrottledDialer) Limits() Limits {
+	return md.limits
+}
+
+// mockFailoverMessage is a mockMessage that can fail over to an alternate
+// sending profile
+type mockFailoverMessage struct {
+	*mockMessage
+	failoverCount int
+	failover      func(error) error
+}
+
+func (mm *mockFailoverMessage) Failover(reason error) error {
+	mm.failoverCount++
+	return mm.failover(reason)
+}
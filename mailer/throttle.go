@@ -0,0 +1,177 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limits describes how quickly mail may be delivered through a sending
+// profile. A zero value for any limit means that it is not enforced.
+type Limits struct {
+	MessagesPerMinute        int
+	MessagesPerHour          int
+	MaxConnections           int
+	MaxMessagesPerConnection int
+}
+
+// ThrottledDialer is a Dialer for a sending profile that restricts how
+// quickly messages may be sent. Mail sharing the same ThrottleKey share
+// the same limits, regardless of which campaign they belong to.
+type ThrottledDialer interface {
+	Dialer
+	ThrottleKey() string
+	Limits() Limits
+}
+
+// ErrRateLimited is passed to Mail.Backoff when a message could not be sent
+// because the sending profile's rate limit was reached. RetryAfter is the
+// earliest time after which the limit allows another message.
+type ErrRateLimited struct {
+	RetryAfter time.Duration
+}
+
+// Error returns the error message
+func (e *ErrRateLimited) Error() string {
+	return fmt.Sprintf("Sending profile rate limit reached - retrying in %s", e.RetryAfter.Round(time.Second))
+}
+
+// throttle tracks the messages sent and the connections opened for a single
+// sending profile.
+type throttle struct {
+	mu     sync.Mutex
+	limits Limits
+	// sent holds the times of the messages sent within the last hour,
+	// oldest first.
+	sent []time.Time
+	// conns is used as a semaphore to cap the number of open connections.
+	conns chan struct{}
+}
+
+// setLimits updates the limits enforced by the throttle, since a sending
+// profile may be modified while mail is being sent.
+func (t *throttle) setLimits(l Limits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limits = l
+	if l.MaxConnections > 0 && (t.conns == nil || cap(t.conns) != l.MaxConnections) {
+		t.conns = make(chan struct{}, l.MaxConnections)
+	}
+	if l.MaxConnections <= 0 {
+		t.conns = nil
+	}
+}
+
+// acquire blocks until a connection may be opened, returning a function
+// that releases it. It returns false if the context was cancelled.
+func (t *throttle) acquire(ctx context.Context) (func(), bool) {
+	t.mu.Lock()
+	conns := t.conns
+	t.mu.Unlock()
+	if conns == nil {
+		return func() {}, true
+	}
+	select {
+	case conns <- struct{}{}:
+		return func() { <-conns }, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// maxMessagesPerConnection returns the number of messages that may be sent
+// before reconnecting.
+func (t *throttle) maxMessagesPerConnection() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limits.MaxMessagesPerConnection
+}
+
+// reserve records a message as sent if the limits allow it. Otherwise, it
+// returns how long to wait before another message may be sent.
+func (t *throttle) reserve(now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Discard the sends that no longer count against any limit
+	cutoff := now.Add(-time.Hour)
+	i := 0
+	for i < len(t.sent) && !t.sent[i].After(cutoff) {
+		i++
+	}
+	t.sent = t.sent[i:]
+
+	var wait time.Duration
+	if w := t.windowWait(now, time.Minute, t.limits.MessagesPerMinute); w > wait {
+		wait = w
+	}
+	if w := t.windowWait(now, time.Hour, t.limits.MessagesPerHour); w > wait {
+		wait = w
+	}
+	if wait > 0 {
+		return wait
+	}
+	if t.limits.MessagesPerMinute > 0 || t.limits.MessagesPerHour > 0 {
+		t.sent = append(t.sent, now)
+	}
+	return 0
+}
+
+// windowWait returns how long until fewer than limit messages have been sent
+// within the trailing window.
+func (t *throttle) windowWait(now time.Time, window time.Duration, limit int) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	cutoff := now.Add(-window)
+	count := 0
+	for j := len(t.sent) - 1; j >= 0 && t.sent[j].After(cutoff); j-- {
+		count++
+	}
+	if count < limit {
+		return 0
+	}
+	// The oldest send that needs to leave the window before we can send
+	// another message.
+	oldest := t.sent[len(t.sent)-limit]
+	return oldest.Add(window).Sub(now)
+}
+
+// throttles holds the throttle for each sending profile in use.
+type throttles struct {
+	mu sync.Mutex
+	m  map[string]*throttle
+}
+
+// get returns the throttle for the provided dialer, or nil if the dialer
+// doesn't have any limits.
+func (ts *throttles) get(dialer Dialer) *throttle {
+	td, ok := dialer.(ThrottledDialer)
+	if !ok {
+		return nil
+	}
+	limits := td.Limits()
+	if limits == (Limits{}) {
+		return nil
+	}
+	key := td.ThrottleKey()
+	// Without a key, we have no way of sharing state between batches, so
+	// the limits only apply to this batch.
+	if key == "" {
+		t := &throttle{}
+		t.setLimits(limits)
+		return t
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.m == nil {
+		ts.m = make(map[string]*throttle)
+	}
+	t, ok := ts.m[key]
+	if !ok {
+		t = &throttle{}
+		ts.m[key] = t
+	}
+	t.setLimits(limits)
+	return t
+}
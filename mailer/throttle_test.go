@@ -0,0 +1,130 @@
+package mailer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newNoopSender returns a mockSender that accepts every message without
+// waiting for it to be received.
+func newNoopSender() (Sender, error) {
+	sender := newMockSender()
+	sender.setSend(func(mm *mockMessage) error {
+		return nil
+	})
+	return sender, nil
+}
+
+func TestThrottleReserve(t *testing.T) {
+	th := &throttle{}
+	th.setLimits(Limits{MessagesPerMinute: 2, MessagesPerHour: 3})
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if wait := th.reserve(now); wait != 0 {
+			t.Fatalf("Unexpected wait for message %d. Got %s", i, wait)
+		}
+	}
+	// The per-minute limit has been reached
+	if wait := th.reserve(now.Add(time.Second)); wait != time.Minute-time.Second {
+		t.Fatalf("Unexpected wait. Expected %s Got %s", time.Minute-time.Second, wait)
+	}
+	if wait := th.reserve(now.Add(time.Minute)); wait != 0 {
+		t.Fatalf("Unexpected wait after the minute elapsed. Got %s", wait)
+	}
+	// The per-hour limit has been reached
+	expected := time.Hour - 2*time.Minute
+	if wait := th.reserve(now.Add(2 * time.Minute)); wait != expected {
+		t.Fatalf("Unexpected wait. Expected %s Got %s", expected, wait)
+	}
+}
+
+func TestThrottlesShareState(t *testing.T) {
+	ts := &throttles{}
+	limits := Limits{MessagesPerMinute: 1}
+	if th := ts.get(newMockDialer()); th != nil {
+		t.Fatalf("Expected no throttle for a dialer without limits")
+	}
+	if th := ts.get(newMockThrottledDialer("1", Limits{})); th != nil {
+		t.Fatalf("Expected no throttle for a profile without limits")
+	}
+	first := ts.get(newMockThrottledDialer("1", limits))
+	second := ts.get(newMockThrottledDialer("1", limits))
+	if first != second {
+		t.Fatalf("Expected dialers for the same profile to share a throttle")
+	}
+	other := ts.get(newMockThrottledDialer("2", limits))
+	if other == first {
+		t.Fatalf("Expected dialers for different profiles to have separate throttles")
+	}
+}
+
+func TestSendMailRateLimited(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dialer := newMockThrottledDialer("1", Limits{MessagesPerMinute: 1})
+	dialer.setDial(newNoopSender)
+	messages := generateMessages(dialer)
+
+	ts := &throttles{}
+	sendMail(ctx, dialer, messages, ts.get(dialer))
+
+	first := messages[0].(*mockMessage)
+	if !first.finished || first.backoffCount != 0 {
+		t.Fatalf("Expected the first message to be sent")
+	}
+	// The second message should be deferred rather than errored
+	second := messages[1].(*mockMessage)
+	if second.finished {
+		t.Fatalf("Expected the second message to be deferred")
+	}
+	if second.backoffCount != 1 {
+		t.Fatalf("Unexpected backoff count. Expected 1 Got %d", second.backoffCount)
+	}
+	if _, ok := second.err.(*ErrRateLimited); !ok {
+		t.Fatalf("Expected ErrRateLimited. Got %#v", second.err)
+	}
+}
+
+func TestSendMailMaxMessagesPerConnection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dialer := newMockThrottledDialer("1", Limits{MaxMessagesPerConnection: 1})
+	dialer.setDial(newNoopSender)
+	messages := generateMessages(dialer)
+
+	ts := &throttles{}
+	sendMail(ctx, dialer, messages, ts.get(dialer))
+
+	for i, m := range messages {
+		if !m.(*mockMessage).finished {
+			t.Fatalf("Expected message %d to be sent", i)
+		}
+	}
+	// We should have reconnected to send the second message
+	expectedDialCount := 2
+	if dialer.dialCount != expectedDialCount {
+		t.Fatalf("Unexpected dial count. Expected %d Got %d", expectedDialCount, dialer.dialCount)
+	}
+}
+
+func TestThrottleMaxConnections(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	th := &throttle{}
+	th.setLimits(Limits{MaxConnections: 1})
+	release, ok := th.acquire(ctx)
+	if !ok {
+		t.Fatalf("Expected to acquire a connection")
+	}
+	// A second connection should block until the context is cancelled
+	cancel()
+	if _, ok := th.acquire(ctx); ok {
+		t.Fatalf("Expected the connection limit to be enforced")
+	}
+	release()
+	if _, ok := th.acquire(context.Background()); !ok {
+		t.Fatalf("Expected to acquire a connection once released")
+	}
+}
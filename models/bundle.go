@@ -0,0 +1,697 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/jinzhu/gorm"
+)
+
+// BundleVersion is the version of the bundle format written by ExportBundle
+const BundleVersion = 1
+
+// bundleManifestName is the name of the manifest file in a bundle
+const bundleManifestName = "manifest.json"
+
+// maxBundleFileSize is the largest file read from a bundle, which guards
+// against zip bombs
+const maxBundleFileSize = 50 * 1024 * 1024
+
+// MaxBundleSize is the total uncompressed size of the files read from a
+// bundle
+const MaxBundleSize = 200 << 20
+
+// Conflict resolution strategies used when an imported item has the same name
+// as an existing one
+const (
+	BundleConflictRename    = "rename"
+	BundleConflictSkip      = "skip"
+	BundleConflictOverwrite = "overwrite"
+)
+
+// ErrInvalidBundle is thrown when an imported bundle can't be read
+var ErrInvalidBundle = errors.New("Invalid bundle. Bundles must be zip files containing a manifest.json")
+
+// ErrBundleTooLarge is thrown when the files in a bundle are larger than
+// MaxBundleSize once uncompressed
+var ErrBundleTooLarge = fmt.Errorf("Bundles can contain at most %d MB of files", MaxBundleSize>>20)
+
+// ErrUnsupportedBundleVersion is thrown when an imported bundle was written
+// by a newer version of the bundle format
+var ErrUnsupportedBundleVersion = errors.New("Unsupported bundle version")
+
+// ErrInvalidBundleConflict is thrown when an unknown conflict resolution
+// strategy is requested
+var ErrInvalidBundleConflict = errors.New("Invalid conflict resolution. Must be one of rename, skip or overwrite")
+
+// BundleManifest describes the contents of a bundle. Large content, such as
+// HTML and attachments, is stored in separate files in the bundle and
+// referenced by path.
+type BundleManifest struct {
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Templates  []BundleTemplate `json:"templates"`
+	Pages      []BundlePage     `json:"pages"`
+	SMS        []BundleSMS      `json:"sms"`
+}
+
+// BundleTemplate is a template in a bundle
+type BundleTemplate struct {
+	Name           string             `json:"name"`
+	Type           string             `json:"type"`
+	EnvelopeSender string             `json:"envelope_sender"`
+	Subject        string             `json:"subject"`
+	Text           string             `json:"text,omitempty"`
+	HTML           string             `json:"html,omitempty"`
+	Attachments    []BundleAttachment `json:"attachments"`
+	Tags           []string           `json:"tags,omitempty"`
+	Difficulty     string             `json:"difficulty,omitempty"`
+	Language       string             `json:"language,omitempty"`
+	Category       string             `json:"category,omitempty"`
+	// Variants are the template's translations, sent to recipients
+	// whose language matches
+	Variants         []BundleVariant `json:"variants,omitempty"`
+	FallbackLanguage string          `json:"fallback_language,omitempty"`
+	// Page is the name of the landing page used with this template, which
+	// is included in the bundle's pages
+	Page string `json:"page,omitempty"`
+}
+
+// BundleVariant is a translation of a template in a bundle
+type BundleVariant struct {
+	Language string `json:"language"`
+	Subject  string `json:"subject"`
+	Text     string `json:"text,omitempty"`
+	HTML     string `json:"html,omitempty"`
+}
+
+// BundleAttachment is a template attachment in a bundle
+type BundleAttachment struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Path string `json:"path"`
+}
+
+// BundlePage is a landing page in a bundle
+type BundlePage struct {
+	Name               string   `json:"name"`
+	HTML               string   `json:"html"`
+	CaptureCredentials bool     `json:"capture_credentials"`
+	CapturePasswords   bool     `json:"capture_passwords"`
+	RedirectURL        string   `json:"redirect_url"`
+	Tags               []string `json:"tags,omitempty"`
+	Difficulty         string   `json:"difficulty,omitempty"`
+	Language           string   `json:"language,omitempty"`
+	Category           string   `json:"category,omitempty"`
+	// Steps are the pages shown after the page's HTML
+	Steps []BundlePageStep `json:"steps,omitempty"`
+}
+
+// BundlePageStep is a step of a landing page in a bundle. Steps which show
+// the training page may have no HTML.
+type BundlePageStep struct {
+	Name     string `json:"name"`
+	HTML     string `json:"html,omitempty"`
+	Training bool   `json:"training"`
+}
+
+// BundleSMS is an SMS profile in a bundle. The auth token is only included
+// when explicitly requested.
+type BundleSMS struct {
+	Name             string `json:"name"`
+	InterfaceType    string `json:"interface_type"`
+	TwilioAccountSid string `json:"account_sid"`
+	TwilioAuthToken  string `json:"auth_token,omitempty"`
+	SMSFrom          string `json:"sms_from"`
+}
+
+// BundleExport lists the items to include in an exported bundle
+type BundleExport struct {
+	Templates []Template
+	Pages     []Page
+	SMS       []SMS
+	// IncludeCredentials includes the SMS profiles' auth tokens
+	IncludeCredentials bool
+	// TemplatePages maps a template's id to the landing page used with it
+	TemplatePages map[int64]string
+}
+
+// BundleImportItem describes the outcome of importing a single item
+type BundleImportItem struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Id      int64  `json:"id"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// PageId and Page are the landing page used with an imported template
+	PageId int64  `json:"page_id,omitempty"`
+	Page   string `json:"page,omitempty"`
+}
+
+// BundleImport describes the outcome of importing a bundle
+type BundleImport struct {
+	Items []BundleImportItem `json:"items"`
+}
+
+// bundleWriter writes the manifest and content files of a bundle
+type bundleWriter struct {
+	zw *zip.Writer
+}
+
+func (bw *bundleWriter) writeFile(name string, content []byte) (string, error) {
+	f, err := bw.zw.Create(name)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(content)
+	return name, err
+}
+
+// ExportBundle writes the given items to a zip bundle
+func ExportBundle(e BundleExport) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	bw := &bundleWriter{zw: zip.NewWriter(buf)}
+	m := BundleManifest{
+		Version:    BundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Templates:  []BundleTemplate{},
+		Pages:      []BundlePage{},
+		SMS:        []BundleSMS{},
+	}
+	var err error
+	for i, t := range e.Templates {
+		dir := fmt.Sprintf("templates/%d", i)
+		bt := BundleTemplate{
+			Name:             t.Name,
+			Type:             t.Type,
+			EnvelopeSender:   t.EnvelopeSender,
+			Subject:          t.Subject,
+			Attachments:      []BundleAttachment{},
+			Tags:             t.Tags,
+			Difficulty:       t.Difficulty,
+			Language:         t.Language,
+			Category:         t.Category,
+			Page:             e.TemplatePages[t.Id],
+			FallbackLanguage: t.FallbackLanguage,
+		}
+		if t.Text != "" {
+			bt.Text, err = bw.writeFile(path.Join(dir, "email.txt"), []byte(t.Text))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if t.HTML != "" {
+			bt.HTML, err = bw.writeFile(path.Join(dir, "email.html"), []byte(t.HTML))
+			if err != nil {
+				return nil, err
+			}
+		}
+		for j, a := range t.Attachments {
+			content, err := base64.StdEncoding.DecodeString(a.Content)
+			if err != nil {
+				return nil, err
+			}
+			// Attachments are stored by index so that names which aren't
+			// valid paths can be exported
+			p, err := bw.writeFile(fmt.Sprintf("%s/attachments/%d", dir, j), content)
+			if err != nil {
+				return nil, err
+			}
+			bt.Attachments = append(bt.Attachments, BundleAttachment{Name: a.Name, Type: a.Type, Path: p})
+		}
+		for j, v := range t.Variants {
+			bv := BundleVariant{Language: v.Language, Subject: v.Subject}
+			vdir := fmt.Sprintf("%s/variants/%d", dir, j)
+			if v.Text != "" {
+				bv.Text, err = bw.writeFile(path.Join(vdir, "email.txt"), []byte(v.Text))
+				if err != nil {
+					return nil, err
+				}
+			}
+			if v.HTML != "" {
+				bv.HTML, err = bw.writeFile(path.Join(vdir, "email.html"), []byte(v.HTML))
+				if err != nil {
+					return nil, err
+				}
+			}
+			bt.Variants = append(bt.Variants, bv)
+		}
+		m.Templates = append(m.Templates, bt)
+	}
+	for i, p := range e.Pages {
+		bp := BundlePage{
+			Name:               p.Name,
+			CaptureCredentials: p.CaptureCredentials,
+			CapturePasswords:   p.CapturePasswords,
+			RedirectURL:        p.RedirectURL,
+			Tags:               p.Tags,
+			Difficulty:         p.Difficulty,
+			Language:           p.Language,
+			Category:           p.Category,
+		}
+		bp.HTML, err = bw.writeFile(fmt.Sprintf("pages/%d/page.html", i), []byte(p.HTML))
+		if err != nil {
+			return nil, err
+		}
+		for j, ps := range p.Steps {
+			bs := BundlePageStep{Name: ps.Name, Training: ps.Training}
+			if ps.HTML != "" {
+				bs.HTML, err = bw.writeFile(fmt.Sprintf("pages/%d/steps/%d.html", i, j), []byte(ps.HTML))
+				if err != nil {
+					return nil, err
+				}
+			}
+			bp.Steps = append(bp.Steps, bs)
+		}
+		m.Pages = append(m.Pages, bp)
+	}
+	for _, s := range e.SMS {
+		bs := BundleSMS{
+			Name:             s.Name,
+			InterfaceType:    s.InterfaceType,
+			TwilioAccountSid: s.TwilioAccountSid,
+			SMSFrom:          s.SMSFrom,
+		}
+		if e.IncludeCredentials {
+			bs.TwilioAuthToken = s.TwilioAuthToken
+		}
+		m.SMS = append(m.SMS, bs)
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	_, err = bw.writeFile(bundleManifestName, manifest)
+	if err != nil {
+		return nil, err
+	}
+	err = bw.zw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bundleReader reads the files of a bundle, keeping track of the total
+// size read so that files referenced many times count each time
+type bundleReader struct {
+	files map[string]*zip.File
+	read  int64
+}
+
+// readFile returns the contents of a file in the bundle
+func (br *bundleReader) readFile(name string) ([]byte, error) {
+	f, ok := br.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is missing from the bundle", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	limit := int64(maxBundleFileSize)
+	if remaining := MaxBundleSize - br.read; remaining < limit {
+		limit = remaining
+	}
+	content, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	br.read += int64(len(content))
+	if br.read > MaxBundleSize {
+		return nil, ErrBundleTooLarge
+	}
+	if len(content) > maxBundleFileSize {
+		return nil, fmt.Errorf("%s is too large", name)
+	}
+	return content, nil
+}
+
+// readOptionalFile returns the contents of a file in the bundle, or an
+// empty string if no path is given
+func (br *bundleReader) readOptionalFile(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	content, err := br.readFile(name)
+	return string(content), err
+}
+
+// bundleContents holds the items read from a bundle before they are saved
+type bundleContents struct {
+	templates []Template
+	// templatePages holds the name of the landing page used with each
+	// template
+	templatePages []string
+	pages         []Page
+	sms           []SMS
+}
+
+// readBundle reads and validates every item in the bundle
+func readBundle(data []byte) (bundleContents, error) {
+	bc := bundleContents{}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return bc, ErrInvalidBundle
+	}
+	// Check the declared sizes before reading anything, so that oversized
+	// bundles are rejected without being decompressed
+	br := &bundleReader{files: make(map[string]*zip.File)}
+	var declared uint64
+	for _, f := range zr.File {
+		if f.UncompressedSize64 > maxBundleFileSize {
+			return bc, fmt.Errorf("%s is too large", f.Name)
+		}
+		declared += f.UncompressedSize64
+		if declared > MaxBundleSize {
+			return bc, ErrBundleTooLarge
+		}
+		br.files[f.Name] = f
+	}
+	content, err := br.readFile(bundleManifestName)
+	if err != nil {
+		return bc, ErrInvalidBundle
+	}
+	m := BundleManifest{}
+	err = json.Unmarshal(content, &m)
+	if err != nil {
+		return bc, ErrInvalidBundle
+	}
+	if m.Version > BundleVersion {
+		return bc, ErrUnsupportedBundleVersion
+	}
+	for _, bt := range m.Templates {
+		t := Template{
+			Name:             bt.Name,
+			Type:             bt.Type,
+			EnvelopeSender:   bt.EnvelopeSender,
+			Subject:          bt.Subject,
+			Tags:             normalizeTags(bt.Tags),
+			Difficulty:       bt.Difficulty,
+			Language:         bt.Language,
+			Category:         bt.Category,
+			Attachments:      []Attachment{},
+			FallbackLanguage: bt.FallbackLanguage,
+			Variants:         []TemplateVariant{},
+		}
+		t.Text, err = br.readOptionalFile(bt.Text)
+		if err != nil {
+			return bc, err
+		}
+		t.HTML, err = br.readOptionalFile(bt.HTML)
+		if err != nil {
+			return bc, err
+		}
+		for _, ba := range bt.Attachments {
+			content, err := br.readFile(ba.Path)
+			if err != nil {
+				return bc, err
+			}
+			t.Attachments = append(t.Attachments, Attachment{
+				Name:    ba.Name,
+				Type:    ba.Type,
+				Content: base64.StdEncoding.EncodeToString(content),
+			})
+		}
+		for _, bv := range bt.Variants {
+			v := TemplateVariant{Language: bv.Language, Subject: bv.Subject}
+			v.Text, err = br.readOptionalFile(bv.Text)
+			if err != nil {
+				return bc, err
+			}
+			v.HTML, err = br.readOptionalFile(bv.HTML)
+			if err != nil {
+				return bc, err
+			}
+			t.Variants = append(t.Variants, v)
+		}
+		err = t.Validate()
+		if err != nil {
+			return bc, fmt.Errorf("template %q: %s", t.Name, err)
+		}
+		bc.templates = append(bc.templates, t)
+		bc.templatePages = append(bc.templatePages, bt.Page)
+	}
+	for _, bp := range m.Pages {
+		p := Page{
+			Name:               bp.Name,
+			CaptureCredentials: bp.CaptureCredentials,
+			CapturePasswords:   bp.CapturePasswords,
+			RedirectURL:        bp.RedirectURL,
+			Tags:               normalizeTags(bp.Tags),
+			Difficulty:         bp.Difficulty,
+			Language:           bp.Language,
+			Category:           bp.Category,
+		}
+		p.HTML, err = br.readOptionalFile(bp.HTML)
+		if err != nil {
+			return bc, err
+		}
+		for _, bs := range bp.Steps {
+			ps := PageStep{Name: bs.Name, Training: bs.Training}
+			ps.HTML, err = br.readOptionalFile(bs.HTML)
+			if err != nil {
+				return bc, err
+			}
+			p.Steps = append(p.Steps, ps)
+		}
+		err = p.Validate()
+		if err != nil {
+			return bc, fmt.Errorf("page %q: %s", p.Name, err)
+		}
+		bc.pages = append(bc.pages, p)
+	}
+	for _, bs := range m.SMS {
+		s := SMS{
+			Name:             bs.Name,
+			InterfaceType:    bs.InterfaceType,
+			TwilioAccountSid: bs.TwilioAccountSid,
+			TwilioAuthToken:  bs.TwilioAuthToken,
+			SMSFrom:          bs.SMSFrom,
+		}
+		if s.InterfaceType == "" {
+			s.InterfaceType = "SMS"
+		}
+		err = s.Validate()
+		if err == ErrAuthTokenNotSpecified {
+			// Auth tokens are only exported on request, so the rest of the
+			// profile is checked as if it had one
+			withToken := s
+			withToken.TwilioAuthToken = "-"
+			err = withToken.Validate()
+		}
+		if err != nil {
+			return bc, fmt.Errorf("SMS profile %q: %s", s.Name, err)
+		}
+		bc.sms = append(bc.sms, s)
+	}
+	return bc, nil
+}
+
+// importName returns the name to save an imported item under, given a
+// function which returns the id of the existing item with a name, if any.
+// Names which are already in use are suffixed with "(imported)".
+func importName(name string, exists func(string) (int64, error)) (string, error) {
+	candidate := name
+	for i := 1; ; i++ {
+		id, err := exists(candidate)
+		if err != nil || id == 0 {
+			return candidate, err
+		}
+		candidate = fmt.Sprintf("%s (imported)", name)
+		if i > 1 {
+			candidate = fmt.Sprintf("%s (imported %d)", name, i)
+		}
+	}
+}
+
+// bundleItemLookup returns a function which looks up the id of the user's
+// item with a name in the given table, returning 0 if there isn't one
+func bundleItemLookup(tx *gorm.DB, table string, uid int64) func(string) (int64, error) {
+	return func(name string) (int64, error) {
+		query := tx.Table(table).Where("name=?", name)
+		if uid != 0 {
+			query = query.Where("user_id=?", uid)
+		}
+		ids := []int64{}
+		err := query.Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return 0, err
+		}
+		return ids[0], nil
+	}
+}
+
+// resolveConflict determines the name to import an item under and whether
+// it should replace an existing item (existing != 0) or be skipped
+func resolveConflict(name, conflict string, exists func(string) (int64, error)) (string, int64, bool, error) {
+	if conflict == BundleConflictRename {
+		renamed, err := importName(name, exists)
+		return renamed, 0, false, err
+	}
+	id, err := exists(name)
+	if err != nil {
+		return name, 0, false, err
+	}
+	if id != 0 && conflict == BundleConflictSkip {
+		return name, id, true, nil
+	}
+	return name, id, false, nil
+}
+
+// ImportBundle validates every item in the bundle and then saves them for
+// the given user in a single transaction. Items whose names are already in
+// use are renamed, skipped or overwritten depending on the conflict strategy.
+func ImportBundle(data []byte, uid int64, conflict string) (BundleImport, error) {
+	result := BundleImport{Items: []BundleImportItem{}}
+	if conflict == "" {
+		conflict = BundleConflictRename
+	}
+	switch conflict {
+	case BundleConflictRename, BundleConflictSkip, BundleConflictOverwrite:
+	default:
+		return result, ErrInvalidBundleConflict
+	}
+	bc, err := readBundle(data)
+	if err != nil {
+		return result, err
+	}
+	tx := db.Begin()
+	result, err = importBundle(tx, bc, uid, conflict)
+	if err != nil {
+		tx.Rollback()
+		return BundleImport{Items: []BundleImportItem{}}, err
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		log.Error(err)
+	}
+	return result, err
+}
+
+// importBundle saves the items read from a bundle. Pages are saved first so
+// that templates can be linked to the imported pages.
+func importBundle(tx *gorm.DB, bc bundleContents, uid int64, conflict string) (BundleImport, error) {
+	result := BundleImport{Items: []BundleImportItem{}}
+	now := time.Now().UTC()
+	pageItems := []BundleImportItem{}
+	pageIds := make(map[string]BundleImportItem)
+	for _, p := range bc.pages {
+		item := BundleImportItem{Type: "page", Status: "created"}
+		original := p.Name
+		name, existing, skip, err := resolveConflict(p.Name, conflict, bundleItemLookup(tx, "pages", uid))
+		if err != nil {
+			return result, err
+		}
+		p.Name, p.UserId, p.ModifiedDate = name, uid, now
+		switch {
+		case skip:
+			item.Status, p.Id = "skipped", existing
+		case existing != 0:
+			item.Status, p.Id = "overwritten", existing
+			err = tx.Where("id=?", p.Id).Save(&p).Error
+		default:
+			err = tx.Save(&p).Error
+		}
+		if err != nil {
+			log.Error(err)
+			return result, err
+		}
+		item.Id, item.Name = p.Id, p.Name
+		pageItems = append(pageItems, item)
+		pageIds[original] = item
+	}
+	for i, t := range bc.templates {
+		item := BundleImportItem{Type: "template", Status: "created"}
+		name, existing, skip, err := resolveConflict(t.Name, conflict, bundleItemLookup(tx, "templates", uid))
+		if err != nil {
+			return result, err
+		}
+		t.Name, t.UserId, t.ModifiedDate = name, uid, now
+		switch {
+		case skip:
+			item.Status, t.Id = "skipped", existing
+		case existing != 0:
+			item.Status, t.Id = "overwritten", existing
+			current := Template{}
+			err = tx.Where("id=?", existing).First(&current).Error
+			if err == nil {
+				err = updateTemplate(tx, &t, &current)
+			}
+		default:
+			err = insertTemplate(tx, &t)
+		}
+		if err != nil {
+			log.Error(err)
+			return result, err
+		}
+		item.Id, item.Name = t.Id, t.Name
+		if page := bc.templatePages[i]; page != "" {
+			// The page is normally in the bundle, but may also be one the
+			// user already has
+			pageItem, ok := pageIds[page]
+			if !ok {
+				pageItem.Name = page
+				pageItem.Id, err = bundleItemLookup(tx, "pages", uid)(page)
+				if err != nil {
+					return result, err
+				}
+			}
+			if pageItem.Id != 0 {
+				item.PageId, item.Page = pageItem.Id, pageItem.Name
+			}
+		}
+		result.Items = append(result.Items, item)
+	}
+	result.Items = append(result.Items, pageItems...)
+	for _, s := range bc.sms {
+		item := BundleImportItem{Type: "sms", Status: "created"}
+		name, existing, skip, err := resolveConflict(s.Name, conflict, bundleItemLookup(tx, "sms", uid))
+		if err != nil {
+			return result, err
+		}
+		s.Name, s.UserId, s.ModifiedDate = name, uid, now
+		if s.TwilioAuthToken == "" && !skip {
+			if existing == 0 {
+				// Profiles can't be saved without an auth token, so the
+				// user has to create them with one
+				item.Status, item.Name = "needs_credentials", s.Name
+				item.Message = ErrAuthTokenNotSpecified.Error() + ". Export the profile with its credentials or create it manually."
+				result.Items = append(result.Items, item)
+				continue
+			}
+			// Overwritten profiles keep their auth token
+			current := SMS{}
+			err = tx.Where("id=?", existing).First(&current).Error
+			if err != nil {
+				return result, err
+			}
+			s.TwilioAuthToken = current.TwilioAuthToken
+		}
+		switch {
+		case skip:
+			item.Status, s.Id = "skipped", existing
+		case existing != 0:
+			item.Status, s.Id = "overwritten", existing
+			err = tx.Where("id=?", s.Id).Save(&s).Error
+		default:
+			err = tx.Save(&s).Error
+		}
+		if err != nil {
+			log.Error(err)
+			return result, err
+		}
+		item.Id, item.Name = s.Id, s.Name
+		result.Items = append(result.Items, item)
+	}
+	return result, nil
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) createBundleItems(ch *check.C) (Template, Page, SMS) {
+	t := Template{Name: "Bundle Template", UserId: 1}
+	t.Subject = "Hello {{.FirstName}}"
+	t.HTML = "<p>{{.URL}}</p>"
+	t.Text = "{{.URL}}"
+	t.Attachments = []Attachment{{Name: "notes.txt", Type: "text/plain", Content: "VGVzdA=="}}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+
+	p := Page{Name: "Bundle Page", UserId: 1}
+	p.HTML = "<html><form><input name=\"username\"></form></html>"
+	p.CaptureCredentials = true
+	p.RedirectURL = "https://example.com/done"
+	p.Steps = PageSteps{
+		PageStep{Name: "Verify", HTML: "<html><form><input name=\"code\"></form></html>"},
+		PageStep{Name: "Training", Training: true},
+	}
+	ch.Assert(PostPage(&p), check.Equals, nil)
+
+	sms := SMS{Name: "Bundle SMS", UserId: 1}
+	sms.TwilioAccountSid = "AC123"
+	sms.TwilioAuthToken = "secret"
+	sms.SMSFrom = "+15555550100"
+	ch.Assert(PostSMS(&sms), check.Equals, nil)
+	return t, p, sms
+}
+
+func (s *ModelsSuite) TestBundleRoundTrip(ch *check.C) {
+	t, p, sms := s.createBundleItems(ch)
+	bundle, err := ExportBundle(BundleExport{
+		Templates:          []Template{t},
+		Pages:              []Page{p},
+		SMS:                []SMS{sms},
+		IncludeCredentials: true,
+		TemplatePages:      map[int64]string{t.Id: p.Name},
+	})
+	ch.Assert(err, check.Equals, nil)
+
+	// Every name is already taken, so the imported items are renamed
+	result, err := ImportBundle(bundle, 1, "")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(result.Items), check.Equals, 3)
+	for _, item := range result.Items {
+		ch.Assert(item.Status, check.Equals, "created")
+		ch.Assert(strings.HasSuffix(item.Name, " (imported)"), check.Equals, true)
+	}
+
+	// The template is linked to the imported page
+	ch.Assert(result.Items[0].PageId, check.Equals, result.Items[1].Id)
+	ch.Assert(result.Items[0].Page, check.Equals, "Bundle Page (imported)")
+
+	it, err := GetTemplate(result.Items[0].Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(it.Name, check.Equals, "Bundle Template (imported)")
+	ch.Assert(it.Subject, check.Equals, t.Subject)
+	ch.Assert(it.HTML, check.Equals, t.HTML)
+	ch.Assert(it.Text, check.Equals, t.Text)
+	ch.Assert(len(it.Attachments), check.Equals, 1)
+	ch.Assert(it.Attachments[0].Content, check.Equals, "VGVzdA==")
+
+	ip, err := GetPage(result.Items[1].Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(ip.RedirectURL, check.Equals, p.RedirectURL)
+	ch.Assert(ip.CaptureCredentials, check.Equals, true)
+	ch.Assert(len(ip.Steps), check.Equals, 2)
+	ch.Assert(ip.Steps[0].Name, check.Equals, "Verify")
+	ch.Assert(ip.Steps[0].HTML, check.Equals, p.Steps[0].HTML)
+	ch.Assert(ip.Steps[0].Training, check.Equals, false)
+	ch.Assert(ip.Steps[1], check.DeepEquals, PageStep{Name: "Training", Training: true})
+
+	is, err := GetSMS(result.Items[2].Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(is.TwilioAuthToken, check.Equals, "secret")
+
+	// A second import is numbered
+	result, err = ImportBundle(bundle, 1, BundleConflictRename)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.Items[0].Name, check.Equals, "Bundle Template (imported 2)")
+}
+
+func (s *ModelsSuite) TestBundleImportConflicts(ch *check.C) {
+	t, p, _ := s.createBundleItems(ch)
+	edited := t
+	edited.Subject = "Edited"
+	bundle, err := ExportBundle(BundleExport{Templates: []Template{edited}, Pages: []Page{p}})
+	ch.Assert(err, check.Equals, nil)
+
+	result, err := ImportBundle(bundle, 1, BundleConflictSkip)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.Items[0].Status, check.Equals, "skipped")
+	ch.Assert(result.Items[0].Id, check.Equals, t.Id)
+	got, err := GetTemplate(t.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Subject, check.Equals, t.Subject)
+
+	result, err = ImportBundle(bundle, 1, BundleConflictOverwrite)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.Items[0].Status, check.Equals, "overwritten")
+	got, err = GetTemplate(t.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Subject, check.Equals, "Edited")
+
+	_, err = ImportBundle(bundle, 1, "replace")
+	ch.Assert(err, check.Equals, ErrInvalidBundleConflict)
+}
+
+func (s *ModelsSuite) TestBundleLimits(ch *check.C) {
+	_, err := ImportBundle(newRawPageBundle(ch, map[string]uint64{"big.bin": maxBundleFileSize + 1}), 1, "")
+	ch.Assert(err, check.ErrorMatches, "big.bin is too large")
+
+	sizes := map[string]uint64{}
+	for i := 0; i*maxBundleFileSize <= MaxBundleSize; i++ {
+		sizes[fmt.Sprintf("part%d.bin", i)] = maxBundleFileSize
+	}
+	_, err = ImportBundle(newRawPageBundle(ch, sizes), 1, "")
+	ch.Assert(err, check.Equals, ErrBundleTooLarge)
+}
+
+func (s *ModelsSuite) TestBundleImportValidation(ch *check.C) {
+	_, _, sms := s.createBundleItems(ch)
+	invalid := Template{Name: "Invalid Bundle Template", HTML: "{{.Unknown}}"}
+	bundle, err := ExportBundle(BundleExport{Templates: []Template{invalid}})
+	ch.Assert(err, check.Equals, nil)
+	_, err = ImportBundle(bundle, 1, "")
+	ch.Assert(err, check.NotNil)
+	_, err = GetTemplateByName(invalid.Name, 1)
+	ch.Assert(err, check.NotNil)
+
+	// Auth tokens are left out of exports by default, so new profiles are
+	// reported as needing credentials while the rest of the bundle is
+	// imported. Overwritten profiles keep their token.
+	bundle, err = ExportBundle(BundleExport{SMS: []SMS{sms}, Pages: []Page{{Name: "Page With SMS", HTML: "<p>Hi</p>"}}})
+	ch.Assert(err, check.Equals, nil)
+	result, err := ImportBundle(bundle, 1, "")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(result.Items), check.Equals, 2)
+	ch.Assert(result.Items[0].Status, check.Equals, "created")
+	ch.Assert(result.Items[1].Status, check.Equals, "needs_credentials")
+	ch.Assert(result.Items[1].Id, check.Equals, int64(0))
+	ch.Assert(strings.Contains(result.Items[1].Message, ErrAuthTokenNotSpecified.Error()), check.Equals, true)
+	_, err = GetSMSByName("Bundle SMS (imported)", 1)
+	ch.Assert(err, check.NotNil)
+
+	result, err = ImportBundle(bundle, 1, BundleConflictOverwrite)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.Items[1].Status, check.Equals, "overwritten")
+	got, err := GetSMS(sms.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.TwilioAuthToken, check.Equals, "secret")
+
+	_, err = ImportBundle([]byte("not a zip"), 1, "")
+	ch.Assert(err, check.Equals, ErrInvalidBundle)
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	zw.Create("email.html")
+	zw.Close()
+	_, err = ImportBundle(buf.Bytes(), 1, "")
+	ch.Assert(err, check.Equals, ErrInvalidBundle)
+}
+
+func (s *ModelsSuite) TestBundleImportTransaction(ch *check.C) {
+	t := Template{Name: "Transaction Bundle Template", UserId: 1, HTML: "<p>{{.URL}}</p>"}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+	p := Page{Name: "Transaction Bundle Page", HTML: "<p>New</p>"}
+	bundle, err := ExportBundle(BundleExport{Templates: []Template{t}, Pages: []Page{p}})
+	ch.Assert(err, check.Equals, nil)
+
+	// Overwriting the template fails once its next version exists, which
+	// undoes the page imported before it
+	ch.Assert(db.Save(&TemplateVersion{TemplateId: t.Id, Version: t.Version + 1}).Error, check.Equals, nil)
+	_, err = ImportBundle(bundle, 1, BundleConflictOverwrite)
+	ch.Assert(err, check.NotNil)
+	_, err = GetPageByName(p.Name, 1)
+	ch.Assert(err, check.NotNil)
+}
@@ -16,34 +16,45 @@ import (
 
 // Campaign is a struct representing a created campaign
 type Campaign struct {
-	Id                int64     `json:"-"`
-	Rid               string    `json:"id" gorm:"column:rid;unique_index"`
-	UserId            int64     `json:"-"`
-	Name              string    `json:"name" sql:"not null"`
-	CreatedDate       time.Time `json:"created_date"`
-	LaunchDate        time.Time `json:"launch_date"`
-	SendByDate        time.Time `json:"send_by_date"`
-	ScheduledStopDate time.Time `json:"scheduled_stop_date"`
-	CompletedDate     time.Time `json:"completed_date"`
-	TemplateId        int64     `json:"-"`
-	Template          Template  `json:"template"`
-	PageId            int64     `json:"-"`
-	Page              Page      `json:"page"`
-	Status            string    `json:"status"`
-	Results           []Result  `json:"results,omitempty"`
-	Groups            []Group   `json:"groups,omitempty"`
-	Events            []Event   `json:"timeline,omitempty"`
-	SMTPId            int64     `json:"-"`
-	SMTP              SMTP      `json:"smtp"`
-	SMSId             int64     `json:"-"`
-	SMS               SMS       `json:"sms"`
-	URL               string    `json:"url"`
-	CampaignType      string    `json:"campaign_type"`
-	QRSize            int       `json:"qr_size"`
-	CreatedBy         string    `json:"created_by" sql:"-"`
-	AttackObjective   string    `json:"attack_objective"`
-	RedirectURL       string    `json:"redirect_url"`
-	LandingURL        string    `json:"landing_url"`
+	Id                int64          `json:"-"`
+	Rid               string         `json:"id" gorm:"column:rid;unique_index"`
+	UserId            int64          `json:"-"`
+	Name              string         `json:"name" sql:"not null"`
+	CreatedDate       time.Time      `json:"created_date"`
+	LaunchDate        time.Time      `json:"launch_date"`
+	SendByDate        time.Time      `json:"send_by_date"`
+	ScheduledStopDate time.Time      `json:"scheduled_stop_date"`
+	CompletedDate     time.Time      `json:"completed_date"`
+	TemplateId        int64          `json:"-"`
+	TemplateVersion   int            `json:"template_version"`
+	Template          Template       `json:"template"`
+	PageId            int64          `json:"-"`
+	Page              Page           `json:"page"`
+	Status            string         `json:"status"`
+	Results           []Result       `json:"results,omitempty"`
+	Groups            []Group        `json:"groups,omitempty"`
+	Events            []Event        `json:"timeline,omitempty"`
+	SMTPId            int64          `json:"-"`
+	SMTP              SMTP           `json:"smtp"`
+	SMTPPool          []CampaignSMTP `json:"smtp_pool,omitempty" sql:"-"`
+	SMTPStrategy      string         `json:"smtp_strategy"`
+	SMSId             int64          `json:"-"`
+	SMS               SMS            `json:"sms"`
+	URL               string         `json:"url"`
+	CampaignType      string         `json:"campaign_type"`
+	QRSize            int            `json:"qr_size"`
+	CreatedBy         string         `json:"created_by" sql:"-"`
+	AttackObjective   string         `json:"attack_objective"`
+	RedirectURL       string         `json:"redirect_url"`
+	LandingURL        string         `json:"landing_url"`
+	TrainingPageId    int64          `json:"-"`
+	TrainingPage      TrainingPage   `json:"training_page"`
+	TrainingTrigger   string         `json:"training_trigger"`
+	TrainingModuleId  int64          `json:"-"`
+	TrainingModule    TrainingModule `json:"training_module"`
+	TrainingAssignOn  string         `json:"training_assign_on"`
+	// QROptions customizes the campaign's QR codes
+	QROptions
 }
 
 // CampaignResults is a struct representing the results from a campaign
@@ -87,6 +98,26 @@ type CampaignStats struct {
 	SubmittedData int64 `json:"submitted_data"`
 	EmailReported int64 `json:"email_reported"`
 	Error         int64 `json:"error"`
+	Bounced       int64 `json:"bounced"`
+	// Delivered is the number of recipients whose email didn't bounce, which
+	// should be used when calculating open and click rates.
+	Delivered int64 `json:"delivered"`
+	// Difficulty is the difficulty of the campaign's template, so that click
+	// rates can be compared between lures of similar difficulty
+	Difficulty string `json:"difficulty"`
+	// Raw includes the opens and clicks made by scanners, image proxies and
+	// link previews, which aren't counted in OpenedEmail and ClickedLink
+	Raw RawCampaignStats `json:"raw"`
+}
+
+// RawCampaignStats counts the recipients with an open or click of any
+// classification
+type RawCampaignStats struct {
+	OpenedEmail int64 `json:"opened"`
+	ClickedLink int64 `json:"clicked"`
+	// Automated is the number of open and click events of each classification
+	// other than human
+	Automated map[string]int64 `json:"automated"`
 }
 
 // Event contains the fields for an event
@@ -98,6 +129,9 @@ type Event struct {
 	Time       time.Time `json:"time"`
 	Message    string    `json:"message"`
 	Details    string    `json:"details"`
+	// Classification is copied from the details of opens and clicks so that
+	// automated events can be counted
+	Classification string `json:"classification,omitempty"`
 }
 
 // EventDetails is a struct that wraps common attributes we want to store
@@ -105,6 +139,13 @@ type Event struct {
 type EventDetails struct {
 	Payload url.Values        `json:"payload"`
 	Browser map[string]string `json:"browser"`
+	// Step is the name of the landing page step the data was submitted from
+	Step string `json:"step,omitempty"`
+	// Classification is whether an open or click was made by the recipient,
+	// a scanner, an image proxy or a link preview, and ClassificationReason
+	// is the signal it was based on
+	Classification       string `json:"classification,omitempty"`
+	ClassificationReason string `json:"classification_reason,omitempty"`
 }
 
 // EventError is a struct that wraps an error that occurs when sending an
@@ -113,6 +154,14 @@ type EventError struct {
 	Error string `json:"error"`
 }
 
+// EventBounce contains the fields for a bounced email event, taken from the
+// delivery status notification returned by the recipient's mail server.
+type EventBounce struct {
+	Recipient  string `json:"recipient"`
+	Status     string `json:"status"`
+	Diagnostic string `json:"diagnostic"`
+}
+
 // ErrCampaignNameNotSpecified indicates there was no template given by the user
 var ErrCampaignNameNotSpecified = errors.New("Campaign name not specified")
 
@@ -162,14 +211,26 @@ func (c *Campaign) Validate() error {
 			return errors.New("No SMS profile specified")
 		}
 	} else {
-		if c.SMTP.Name == "" {
+		if c.SMTP.Name == "" && len(c.SMTPPool) == 0 {
 			return ErrSMTPNotSpecified
 		}
+		if err := c.validateSMTPPool(); err != nil {
+			return err
+		}
 	}
 	if !c.SendByDate.IsZero() && !c.LaunchDate.IsZero() && c.SendByDate.Before(c.LaunchDate) {
 		return ErrInvalidSendByDate
 	}
-	return nil
+	if err := c.validateTraining(); err != nil {
+		return err
+	}
+	if err := c.validateTrainingModule(); err != nil {
+		return err
+	}
+	if err := c.validateURL(); err != nil {
+		return err
+	}
+	return c.QROptions.Validate()
 }
 
 // UpdateStatus changes the campaign status appropriately
@@ -227,6 +288,10 @@ func AddEvent(e *Event, campaignID int64) error {
 	} else {
 		log.Errorf("error getting active webhooks: %v", err)
 	}
+	err = queueXAPIStatements(e)
+	if err != nil {
+		log.Errorf("error queueing xAPI statements: %v", err)
+	}
 
 	return db.Save(e).Error
 }
@@ -246,19 +311,15 @@ func (c *Campaign) getDetails() error {
 		log.Warnf("%s: events not found for campaign", err)
 		return err
 	}
-	err = db.Table("templates").Where("id=?", c.TemplateId).Find(&c.Template).Error
+	err = c.getTemplate()
 	if err != nil {
 		if err != gorm.ErrRecordNotFound {
+			log.Warn(err)
 			return err
 		}
 		c.Template = Template{Name: "[Deleted]"}
 		log.Warnf("%s: template not found for campaign", err)
 	}
-	err = db.Where("template_id=?", c.Template.Id).Find(&c.Template.Attachments).Error
-	if err != nil && err != gorm.ErrRecordNotFound {
-		log.Warn(err)
-		return err
-	}
 	err = db.Table("smtp").Where("id=?", c.SMTPId).Find(&c.SMTP).Error
 	if err != nil {
 		// Check if the SMTP was deleted
@@ -273,6 +334,11 @@ func (c *Campaign) getDetails() error {
 		log.Warn(err)
 		return err
 	}
+	err = c.getSMTPPool()
+	if err != nil {
+		log.Warn(err)
+		return err
+	}
 	err = db.Table("sms").Where("id=?", c.SMSId).Find(&c.SMS).Error
 	if err != nil {
 		if err != gorm.ErrRecordNotFound {
@@ -281,6 +347,16 @@ func (c *Campaign) getDetails() error {
 		c.SMS = SMS{Name: "[Deleted]"}
 		log.Warnf("%s: sms profile not found for campaign", err)
 	}
+	err = c.getTrainingPage()
+	if err != nil {
+		log.Warn(err)
+		return err
+	}
+	err = c.getTrainingModule()
+	if err != nil {
+		log.Warn(err)
+		return err
+	}
 	return nil
 }
 
@@ -304,6 +380,12 @@ func (c Campaign) getQRSize() string {
 	return strconv.Itoa(c.QRSize)
 }
 
+// getQROptions returns the Campaign's QR code customizations.
+// This is used to implement the TemplateContext interface.
+func (c Campaign) getQROptions() QROptions {
+	return c.QROptions
+}
+
 // getFromAddress returns the Campaign's configured SMTP "From" address.
 // This is used to implement the TemplateContext interface.
 func (c *Campaign) getFromAddress() string {
@@ -373,9 +455,87 @@ func getCampaignStats(cid int64) (CampaignStats, error) {
 	// Every opened email event implies the email was sent
 	s.EmailsSent += s.OpenedEmail
 	err = query.Where("status=?", Error).Count(&s.Error).Error
+	if err != nil {
+		return s, err
+	}
+	err = query.Where("status=?", EventBounced).Count(&s.Bounced).Error
+	// Bounced recipients never received the email, so they can't have
+	// opened it or clicked the link
+	s.Delivered = s.Total - s.Bounced
+	if err != nil {
+		return s, err
+	}
+	s.Raw, err = getRawCampaignStats(cid)
+	if err != nil {
+		return s, err
+	}
+	s.Difficulty, err = getCampaignDifficulty(cid)
 	return s, err
 }
 
+// getRawCampaignStats counts the recipients who opened the email or clicked
+// the link from the campaign's events, regardless of how they were classified
+func getRawCampaignStats(cid int64) (RawCampaignStats, error) {
+	s := RawCampaignStats{Automated: make(map[string]int64)}
+	query := db.Table("events").Select("count(distinct email)").Where("campaign_id = ?", cid)
+	// As with the human statistics, every click implies an open
+	err := query.Where("message IN (?)", []string{EventClicked, EventDataSubmit}).Row().Scan(&s.ClickedLink)
+	if err != nil {
+		return s, err
+	}
+	err = query.Where("message IN (?)", []string{EventOpened, EventClicked, EventDataSubmit}).Row().Scan(&s.OpenedEmail)
+	if err != nil {
+		return s, err
+	}
+	rows, err := db.Table("events").Select("classification, count(*)").
+		Where("campaign_id = ? and message IN (?) and classification NOT IN (?)", cid,
+			[]string{EventOpened, EventClicked}, []string{"", ClassificationHuman}).
+		Group("classification").Rows()
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var classification string
+		var count int64
+		err = rows.Scan(&classification, &count)
+		if err != nil {
+			return s, err
+		}
+		s.Automated[classification] = count
+	}
+	return s, rows.Err()
+}
+
+// getCampaignDifficulty returns the difficulty of the template version the
+// campaign is pinned to, falling back to the template's current difficulty
+// for campaigns launched before templates were versioned
+func getCampaignDifficulty(cid int64) (string, error) {
+	c := Campaign{}
+	err := db.Select("template_id, template_version").Where("id = ?", cid).First(&c).Error
+	if err == gorm.ErrRecordNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if c.TemplateVersion != 0 {
+		tv, err := getTemplateVersion(c.TemplateId, c.TemplateVersion)
+		if err == nil {
+			return tv.Difficulty, nil
+		}
+		if err != ErrTemplateVersionNotFound {
+			return "", err
+		}
+	}
+	t := Template{}
+	err = db.Select("difficulty").Where("id = ?", c.TemplateId).First(&t).Error
+	if err == gorm.ErrRecordNotFound {
+		return "", nil
+	}
+	return t.Difficulty, err
+}
+
 // GetCampaigns returns the campaigns owned by the given user.
 func GetCampaigns(uid int64, campaignType string) ([]Campaign, error) {
 	cs := []Campaign{}
@@ -465,12 +625,12 @@ func GetCampaignMailContext(id int64, uid int64) (Campaign, error) {
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return c, err
 	}
-	err = db.Table("templates").Where("id=?", c.TemplateId).Find(&c.Template).Error
+	err = c.getSMTPPool()
 	if err != nil {
 		return c, err
 	}
-	err = db.Where("template_id=?", c.Template.Id).Find(&c.Template.Attachments).Error
-	if err != nil && err != gorm.ErrRecordNotFound {
+	err = c.getTemplate()
+	if err != nil {
 		return c, err
 	}
 	return c, nil
@@ -608,6 +768,77 @@ func GetExpiredCampaigns(t time.Time) ([]Campaign, error) {
 	return cs, err
 }
 
+// resolveDependencies replaces the groups, template and sending profiles
+// named in a new campaign with the stored objects. It returns the total number
+// of recipients, counting duplicates.
+func (c *Campaign) resolveDependencies(uid int64) (int, error) {
+	var err error
+	// Check to make sure all the groups already exist
+	// Also, later we'll need to know the total number of recipients (counting
+	// duplicates is ok for now), so we'll do that here to save a loop.
+	totalRecipients := 0
+	for i, g := range c.Groups {
+		c.Groups[i], err = GetGroupByName(g.Name, uid)
+		if err == gorm.ErrRecordNotFound {
+			log.WithFields(logrus.Fields{
+				"group": g.Name,
+			}).Error("Group does not exist")
+			return 0, ErrGroupNotFound
+		} else if err != nil {
+			log.Error(err)
+			return 0, err
+		}
+		totalRecipients += len(c.Groups[i].Targets)
+	}
+	// Check to make sure the template exists
+	t, err := GetTemplateByName(c.Template.Name, uid)
+	if err == gorm.ErrRecordNotFound {
+		log.WithFields(logrus.Fields{
+			"template": c.Template.Name,
+		}).Error("Template does not exist")
+		return 0, ErrTemplateNotFound
+	} else if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+	c.Template = t
+	c.TemplateId = t.Id
+	// Pin the campaign to the current version of the template, so that
+	// later edits don't change what recipients receive
+	err = ensureTemplateVersion(db, &c.Template)
+	if err != nil {
+		return 0, err
+	}
+	c.TemplateVersion = c.Template.Version
+	// Check to make sure the sending profiles exist. If a pool is given,
+	// its first profile is used as the primary sending profile.
+	err = c.resolveSMTPPool(uid)
+	if err != nil {
+		return 0, err
+	}
+	s, err := GetSMTPByName(c.SMTP.Name, uid)
+	if err == gorm.ErrRecordNotFound {
+		log.WithFields(logrus.Fields{
+			"smtp": c.SMTP.Name,
+		}).Error("Sending profile does not exist")
+		return 0, ErrSMTPNotFound
+	} else if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+	c.SMTP = s
+	c.SMTPId = s.Id
+	err = c.resolveTrainingPage(uid)
+	if err != nil {
+		return 0, err
+	}
+	err = c.resolveTrainingModule(uid)
+	if err != nil {
+		return 0, err
+	}
+	return totalRecipients, nil
+}
+
 // PostCampaign inserts a campaign and all associated records into the database.
 func PostCampaign(c *Campaign, uid int64) error {
 	log.WithFields(logrus.Fields{
@@ -655,49 +886,12 @@ func PostCampaign(c *Campaign, uid int64) error {
 	if c.LaunchDate.Before(c.CreatedDate) || c.LaunchDate.Equal(c.CreatedDate) {
 		c.Status = CampaignInProgress
 	}
-	// Check to make sure all the groups already exist
-	// Also, later we'll need to know the total number of recipients (counting
-	// duplicates is ok for now), so we'll do that here to save a loop.
-	totalRecipients := 0
-	for i, g := range c.Groups {
-		c.Groups[i], err = GetGroupByName(g.Name, uid)
-		if err == gorm.ErrRecordNotFound {
-			log.WithFields(logrus.Fields{
-				"group": g.Name,
-			}).Error("Group does not exist")
-			return ErrGroupNotFound
-		} else if err != nil {
-			log.Error(err)
-			return err
-		}
-		totalRecipients += len(c.Groups[i].Targets)
-	}
-	// Check to make sure the template exists
-	t, err := GetTemplateByName(c.Template.Name, uid)
-	if err == gorm.ErrRecordNotFound {
-		log.WithFields(logrus.Fields{
-			"template": c.Template.Name,
-		}).Error("Template does not exist")
-		return ErrTemplateNotFound
-	} else if err != nil {
-		log.Error(err)
-		return err
-	}
-	c.Template = t
-	c.TemplateId = t.Id
-	// Check to make sure the sending profile exists
-	s, err := GetSMTPByName(c.SMTP.Name, uid)
-	if err == gorm.ErrRecordNotFound {
-		log.WithFields(logrus.Fields{
-			"smtp": c.SMTP.Name,
-		}).Error("Sending profile does not exist")
-		return ErrSMTPNotFound
-	} else if err != nil {
-		log.Error(err)
+	// Check to make sure all the groups, the template and the sending
+	// profiles exist
+	totalRecipients, err := c.resolveDependencies(uid)
+	if err != nil {
 		return err
 	}
-	c.SMTP = s
-	c.SMTPId = s.Id
 	// Generate Rid
 	err = c.GenerateRid()
 	if err != nil {
@@ -718,6 +912,19 @@ func PostCampaign(c *Campaign, uid int64) error {
 	resultMap := make(map[string]bool)
 	recipientIndex := 0
 	tx := db.Begin()
+	for i := range c.SMTPPool {
+		c.SMTPPool[i].CampaignId = c.Id
+		err = tx.Save(&c.SMTPPool[i]).Error
+		if err != nil {
+			log.Error(err)
+			tx.Rollback()
+			return err
+		}
+	}
+	var selector *smtpSelector
+	if len(c.SMTPPool) > 0 {
+		selector = newSMTPSelector(c)
+	}
 	for _, g := range c.Groups {
 		// Insert a result for each target in the group
 		for _, t := range g.Targets {
@@ -730,10 +937,13 @@ func PostCampaign(c *Campaign, uid int64) error {
 			sendDate := c.generateSendDate(recipientIndex, totalRecipients)
 			r := &Result{
 				BaseRecipient: BaseRecipient{
-					Email:     t.Email,
-					Position:  t.Position,
-					FirstName: t.FirstName,
-					LastName:  t.LastName,
+					Email:      t.Email,
+					Position:   t.Position,
+					FirstName:  t.FirstName,
+					LastName:   t.LastName,
+					Phone:      t.Phone,
+					Language:   t.Language,
+					Attributes: t.Attributes,
 				},
 				Status:       StatusScheduled,
 				CampaignId:   c.Id,
@@ -772,6 +982,10 @@ func PostCampaign(c *Campaign, uid int64) error {
 				RId:        r.RId,
 				SendDate:   sendDate,
 				Processing: processing,
+				SMTPId:     c.SMTPId,
+			}
+			if selector != nil {
+				m.SMTPId = selector.pick()
 			}
 			err = tx.Save(m).Error
 			if err != nil {
@@ -841,6 +1055,11 @@ func PostSMSCampaign(c *Campaign, uid int64) error {
 	}
 	c.Template = t
 	c.TemplateId = t.Id
+	err = ensureTemplateVersion(db, &c.Template)
+	if err != nil {
+		return err
+	}
+	c.TemplateVersion = c.Template.Version
 	// Check to make sure the SMS profile exists
 	s, err := GetSMSByName(c.SMS.Name, uid)
 	if err == gorm.ErrRecordNotFound {
@@ -876,17 +1095,27 @@ func PostSMSCampaign(c *Campaign, uid int64) error {
 	tx := db.Begin()
 	for _, g := range c.Groups {
 		for _, t := range g.Targets {
-			if _, ok := resultMap[t.Email]; ok {
+			// SMS campaigns can only be sent to targets with a phone number
+			if t.Phone == "" {
+				log.WithFields(logrus.Fields{
+					"email": t.Email,
+				}).Warn("Skipping target without a phone number")
 				continue
 			}
-			resultMap[t.Email] = true
+			if _, ok := resultMap[t.Phone]; ok {
+				continue
+			}
+			resultMap[t.Phone] = true
 			sendDate := c.generateSendDate(recipientIndex, totalRecipients)
 			r := &Result{
 				BaseRecipient: BaseRecipient{
-					Email:     t.Email,
-					Position:  t.Position,
-					FirstName: t.FirstName,
-					LastName:  t.LastName,
+					Email:      t.Email,
+					Position:   t.Position,
+					FirstName:  t.FirstName,
+					LastName:   t.LastName,
+					Phone:      t.Phone,
+					Language:   t.Language,
+					Attributes: t.Attributes,
 				},
 				Status:       StatusScheduled,
 				CampaignId:   c.Id,
@@ -918,7 +1147,7 @@ func PostSMSCampaign(c *Campaign, uid int64) error {
 				RId:        r.RId,
 				SendDate:   sendDate,
 				Processing: processing,
-				Target:     t.Email, // Re-using Email field for Phone number in SMS
+				Target:     t.Phone,
 			}
 			err = tx.Save(m).Error
 			if err != nil {
@@ -942,7 +1171,7 @@ func GetCampaignSMSContext(id int64, uid int64) (Campaign, error) {
 	if err != nil {
 		return c, err
 	}
-	err = db.Table("templates").Where("id=?", c.TemplateId).Find(&c.Template).Error
+	err = c.getTemplate()
 	if err != nil {
 		return c, err
 	}
@@ -970,6 +1199,12 @@ func DeleteCampaign(id int64, uid int64) error {
 		return err
 	}
 	err = tx.Where("campaign_id=?", c.Id).Delete(MailLog{}).Error
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return err
+	}
+	err = tx.Where("campaign_id=?", c.Id).Delete(CampaignSMTP{}).Error
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/mailer"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/simulation"
+)
+
+// ErrEncryptionKeyNotSet is thrown when a secret needs to be stored but no
+// encryption key is configured
+var ErrEncryptionKeyNotSet = errors.New("No encryption key configured. Please set encryption_key in config.json")
+
+// ErrDKIMSelectorNotSpecified is thrown when a sending profile specifies a
+// DKIM domain without a selector, or vice versa
+var ErrDKIMSelectorNotSpecified = errors.New("DKIM signing requires both a domain and a selector")
+
+// ErrDKIMKeyNotSpecified is thrown when a sending profile is configured for
+// DKIM signing without a private key
+var ErrDKIMKeyNotSpecified = errors.New("No DKIM private key specified")
+
+// ErrDKIMNotConfigured is thrown when validating the DKIM record of a
+// sending profile which doesn't sign messages
+var ErrDKIMNotConfigured = errors.New("DKIM signing is not configured for this sending profile")
+
+// lookupTXT resolves DNS TXT records. It's a variable so that tests can
+// avoid making DNS queries.
+var lookupTXT = net.LookupTXT
+
+// DKIMValidation is the result of checking a sending profile's published
+// DKIM record against its private key
+type DKIMValidation struct {
+	Valid    bool     `json:"valid"`
+	Name     string   `json:"name"`
+	Expected string   `json:"expected"`
+	Records  []string `json:"records"`
+	Message  string   `json:"message"`
+}
+
+// encryptionKey derives the 32 byte AES-256 key used to encrypt secrets
+// from the configured encryption key.
+func encryptionKey() (string, error) {
+	if conf == nil || conf.EncryptionKey == "" {
+		return "", ErrEncryptionKeyNotSet
+	}
+	key := sha256.Sum256([]byte(conf.EncryptionKey))
+	return string(key[:]), nil
+}
+
+// encryptSecret encrypts a secret so that it can be stored in the database
+func encryptSecret(secret string) (string, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return "", err
+	}
+	return simulation.EncryptRD(secret, key)
+}
+
+// decryptSecret decrypts a secret stored with encryptSecret
+func decryptSecret(secret string) (string, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return "", err
+	}
+	return simulation.DecryptRD(secret, key)
+}
+
+// validateDKIM ensures that the sending profile's DKIM settings are complete
+// and that any newly provided private key can be used for signing
+func (s *SMTP) validateDKIM() error {
+	if (s.DKIMDomain == "") != (s.DKIMSelector == "") {
+		return ErrDKIMSelectorNotSpecified
+	}
+	if s.DKIMPrivateKey != "" {
+		_, err := mailer.ParseDKIMPrivateKey(s.DKIMPrivateKey)
+		return err
+	}
+	return nil
+}
+
+// setDKIMKey encrypts a newly provided DKIM private key for storage. If no
+// new key was provided, the existing encrypted key is kept.
+func (s *SMTP) setDKIMKey(existing string) error {
+	if s.DKIMPrivateKey == "" {
+		s.EncryptedDKIMKey = existing
+	} else {
+		key, err := encryptSecret(s.DKIMPrivateKey)
+		if err != nil {
+			return err
+		}
+		s.EncryptedDKIMKey = key
+		s.DKIMPrivateKey = ""
+	}
+	if s.DKIMDomain != "" && s.EncryptedDKIMKey == "" {
+		return ErrDKIMKeyNotSpecified
+	}
+	return nil
+}
+
+// getDKIMSigner returns the signer used to sign messages sent through this
+// profile, or nil if DKIM signing isn't configured.
+func (s *SMTP) getDKIMSigner() (*mailer.DKIMSigner, error) {
+	if s.DKIMDomain == "" || s.DKIMSelector == "" || s.EncryptedDKIMKey == "" {
+		return nil, nil
+	}
+	key, err := decryptSecret(s.EncryptedDKIMKey)
+	if err != nil {
+		return nil, err
+	}
+	return mailer.NewDKIMSigner(s.DKIMDomain, s.DKIMSelector, key)
+}
+
+// ValidateDKIMRecord checks that the DKIM record published in DNS for the
+// sending profile's domain and selector matches its private key.
+func (s *SMTP) ValidateDKIMRecord() (DKIMValidation, error) {
+	v := DKIMValidation{}
+	signer, err := s.getDKIMSigner()
+	if err != nil {
+		return v, err
+	}
+	if signer == nil {
+		return v, ErrDKIMNotConfigured
+	}
+	v.Name = fmt.Sprintf("%s._domainkey.%s", s.DKIMSelector, s.DKIMDomain)
+	v.Expected, err = mailer.DKIMRecord(signer.Key)
+	if err != nil {
+		return v, err
+	}
+	v.Records, err = lookupTXT(v.Name)
+	if err != nil {
+		v.Message = fmt.Sprintf("Unable to find DKIM record: %s", err)
+		return v, nil
+	}
+	expected := dkimTags(v.Expected)
+	for _, record := range v.Records {
+		tags := dkimTags(record)
+		keyType := tags["k"]
+		if keyType == "" {
+			keyType = "rsa"
+		}
+		if tags["p"] == expected["p"] && keyType == expected["k"] {
+			v.Valid = true
+			v.Message = "DKIM record matches the sending profile's private key"
+			return v, nil
+		}
+	}
+	v.Message = "DKIM record does not match the sending profile's private key"
+	return v, nil
+}
+
+// dkimTags parses the tag=value pairs in a DKIM record. Whitespace is
+// removed from values, since long keys are often split.
+func dkimTags(record string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(record, ";") {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		tags[strings.TrimSpace(kv[0])] = strings.Join(strings.Fields(kv[1]), "")
+	}
+	return tags
+}
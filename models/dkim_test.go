@@ -0,0 +1,133 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/mailer"
+	"gopkg.in/check.v1"
+)
+
+func generateDKIMKey(ch *check.C) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	ch.Assert(err, check.Equals, nil)
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+}
+
+func (s *ModelsSuite) createDKIMProfile(ch *check.C) SMTP {
+	smtp := SMTP{
+		Name:           "DKIM Profile",
+		Host:           "example.com:25",
+		FromAddress:    "sender@example.com",
+		UserId:         1,
+		DKIMDomain:     "example.com",
+		DKIMSelector:   "sim",
+		DKIMPrivateKey: generateDKIMKey(ch),
+	}
+	ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+	return smtp
+}
+
+func (s *ModelsSuite) TestPostSMTPDKIM(ch *check.C) {
+	conf.EncryptionKey = "test encryption key"
+	defer func() { conf.EncryptionKey = "" }()
+
+	key := generateDKIMKey(ch)
+	smtp := SMTP{
+		Name:        "DKIM Profile",
+		Host:        "example.com:25",
+		FromAddress: "sender@example.com",
+		UserId:      1,
+		DKIMDomain:  "example.com",
+	}
+	ch.Assert(PostSMTP(&smtp), check.Equals, ErrDKIMSelectorNotSpecified)
+	smtp.DKIMSelector = "sim"
+	ch.Assert(PostSMTP(&smtp), check.Equals, ErrDKIMKeyNotSpecified)
+	smtp.DKIMPrivateKey = "invalid"
+	ch.Assert(PostSMTP(&smtp), check.Equals, mailer.ErrInvalidDKIMKey)
+	smtp.DKIMPrivateKey = key
+	ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+
+	// The key should only be stored encrypted
+	stored, err := GetSMTP(smtp.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(stored.DKIMPrivateKey, check.Equals, "")
+	ch.Assert(stored.EncryptedDKIMKey, check.Not(check.Equals), "")
+	ch.Assert(strings.Contains(stored.EncryptedDKIMKey, "PRIVATE KEY"), check.Equals, false)
+
+	// Updating the profile without providing a key keeps the existing one
+	stored.Name = "Updated DKIM Profile"
+	ch.Assert(PutSMTP(&stored), check.Equals, nil)
+	stored, err = GetSMTP(smtp.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(stored.EncryptedDKIMKey, check.Not(check.Equals), "")
+
+	d, err := stored.GetDialer()
+	ch.Assert(err, check.Equals, nil)
+	signer := d.(*Dialer).Signer().(*mailer.DKIMSigner)
+	ch.Assert(signer.Domain, check.Equals, "example.com")
+	ch.Assert(signer.Selector, check.Equals, "sim")
+}
+
+func (s *ModelsSuite) TestPostSMTPDKIMNoEncryptionKey(ch *check.C) {
+	smtp := SMTP{
+		Name:           "DKIM Profile",
+		Host:           "example.com:25",
+		FromAddress:    "sender@example.com",
+		UserId:         1,
+		DKIMDomain:     "example.com",
+		DKIMSelector:   "sim",
+		DKIMPrivateKey: generateDKIMKey(ch),
+	}
+	ch.Assert(PostSMTP(&smtp), check.Equals, ErrEncryptionKeyNotSet)
+}
+
+func (s *ModelsSuite) TestValidateDKIMRecord(ch *check.C) {
+	conf.EncryptionKey = "test encryption key"
+	defer func() { conf.EncryptionKey = "" }()
+	smtp := s.createDKIMProfile(ch)
+
+	signer, err := smtp.getDKIMSigner()
+	ch.Assert(err, check.Equals, nil)
+	expected, err := mailer.DKIMRecord(signer.Key)
+	ch.Assert(err, check.Equals, nil)
+
+	records := map[string][]string{}
+	lookupTXT = func(name string) ([]string, error) {
+		r, ok := records[name]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		return r, nil
+	}
+	defer func() { lookupTXT = net.LookupTXT }()
+
+	v, err := smtp.ValidateDKIMRecord()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(v.Valid, check.Equals, false)
+	ch.Assert(v.Name, check.Equals, "sim._domainkey.example.com")
+
+	// Long records are often split across multiple strings
+	split := strings.Replace(expected, "p=", "p= ", 1)
+	records[v.Name] = []string{"v=spf1 -all", split}
+	v, err = smtp.ValidateDKIMRecord()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(v.Valid, check.Equals, true)
+
+	records[v.Name] = []string{"v=DKIM1; k=rsa; p=AAAA"}
+	v, err = smtp.ValidateDKIMRecord()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(v.Valid, check.Equals, false)
+
+	smtp = SMTP{}
+	_, err = smtp.ValidateDKIMRecord()
+	ch.Assert(err, check.Equals, ErrDKIMNotConfigured)
+}
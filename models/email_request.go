@@ -34,6 +34,7 @@ type EmailRequest struct {
 	FromAddress string       `json:"-"`
 	BaseRecipient
 	QRSize int `json:"qr_size"`
+	QROptions
 }
 
 func (s *EmailRequest) getBaseURL() string {
@@ -51,6 +52,10 @@ func (s *EmailRequest) getQRSize() string {
 	return strconv.Itoa(s.QRSize)
 }
 
+func (s *EmailRequest) getQROptions() QROptions {
+	return s.QROptions
+}
+
 func (s *EmailRequest) getTrackingURL() string {
 	return s.URL
 }
@@ -64,7 +69,7 @@ func (s *EmailRequest) Validate() error {
 	case s.FromAddress == "" && s.SMTP.FromAddress == "":
 		return ErrFromAddressNotSpecified
 	}
-	return nil
+	return s.QROptions.Validate()
 }
 
 // Backoff treats temporary errors as permanent since this is expected to be a
@@ -163,8 +168,13 @@ func (s *EmailRequest) Generate(msg *email.Email) error {
 		msg.Headers.Set(key, value)
 	}
 
-	// Parse remaining templates
-	subject, err := ExecuteTemplate(s.Template.Subject, ptx)
+	// Parse remaining templates, using the variant in the recipient's
+	// language
+	t := s.Template.Localize(s.Language)
+	subject, err := ExecuteTemplate(t.Subject, ptx)
+	if IsTemplateLimitError(err) {
+		return fmt.Errorf("Unable to render the email subject: %w", err)
+	}
 	if err != nil {
 		log.Error(err)
 	}
@@ -174,15 +184,21 @@ func (s *EmailRequest) Generate(msg *email.Email) error {
 	}
 
 	msg.To = []string{s.FormatAddress()}
-	if s.Template.Text != "" {
-		text, err := ExecuteTemplate(s.Template.Text, ptx)
+	if t.Text != "" {
+		text, err := ExecuteTemplate(t.Text, ptx)
+		if IsTemplateLimitError(err) {
+			return fmt.Errorf("Unable to render the email text: %w", err)
+		}
 		if err != nil {
 			log.Error(err)
 		}
 		msg.Text = []byte(text)
 	}
-	if s.Template.HTML != "" {
-		html, err := ExecuteTemplate(s.Template.HTML, ptx)
+	if t.HTML != "" {
+		html, err := ExecuteTemplate(t.HTML, ptx)
+		if IsTemplateLimitError(err) {
+			return fmt.Errorf("Unable to render the email HTML: %w", err)
+		}
 		if err != nil {
 			log.Error(err)
 		}
@@ -191,7 +207,10 @@ func (s *EmailRequest) Generate(msg *email.Email) error {
 
 	// Attach the files
 	for _, a := range s.Template.Attachments {
-		addAttachment(msg, a, ptx)
+		err = addAttachment(msg, a, ptx)
+		if err != nil {
+			log.Warn(err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,223 @@
+package models
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+)
+
+// The classifications given to opens and clicks. Only human events update the
+// status of a result, so that the campaign statistics aren't inflated by
+// automated requests.
+const (
+	ClassificationHuman      = "human"
+	ClassificationScanner    = "scanner"
+	ClassificationImageProxy = "image_proxy"
+	ClassificationPreview    = "preview"
+)
+
+// DefaultScannerWindow is the time after an email is sent during which opens
+// and clicks are attributed to mail security scanners, since recipients
+// rarely act on an email that quickly
+const DefaultScannerWindow = 10 * time.Second
+
+// headFollowUpWindow is the time after a HEAD request during which a GET
+// from the same address is attributed to the same scanner
+const headFollowUpWindow = time.Minute
+
+// defaultImageProxyNetworks are the ranges used by Apple Mail Privacy
+// Protection to prefetch remote images
+var defaultImageProxyNetworks = []string{"17.0.0.0/8"}
+
+// previewAgents are substrings of the user agents used by chat applications
+// and social networks to unfurl links
+var previewAgents = []string{
+	"slackbot",
+	"skypeuripreview",
+	"microsoftpreview",
+	"teamsbot",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"facebookexternalhit",
+	"twitterbot",
+	"linkedinbot",
+}
+
+// imageProxyAgents are substrings of the user agents used by webmail
+// providers to fetch remote images on behalf of the recipient
+var imageProxyAgents = []string{
+	"googleimageproxy",
+	"ggpht.com",
+	"yahoomailproxy",
+}
+
+// scannerAgents are substrings of the user agents used by mail security
+// gateways and generic HTTP clients
+var scannerAgents = []string{
+	"barracuda",
+	"mimecast",
+	"proofpoint",
+	"messagelabs",
+	"symantec",
+	"forcepoint",
+	"ironport",
+	"trendmicro",
+	"fireeye",
+	"sophos",
+	"zscaler",
+	"bot",
+	"crawler",
+	"spider",
+	"headlesschrome",
+	"phantomjs",
+	"python-requests",
+	"python-urllib",
+	"go-http-client",
+	"curl/",
+	"wget/",
+	"java/",
+	"libwww-perl",
+	"okhttp",
+}
+
+// headRequests records the HEAD requests made for each result within the
+// last headFollowUpWindow, keyed by the result id and address
+type headRequests struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// recentHeadRequests holds the HEAD requests used to classify the requests
+// which follow them
+var recentHeadRequests = &headRequests{seen: make(map[string]time.Time)}
+
+// add records a HEAD request, forgetting the requests which are too old to
+// be followed up
+func (h *headRequests) add(key string, t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for k, seen := range h.seen {
+		if t.Sub(seen) > headFollowUpWindow {
+			delete(h.seen, k)
+		}
+	}
+	h.seen[key] = t
+}
+
+// since returns whether a HEAD request was recorded for the key since t
+func (h *headRequests) since(key string, t time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	seen, ok := h.seen[key]
+	return ok && !seen.Before(t)
+}
+
+// EventClassificationEnabled returns whether opens and clicks are
+// classified, rather than all being counted as made by the recipient
+func EventClassificationEnabled() bool {
+	return conf != nil && conf.EventClassification.Enabled
+}
+
+// classifyEvent determines whether an open or click was made by the recipient
+// or by something acting on their behalf, returning the classification and
+// the signal it was based on.
+func (r *Result) classifyEvent(message string, details EventDetails) (string, string) {
+	if !EventClassificationEnabled() {
+		return ClassificationHuman, ""
+	}
+	ua := strings.ToLower(details.Browser["user-agent"])
+	ip := net.ParseIP(details.Browser["address"])
+	switch {
+	case details.Browser["method"] == "HEAD":
+		if ip != nil {
+			recentHeadRequests.add(r.RId+" "+ip.String(), time.Now().UTC())
+		}
+		return ClassificationScanner, "HEAD request"
+	case ua == "":
+		return ClassificationScanner, "missing user agent"
+	case containsAny(ua, previewAgents):
+		return ClassificationPreview, "link preview user agent"
+	case containsAny(ua, imageProxyAgents):
+		return ClassificationImageProxy, "image proxy user agent"
+	case containsAny(ua, scannerAgents):
+		return ClassificationScanner, "scanner user agent"
+	case message == EventOpened && inNetworks(ip, imageProxyNetworks()):
+		return ClassificationImageProxy, "image proxy address"
+	case inNetworks(ip, scannerNetworks()):
+		return ClassificationScanner, "scanner address"
+	case r.sentRecently():
+		return ClassificationScanner, "too soon after sending"
+	case ip != nil && r.followsHeadRequest(ip):
+		return ClassificationScanner, "follows a HEAD request"
+	}
+	return ClassificationHuman, ""
+}
+
+// followsHeadRequest returns whether a HEAD request was recently made for the
+// result from the same address
+func (r *Result) followsHeadRequest(ip net.IP) bool {
+	return recentHeadRequests.since(r.RId+" "+ip.String(), time.Now().UTC().Add(-headFollowUpWindow))
+}
+
+// sentRecently returns whether the email was sent within the scanner window
+// and the recipient hasn't interacted with it since. The send date is only
+// the time the email was sent while the status is still sent.
+func (r *Result) sentRecently() bool {
+	if r.Status != EventSent && r.Status != EventSMSSent {
+		return false
+	}
+	return time.Since(r.SendDate) < scannerWindow()
+}
+
+func scannerWindow() time.Duration {
+	if conf == nil || conf.EventClassification.ScannerWindow == 0 {
+		return DefaultScannerWindow
+	}
+	return time.Duration(conf.EventClassification.ScannerWindow) * time.Second
+}
+
+func scannerNetworks() []string {
+	if conf == nil {
+		return nil
+	}
+	return conf.EventClassification.ScannerNetworks
+}
+
+func imageProxyNetworks() []string {
+	if conf == nil || len(conf.EventClassification.ImageProxyNetworks) == 0 {
+		return defaultImageProxyNetworks
+	}
+	return conf.EventClassification.ImageProxyNetworks
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// inNetworks returns whether the IP is in any of the CIDR ranges. Invalid
+// ranges are logged and skipped.
+func inNetworks(ip net.IP, cidrs []string) bool {
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Errorf("invalid network %q in event classification settings: %v", cidr, err)
+			continue
+		}
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
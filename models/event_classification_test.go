@@ -0,0 +1,142 @@
+package models
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+const testBrowserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+// newHumanEventDetails returns the details of a request made by a
+// recipient's browser
+func newHumanEventDetails() EventDetails {
+	return newTestEventDetails("GET", testBrowserUserAgent, "198.51.100.10")
+}
+
+func newTestEventDetails(method, ua, address string) EventDetails {
+	return EventDetails{Browser: map[string]string{
+		"method":     method,
+		"user-agent": ua,
+		"address":    address,
+	}}
+}
+
+func (s *ModelsSuite) enableEventClassification() func() {
+	conf.EventClassification.Enabled = true
+	return func() { conf.EventClassification.Enabled = false }
+}
+
+func (s *ModelsSuite) TestClassifyEvent(ch *check.C) {
+	defer s.enableEventClassification()()
+	r := Result{Status: EventSent, SendDate: time.Now().UTC().Add(-time.Hour)}
+	tests := []struct {
+		message  string
+		details  EventDetails
+		expected string
+	}{
+		{EventClicked, newHumanEventDetails(), ClassificationHuman},
+		{EventOpened, newHumanEventDetails(), ClassificationHuman},
+		{EventClicked, newTestEventDetails("HEAD", testBrowserUserAgent, "198.51.100.11"), ClassificationScanner},
+		{EventClicked, newTestEventDetails("GET", "", "198.51.100.10"), ClassificationScanner},
+		{EventClicked, newTestEventDetails("GET", "python-requests/2.31.0", "198.51.100.10"), ClassificationScanner},
+		{EventClicked, newTestEventDetails("GET", "Mozilla/5.0 (compatible; Barracuda Sentinel)", "198.51.100.10"), ClassificationScanner},
+		{EventClicked, newTestEventDetails("GET", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", "198.51.100.10"), ClassificationPreview},
+		{EventOpened, newTestEventDetails("GET", "Mozilla/5.0 (Windows NT 5.1; rv:11.0) Gecko Firefox/11.0 (via ggpht.com GoogleImageProxy)", "66.249.84.1"), ClassificationImageProxy},
+		{EventOpened, newTestEventDetails("GET", "Mozilla/5.0", "17.58.1.1"), ClassificationImageProxy},
+		// Clicks from Apple's network are made by people using Apple devices
+		{EventClicked, newTestEventDetails("GET", testBrowserUserAgent, "17.58.1.1"), ClassificationHuman},
+	}
+	for _, test := range tests {
+		classification, _ := r.classifyEvent(test.message, test.details)
+		ch.Assert(classification, check.Equals, test.expected, check.Commentf("%s %v", test.message, test.details.Browser))
+	}
+
+	// Configured scanner networks are used
+	conf.EventClassification.ScannerNetworks = []string{"invalid", "203.0.113.0/24"}
+	defer func() { conf.EventClassification.ScannerNetworks = nil }()
+	classification, reason := r.classifyEvent(EventClicked, newTestEventDetails("GET", testBrowserUserAgent, "203.0.113.7"))
+	ch.Assert(classification, check.Equals, ClassificationScanner)
+	ch.Assert(reason, check.Equals, "scanner address")
+
+	// Requests just after sending are made by scanners, unless the recipient
+	// already interacted with the email
+	r.SendDate = time.Now().UTC()
+	classification, _ = r.classifyEvent(EventClicked, newHumanEventDetails())
+	ch.Assert(classification, check.Equals, ClassificationScanner)
+	r.Status = EventOpened
+	classification, _ = r.classifyEvent(EventClicked, newHumanEventDetails())
+	ch.Assert(classification, check.Equals, ClassificationHuman)
+}
+
+func (s *ModelsSuite) TestAutomatedEventsNotCounted(ch *check.C) {
+	defer s.enableEventClassification()()
+	c := s.createCampaignDependencies(ch)
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	r := c.Results[0]
+	status := r.Status
+
+	// Opens by image proxies and scanner clicks are recorded without
+	// updating the status
+	proxy := newTestEventDetails("GET", "Mozilla/5.0 (via ggpht.com GoogleImageProxy)", "66.249.84.1")
+	ch.Assert(r.HandleEmailOpened(proxy), check.Equals, nil)
+	ch.Assert(r.HandleClickedLink(newTestEventDetails("HEAD", testBrowserUserAgent, "198.51.100.20")), check.Equals, nil)
+	// A GET following the HEAD request is made by the same scanner
+	ch.Assert(r.HandleClickedLink(newTestEventDetails("GET", testBrowserUserAgent, "198.51.100.20")), check.Equals, nil)
+	ch.Assert(r.Status, check.Equals, status)
+	_, err := GetTrainingAssignment(r.RId)
+	ch.Assert(err, check.Equals, ErrTrainingAssignmentNotFound)
+
+	stats, err := getCampaignStats(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(stats.OpenedEmail, check.Equals, int64(0))
+	ch.Assert(stats.ClickedLink, check.Equals, int64(0))
+	ch.Assert(stats.Raw.OpenedEmail, check.Equals, int64(1))
+	ch.Assert(stats.Raw.ClickedLink, check.Equals, int64(1))
+	ch.Assert(stats.Raw.Automated, check.DeepEquals, map[string]int64{
+		ClassificationImageProxy: 1,
+		ClassificationScanner:    2,
+	})
+
+	// Clicks by the recipient are counted
+	ch.Assert(r.HandleClickedLink(newHumanEventDetails()), check.Equals, nil)
+	ch.Assert(r.Status, check.Equals, EventClicked)
+	stats, err = getCampaignStats(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(stats.OpenedEmail, check.Equals, int64(1))
+	ch.Assert(stats.ClickedLink, check.Equals, int64(1))
+	ch.Assert(stats.Raw.ClickedLink, check.Equals, int64(1))
+
+	events := []Event{}
+	ch.Assert(db.Where("campaign_id=? and message=?", c.Id, EventClicked).Order("id").Find(&events).Error, check.Equals, nil)
+	ch.Assert(len(events), check.Equals, 3)
+	d := EventDetails{}
+	ch.Assert(json.Unmarshal([]byte(events[1].Details), &d), check.Equals, nil)
+	ch.Assert(d.Classification, check.Equals, ClassificationScanner)
+	ch.Assert(d.ClassificationReason, check.Equals, "follows a HEAD request")
+	ch.Assert(events[2].Classification, check.Equals, ClassificationHuman)
+}
+
+func (s *ModelsSuite) TestEventClassificationDisabled(ch *check.C) {
+	r := Result{RId: "disabled", Status: EventSent, SendDate: time.Now().UTC()}
+	classification, reason := r.classifyEvent(EventClicked, newTestEventDetails("HEAD", "python-requests/2.31.0", "198.51.100.30"))
+	ch.Assert(classification, check.Equals, ClassificationHuman)
+	ch.Assert(reason, check.Equals, "")
+	ch.Assert(r.followsHeadRequest(net.ParseIP("198.51.100.30")), check.Equals, false)
+}
+
+func (s *ModelsSuite) TestFollowsHeadRequest(ch *check.C) {
+	defer s.enableEventClassification()()
+	r := Result{RId: "follows", Status: EventOpened}
+	ip := net.ParseIP("198.51.100.40")
+	ch.Assert(r.followsHeadRequest(ip), check.Equals, false)
+	r.classifyEvent(EventClicked, newTestEventDetails("HEAD", testBrowserUserAgent, ip.String()))
+	ch.Assert(r.followsHeadRequest(ip), check.Equals, true)
+	ch.Assert(r.followsHeadRequest(net.ParseIP("198.51.100.41")), check.Equals, false)
+
+	// HEAD requests are forgotten once they're too old to be followed up
+	recentHeadRequests.add("other", time.Now().UTC().Add(2*headFollowUpWindow))
+	ch.Assert(r.followsHeadRequest(ip), check.Equals, false)
+}
@@ -1,9 +1,12 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/mail"
+	"strings"
 	"time"
 
 	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
@@ -53,6 +56,26 @@ type GroupTarget struct {
 type Target struct {
 	Id int64 `json:"-"`
 	BaseRecipient
+	// phoneGiven is whether the phone field was included when the target
+	// was decoded from JSON, even if it was empty
+	phoneGiven bool
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, recording whether
+// the phone number was given so that it can be cleared by sending it empty
+func (t *Target) UnmarshalJSON(b []byte) error {
+	type target Target
+	err := json.Unmarshal(b, (*target)(t))
+	if err != nil {
+		return err
+	}
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(b, &fields)
+	if err != nil {
+		return err
+	}
+	_, t.phoneGiven = fields["phone"]
+	return nil
 }
 
 // BaseRecipient contains the fields for a single recipient. This is the base
@@ -62,6 +85,72 @@ type BaseRecipient struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	Position  string `json:"position"`
+	Phone     string `json:"phone"`
+	// Language is the recipient's preferred language, used to choose
+	// between a template's variants
+	Language string `json:"language"`
+	// Attributes are custom fields, such as a department or manager, which
+	// templates can use with {{.Attr "name"}}
+	Attributes RecipientAttributes `json:"attributes" gorm:"type:text"`
+}
+
+// RecipientAttributes are the custom fields for a recipient. Names are case
+// insensitive and are stored in lower case. The attributes are stored as a
+// JSON object.
+type RecipientAttributes map[string]string
+
+// Value implements the driver.Valuer interface
+func (ra RecipientAttributes) Value() (driver.Value, error) {
+	if len(ra) == 0 {
+		return "", nil
+	}
+	normalized := make(map[string]string, len(ra))
+	for name, value := range ra {
+		normalized[strings.ToLower(strings.TrimSpace(name))] = value
+	}
+	data, err := json.Marshal(normalized)
+	return string(data), err
+}
+
+// Scan implements the sql.Scanner interface
+func (ra *RecipientAttributes) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("unable to scan %T into RecipientAttributes", value)
+	}
+	*ra = RecipientAttributes{}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, ra)
+}
+
+// Attr returns the value of the recipient's custom attribute with the given
+// name, or an empty string if the recipient doesn't have the attribute.
+func (r BaseRecipient) Attr(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for n, value := range r.Attributes {
+		if strings.ToLower(n) == name {
+			return value
+		}
+	}
+	return ""
+}
+
+// recipientKey returns the value used to uniquely identify a target. Email
+// addresses are preferred, but SMS-only targets are identified by their
+// phone number.
+func (r *BaseRecipient) recipientKey() string {
+	if r.Email != "" {
+		return r.Email
+	}
+	return r.Phone
 }
 
 // FormatAddress returns the email address to use in the "To" header of the email
@@ -99,7 +188,12 @@ var ErrGroupNameNotSpecified = errors.New("Group name not specified")
 // ErrNoTargetsSpecified is thrown when no targets are specified by the user
 var ErrNoTargetsSpecified = errors.New("No targets specified")
 
-// Validate performs validation on a group given by the user
+// ErrRecipientNotSpecified is thrown when a target has neither an email
+// address nor a phone number
+var ErrRecipientNotSpecified = errors.New("No email address or phone number specified")
+
+// Validate performs validation on a group given by the user. Any phone
+// numbers provided for the targets are normalized to the E.164 format.
 func (g *Group) Validate() error {
 	switch {
 	case g.Name == "":
@@ -107,6 +201,18 @@ func (g *Group) Validate() error {
 	case len(g.Targets) == 0:
 		return ErrNoTargetsSpecified
 	}
+	for i, t := range g.Targets {
+		if t.Phone != "" {
+			phone, err := NormalizePhoneNumber(t.Phone, GetDefaultCountryCode())
+			if err != nil {
+				return fmt.Errorf("%s: %s", err, t.Phone)
+			}
+			g.Targets[i].Phone = phone
+		}
+		if t.recipientKey() == "" {
+			return ErrRecipientNotSpecified
+		}
+	}
 	return nil
 }
 
@@ -267,18 +373,18 @@ func PutGroup(g *Group) error {
 	// Preload the caches
 	cacheNew := make(map[string]int64, len(g.Targets))
 	for _, t := range g.Targets {
-		cacheNew[t.Email] = t.Id
+		cacheNew[t.recipientKey()] = t.Id
 	}
 
 	cacheExisting := make(map[string]int64, len(ts))
 	for _, t := range ts {
-		cacheExisting[t.Email] = t.Id
+		cacheExisting[t.recipientKey()] = t.Id
 	}
 
 	tx := db.Begin()
 	// Check existing targets, removing any that are no longer in the group.
 	for _, t := range ts {
-		if _, ok := cacheNew[t.Email]; ok {
+		if _, ok := cacheNew[t.recipientKey()]; ok {
 			continue
 		}
 
@@ -295,7 +401,7 @@ func PutGroup(g *Group) error {
 	for _, nt := range g.Targets {
 		// If the target already exists in the database, we should just update
 		// the record with the latest information.
-		if id, ok := cacheExisting[nt.Email]; ok {
+		if id, ok := cacheExisting[nt.recipientKey()]; ok {
 			nt.Id = id
 			err = UpdateTarget(tx, nt)
 			if err != nil {
@@ -354,19 +460,24 @@ func DeleteGroup(id int64, uid int64) error {
 }
 
 func insertTargetIntoGroup(tx *gorm.DB, t Target, gid int64) (int64, int64, error) {
-	// If the email doesn't parse as an address, it might be a phone number for SMS.
-	// We log it as a warning but don't block the request.
-	if _, err := mail.ParseAddress(t.Email); err != nil {
+	// SMS-only targets may not have an email address, but anything we
+	// were given should parse. We log it as a warning but don't block the
+	// request.
+	if _, err := mail.ParseAddress(t.Email); t.Email != "" && err != nil {
 		log.WithFields(logrus.Fields{
 			"email": t.Email,
-		}).Warn("Non-standard email address provided (could be an SMS phone number)")
+		}).Warn("Non-standard email address provided")
 	}
 	var addedTargetID int64
 	var linkedTargetID int64
 
 	// Check if target exists
 	existing := Target{}
-	err := tx.Where("email = ?", t.Email).First(&existing).Error
+	query := tx.Where("email = ?", t.Email)
+	if t.Email == "" {
+		query = tx.Where("email = ? AND phone = ?", "", t.Phone)
+	}
+	err := query.First(&existing).Error
 	if err != nil && !gorm.IsRecordNotFoundError(err) {
 		return 0, 0, err
 	}
@@ -404,13 +515,26 @@ func insertTargetIntoGroup(tx *gorm.DB, t Target, gid int64) (int64, int64, erro
 	return addedTargetID, linkedTargetID, nil
 }
 
-// UpdateTarget updates the given target information in the database.
+// UpdateTarget updates the given target information in the database. The
+// phone number, language and attributes are left unchanged when none are
+// given, so that clients which don't send them don't remove them. The phone
+// number can be removed by sending it empty, and attributes by giving an
+// empty set.
 func UpdateTarget(tx *gorm.DB, target Target) error {
 	targetInfo := map[string]interface{}{
 		"first_name": target.FirstName,
 		"last_name":  target.LastName,
 		"position":   target.Position,
 	}
+	if target.Phone != "" || target.phoneGiven {
+		targetInfo["phone"] = target.Phone
+	}
+	if target.Language != "" {
+		targetInfo["language"] = target.Language
+	}
+	if target.Attributes != nil {
+		targetInfo["attributes"] = target.Attributes
+	}
 	err := tx.Model(&target).Where("id = ?", target.Id).Updates(targetInfo).Error
 	if err != nil {
 		log.WithFields(logrus.Fields{
@@ -423,7 +547,7 @@ func UpdateTarget(tx *gorm.DB, target Target) error {
 // GetTargets performs a many-to-many select to get all the Targets for a Group
 func GetTargets(gid int64) ([]Target, error) {
 	ts := []Target{}
-	err := db.Table("targets").Select("targets.id, targets.email, targets.first_name, targets.last_name, targets.position").Joins("left join group_targets gt ON targets.id = gt.target_id").Where("gt.group_id=?", gid).Scan(&ts).Error
+	err := db.Table("targets").Select("targets.id, targets.email, targets.first_name, targets.last_name, targets.position, targets.phone, targets.language, targets.attributes").Joins("left join group_targets gt ON targets.id = gt.target_id").Where("gt.group_id=?", gid).Scan(&ts).Error
 	return ts, err
 }
 
@@ -291,3 +291,33 @@ func BenchmarkPutGroup10000(b *testing.B) {
 	}
 	tearDownBenchmark(b)
 }
+
+func (s *ModelsSuite) TestTargetAttributes(c *check.C) {
+	group := Group{Name: "Attribute Group", UserId: 1}
+	group.Targets = []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "attrs@example.com", Attributes: RecipientAttributes{"Department": "Finance"}}},
+	}
+	c.Assert(PostGroup(&group), check.Equals, nil)
+	targets, err := GetTargets(group.Id)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(targets), check.Equals, 1)
+	c.Assert(targets[0].Attributes, check.DeepEquals, RecipientAttributes{"department": "Finance"})
+	c.Assert(targets[0].Attr("DEPARTMENT"), check.Equals, "Finance")
+
+	// Clients which don't send the attributes don't remove them
+	group.Targets = []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "attrs@example.com", FirstName: "Attrs"}},
+	}
+	c.Assert(PutGroup(&group), check.Equals, nil)
+	targets, err = GetTargets(group.Id)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(targets[0].FirstName, check.Equals, "Attrs")
+	c.Assert(targets[0].Attr("department"), check.Equals, "Finance")
+
+	// Giving an empty set removes them
+	group.Targets[0].Attributes = RecipientAttributes{}
+	c.Assert(PutGroup(&group), check.Equals, nil)
+	targets, err = GetTargets(group.Id)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(targets[0].Attr("department"), check.Equals, "")
+}
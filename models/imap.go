@@ -28,6 +28,10 @@ type IMAP struct {
 	LastLogin                   time.Time `json:"last_login,omitempty"`
 	ModifiedDate                time.Time `json:"modified_date"`
 	IMAPFreq                    uint32    `json:"imap_freq,string,omitempty"`
+	// BounceFolder is an optional folder which is checked for delivery
+	// status notifications in addition to Folder, e.g. when bounces are
+	// filtered out of the inbox.
+	BounceFolder string `json:"bounce_folder"`
 }
 
 // ErrIMAPHostNotSpecified is thrown when there is no Host specified
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Difficulty levels for templates and pages. These follow the detection
+// difficulty ratings of the NIST Phish Scale, which weighs the number of
+// observable cues in a lure against how well its premise aligns with the
+// target's work context.
+const (
+	// DifficultyLow lures have many cues and a poorly aligned premise, so
+	// they are the least difficult to detect
+	DifficultyLow = "low"
+	// DifficultyModerate lures are moderately difficult to detect
+	DifficultyModerate = "moderate"
+	// DifficultyHigh lures have few cues and a well aligned premise, so
+	// they are very difficult to detect
+	DifficultyHigh = "high"
+)
+
+// ErrInvalidDifficulty is thrown when a template or page has an unknown
+// difficulty level
+var ErrInvalidDifficulty = errors.New("Invalid difficulty. Must be one of low, moderate or high")
+
+// ErrInvalidLanguage is thrown when a template or page language isn't a
+// valid language tag
+var ErrInvalidLanguage = errors.New("Invalid language. Languages must be language tags such as en or en-US")
+
+// languageRegex matches BCP 47 style language tags, e.g. "en" or "pt-BR"
+var languageRegex = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// TagList is a list of tags. It's stored as a single comma separated column
+// with leading and trailing commas, so that a tag can be matched with LIKE.
+type TagList []string
+
+// Value implements the driver.Valuer interface
+func (tl TagList) Value() (driver.Value, error) {
+	tags := normalizeTags(tl)
+	if len(tags) == 0 {
+		return "", nil
+	}
+	return "," + strings.Join(tags, ",") + ",", nil
+}
+
+// Scan implements the sql.Scanner interface
+func (tl *TagList) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("unable to scan %T into TagList", value)
+	}
+	*tl = normalizeTags(strings.Split(s, ","))
+	return nil
+}
+
+// normalizeTags lowercases, trims, sorts and removes duplicate and empty
+// tags. Commas are removed since they separate stored tags.
+func normalizeTags(tags []string) TagList {
+	seen := make(map[string]bool)
+	normalized := TagList{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(strings.Replace(tag, ",", "", -1)))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// validateLibraryFields validates the fields used to organize templates and
+// pages
+func validateLibraryFields(difficulty, language string) error {
+	switch difficulty {
+	case "", DifficultyLow, DifficultyModerate, DifficultyHigh:
+	default:
+		return ErrInvalidDifficulty
+	}
+	if language != "" && !languageRegex.MatchString(language) {
+		return ErrInvalidLanguage
+	}
+	return nil
+}
+
+// LibraryFilter filters the templates or pages returned by GetTemplates and
+// GetPages. Empty fields aren't filtered on.
+type LibraryFilter struct {
+	// Search matches part of the name, or the subject of templates
+	Search string
+	// Tags matches items which have every one of the tags
+	Tags       []string
+	Difficulty string
+	// Language matches the language exactly or, if no region is given, any
+	// region of the language (e.g. "en" matches "en-GB")
+	Language string
+	Category string
+}
+
+// likeEscape is the escape character used in LIKE patterns. A backslash
+// isn't used since MySQL and SQLite treat it differently in string literals.
+const likeEscape = "!"
+
+// escapeLike escapes the wildcards in a value used in a LIKE pattern, so
+// that it's matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_").Replace(s)
+}
+
+// apply adds the filter's conditions to a query on the given table
+func (f LibraryFilter) apply(query *gorm.DB, table string) *gorm.DB {
+	if f.Search != "" {
+		search := "%" + escapeLike(strings.ToLower(f.Search)) + "%"
+		if table == "templates" {
+			query = query.Where(fmt.Sprintf("LOWER(templates.name) LIKE ? ESCAPE '%[1]s' OR LOWER(templates.subject) LIKE ? ESCAPE '%[1]s'", likeEscape), search, search)
+		} else {
+			query = query.Where(fmt.Sprintf("LOWER(%s.name) LIKE ? ESCAPE '%s'", table, likeEscape), search)
+		}
+	}
+	for _, tag := range normalizeTags(f.Tags) {
+		query = query.Where(fmt.Sprintf("%s.tags LIKE ? ESCAPE '%s'", table, likeEscape), "%,"+escapeLike(tag)+",%")
+	}
+	if f.Difficulty != "" {
+		query = query.Where(fmt.Sprintf("%s.difficulty = ?", table), f.Difficulty)
+	}
+	if f.Language != "" {
+		query = query.Where(fmt.Sprintf("%s.language = ? OR %s.language LIKE ? ESCAPE '%s'", table, table, likeEscape), f.Language, escapeLike(f.Language)+"-%")
+	}
+	if f.Category != "" {
+		query = query.Where(fmt.Sprintf("%s.category = ?", table), f.Category)
+	}
+	return query
+}
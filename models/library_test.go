@@ -0,0 +1,104 @@
+package models
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) createLibraryTemplate(ch *check.C, name, subject, difficulty, language string, tags ...string) Template {
+	t := Template{Name: name, UserId: 1}
+	t.Subject = subject
+	t.HTML = "<p>{{.URL}}</p>"
+	t.Tags = tags
+	t.Difficulty = difficulty
+	t.Language = language
+	t.Category = "credentials"
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+	return t
+}
+
+func templateNames(ts []Template) []string {
+	names := []string{}
+	for _, t := range ts {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func (s *ModelsSuite) TestTemplateTags(ch *check.C) {
+	t := s.createLibraryTemplate(ch, "Library Tags", "Invoice", DifficultyLow, "en", " Finance", "invoice", "finance", "")
+	got, err := GetTemplate(t.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Tags, check.DeepEquals, TagList{"finance", "invoice"})
+	ch.Assert(got.Difficulty, check.Equals, DifficultyLow)
+	ch.Assert(got.Category, check.Equals, "credentials")
+}
+
+func (s *ModelsSuite) TestLibraryValidation(ch *check.C) {
+	t := Template{Name: "Library Invalid", UserId: 1, HTML: "<p>{{.URL}}</p>"}
+	t.Difficulty = "impossible"
+	ch.Assert(PostTemplate(&t), check.Equals, ErrInvalidDifficulty)
+	t.Difficulty = DifficultyHigh
+	t.Language = "not a language"
+	ch.Assert(PostTemplate(&t), check.Equals, ErrInvalidLanguage)
+
+	p := Page{Name: "Library Invalid Page", UserId: 1, HTML: "<html></html>"}
+	p.Difficulty = "extreme"
+	ch.Assert(PostPage(&p), check.Equals, ErrInvalidDifficulty)
+}
+
+func (s *ModelsSuite) TestGetTemplatesFilter(ch *check.C) {
+	s.createLibraryTemplate(ch, "Library Payroll", "Your payslip", DifficultyHigh, "en-GB", "hr", "payroll")
+	s.createLibraryTemplate(ch, "Library Parcel", "Delivery failed", DifficultyLow, "de", "delivery")
+	s.createLibraryTemplate(ch, "Library Benefits", "Open enrollment", DifficultyModerate, "en", "hr")
+
+	ts, err := GetTemplates(1, LibraryFilter{Search: "library", Tags: []string{"HR"}})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(templateNames(ts), check.DeepEquals, []string{"Library Payroll", "Library Benefits"})
+
+	ts, err = GetTemplates(1, LibraryFilter{Search: "PAYSLIP"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(templateNames(ts), check.DeepEquals, []string{"Library Payroll"})
+
+	ts, err = GetTemplates(1, LibraryFilter{Search: "library", Language: "en"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(templateNames(ts), check.DeepEquals, []string{"Library Payroll", "Library Benefits"})
+
+	ts, err = GetTemplates(1, LibraryFilter{Tags: []string{"hr", "payroll"}, Difficulty: DifficultyHigh})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(templateNames(ts), check.DeepEquals, []string{"Library Payroll"})
+
+	ts, err = GetTemplates(1, LibraryFilter{Search: "library", Category: "other"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ts), check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestGetTemplatesFilterWildcards(ch *check.C) {
+	s.createLibraryTemplate(ch, "Library 100% Off", "Sale", DifficultyLow, "en", "a_b")
+	s.createLibraryTemplate(ch, "Library 1000 Gifts", "Sale", DifficultyLow, "en", "axb")
+
+	// Wildcards in the search and tags are matched literally
+	ts, err := GetTemplates(1, LibraryFilter{Search: "library 100%"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(templateNames(ts), check.DeepEquals, []string{"Library 100% Off"})
+
+	ts, err = GetTemplates(1, LibraryFilter{Tags: []string{"a_b"}})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(templateNames(ts), check.DeepEquals, []string{"Library 100% Off"})
+}
+
+func (s *ModelsSuite) TestCampaignStatsDifficulty(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.Template.Difficulty = DifficultyHigh
+	ch.Assert(PutTemplate(&c.Template), check.Equals, nil)
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+
+	// The difficulty is taken from the version the campaign was launched
+	// with, even if the template is edited afterwards
+	edited := c.Template
+	edited.Difficulty = DifficultyLow
+	ch.Assert(PutTemplate(&edited), check.Equals, nil)
+
+	stats, err := getCampaignStats(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(stats.Difficulty, check.Equals, DifficultyHigh)
+}
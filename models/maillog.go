@@ -42,8 +42,15 @@ type MailLog struct {
 	SendDate    time.Time `json:"send_date"`
 	SendAttempt int       `json:"send_attempt"`
 	Processing  bool      `json:"-"`
+	// SMTPId is the sending profile used to send the email, which may be
+	// any profile in the campaign's pool.
+	SMTPId          int64 `json:"smtp_id"`
+	FailoverAttempt int   `json:"failover_attempt"`
 
 	cachedCampaign *Campaign
+	// messageID is the Message-Id of the most recently generated email,
+	// which is stored on the result so that bounces can be matched to it.
+	messageID string
 }
 
 // GenerateMailLog creates a new maillog for the given campaign and
@@ -67,6 +74,21 @@ func (m *MailLog) Backoff(reason error) error {
 	if err != nil {
 		return err
 	}
+	// Mail deferred by the sending profile's rate limits hasn't failed, so
+	// it's rescheduled without counting it as a send attempt.
+	var rle *mailer.ErrRateLimited
+	if errors.As(reason, &rle) {
+		m.SendDate = time.Now().UTC().Add(rle.RetryAfter)
+		err = db.Save(m).Error
+		if err != nil {
+			return err
+		}
+		err = r.HandleEmailDeferred(m.SendDate)
+		if err != nil {
+			return err
+		}
+		return m.Unlock()
+	}
 	if m.SendAttempt == MaxSendAttempts {
 		r.HandleEmailError(ErrMaxSendAttempts)
 		return ErrMaxSendAttempts
@@ -125,6 +147,8 @@ func (m *MailLog) Success() error {
 	if err != nil {
 		return err
 	}
+	r.SMTPId = m.SMTPId
+	r.MessageId = m.messageID
 	err = r.HandleEmailSent()
 	if err != nil {
 		return err
@@ -133,15 +157,78 @@ func (m *MailLog) Success() error {
 	return err
 }
 
-// GetDialer returns a dialer based on the maillog campaign's SMTP configuration
+// Failover switches the maillog to the next sending profile in its
+// campaign's pool after its current profile could not be reached. The
+// maillog is unlocked without changing its send date, so that it is retried
+// through the new profile right away.
+func (m *MailLog) Failover(reason error) error {
+	c, err := m.getCampaign()
+	if err != nil {
+		return err
+	}
+	current := m.SMTPId
+	if current == 0 {
+		current = c.SMTPId
+	}
+	next, err := c.nextFailoverSMTP(current, m.FailoverAttempt)
+	if err != nil {
+		return err
+	}
+	r, err := GetResult(m.RId)
+	if err != nil {
+		return err
+	}
+	m.SMTPId = next
+	m.FailoverAttempt++
+	err = db.Save(m).Error
+	if err != nil {
+		return err
+	}
+	err = r.HandleEmailBackoff(reason, m.SendDate)
+	if err != nil {
+		return err
+	}
+	return m.Unlock()
+}
+
+// getCampaign returns the maillog's campaign, using the cached campaign if
+// one is available.
+func (m *MailLog) getCampaign() (*Campaign, error) {
+	if m.cachedCampaign != nil {
+		return m.cachedCampaign, nil
+	}
+	campaign, err := GetCampaignMailContext(m.CampaignId, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// getSendingCampaign returns the maillog's campaign with its sending
+// profile set to the profile the maillog is assigned to.
+func (m *MailLog) getSendingCampaign() (*Campaign, error) {
+	c, err := m.getCampaign()
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.getSMTP(m.SMTPId)
+	if err != nil {
+		return nil, err
+	}
+	if s.Id == c.SMTP.Id {
+		return c, nil
+	}
+	sc := *c
+	sc.SMTP = s
+	return &sc, nil
+}
+
+// GetDialer returns a dialer based on the SMTP configuration of the
+// sending profile assigned to the maillog
 func (m *MailLog) GetDialer() (mailer.Dialer, error) {
-	c := m.cachedCampaign
-	if c == nil {
-		campaign, err := GetCampaignMailContext(m.CampaignId, 0)
-		if err != nil {
-			return nil, err
-		}
-		c = &campaign
+	c, err := m.getSendingCampaign()
+	if err != nil {
+		return nil, err
 	}
 	return c.SMTP.GetDialer()
 }
@@ -157,7 +244,7 @@ func (m *MailLog) CacheCampaign(campaign *Campaign) error {
 }
 
 func (m *MailLog) GetSmtpFrom() (string, error) {
-	c, err := GetCampaign(m.CampaignId, 0)
+	c, err := m.getSendingCampaign()
 	if err != nil {
 		return "", err
 	}
@@ -183,15 +270,27 @@ func (m *MailLog) Generate(msg *email.Email) error {
 	if err != nil {
 		return err
 	}
-	c := m.cachedCampaign
-	if c == nil {
-		campaign, err := GetCampaignMailContext(m.CampaignId, 0)
-		if err != nil {
-			return err
-		}
-		c = &campaign
+	c, err := m.getSendingCampaign()
+	if err != nil {
+		return err
 	}
+	err = generateMail(msg, c, r, func(field string, err error) {
+		log.Warn(err)
+	})
+	if err != nil {
+		return err
+	}
+	m.messageID = msg.Headers.Get("Message-Id")
+	return nil
+}
+
+// renderWarning is called when part of an email can't be rendered. These
+// errors don't prevent the email from being sent, so they are reported
+// through this callback rather than returned.
+type renderWarning func(field string, err error)
 
+// generateMail renders the campaign's email for the given result.
+func generateMail(msg *email.Email, c *Campaign, r Result, warn renderWarning) error {
 	f, err := mail.ParseAddress(c.Template.EnvelopeSender)
 	if err != nil {
 		f, err = mail.ParseAddress(c.SMTP.FromAddress)
@@ -205,6 +304,9 @@ func (m *MailLog) Generate(msg *email.Email) error {
 	if err != nil {
 		return err
 	}
+	if !r.SendDate.IsZero() {
+		ptx.SendDate = r.SendDate
+	}
 
 	if ptx.QRBase64 != "" {
 		qrContent, err := base64.StdEncoding.DecodeString(ptx.QRBase64)
@@ -220,33 +322,47 @@ func (m *MailLog) Generate(msg *email.Email) error {
 	}
 
 	// Add Message-Id header as described in RFC 2822.
-	messageID, err := m.generateMessageID()
+	messageID, err := generateMessageID()
 	if err != nil {
 		return err
 	}
 	msg.Headers.Set("Message-Id", messageID)
 
+	// Templates which exceed the execution limits can't be rendered, so
+	// the email fails rather than being sent with missing content
+	render := func(field, text string) (string, error) {
+		rendered, err := ExecuteTemplate(text, ptx)
+		if IsTemplateLimitError(err) {
+			return "", fmt.Errorf("Unable to render the email %s: %w", field, err)
+		}
+		if err != nil {
+			warn(field, err)
+		}
+		return rendered, nil
+	}
+
 	// Parse the customHeader templates
 	for _, header := range c.SMTP.Headers {
-		key, err := ExecuteTemplate(header.Key, ptx)
+		key, err := render("header", header.Key)
 		if err != nil {
-			log.Warn(err)
+			return err
 		}
 
-		value, err := ExecuteTemplate(header.Value, ptx)
+		value, err := render("header", header.Value)
 		if err != nil {
-			log.Warn(err)
+			return err
 		}
 
 		// Add our header immediately
 		msg.Headers.Set(key, value)
 	}
 
-	// Parse remaining templates
-	subject, err := ExecuteTemplate(c.Template.Subject, ptx)
-
+	// Parse remaining templates, using the variant in the recipient's
+	// language
+	t := c.Template.Localize(r.Language)
+	subject, err := render("subject", t.Subject)
 	if err != nil {
-		log.Warn(err)
+		return err
 	}
 	// don't set Subject header if the subject is empty
 	if subject != "" {
@@ -254,23 +370,29 @@ func (m *MailLog) Generate(msg *email.Email) error {
 	}
 
 	msg.To = []string{r.FormatAddress()}
-	if c.Template.Text != "" {
-		text, err := ExecuteTemplate(c.Template.Text, ptx)
+	if t.Text != "" {
+		text, err := render("text", t.Text)
 		if err != nil {
-			log.Warn(err)
+			return err
 		}
 		msg.Text = []byte(text)
 	}
-	if c.Template.HTML != "" {
-		html, err := ExecuteTemplate(c.Template.HTML, ptx)
+	if t.HTML != "" {
+		html, err := render("html", t.HTML)
 		if err != nil {
-			log.Warn(err)
+			return err
 		}
 		msg.HTML = []byte(html)
 	}
 	// Attach the files
 	for _, a := range c.Template.Attachments {
-		addAttachment(msg, a, ptx)
+		err = addAttachment(msg, a, ptx)
+		if IsTemplateLimitError(err) {
+			return fmt.Errorf("Unable to render the email attachment %s: %w", a.Name, err)
+		}
+		if err != nil {
+			warn("attachment", err)
+		}
 	}
 
 	return nil
@@ -327,7 +449,7 @@ var maxBigInt = big.NewInt(math.MaxInt64)
 // - The calling PID
 // - A cryptographically random int64
 // - The sending hostname
-func (m *MailLog) generateMessageID() (string, error) {
+func generateMessageID() (string, error) {
 	t := time.Now().UnixNano()
 	pid := os.Getpid()
 	rint, err := rand.Int(rand.Reader, maxBigInt)
@@ -357,16 +479,14 @@ func shouldEmbedAttachment(name string) bool {
 
 // Add an attachment to a gomail message, with the Content-Disposition
 // header set to inline or attachment depending on its file extension.
-func addAttachment(msg *email.Email, a Attachment, ptx PhishingTemplateContext) {
+func addAttachment(msg *email.Email, a Attachment, ptx PhishingTemplateContext) error {
 	reader, err := a.ApplyTemplate(ptx)
 	if err != nil {
-		log.Warn(err)
-		return
+		return err
 	}
 	content, err := io.ReadAll(reader)
 	if err != nil {
-		log.Warn(err)
-		return
+		return err
 	}
 	at := &email.Attachment{
 		Filename:    a.Name,
@@ -378,4 +498,5 @@ func addAttachment(msg *email.Email, a Attachment, ptx PhishingTemplateContext)
 		at.Header.Set("Content-Disposition", "inline")
 	}
 	msg.Attachments = append(msg.Attachments, at)
+	return nil
 }
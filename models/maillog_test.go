@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/7nikhilkamboj/TrustStrike-Simulation/config"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/mailer"
 
 	"github.com/jordan-wright/email"
 	"gopkg.in/check.v1"
@@ -104,6 +105,31 @@ func (s *ModelsSuite) TestMailLogBackoff(ch *check.C) {
 	ch.Assert(err, check.Equals, ErrMaxSendAttempts)
 }
 
+func (s *ModelsSuite) TestMailLogBackoffRateLimited(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	result := campaign.Results[0]
+	m := &MailLog{}
+	err := db.Where("r_id=? AND campaign_id=?", result.RId, campaign.Id).
+		Find(m).Error
+	ch.Assert(err, check.Equals, nil)
+
+	err = m.Lock()
+	ch.Assert(err, check.Equals, nil)
+	before := time.Now().UTC()
+	err = m.Backoff(&mailer.ErrRateLimited{RetryAfter: time.Minute})
+	ch.Assert(err, check.Equals, nil)
+
+	// Deferred mail shouldn't count against the maximum send attempts
+	ch.Assert(m.SendAttempt, check.Equals, 0)
+	ch.Assert(m.Processing, check.Equals, false)
+	ch.Assert(m.SendDate.Before(before.Add(time.Minute)), check.Equals, false)
+
+	result, err = GetResult(m.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.Status, check.Equals, StatusScheduled)
+	ch.Assert(result.SendDate.Equal(m.SendDate), check.Equals, true)
+}
+
 func (s *ModelsSuite) TestMailLogError(ch *check.C) {
 	campaign := s.createCampaign(ch)
 	result := campaign.Results[0]
@@ -56,8 +56,10 @@ const (
 	EventClicked       string = "Clicked Link"
 	EventDataSubmit    string = "Submitted Data"
 	EventReported      string = "Email Reported"
+	EventBounced       string = "Email Bounced"
 	EventSMSSent       string = "SMS Sent"
 	EventProxyRequest  string = "Proxied request"
+	EventTraining      string = "Completed Training"
 	StatusSuccess      string = "Success"
 	StatusQueued       string = "Queued"
 	StatusSending      string = "Sending"
@@ -52,6 +52,14 @@ func (s *ModelsSuite) TearDownTest(c *check.C) {
 	db.Delete(Result{})
 	db.Delete(MailLog{})
 	db.Delete(Campaign{})
+	db.Delete(CampaignSMTP{})
+	db.Delete(TrainingPage{})
+	db.Delete(TrainingModule{})
+	db.Delete(TrainingAssignment{})
+	db.Delete(LRS{})
+	db.Delete(XAPIStatement{})
+	db.Delete(PageAsset{})
+	db.Delete(PhishingHost{})
 
 	// Reset users table to default state.
 	db.Not("id", 1).Delete(User{})
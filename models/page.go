@@ -1,7 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -18,20 +20,112 @@ type Page struct {
 	CaptureCredentials bool      `json:"capture_credentials" gorm:"column:capture_credentials"`
 	CapturePasswords   bool      `json:"capture_passwords" gorm:"column:capture_passwords"`
 	RedirectURL        string    `json:"redirect_url" gorm:"column:redirect_url"`
+	Tags               TagList   `json:"tags"`
+	Difficulty         string    `json:"difficulty"`
+	Language           string    `json:"language"`
+	Category           string    `json:"category"`
+	Steps              PageSteps `json:"steps" gorm:"column:steps;type:text"`
 	ModifiedDate       time.Time `json:"modified_date"`
 	CreatedBy          string    `json:"created_by" sql:"-"`
 }
 
+// PageStep is a page shown after the recipient submits the previous step
+// of a landing page, such as an MFA prompt shown after the login form. The
+// last step may show the campaign's training page instead of its HTML. In
+// campaigns without a training page, the step before it is the last step.
+type PageStep struct {
+	Name     string `json:"name"`
+	HTML     string `json:"html"`
+	Training bool   `json:"training"`
+}
+
+// PageSteps are the steps shown after a landing page's HTML, stored as a
+// JSON array
+type PageSteps []PageStep
+
+// Value implements the driver.Valuer interface
+func (ps PageSteps) Value() (driver.Value, error) {
+	return jsonValue(ps, len(ps))
+}
+
+// Scan implements the sql.Scanner interface
+func (ps *PageSteps) Scan(value interface{}) error {
+	*ps = PageSteps{}
+	return jsonScan(value, ps)
+}
+
 // ErrPageNameNotSpecified is thrown if the name of the landing page is blank.
 var ErrPageNameNotSpecified = errors.New("Page Name not specified")
 
+// ErrPageStepHTMLNotSpecified is thrown if a step of a landing page has no
+// HTML and doesn't show the training page
+var ErrPageStepHTMLNotSpecified = errors.New("Page step HTML not specified")
+
+// ErrInvalidPageTrainingStep is thrown if a step other than the last one of
+// a landing page shows the training page
+var ErrInvalidPageTrainingStep = errors.New("Only the last page step can show the training page")
+
+// StepCount returns the number of steps in the page, including the page's
+// own HTML
+func (p *Page) StepCount() int {
+	return len(p.Steps) + 1
+}
+
+// LastStep returns the index of the page's last step
+func (p *Page) LastStep() int {
+	return len(p.Steps)
+}
+
+// StepName returns the name of a step, which is "Step N" for unnamed steps
+func (p *Page) StepName(i int) string {
+	if i > 0 && i <= len(p.Steps) && p.Steps[i-1].Name != "" {
+		return p.Steps[i-1].Name
+	}
+	return fmt.Sprintf("Step %d", i+1)
+}
+
+// IsTrainingStep returns whether the step shows the campaign's training page
+func (p *Page) IsTrainingStep(i int) bool {
+	return i > 0 && i <= len(p.Steps) && p.Steps[i-1].Training
+}
+
+// Step returns a copy of the page which renders the given step. Step 0 is
+// the page's own HTML.
+func (p *Page) Step(i int) Page {
+	sp := *p
+	if i > 0 && i <= len(p.Steps) {
+		sp.HTML = p.Steps[i-1].HTML
+	}
+	return sp
+}
+
 // parseHTML parses the page HTML on save to handle the
 // capturing (or lack thereof!) of credentials and passwords
 func (p *Page) parseHTML() error {
-	d, err := goquery.NewDocumentFromReader(strings.NewReader(p.HTML))
+	var err error
+	p.HTML, err = p.parseFormHTML(p.HTML)
 	if err != nil {
 		return err
 	}
+	for i := range p.Steps {
+		if p.Steps[i].HTML == "" {
+			continue
+		}
+		p.Steps[i].HTML, err = p.parseFormHTML(p.Steps[i].HTML)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseFormHTML updates the forms in the HTML of a page or page step to
+// submit to our server and capture the configured fields
+func (p *Page) parseFormHTML(html string) (string, error) {
+	d, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return html, err
+	}
 	forms := d.Find("form")
 	forms.Each(func(i int, f *goquery.Selection) {
 		// We always want the submitted events to be
@@ -66,8 +160,7 @@ func (p *Page) parseHTML() error {
 			})
 		}
 	})
-	p.HTML, err = d.Html()
-	return err
+	return d.Html()
 }
 
 // Validate ensures that a page contains the appropriate details
@@ -80,22 +173,41 @@ func (p *Page) Validate() error {
 	if p.CapturePasswords && !p.CaptureCredentials {
 		p.CaptureCredentials = true
 	}
+	if err := validateLibraryFields(p.Difficulty, p.Language); err != nil {
+		return err
+	}
 	if err := ValidateTemplate(p.HTML); err != nil {
 		return err
 	}
 	if err := ValidateTemplate(p.RedirectURL); err != nil {
 		return err
 	}
+	for i, s := range p.Steps {
+		if s.Training {
+			if i != len(p.Steps)-1 {
+				return ErrInvalidPageTrainingStep
+			}
+			continue
+		}
+		if s.HTML == "" {
+			return ErrPageStepHTMLNotSpecified
+		}
+		if err := ValidateTemplate(s.HTML); err != nil {
+			return err
+		}
+	}
 	return p.parseHTML()
 }
 
-// GetPages returns the pages owned by the given user.
-func GetPages(uid int64) ([]Page, error) {
+// GetPages returns the pages owned by the given user which match the
+// filter.
+func GetPages(uid int64, f LibraryFilter) ([]Page, error) {
 	ps := []Page{}
 	query := db.Model(&Page{})
 	if uid != 0 {
 		query = query.Where("pages.user_id = ?", uid)
 	}
+	query = f.apply(query, "pages")
 	query = query.Select("pages.*, users.username as created_by").Joins("left join users on pages.user_id = users.id")
 	err := query.Find(&ps).Error
 	if err != nil {
@@ -185,6 +297,11 @@ func DeletePage(id int64, uid int64) error {
 	if uid != 0 && uid != 1 && p.UserId == 1 {
 		return errors.New("Only administrators can delete this resource. Please contact the admin.")
 	}
+	err = DeletePageAssets(id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	err = db.Delete(Page{Id: id}).Error
 	if err != nil {
 		log.Error(err)
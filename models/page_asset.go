@@ -0,0 +1,353 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PageAssetPath is the path on the phishing server where the assets
+// uploaded for a landing page are served, followed by the page's id
+const PageAssetPath = "assets"
+
+// pageAssetIndex is the file in a page bundle used as the page's HTML
+const pageAssetIndex = "index.html"
+
+// MaxPageAssetSize is the largest file accepted in a page bundle
+const MaxPageAssetSize = 10 << 20
+
+// MaxPageAssets is the number of files accepted in a page bundle
+const MaxPageAssets = 500
+
+// MaxPageBundleSize is the total uncompressed size of the files accepted in
+// a page bundle
+const MaxPageBundleSize = 50 << 20
+
+// ErrInvalidPageBundle is thrown when an uploaded page bundle isn't a
+// valid zip file
+var ErrInvalidPageBundle = errors.New("Page bundle must be a zip file")
+
+// ErrEmptyPageBundle is thrown when a page bundle doesn't contain any files
+var ErrEmptyPageBundle = errors.New("Page bundle doesn't contain any files")
+
+// ErrTooManyPageAssets is thrown when a page bundle contains more than
+// MaxPageAssets files
+var ErrTooManyPageAssets = fmt.Errorf("Page bundles can contain at most %d files", MaxPageAssets)
+
+// ErrPageBundleTooLarge is thrown when the files in a page bundle are
+// larger than MaxPageBundleSize once uncompressed
+var ErrPageBundleTooLarge = fmt.Errorf("Page bundles can contain at most %d MB of files", MaxPageBundleSize>>20)
+
+// ErrPageAssetNotFound is thrown when a page doesn't have an asset with the
+// requested path
+var ErrPageAssetNotFound = errors.New("Page asset not found")
+
+// PageAsset is a file, such as an image or stylesheet, uploaded for a
+// landing page
+type PageAsset struct {
+	Id           int64     `json:"id"`
+	PageId       int64     `json:"page_id"`
+	Path         string    `json:"path"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Content      []byte    `json:"-"`
+	ModifiedDate time.Time `json:"modified_date"`
+}
+
+// PageBundleImport is the result of uploading a page bundle
+type PageBundleImport struct {
+	Assets      []PageAsset `json:"assets"`
+	HTMLUpdated bool        `json:"html_updated"`
+}
+
+// cleanAssetPath returns the normalized path of a file in a page bundle.
+// Paths which would escape the bundle return false.
+func cleanAssetPath(p string) (string, bool) {
+	p = strings.Replace(p, "\\", "/", -1)
+	if strings.HasPrefix(p, "/") {
+		return "", false
+	}
+	cleaned := path.Clean(p)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+	return cleaned, true
+}
+
+// assetContentType returns the content type an asset is served with
+func assetContentType(p string, content []byte) string {
+	ct := mime.TypeByExtension(path.Ext(p))
+	if ct == "" {
+		ct = http.DetectContentType(content)
+	}
+	return ct
+}
+
+// readPageBundle returns the files in a page bundle.
+func readPageBundle(data []byte, modified time.Time) ([]PageAsset, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrInvalidPageBundle
+	}
+	// The sizes recorded in the bundle are checked before decompressing
+	// anything, since the zip reader fails if a file's content doesn't match
+	// its recorded size
+	var declared uint64
+	for _, f := range zr.File {
+		if f.UncompressedSize64 > MaxPageAssetSize {
+			return nil, fmt.Errorf("%s is too large", f.Name)
+		}
+		declared += f.UncompressedSize64
+		if declared > MaxPageBundleSize {
+			return nil, ErrPageBundleTooLarge
+		}
+	}
+	assets := []PageAsset{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		p, ok := cleanAssetPath(f.Name)
+		if !ok {
+			return nil, fmt.Errorf("%s isn't a valid path in a page bundle", f.Name)
+		}
+		// Skip the metadata added by macOS archive utilities
+		if strings.HasPrefix(p, "__MACOSX/") || path.Base(p) == ".DS_Store" {
+			continue
+		}
+		if len(assets) == MaxPageAssets {
+			return nil, ErrTooManyPageAssets
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, MaxPageAssetSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(content) > MaxPageAssetSize {
+			return nil, fmt.Errorf("%s is too large", f.Name)
+		}
+		assets = append(assets, PageAsset{
+			Path:         p,
+			ContentType:  assetContentType(p, content),
+			Size:         int64(len(content)),
+			Content:      content,
+			ModifiedDate: modified,
+		})
+	}
+	if len(assets) == 0 {
+		return nil, ErrEmptyPageBundle
+	}
+	// Bundles are often zipped from the folder containing the page, in which
+	// case that folder is removed from the paths
+	root := strings.SplitN(assets[0].Path, "/", 2)[0] + "/"
+	index := false
+	for _, a := range assets {
+		if !strings.HasPrefix(a.Path, root) {
+			return assets, nil
+		}
+		index = index || a.Path == root+pageAssetIndex
+	}
+	if !index {
+		return assets, nil
+	}
+	for i := range assets {
+		assets[i].Path = strings.TrimPrefix(assets[i].Path, root)
+	}
+	return assets, nil
+}
+
+// ImportPageAssets replaces the assets of a page with the files in the
+// uploaded zip bundle. If the bundle contains an index.html, it's used as
+// the page's HTML and isn't stored as an asset.
+func ImportPageAssets(p *Page, data []byte) (PageBundleImport, error) {
+	result := PageBundleImport{Assets: []PageAsset{}}
+	now := time.Now().UTC()
+	assets, err := readPageBundle(data, now)
+	if err != nil {
+		return result, err
+	}
+	for _, a := range assets {
+		if a.Path == pageAssetIndex {
+			p.HTML = string(a.Content)
+			p.ModifiedDate = now
+			err = p.Validate()
+			if err != nil {
+				return result, err
+			}
+			result.HTMLUpdated = true
+			continue
+		}
+		result.Assets = append(result.Assets, a)
+	}
+	tx := db.Begin()
+	err = tx.Where("page_id=?", p.Id).Delete(&PageAsset{}).Error
+	if err != nil {
+		tx.Rollback()
+		log.Error(err)
+		return result, err
+	}
+	for i := range result.Assets {
+		result.Assets[i].PageId = p.Id
+		err = tx.Save(&result.Assets[i]).Error
+		if err != nil {
+			tx.Rollback()
+			log.Error(err)
+			return result, err
+		}
+	}
+	if result.HTMLUpdated {
+		err = tx.Model(p).Updates(map[string]interface{}{"html": p.HTML, "modified_date": p.ModifiedDate}).Error
+		if err != nil {
+			tx.Rollback()
+			log.Error(err)
+			return result, err
+		}
+	}
+	return result, tx.Commit().Error
+}
+
+// GetPageAssets returns the assets uploaded for a page, without their
+// content
+func GetPageAssets(pageId int64) ([]PageAsset, error) {
+	as := []PageAsset{}
+	err := db.Select("id, page_id, path, content_type, size, modified_date").
+		Where("page_id=?", pageId).Order("path asc").Find(&as).Error
+	return as, err
+}
+
+// GetPageAsset returns the asset with the given path uploaded for a page
+func GetPageAsset(pageId int64, p string) (PageAsset, error) {
+	a := PageAsset{}
+	p, ok := cleanAssetPath(p)
+	if !ok {
+		return a, ErrPageAssetNotFound
+	}
+	err := db.Where("page_id=? and path=?", pageId, p).First(&a).Error
+	if err != nil {
+		return a, ErrPageAssetNotFound
+	}
+	return a, nil
+}
+
+// DeletePageAssets deletes the assets uploaded for a page
+func DeletePageAssets(pageId int64) error {
+	return db.Where("page_id=?", pageId).Delete(&PageAsset{}).Error
+}
+
+// PageAssetURL returns the path the phishing server serves a page's asset
+// from
+func PageAssetURL(pageId int64, p string) string {
+	return fmt.Sprintf("/%s/%d/%s", PageAssetPath, pageId, p)
+}
+
+// cssURLRegex matches the url() references in CSS
+var cssURLRegex = regexp.MustCompile(`url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)`)
+
+// assetRewriter rewrites references to a page's assets to the URL they're
+// loaded from
+type assetRewriter struct {
+	urls map[string]string
+}
+
+// rewrite returns the URL an asset reference is loaded from, leaving
+// references which aren't to the page's assets unchanged
+func (ar *assetRewriter) rewrite(ref string) string {
+	trimmed := strings.TrimSpace(ref)
+	if trimmed == "" || strings.HasPrefix(trimmed, "/") || strings.HasPrefix(trimmed, "#") ||
+		strings.HasPrefix(trimmed, "?") || strings.Contains(strings.SplitN(trimmed, "/", 2)[0], ":") {
+		return ref
+	}
+	p, suffix := trimmed, ""
+	if i := strings.IndexAny(p, "?#"); i != -1 {
+		p, suffix = p[:i], p[i:]
+	}
+	p, ok := cleanAssetPath(p)
+	if !ok {
+		return ref
+	}
+	u, ok := ar.urls[p]
+	if !ok {
+		return ref
+	}
+	return u + suffix
+}
+
+// rewriteCSS rewrites the url() references in CSS
+func (ar *assetRewriter) rewriteCSS(css string) string {
+	return cssURLRegex.ReplaceAllStringFunc(css, func(m string) string {
+		parts := cssURLRegex.FindStringSubmatch(m)
+		return "url(" + parts[1] + ar.rewrite(parts[2]) + parts[3] + ")"
+	})
+}
+
+// rewriteSrcset rewrites each candidate in a srcset attribute
+func (ar *assetRewriter) rewriteSrcset(srcset string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, c := range candidates {
+		fields := strings.Fields(c)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = ar.rewrite(fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// RewriteAssetURLs rewrites the relative references in the rendered page
+// HTML which point to the page's uploaded assets, so they're loaded from the
+// phishing server regardless of the path the page was requested from.
+func (p *Page) RewriteAssetURLs(html string) (string, error) {
+	as, err := GetPageAssets(p.Id)
+	if err != nil || len(as) == 0 {
+		return html, err
+	}
+	urls := make(map[string]string)
+	for _, a := range as {
+		urls[a.Path] = PageAssetURL(p.Id, a.Path)
+	}
+	return rewriteAssetURLs(html, urls)
+}
+
+// rewriteAssetURLs rewrites the asset references in the HTML to the URLs
+// they're loaded from
+func rewriteAssetURLs(html string, urls map[string]string) (string, error) {
+	ar := &assetRewriter{urls: urls}
+	d, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return html, err
+	}
+	for _, attr := range []string{"src", "href", "poster", "data-src", "background"} {
+		d.Find("[" + attr + "]").Each(func(i int, s *goquery.Selection) {
+			v, _ := s.Attr(attr)
+			s.SetAttr(attr, ar.rewrite(v))
+		})
+	}
+	d.Find("[srcset]").Each(func(i int, s *goquery.Selection) {
+		v, _ := s.Attr("srcset")
+		s.SetAttr("srcset", ar.rewriteSrcset(v))
+	})
+	d.Find("[style]").Each(func(i int, s *goquery.Selection) {
+		v, _ := s.Attr("style")
+		s.SetAttr("style", ar.rewriteCSS(v))
+	})
+	d.Find("style").Each(func(i int, s *goquery.Selection) {
+		s.SetText(ar.rewriteCSS(s.Text()))
+	})
+	return d.Html()
+}
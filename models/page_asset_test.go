@@ -0,0 +1,121 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func newTestPageBundle(ch *check.C, files map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		ch.Assert(err, check.Equals, nil)
+		_, err = f.Write([]byte(content))
+		ch.Assert(err, check.Equals, nil)
+	}
+	ch.Assert(zw.Close(), check.Equals, nil)
+	return buf.Bytes()
+}
+
+// newRawPageBundle returns a bundle whose files record the given
+// uncompressed sizes without containing that much data
+func newRawPageBundle(ch *check.C, sizes map[string]uint64) []byte {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for name, size := range sizes {
+		f, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               name,
+			Method:             zip.Store,
+			CompressedSize64:   1,
+			UncompressedSize64: size,
+		})
+		ch.Assert(err, check.Equals, nil)
+		_, err = f.Write([]byte("x"))
+		ch.Assert(err, check.Equals, nil)
+	}
+	ch.Assert(zw.Close(), check.Equals, nil)
+	return buf.Bytes()
+}
+
+func (s *ModelsSuite) TestPageBundleLimits(ch *check.C) {
+	p := Page{Name: "Limited Asset Page", UserId: 1, HTML: "<html><body>Old</body></html>"}
+	ch.Assert(PostPage(&p), check.Equals, nil)
+
+	_, err := ImportPageAssets(&p, newRawPageBundle(ch, map[string]uint64{"big.bin": MaxPageAssetSize + 1}))
+	ch.Assert(err, check.ErrorMatches, "big.bin is too large")
+
+	sizes := map[string]uint64{}
+	for i := 0; i*MaxPageAssetSize <= MaxPageBundleSize; i++ {
+		sizes[fmt.Sprintf("part%d.bin", i)] = MaxPageAssetSize
+	}
+	_, err = ImportPageAssets(&p, newRawPageBundle(ch, sizes))
+	ch.Assert(err, check.Equals, ErrPageBundleTooLarge)
+
+	as, err := GetPageAssets(p.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(as), check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestImportPageAssets(ch *check.C) {
+	p := Page{Name: "Asset Page", UserId: 1, HTML: "<html><body>Old</body></html>"}
+	ch.Assert(PostPage(&p), check.Equals, nil)
+
+	_, err := ImportPageAssets(&p, []byte("not a zip"))
+	ch.Assert(err, check.Equals, ErrInvalidPageBundle)
+	_, err = ImportPageAssets(&p, newTestPageBundle(ch, map[string]string{"../escape.js": "alert(1)"}))
+	ch.Assert(err, check.NotNil)
+
+	bundle := newTestPageBundle(ch, map[string]string{
+		"site/index.html":      `<html><head><link rel="stylesheet" href="css/style.css"></head><body><img src="./img/logo.png?v=1"><img src="https://example.com/logo.png"><img src="missing.png"><div style="background: url('img/logo.png')"></div></body></html>`,
+		"site/css/style.css":   `body { background: url(../img/logo.png); }`,
+		"site/img/logo.png":    "\x89PNG\r\n\x1a\n",
+		"__MACOSX/site/._logo": "metadata",
+	})
+	result, err := ImportPageAssets(&p, bundle)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.HTMLUpdated, check.Equals, true)
+	ch.Assert(len(result.Assets), check.Equals, 2)
+
+	as, err := GetPageAssets(p.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(as), check.Equals, 2)
+	ch.Assert(as[0].Path, check.Equals, "css/style.css")
+	ch.Assert(as[0].ContentType, check.Equals, "text/css; charset=utf-8")
+	ch.Assert(as[1].Path, check.Equals, "img/logo.png")
+	ch.Assert(as[1].Content, check.IsNil)
+
+	a, err := GetPageAsset(p.Id, "img/../css/style.css")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(a.Content), check.Equals, `body { background: url(../img/logo.png); }`)
+	_, err = GetPageAsset(p.Id, "../img/logo.png")
+	ch.Assert(err, check.Equals, ErrPageAssetNotFound)
+
+	p, err = GetPage(p.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	html, err := p.RewriteAssetURLs(p.HTML)
+	ch.Assert(err, check.Equals, nil)
+	assetURL := PageAssetURL(p.Id, "")
+	ch.Assert(strings.Contains(html, `href="`+assetURL+`css/style.css"`), check.Equals, true)
+	ch.Assert(strings.Contains(html, `src="`+assetURL+`img/logo.png?v=1"`), check.Equals, true)
+	ch.Assert(strings.Contains(html, `src="https://example.com/logo.png"`), check.Equals, true)
+	ch.Assert(strings.Contains(html, `src="missing.png"`), check.Equals, true)
+	ch.Assert(strings.Contains(html, `url(&#39;`+assetURL+`img/logo.png&#39;)`), check.Equals, true)
+
+	// Uploading a bundle replaces the existing assets
+	_, err = ImportPageAssets(&p, newTestPageBundle(ch, map[string]string{"logo.svg": "<svg></svg>"}))
+	ch.Assert(err, check.Equals, nil)
+	as, err = GetPageAssets(p.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(as), check.Equals, 1)
+	ch.Assert(as[0].ContentType, check.Equals, "image/svg+xml")
+
+	ch.Assert(DeletePage(p.Id, 1), check.Equals, nil)
+	as, err = GetPageAssets(p.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(as), check.Equals, 0)
+}
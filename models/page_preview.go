@@ -0,0 +1,182 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PreviewSubmitMessage is the type of the message posted to the parent
+// window when a form is submitted in a page preview
+const PreviewSubmitMessage = "page-preview-submit"
+
+// previewFormInterceptor is added to previews so that submitting a form
+// posts its fields to the admin UI instead of leaving the page
+const previewFormInterceptor = `<script>
+document.addEventListener("submit", function (e) {
+	e.preventDefault();
+	var fields = {};
+	new FormData(e.target).forEach(function (v, k) { fields[k] = String(v); });
+	window.parent.postMessage({type: "` + PreviewSubmitMessage + `", fields: fields}, "*");
+}, true);
+</script>`
+
+// SampleRecipient is the synthetic recipient pages are previewed with when
+// no recipient is chosen
+var SampleRecipient = BaseRecipient{
+	Email:     "jane.doe@example.com",
+	FirstName: "Jane",
+	LastName:  "Doe",
+	Position:  "Employee",
+}
+
+// defaultPreviewURL is the campaign URL pages are previewed with when none
+// is given
+const defaultPreviewURL = "https://example.com"
+
+// defaultPreviewFromAddress is the sender pages are previewed with when none
+// is given
+const defaultPreviewFromAddress = "IT Support <it-support@example.com>"
+
+// ErrPreviewHTMLNotSpecified is thrown when previewing unsaved HTML without
+// providing any
+var ErrPreviewHTMLNotSpecified = errors.New("HTML not specified")
+
+// ErrInvalidPreviewStep is thrown when previewing a step the page doesn't
+// have
+var ErrInvalidPreviewStep = errors.New("Page doesn't have the requested step")
+
+// ErrPreviewTargetNotFound is thrown when the chosen recipient isn't in the
+// chosen group
+var ErrPreviewTargetNotFound = errors.New("Recipient not found in group")
+
+// PagePreview is a request to render a landing page for a recipient without
+// sending an email. The recipient is either chosen from a group, given
+// directly or, if neither, SampleRecipient. The page editor only previews
+// the page's own HTML, so steps and groups can only be chosen through the
+// API.
+type PagePreview struct {
+	// HTML renders unsaved changes instead of the HTML of the chosen step
+	HTML        string `json:"html"`
+	Step        int    `json:"step"`
+	GroupId     int64  `json:"group_id"`
+	URL         string `json:"url"`
+	FromAddress string `json:"from_address"`
+	BaseRecipient
+}
+
+// PagePreviewResult is the rendered page returned for a PagePreview
+type PagePreviewResult struct {
+	HTML      string        `json:"html"`
+	Step      int           `json:"step"`
+	StepCount int           `json:"step_count"`
+	StepName  string        `json:"step_name"`
+	Recipient BaseRecipient `json:"recipient"`
+}
+
+func (pp *PagePreview) getBaseURL() string {
+	if pp.URL == "" {
+		return defaultPreviewURL
+	}
+	return pp.URL
+}
+
+func (pp *PagePreview) getFromAddress() string {
+	if pp.FromAddress == "" {
+		return defaultPreviewFromAddress
+	}
+	return pp.FromAddress
+}
+
+func (pp *PagePreview) getQRSize() string {
+	return ""
+}
+
+func (pp *PagePreview) getQROptions() QROptions {
+	return QROptions{}
+}
+
+func (pp *PagePreview) getTrackingURL() string {
+	return pp.getBaseURL()
+}
+
+// recipient returns the recipient the page is previewed for
+func (pp *PagePreview) recipient(uid int64) (BaseRecipient, error) {
+	if pp.GroupId != 0 {
+		g, err := GetGroup(pp.GroupId, uid)
+		if err != nil {
+			return BaseRecipient{}, err
+		}
+		for _, t := range g.Targets {
+			if pp.Email == "" || strings.EqualFold(t.Email, pp.Email) {
+				return t.BaseRecipient, nil
+			}
+		}
+		return BaseRecipient{}, ErrPreviewTargetNotFound
+	}
+	if pp.Email == "" && pp.FirstName == "" && pp.LastName == "" {
+		return SampleRecipient, nil
+	}
+	return pp.BaseRecipient, nil
+}
+
+// Render returns the page HTML as it's shown to the recipient, with
+// uploaded assets inlined so the preview doesn't depend on the phishing
+// server, and with form submissions intercepted.
+func (pp *PagePreview) Render(p *Page, uid int64) (PagePreviewResult, error) {
+	result := PagePreviewResult{Step: pp.Step, StepCount: p.StepCount()}
+	if pp.Step < 0 || pp.Step > p.LastStep() {
+		return result, ErrInvalidPreviewStep
+	}
+	sp := p.Step(pp.Step)
+	switch {
+	case pp.HTML != "":
+		sp.HTML = pp.HTML
+	case p.Id == 0:
+		return result, ErrPreviewHTMLNotSpecified
+	}
+	result.StepName = p.StepName(pp.Step)
+	r, err := pp.recipient(uid)
+	if err != nil {
+		return result, err
+	}
+	result.Recipient = r
+	ptx, err := NewPhishingTemplateContext(pp, r, PreviewPrefix+"page")
+	if err != nil {
+		return result, err
+	}
+	html, err := ExecuteTemplate(sp.HTML, ptx)
+	if err != nil {
+		return result, err
+	}
+	if p.Id != 0 {
+		html, err = inlineAssets(p.Id, html)
+		if err != nil {
+			return result, err
+		}
+	}
+	d, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return result, err
+	}
+	d.Find("body").AppendHtml(previewFormInterceptor)
+	result.HTML, err = d.Html()
+	return result, err
+}
+
+// inlineAssets rewrites references to a page's uploaded assets to data URIs.
+// References made from within uploaded stylesheets aren't rewritten.
+func inlineAssets(pageId int64, html string) (string, error) {
+	as := []PageAsset{}
+	err := db.Where("page_id=?", pageId).Find(&as).Error
+	if err != nil || len(as) == 0 {
+		return html, err
+	}
+	urls := make(map[string]string)
+	for _, a := range as {
+		urls[a.Path] = "data:" + a.ContentType + ";base64," + base64.StdEncoding.EncodeToString(a.Content)
+	}
+	return rewriteAssetURLs(html, urls)
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestPagePreview(ch *check.C) {
+	group := Group{Name: "Preview Group", UserId: 1}
+	group.Targets = []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "first@example.com", FirstName: "First", LastName: "Example"}},
+		Target{BaseRecipient: BaseRecipient{Email: "second@example.com", FirstName: "Second", LastName: "Example"}},
+	}
+	ch.Assert(PostGroup(&group), check.Equals, nil)
+	p := Page{
+		Name:   "Preview Page",
+		UserId: 1,
+		HTML:   `<html><body><p>Hello {{.FirstName}}</p><img src="logo.png"><form method="post"><input name="username"></form></body></html>`,
+		Steps:  PageSteps{PageStep{Name: "Verify", HTML: "<html><body>Code for {{.Email}}</body></html>"}},
+	}
+	ch.Assert(PostPage(&p), check.Equals, nil)
+	_, err := ImportPageAssets(&p, newTestPageBundle(ch, map[string]string{"logo.png": "\x89PNG\r\n\x1a\n"}))
+	ch.Assert(err, check.Equals, nil)
+	p, err = GetPage(p.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+
+	// Without a recipient, the sample recipient is used
+	pp := PagePreview{}
+	result, err := pp.Render(&p, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.Recipient, check.DeepEquals, SampleRecipient)
+	ch.Assert(result.StepCount, check.Equals, 2)
+	ch.Assert(strings.Contains(result.HTML, "Hello "+SampleRecipient.FirstName), check.Equals, true)
+	ch.Assert(strings.Contains(result.HTML, PreviewSubmitMessage), check.Equals, true)
+	ch.Assert(strings.Contains(result.HTML, `src="data:image/png;base64,`+base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\n"))+`"`), check.Equals, true)
+
+	// Recipients can be chosen from a group
+	pp = PagePreview{GroupId: group.Id, Step: 1}
+	pp.Email = "SECOND@example.com"
+	result, err = pp.Render(&p, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.StepName, check.Equals, "Verify")
+	ch.Assert(strings.Contains(result.HTML, "Code for second@example.com"), check.Equals, true)
+	pp.Email = "missing@example.com"
+	_, err = pp.Render(&p, 1)
+	ch.Assert(err, check.Equals, ErrPreviewTargetNotFound)
+
+	// Steps are validated even when previewing unsaved HTML
+	for _, step := range []int{-1, 2, 99} {
+		pp = PagePreview{Step: step}
+		_, err = pp.Render(&p, 1)
+		ch.Assert(err, check.Equals, ErrInvalidPreviewStep)
+		pp.HTML = "<html><body>Unsaved</body></html>"
+		_, err = pp.Render(&p, 1)
+		ch.Assert(err, check.Equals, ErrInvalidPreviewStep)
+		_, err = pp.Render(&Page{}, 1)
+		ch.Assert(err, check.Equals, ErrInvalidPreviewStep)
+	}
+
+	// Unsaved HTML can be previewed with a given recipient
+	pp = PagePreview{HTML: "<html><body>{{.LastName}} {{.URL}}</body></html>", URL: "https://login.example.com/"}
+	pp.LastName = "Tester"
+	result, err = pp.Render(&Page{}, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.Contains(result.HTML, "Tester https://login.example.com/"), check.Equals, true)
+	_, err = (&PagePreview{}).Render(&Page{}, 1)
+	ch.Assert(err, check.Equals, ErrPreviewHTMLNotSpecified)
+}
@@ -140,3 +140,35 @@ func (s *ModelsSuite) TestPageValidation(c *check.C) {
 	err = p.Validate()
 	c.Assert(err, check.NotNil)
 }
+
+func (s *ModelsSuite) TestPageSteps(c *check.C) {
+	p := Page{
+		Name:   "Multi-step Page",
+		UserId: 1,
+		HTML:   `<form action="example.com"><input name="username"/></form>`,
+		Steps: PageSteps{
+			{Name: "MFA", HTML: `<form action="example.com"><input name="code"/></form>`},
+			{HTML: "{{.INVALIDTAG}}"},
+		},
+		CaptureCredentials: true,
+	}
+	c.Assert(p.Validate(), check.NotNil)
+	p.Steps[1] = PageStep{}
+	c.Assert(p.Validate(), check.Equals, ErrPageStepHTMLNotSpecified)
+	p.Steps = PageSteps{{Training: true}, p.Steps[0]}
+	c.Assert(p.Validate(), check.Equals, ErrInvalidPageTrainingStep)
+	p.Steps = PageSteps{p.Steps[1], {Training: true}}
+	c.Assert(PostPage(&p), check.Equals, nil)
+
+	p, err := GetPage(p.Id, 1)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(p.StepCount(), check.Equals, 3)
+	c.Assert(p.StepName(0), check.Equals, "Step 1")
+	c.Assert(p.StepName(1), check.Equals, "MFA")
+	c.Assert(p.IsTrainingStep(1), check.Equals, false)
+	c.Assert(p.IsTrainingStep(2), check.Equals, true)
+	// Forms in every step submit to our server
+	sp := p.Step(1)
+	c.Assert(strings.Contains(sp.HTML, `<form action=""><input name="code"/></form>`), check.Equals, true)
+	c.Assert(p.Step(0).HTML, check.Equals, p.HTML)
+}
@@ -0,0 +1,304 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+)
+
+// ErrPhishingHostnameNotSpecified is thrown when a phishing host has no
+// hostname
+var ErrPhishingHostnameNotSpecified = errors.New("Hostname not specified")
+
+// ErrInvalidPhishingHostname is thrown when a phishing host's hostname
+// includes a scheme, port or path
+var ErrInvalidPhishingHostname = errors.New("Hostname must not include a scheme, port or path")
+
+// ErrPhishingHostExists is thrown when a hostname is configured twice
+var ErrPhishingHostExists = errors.New("Hostname is already configured")
+
+// ErrInvalidPhishingHostPath is thrown when a path prefix of a phishing host
+// doesn't start with a slash
+var ErrInvalidPhishingHostPath = errors.New("Path prefixes must start with /")
+
+// ErrInvalidCampaignURL is thrown when the campaign URL isn't an absolute
+// http or https URL
+var ErrInvalidCampaignURL = errors.New("Campaign URL must be an absolute http or https URL")
+
+// ErrPhishingHostNotConfigured is thrown when a campaign URL uses a host
+// which isn't configured as a phishing host
+var ErrPhishingHostNotConfigured = errors.New("Campaign URL host is not a configured phishing host")
+
+// ErrPhishingPathNotConfigured is thrown when a campaign URL uses a path
+// which isn't one of the phishing host's path prefixes
+var ErrPhishingPathNotConfigured = errors.New("Campaign URL path is not configured for the phishing host")
+
+// ErrReservedCampaignPath is thrown when a campaign URL's path is used by
+// the phishing server's own handlers
+var ErrReservedCampaignPath = errors.New("Campaign URL path is reserved by the phishing server")
+
+// rootCampaignPaths are the endpoints the phishing server also serves from
+// the root of a campaign's host, regardless of the campaign's path
+var rootCampaignPaths = []string{"/track", "/report"}
+
+// reservedCampaignPaths are the first path segments the phishing server
+// routes to its own handlers
+var reservedCampaignPaths = []string{"static", "track", "report", "robots.txt", PageAssetPath, TrainingPath}
+
+// HostPaths are the path prefixes campaigns may use on a phishing host,
+// stored as a JSON array
+type HostPaths []string
+
+// Value implements the driver.Valuer interface
+func (hp HostPaths) Value() (driver.Value, error) {
+	return jsonValue(hp, len(hp))
+}
+
+// Scan implements the sql.Scanner interface
+func (hp *HostPaths) Scan(value interface{}) error {
+	*hp = HostPaths{}
+	return jsonScan(value, hp)
+}
+
+// PhishingHost is a hostname served by the phishing server. Once any hosts
+// are configured, new campaign URLs must use one of them, and the phishing
+// server only handles requests for those campaigns on their own host and
+// path.
+type PhishingHost struct {
+	Id       int64  `json:"id"`
+	Hostname string `json:"hostname"`
+	// Paths restricts the path prefixes campaigns can use on the host. Any
+	// path can be used if no prefixes are given.
+	Paths        HostPaths `json:"paths" gorm:"column:paths;type:text"`
+	ModifiedDate time.Time `json:"modified_date"`
+}
+
+// normalizeHostname returns the hostname of a Host header or URL host,
+// without the port, in lowercase
+func normalizeHostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// Validate ensures that a phishing host contains the appropriate details
+func (h *PhishingHost) Validate() error {
+	if strings.TrimSpace(h.Hostname) == "" {
+		return ErrPhishingHostnameNotSpecified
+	}
+	if strings.ContainsAny(h.Hostname, ":/?# ") {
+		return ErrInvalidPhishingHostname
+	}
+	h.Hostname = normalizeHostname(h.Hostname)
+	for i, p := range h.Paths {
+		if !strings.HasPrefix(p, "/") {
+			return ErrInvalidPhishingHostPath
+		}
+		h.Paths[i] = strings.TrimSuffix(p, "/") + "/"
+	}
+	return nil
+}
+
+// allowsPath returns whether campaigns on the host can use the path
+func (h *PhishingHost) allowsPath(p string) bool {
+	if len(h.Paths) == 0 {
+		return true
+	}
+	for _, prefix := range h.Paths {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPhishingHosts returns the configured phishing hosts
+func GetPhishingHosts() ([]PhishingHost, error) {
+	hs := []PhishingHost{}
+	err := db.Order("hostname asc").Find(&hs).Error
+	return hs, err
+}
+
+// GetPhishingHost returns the phishing host with the given id
+func GetPhishingHost(id int64) (PhishingHost, error) {
+	h := PhishingHost{}
+	err := db.Where("id=?", id).First(&h).Error
+	return h, err
+}
+
+// GetPhishingHostByName returns the phishing host with the given hostname
+func GetPhishingHostByName(hostname string) (PhishingHost, error) {
+	h := PhishingHost{}
+	err := db.Where("hostname=?", normalizeHostname(hostname)).First(&h).Error
+	return h, err
+}
+
+// savePhishingHost validates the phishing host and saves it, ensuring the
+// hostname is only configured once
+func savePhishingHost(h *PhishingHost) error {
+	err := h.Validate()
+	if err != nil {
+		return err
+	}
+	existing, err := GetPhishingHostByName(h.Hostname)
+	if err == nil && existing.Id != h.Id {
+		return ErrPhishingHostExists
+	}
+	h.ModifiedDate = time.Now().UTC()
+	err = db.Save(h).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// PostPhishingHost creates a new phishing host in the database.
+func PostPhishingHost(h *PhishingHost) error {
+	return savePhishingHost(h)
+}
+
+// PutPhishingHost edits an existing phishing host in the database.
+func PutPhishingHost(h *PhishingHost) error {
+	return savePhishingHost(h)
+}
+
+// DeletePhishingHost deletes an existing phishing host in the database.
+func DeletePhishingHost(id int64) error {
+	return db.Where("id=?", id).Delete(&PhishingHost{}).Error
+}
+
+// phishingHostsConfigured returns whether any phishing hosts are configured
+func phishingHostsConfigured() (bool, error) {
+	count := 0
+	err := db.Model(&PhishingHost{}).Count(&count).Error
+	return count > 0, err
+}
+
+// urlScope is the host and path prefix a campaign URL is served from.
+type urlScope struct {
+	// host is empty if the host is set by a template variable
+	host string
+	// prefix matches the request path with a trailing slash appended
+	prefix string
+}
+
+// matchesHost returns whether a request for the host is in the scope
+func (s urlScope) matchesHost(host string) bool {
+	return s.host == "" || s.host == normalizeHostname(host)
+}
+
+// matches returns whether a request for the host and path is in the scope
+func (s urlScope) matches(host string, p string) bool {
+	return s.matchesHost(host) && strings.HasPrefix(p+"/", s.prefix)
+}
+
+// parseURLScope returns the host and path prefix of a campaign URL. URLs
+// may contain template variables, in which case the scope is limited to the
+// part of the URL before the first variable.
+func parseURLScope(raw string) (urlScope, error) {
+	static, templated := raw, false
+	if i := strings.Index(raw, "{{"); i != -1 {
+		static, templated = raw[:i], true
+	}
+	u, err := url.Parse(static)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return urlScope{}, ErrInvalidCampaignURL
+	}
+	// A template variable in the host leaves no path to scope requests to
+	if templated && !strings.Contains(strings.TrimPrefix(static, u.Scheme+"://"), "/") {
+		return urlScope{prefix: "/"}, nil
+	}
+	if u.Host == "" {
+		return urlScope{}, ErrInvalidCampaignURL
+	}
+	s := urlScope{host: normalizeHostname(u.Host), prefix: u.Path}
+	if !strings.HasPrefix(s.prefix, "/") {
+		s.prefix = "/" + s.prefix
+	}
+	if !templated {
+		s.prefix = strings.TrimSuffix(s.prefix, "/") + "/"
+	}
+	return s, nil
+}
+
+// validateURL ensures the campaign URL doesn't use a path reserved by the
+// phishing server and, if phishing hosts are configured, that its host and
+// path are configured.
+func (c *Campaign) validateURL() error {
+	if c.URL == "" {
+		return nil
+	}
+	s, err := parseURLScope(c.URL)
+	if err != nil {
+		return err
+	}
+	segment := strings.SplitN(strings.TrimPrefix(s.prefix, "/"), "/", 2)[0]
+	for _, reserved := range reservedCampaignPaths {
+		if segment == reserved {
+			return ErrReservedCampaignPath
+		}
+	}
+	configured, err := phishingHostsConfigured()
+	if err != nil || !configured || s.host == "" {
+		return err
+	}
+	h, err := GetPhishingHostByName(s.host)
+	if err != nil {
+		return ErrPhishingHostNotConfigured
+	}
+	if !h.allowsPath(s.prefix) {
+		return ErrPhishingPathNotConfigured
+	}
+	return nil
+}
+
+// ServesRequest returns whether a request for the host and path belongs to
+// the campaign. Requests are only checked for campaigns whose campaign URL
+// or landing URL uses a configured phishing host, so that campaigns launched
+// on other hosts keep working once phishing hosts are added. For those
+// campaigns, requests must be for the host and path of the campaign URL or
+// landing URL. The tracking and report endpoints are also accepted at the
+// root of the campaign's host.
+func (c *Campaign) ServesRequest(host string, p string) (bool, error) {
+	scopes := []urlScope{}
+	hosts := []string{}
+	for _, raw := range []string{c.URL, c.LandingURL} {
+		if raw == "" {
+			continue
+		}
+		s, err := parseURLScope(raw)
+		if err != nil {
+			continue
+		}
+		scopes = append(scopes, s)
+		if s.host != "" {
+			hosts = append(hosts, s.host)
+		}
+	}
+	if len(hosts) == 0 {
+		return true, nil
+	}
+	count := 0
+	err := db.Model(&PhishingHost{}).Where("hostname in (?)", hosts).Count(&count).Error
+	if err != nil || count == 0 {
+		return true, err
+	}
+	root := false
+	for _, rp := range rootCampaignPaths {
+		if p == rp {
+			root = true
+		}
+	}
+	for _, s := range scopes {
+		if s.matches(host, p) || (root && s.matchesHost(host)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
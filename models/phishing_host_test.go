@@ -0,0 +1,80 @@
+package models
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestPhishingHostValidation(ch *check.C) {
+	h := PhishingHost{}
+	ch.Assert(PostPhishingHost(&h), check.Equals, ErrPhishingHostnameNotSpecified)
+	h.Hostname = "https://login.example.com"
+	ch.Assert(PostPhishingHost(&h), check.Equals, ErrInvalidPhishingHostname)
+	h.Hostname = "Login.Example.com"
+	h.Paths = HostPaths{"hr"}
+	ch.Assert(PostPhishingHost(&h), check.Equals, ErrInvalidPhishingHostPath)
+	h.Paths = HostPaths{"/hr"}
+	ch.Assert(PostPhishingHost(&h), check.Equals, nil)
+	ch.Assert(h.Hostname, check.Equals, "login.example.com")
+	ch.Assert(h.Paths[0], check.Equals, "/hr/")
+
+	duplicate := PhishingHost{Hostname: "login.example.com"}
+	ch.Assert(PostPhishingHost(&duplicate), check.Equals, ErrPhishingHostExists)
+	h, err := GetPhishingHost(h.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(h.Paths), check.Equals, 1)
+}
+
+func (s *ModelsSuite) TestCampaignURLValidation(ch *check.C) {
+	c := Campaign{URL: "login.example.com"}
+	ch.Assert(c.validateURL(), check.Equals, ErrInvalidCampaignURL)
+	c.URL = "https://login.example.com/static/"
+	ch.Assert(c.validateURL(), check.Equals, ErrReservedCampaignPath)
+	// Any host can be used until phishing hosts are configured
+	c.URL = "https://other.example.com/hr/benefits"
+	ch.Assert(c.validateURL(), check.Equals, nil)
+	ok, err := c.ServesRequest("anything.example.com", "/")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(ok, check.Equals, true)
+
+	h := PhishingHost{Hostname: "login.example.com", Paths: HostPaths{"/hr"}}
+	ch.Assert(PostPhishingHost(&h), check.Equals, nil)
+	ch.Assert(c.validateURL(), check.Equals, ErrPhishingHostNotConfigured)
+	c.URL = "https://login.example.com/it"
+	ch.Assert(c.validateURL(), check.Equals, ErrPhishingPathNotConfigured)
+	c.URL = "https://login.example.com/hr/benefits"
+	ch.Assert(c.validateURL(), check.Equals, nil)
+
+	requests := []struct {
+		host     string
+		path     string
+		expected bool
+	}{
+		{"login.example.com", "/hr/benefits", true},
+		{"LOGIN.example.com:443", "/hr/benefits/track", true},
+		{"login.example.com", "/hr/benefitsx", false},
+		{"login.example.com", "/", false},
+		{"login.example.com", "/track", true},
+		{"login.example.com", "/report", true},
+		{"login.example.com", "/trackx", false},
+		{"other.example.com", "/track", false},
+		{"other.example.com", "/hr/benefits", false},
+	}
+	for _, r := range requests {
+		ok, err := c.ServesRequest(r.host, r.path)
+		ch.Assert(err, check.Equals, nil)
+		ch.Assert(ok, check.Equals, r.expected, check.Commentf("%s%s", r.host, r.path))
+	}
+
+	// Campaigns on hosts which aren't configured keep serving requests
+	legacy := Campaign{URL: "https://other.example.com/hr/benefits"}
+	ok, err = legacy.ServesRequest("other.example.com", "/anything")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(ok, check.Equals, true)
+
+	// Template variables limit the scope to the URL before the variable
+	c.URL = "https://login.example.com/hr/{{.RId}}"
+	ch.Assert(c.validateURL(), check.Equals, nil)
+	ok, err = c.ServesRequest("login.example.com", "/hr/abc123")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(ok, check.Equals, true)
+}
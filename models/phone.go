@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// DefaultCountryCode is the calling code used to normalize phone numbers
+// that are provided without an international prefix when no default is
+// configured.
+const DefaultCountryCode = "1"
+
+// ErrInvalidPhoneNumber is thrown when a phone number cannot be normalized
+// to the E.164 format
+var ErrInvalidPhoneNumber = errors.New("Invalid phone number")
+
+// ErrInvalidCountryCode is thrown when the default country code used to
+// normalize phone numbers is not a valid calling code
+var ErrInvalidCountryCode = errors.New("Invalid country calling code")
+
+var (
+	e164Regex        = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+	countryCodeRegex = regexp.MustCompile(`^[1-9][0-9]{0,2}$`)
+	// Characters commonly used to format phone numbers for humans
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "/", "", "\u00a0", "")
+)
+
+// GetDefaultCountryCode returns the calling code configured to normalize
+// phone numbers, falling back to DefaultCountryCode.
+func GetDefaultCountryCode() string {
+	if conf != nil && conf.DefaultCountryCode != "" {
+		return conf.DefaultCountryCode
+	}
+	return DefaultCountryCode
+}
+
+// ValidateCountryCode ensures the provided country calling code (with or
+// without a leading "+") is well-formed.
+func ValidateCountryCode(countryCode string) error {
+	countryCode = strings.TrimPrefix(strings.TrimSpace(countryCode), "+")
+	if !countryCodeRegex.MatchString(countryCode) {
+		return ErrInvalidCountryCode
+	}
+	return nil
+}
+
+// NormalizePhoneNumber converts the provided phone number to the E.164
+// format (e.g. +14155550100). Numbers without an international prefix are
+// assumed to belong to the given country calling code, with any leading
+// trunk prefix ("0") removed.
+func NormalizePhoneNumber(number string, countryCode string) (string, error) {
+	if err := ValidateCountryCode(countryCode); err != nil {
+		return "", err
+	}
+	countryCode = strings.TrimPrefix(strings.TrimSpace(countryCode), "+")
+	n := phoneSeparators.Replace(strings.TrimSpace(number))
+	switch {
+	case n == "":
+		return "", ErrInvalidPhoneNumber
+	case strings.HasPrefix(n, "+"):
+	case strings.HasPrefix(n, "00"):
+		n = "+" + strings.TrimPrefix(n, "00")
+	default:
+		n = "+" + countryCode + strings.TrimLeft(n, "0")
+	}
+	if !e164Regex.MatchString(n) {
+		return "", ErrInvalidPhoneNumber
+	}
+	return n, nil
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	check "gopkg.in/check.v1"
+)
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	testCases := []struct {
+		input       string
+		countryCode string
+		expected    string
+		err         error
+	}{
+		{"+1 (415) 555-0100", "1", "+14155550100", nil},
+		{"415.555.0100", "1", "+14155550100", nil},
+		{"020 7946 0018", "44", "+442079460018", nil},
+		{"0044 20 7946 0018", "1", "+442079460018", nil},
+		{"07700 900123", "+44", "+447700900123", nil},
+		{"555-01", "1", "", ErrInvalidPhoneNumber},
+		{"+1 415 CALL NOW", "1", "", ErrInvalidPhoneNumber},
+		{"", "1", "", ErrInvalidPhoneNumber},
+		{"4155550100", "abc", "", ErrInvalidCountryCode},
+	}
+	for _, tc := range testCases {
+		got, err := NormalizePhoneNumber(tc.input, tc.countryCode)
+		if err != tc.err {
+			t.Fatalf("unexpected error normalizing %q. expected %v got %v", tc.input, tc.err, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("unexpected phone number normalizing %q. expected %q got %q", tc.input, tc.expected, got)
+		}
+	}
+}
+
+func (s *ModelsSuite) TestPostGroupNormalizesPhone(c *check.C) {
+	g := Group{Name: "SMS Group", GroupType: "sms", UserId: 1}
+	g.Targets = []Target{
+		Target{BaseRecipient: BaseRecipient{Phone: "(415) 555-0100", FirstName: "First"}},
+		Target{BaseRecipient: BaseRecipient{Phone: "+44 7700 900123", FirstName: "Second"}},
+	}
+	c.Assert(PostGroup(&g), check.Equals, nil)
+	ts, err := GetTargets(g.Id)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(ts), check.Equals, 2)
+	c.Assert(ts[0].Phone, check.Equals, "+14155550100")
+	c.Assert(ts[1].Phone, check.Equals, "+447700900123")
+}
+
+func (s *ModelsSuite) TestPostGroupInvalidPhone(c *check.C) {
+	g := Group{Name: "SMS Group", GroupType: "sms", UserId: 1}
+	g.Targets = []Target{
+		Target{BaseRecipient: BaseRecipient{Phone: "not a number"}},
+	}
+	c.Assert(PostGroup(&g), check.NotNil)
+}
+
+func (s *ModelsSuite) TestPutGroupKeepsPhone(c *check.C) {
+	g := Group{Name: "Mixed Group", UserId: 1}
+	g.Targets = []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "first@example.com", Phone: "(415) 555-0100"}},
+	}
+	c.Assert(PostGroup(&g), check.Equals, nil)
+
+	// Clients which don't send the phone number don't remove it
+	g.Targets = []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "first@example.com", FirstName: "First"}},
+	}
+	c.Assert(PutGroup(&g), check.Equals, nil)
+	ts, err := GetTargets(g.Id)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(ts), check.Equals, 1)
+	c.Assert(ts[0].FirstName, check.Equals, "First")
+	c.Assert(ts[0].Phone, check.Equals, "+14155550100")
+}
+
+func (s *ModelsSuite) TestPutGroupClearsPhone(c *check.C) {
+	g := Group{Name: "Mixed Group", UserId: 1}
+	g.Targets = []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "first@example.com", Phone: "(415) 555-0100"}},
+	}
+	c.Assert(PostGroup(&g), check.Equals, nil)
+
+	// Sending an empty phone number removes it
+	err := json.Unmarshal([]byte(`[{"email": "first@example.com", "phone": ""}]`), &g.Targets)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(PutGroup(&g), check.Equals, nil)
+	ts, err := GetTargets(g.Id)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(ts), check.Equals, 1)
+	c.Assert(ts[0].Phone, check.Equals, "")
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/jordan-wright/email"
+)
+
+// MaxPreflightMessageSize is the rendered message size above which a
+// preflight check warns that the email may be rejected. Many providers
+// reject messages over 10MB once encoded.
+const MaxPreflightMessageSize = 10 * 1024 * 1024
+
+// ErrPreflightRecipientNotFound is thrown when the recipient chosen for a
+// preview isn't in the campaign's groups
+var ErrPreflightRecipientNotFound = errors.New("Preview recipient is not in the selected groups")
+
+// ErrPreflightEmailOnly is thrown when a preflight check is requested for a
+// campaign which doesn't send email
+var ErrPreflightEmailOnly = errors.New("Preflight checks are only available for email campaigns")
+
+// recipientFieldRegex matches the recipient fields and custom attributes
+// referenced by a template
+var recipientFieldRegex = regexp.MustCompile(`\{\{[^}]*\.(FirstName|LastName|Position|Email|Attr\s+"([^"]+)")`)
+
+// PreflightIssue is a problem found when rendering a campaign email for a
+// recipient
+type PreflightIssue struct {
+	Email   string `json:"email"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// PreflightPreview is the rendered email for a single recipient
+type PreflightPreview struct {
+	Email string `json:"email"`
+	MIME  string `json:"mime"`
+	Size  int    `json:"size"`
+}
+
+// PreflightReport contains the results of rendering a campaign's emails
+// without sending them
+type PreflightReport struct {
+	Recipients int               `json:"recipients"`
+	Checked    int               `json:"checked"`
+	MaxSize    int               `json:"max_size"`
+	Issues     []PreflightIssue  `json:"issues"`
+	Preview    *PreflightPreview `json:"preview,omitempty"`
+}
+
+// PreflightCampaign renders the email for each recipient in a new campaign
+// without saving or sending anything, reporting any problems found. If sample
+// is positive, only that many recipients (spread across the groups) are
+// rendered. If previewEmail is given, the rendered MIME message for that
+// recipient is included in the report.
+func PreflightCampaign(c *Campaign, uid int64, sample int, previewEmail string) (PreflightReport, error) {
+	report := PreflightReport{Issues: []PreflightIssue{}}
+	if c.CampaignType != "" && c.CampaignType != "email" {
+		return report, ErrPreflightEmailOnly
+	}
+	err := c.Validate()
+	if err != nil {
+		return report, err
+	}
+	c.UserId = uid
+	_, err = c.resolveDependencies(uid)
+	if err != nil {
+		return report, err
+	}
+	recipients := []BaseRecipient{}
+	seen := make(map[string]bool)
+	preview := -1
+	for _, g := range c.Groups {
+		for _, t := range g.Targets {
+			if seen[t.Email] {
+				continue
+			}
+			seen[t.Email] = true
+			if t.Email == previewEmail {
+				preview = len(recipients)
+			}
+			recipients = append(recipients, t.BaseRecipient)
+		}
+	}
+	if previewEmail != "" && preview == -1 {
+		return report, ErrPreflightRecipientNotFound
+	}
+	report.Recipients = len(recipients)
+	fields := c.referencedFields()
+	for i, r := range recipients {
+		// Spread the sample evenly across the recipients, always including
+		// the recipient being previewed
+		if sample > 0 && i != preview && i*sample/len(recipients) == (i+1)*sample/len(recipients) {
+			continue
+		}
+		report.Checked++
+		mime, issues := c.preflightRecipient(r, fields)
+		report.Issues = append(report.Issues, issues...)
+		if len(mime) > report.MaxSize {
+			report.MaxSize = len(mime)
+		}
+		if i == preview {
+			report.Preview = &PreflightPreview{
+				Email: r.Email,
+				MIME:  string(mime),
+				Size:  len(mime),
+			}
+		}
+	}
+	return report, nil
+}
+
+// referencedFields returns the recipient fields used by the campaign's
+// template and custom headers
+func (c *Campaign) referencedFields() []string {
+	content := c.Template.Subject + c.Template.Text + c.Template.HTML
+	for _, v := range c.Template.Variants {
+		content += v.Subject + v.Text + v.HTML
+	}
+	for _, h := range c.SMTP.Headers {
+		content += h.Key + h.Value
+	}
+	seen := make(map[string]bool)
+	fields := []string{}
+	for _, m := range recipientFieldRegex.FindAllStringSubmatch(content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			fields = append(fields, m[1])
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// preflightRecipient renders the campaign email for a recipient, returning
+// the MIME message and any issues found
+func (c *Campaign) preflightRecipient(r BaseRecipient, fields []string) ([]byte, []PreflightIssue) {
+	issues := []PreflightIssue{}
+	addIssue := func(field string, message string) {
+		issues = append(issues, PreflightIssue{Email: r.Email, Field: field, Message: message})
+	}
+	values := map[string]string{
+		"FirstName": r.FirstName,
+		"LastName":  r.LastName,
+		"Position":  r.Position,
+		"Email":     r.Email,
+	}
+	for _, field := range fields {
+		if m := recipientFieldRegex.FindStringSubmatch("{{." + field); m[2] != "" {
+			values[field] = r.Attr(m[2])
+		}
+		if values[field] == "" {
+			addIssue(field, fmt.Sprintf("The template uses {{.%s}}, but it is empty for this recipient", field))
+		}
+	}
+	rid, err := generateResultId()
+	if err != nil {
+		addIssue("rid", err.Error())
+		return nil, issues
+	}
+	result := Result{RId: rid, BaseRecipient: r}
+	msg := email.NewEmail()
+	err = generateMail(msg, c, result, func(field string, err error) {
+		addIssue(field, err.Error())
+	})
+	if err != nil {
+		addIssue("email", err.Error())
+		return nil, issues
+	}
+	if msg.Subject == "" {
+		addIssue("subject", "The rendered subject is empty")
+	}
+	if len(msg.Text) == 0 && len(msg.HTML) == 0 {
+		addIssue("body", "The rendered email has no content")
+	}
+	mime, err := msg.Bytes()
+	if err != nil {
+		addIssue("email", err.Error())
+		return nil, issues
+	}
+	if len(mime) > MaxPreflightMessageSize {
+		addIssue("size", fmt.Sprintf("The rendered email is %d bytes, which exceeds the %d byte limit of many mail servers", len(mime), MaxPreflightMessageSize))
+	}
+	return mime, issues
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestPreflightCampaign(ch *check.C) {
+	c := s.createCampaignDependencies(ch, "{{.FirstName}}, your {{.Position}} account")
+	report, err := PreflightCampaign(&c, c.UserId, 0, "test2@example.com")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(report.Recipients, check.Equals, 4)
+	ch.Assert(report.Checked, check.Equals, 4)
+
+	// None of the targets have a position
+	ch.Assert(len(report.Issues), check.Equals, 4)
+	for _, issue := range report.Issues {
+		ch.Assert(issue.Field, check.Equals, "Position")
+	}
+
+	ch.Assert(report.Preview, check.NotNil)
+	ch.Assert(report.Preview.Email, check.Equals, "test2@example.com")
+	ch.Assert(strings.Contains(report.Preview.MIME, "Subject: Second, your  account"), check.Equals, true)
+	ch.Assert(report.Preview.Size, check.Equals, len(report.Preview.MIME))
+	ch.Assert(report.MaxSize >= report.Preview.Size, check.Equals, true)
+
+	// Nothing should be saved
+	cs, err := GetCampaigns(c.UserId, "")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(cs), check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestPreflightCampaignSample(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	report, err := PreflightCampaign(&c, c.UserId, 2, "test1@example.com")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(report.Recipients, check.Equals, 4)
+	// The previewed recipient is always rendered, in addition to the sample
+	ch.Assert(report.Checked, check.Equals, 3)
+	ch.Assert(report.Preview, check.NotNil)
+	ch.Assert(len(report.Issues), check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestPreflightCampaignTemplateError(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	// Templates are validated when they're saved, so simulate one which no
+	// longer renders
+	err := db.Model(&Template{}).Where("id=?", c.Template.Id).Update("subject", "{{.Department}}").Error
+	ch.Assert(err, check.Equals, nil)
+	report, err := PreflightCampaign(&c, c.UserId, 1, "")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(report.Checked, check.Equals, 1)
+	ch.Assert(report.Preview, check.IsNil)
+	fields := []string{}
+	for _, issue := range report.Issues {
+		fields = append(fields, issue.Field)
+	}
+	ch.Assert(fields, check.DeepEquals, []string{"subject", "subject"})
+}
+
+func (s *ModelsSuite) TestPreflightCampaignUnknownRecipient(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	_, err := PreflightCampaign(&c, c.UserId, 0, "unknown@example.com")
+	ch.Assert(err, check.Equals, ErrPreflightRecipientNotFound)
+}
+
+func (s *ModelsSuite) TestPreflightCampaignAttributes(ch *check.C) {
+	c := s.createCampaignDependencies(ch, `{{.Attr "department"}} update`)
+	err := db.Model(&Target{}).Where("email=?", "test1@example.com").UpdateColumn("attributes", `{"department":"Finance"}`).Error
+	ch.Assert(err, check.Equals, nil)
+	report, err := PreflightCampaign(&c, c.UserId, 0, "test1@example.com")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.Contains(report.Preview.MIME, "Subject: Finance update"), check.Equals, true)
+	// The other targets don't have a department
+	ch.Assert(len(report.Issues), check.Equals, 3)
+	ch.Assert(report.Issues[0].Field, check.Equals, `Attr "department"`)
+}
@@ -0,0 +1,396 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // Logos may be JPEG images
+	"image/png"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// QR code error correction levels. Higher levels let more of the code be
+// damaged or covered, such as by a logo, at the cost of a denser code.
+const (
+	QRErrorCorrectionLow     = "low"
+	QRErrorCorrectionMedium  = "medium"
+	QRErrorCorrectionHigh    = "high"
+	QRErrorCorrectionHighest = "highest"
+)
+
+// DefaultQRQuietZone is the width, in modules, of the blank border around a
+// QR code. This is the minimum required by the QR code specification.
+const DefaultQRQuietZone = 4
+
+// MaxQRQuietZone is the widest quiet zone which can be configured
+const MaxQRQuietZone = 16
+
+// MaxQRLogoSize is the largest logo, in bytes, which can be embedded in a
+// QR code
+const MaxQRLogoSize = 256 << 10
+
+// MaxQRLogoDimension is the largest width or height, in pixels, of a logo
+// which can be embedded in a QR code
+const MaxQRLogoDimension = 1024
+
+// maxCachedQRLogos is the number of decoded logos kept in memory, so that
+// a campaign's logo is decoded once rather than for every QR code
+const maxCachedQRLogos = 8
+
+// qrLogoScale is the fraction of the QR code's width covered by a logo. At
+// this size the code remains readable with high error correction.
+const qrLogoScale = 0.2
+
+// ErrInvalidQRErrorCorrection is thrown when an unknown error correction
+// level is given
+var ErrInvalidQRErrorCorrection = errors.New("Invalid QR code error correction level. Must be one of low, medium, high or highest")
+
+// ErrInvalidQRColor is thrown when a QR code color isn't a hex color
+var ErrInvalidQRColor = errors.New("Invalid QR code color. Colors must be given in hex, such as #000000")
+
+// ErrQRLowContrast is thrown when the QR code's foreground and background
+// colors are the same
+var ErrQRLowContrast = errors.New("QR code foreground and background colors must be different")
+
+// ErrInvalidQRQuietZone is thrown when the quiet zone is out of range
+var ErrInvalidQRQuietZone = fmt.Errorf("QR code quiet zone must be between 0 and %d modules", MaxQRQuietZone)
+
+// ErrInvalidQRLogo is thrown when the QR code logo isn't a base64 encoded
+// PNG or JPEG image
+var ErrInvalidQRLogo = errors.New("QR code logo must be a base64 encoded PNG or JPEG image")
+
+// ErrQRLogoTooLarge is thrown when the QR code logo is larger than
+// MaxQRLogoSize
+var ErrQRLogoTooLarge = fmt.Errorf("QR code logo must be smaller than %d KB", MaxQRLogoSize>>10)
+
+// ErrQRLogoDimensions is thrown when the QR code logo is wider or taller
+// than MaxQRLogoDimension
+var ErrQRLogoDimensions = fmt.Errorf("QR code logo must be at most %dx%d pixels", MaxQRLogoDimension, MaxQRLogoDimension)
+
+// ErrQRLogoErrorCorrection is thrown when a logo is used with an error
+// correction level too low for the code to be read with the logo covering it
+var ErrQRLogoErrorCorrection = errors.New("QR codes with a logo require high or highest error correction")
+
+// hexColorRegex matches colors such as #fff or #1a2b3c
+var hexColorRegex = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// QROptions customizes the QR codes generated for a campaign. The zero value
+// produces black on white codes with medium error correction.
+type QROptions struct {
+	ErrorCorrection string `json:"qr_error_correction" gorm:"column:qr_error_correction"`
+	Foreground      string `json:"qr_foreground" gorm:"column:qr_foreground"`
+	Background      string `json:"qr_background" gorm:"column:qr_background"`
+	// QuietZone is the width of the border in modules. 0 uses
+	// DefaultQRQuietZone.
+	QuietZone int `json:"qr_quiet_zone" gorm:"column:qr_quiet_zone"`
+	// Logo is a base64 encoded PNG or JPEG image drawn in the center of the
+	// code
+	Logo string `json:"qr_logo" gorm:"column:qr_logo;type:text"`
+}
+
+// Validate checks that the QR code options are valid
+func (o QROptions) Validate() error {
+	switch o.ErrorCorrection {
+	case "", QRErrorCorrectionLow, QRErrorCorrectionMedium, QRErrorCorrectionHigh, QRErrorCorrectionHighest:
+	default:
+		return ErrInvalidQRErrorCorrection
+	}
+	fg, err := parseHexColor(o.Foreground, color.Black)
+	if err != nil {
+		return err
+	}
+	bg, err := parseHexColor(o.Background, color.White)
+	if err != nil {
+		return err
+	}
+	if color.RGBAModel.Convert(fg) == color.RGBAModel.Convert(bg) {
+		return ErrQRLowContrast
+	}
+	if o.QuietZone < 0 || o.QuietZone > MaxQRQuietZone {
+		return ErrInvalidQRQuietZone
+	}
+	if o.Logo == "" {
+		return nil
+	}
+	switch o.ErrorCorrection {
+	case QRErrorCorrectionLow, QRErrorCorrectionMedium:
+		return ErrQRLogoErrorCorrection
+	}
+	_, err = o.logo()
+	return err
+}
+
+// recoveryLevel returns the error correction level to encode codes with.
+// Codes with a logo default to the highest level.
+func (o QROptions) recoveryLevel() qrcode.RecoveryLevel {
+	switch o.ErrorCorrection {
+	case QRErrorCorrectionLow:
+		return qrcode.Low
+	case QRErrorCorrectionHigh:
+		return qrcode.High
+	case QRErrorCorrectionHighest:
+		return qrcode.Highest
+	case QRErrorCorrectionMedium:
+		return qrcode.Medium
+	}
+	if o.Logo != "" {
+		return qrcode.Highest
+	}
+	return qrcode.Medium
+}
+
+// quietZone returns the width of the border in modules
+func (o QROptions) quietZone() int {
+	if o.QuietZone == 0 {
+		return DefaultQRQuietZone
+	}
+	return o.QuietZone
+}
+
+// logoData decodes the logo, which may be given as a data URI
+func (o QROptions) logoData() ([]byte, error) {
+	encoded := o.Logo
+	if i := strings.Index(encoded, ","); strings.HasPrefix(encoded, "data:") && i != -1 {
+		encoded = encoded[i+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidQRLogo
+	}
+	if len(data) > MaxQRLogoSize {
+		return nil, ErrQRLogoTooLarge
+	}
+	return data, nil
+}
+
+// qrLogos holds the most recently decoded logos, keyed by the hash of
+// their encoded form
+var qrLogos = struct {
+	sync.Mutex
+	images map[[sha256.Size]byte]image.Image
+}{images: make(map[[sha256.Size]byte]image.Image)}
+
+// logo decodes the logo image. The image's dimensions are checked before it
+// is decoded, and decoded logos are cached.
+func (o QROptions) logo() (image.Image, error) {
+	key := sha256.Sum256([]byte(o.Logo))
+	qrLogos.Lock()
+	img, ok := qrLogos.images[key]
+	qrLogos.Unlock()
+	if ok {
+		return img, nil
+	}
+	data, err := o.logoData()
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidQRLogo
+	}
+	if config.Width > MaxQRLogoDimension || config.Height > MaxQRLogoDimension {
+		return nil, ErrQRLogoDimensions
+	}
+	img, _, err = image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidQRLogo
+	}
+	qrLogos.Lock()
+	defer qrLogos.Unlock()
+	if len(qrLogos.images) >= maxCachedQRLogos {
+		for k := range qrLogos.images {
+			delete(qrLogos.images, k)
+			break
+		}
+	}
+	qrLogos.images[key] = img
+	return img, nil
+}
+
+// parseHexColor parses a color such as #fff or #1a2b3c, returning the
+// default if the color is empty
+func parseHexColor(s string, def color.Color) (color.Color, error) {
+	if s == "" {
+		return def, nil
+	}
+	if !hexColorRegex.MatchString(s) {
+		return nil, ErrInvalidQRColor
+	}
+	hex := s[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, ErrInvalidQRColor
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// qrSymbol is an encoded QR code, including its quiet zone
+type qrSymbol struct {
+	modules [][]bool
+	// size is the width of the symbol in modules
+	size       int
+	foreground color.Color
+	background color.Color
+	logo       image.Image
+}
+
+// newQRSymbol encodes the content as a QR code using the options
+func newQRSymbol(content string, o QROptions) (*qrSymbol, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	q, err := qrcode.New(content, o.recoveryLevel())
+	if err != nil {
+		return nil, err
+	}
+	q.DisableBorder = true
+	bitmap := q.Bitmap()
+	quiet := o.quietZone()
+	s := &qrSymbol{size: len(bitmap) + 2*quiet}
+	s.modules = make([][]bool, s.size)
+	for y := range s.modules {
+		s.modules[y] = make([]bool, s.size)
+		if y >= quiet && y < quiet+len(bitmap) {
+			copy(s.modules[y][quiet:], bitmap[y-quiet])
+		}
+	}
+	s.foreground, _ = parseHexColor(o.Foreground, color.Black)
+	s.background, _ = parseHexColor(o.Background, color.White)
+	if o.Logo != "" {
+		s.logo, err = o.logo()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// logoBounds returns the area covered by the logo in an image of the given
+// size, keeping the logo's aspect ratio
+func (s *qrSymbol) logoBounds(size int) image.Rectangle {
+	b := s.logo.Bounds()
+	max := int(float64(size) * qrLogoScale)
+	w, h := max, max
+	if b.Dx() > b.Dy() {
+		h = max * b.Dy() / b.Dx()
+	} else {
+		w = max * b.Dx() / b.Dy()
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	x, y := (size-w)/2, (size-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// Image renders the QR code as an image which is size pixels wide. If the
+// size is too small to draw every module, a larger image is returned.
+func (s *qrSymbol) Image(size int) image.Image {
+	if size < s.size {
+		size = s.size
+	}
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(s.background), image.Point{}, draw.Src)
+	fg := image.NewUniform(s.foreground)
+	for y := 0; y < size; y++ {
+		my := y * s.size / size
+		for x := 0; x < size; x++ {
+			if s.modules[my][x*s.size/size] {
+				img.Set(x, y, fg.C)
+			}
+		}
+	}
+	if s.logo != nil {
+		bounds := s.logoBounds(size)
+		// Clear the modules behind the logo so that it stands out
+		padding := size / s.size
+		draw.Draw(img, bounds.Inset(-padding), image.NewUniform(s.background), image.Point{}, draw.Src)
+		draw.Draw(img, bounds, scaleImage(s.logo, bounds.Dx(), bounds.Dy()), image.Point{}, draw.Over)
+	}
+	return img
+}
+
+// PNG renders the QR code as a PNG image
+func (s *qrSymbol) PNG(size int) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, s.Image(size))
+	return buf.Bytes(), err
+}
+
+// SVG renders the QR code as an SVG image which is size pixels wide. The
+// modules are drawn as vector paths so that the code can be printed at any
+// size.
+func (s *qrSymbol) SVG(size int) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n", size, size, s.size, s.size)
+	fmt.Fprintf(buf, `<rect width="%d" height="%d" fill="%s"/>`+"\n", s.size, s.size, svgColor(s.background))
+	fmt.Fprintf(buf, `<path fill="%s" d="`, svgColor(s.foreground))
+	for y, row := range s.modules {
+		// Draw each horizontal run of modules as a single rectangle
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/>` + "\n")
+	if s.logo != nil {
+		// Work in a larger coordinate space so the logo can be positioned
+		// between modules
+		const scale = 100
+		bounds := s.logoBounds(s.size * scale)
+		logo := &bytes.Buffer{}
+		err := png.Encode(logo, s.logo)
+		if err != nil {
+			return nil, err
+		}
+		padded := bounds.Inset(-scale)
+		fmt.Fprintf(buf, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"/>`+"\n",
+			float64(padded.Min.X)/scale, float64(padded.Min.Y)/scale, float64(padded.Dx())/scale, float64(padded.Dy())/scale, svgColor(s.background))
+		fmt.Fprintf(buf, `<image x="%.2f" y="%.2f" width="%.2f" height="%.2f" href="data:image/png;base64,%s"/>`+"\n",
+			float64(bounds.Min.X)/scale, float64(bounds.Min.Y)/scale, float64(bounds.Dx())/scale, float64(bounds.Dy())/scale,
+			base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes(), nil
+}
+
+// svgColor formats the color for use in an SVG document
+func svgColor(c color.Color) string {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
+
+// scaleImage resizes the image using nearest neighbor sampling
+func scaleImage(src image.Image, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	b := src.Bounds()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dst.Set(x, y, src.At(b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,309 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"image"
+	"regexp"
+	"strings"
+)
+
+// Formats for exporting a campaign's QR codes
+const (
+	// QRExportPDF exports a printable PDF with one recipient's code per page
+	QRExportPDF = "pdf"
+	// QRExportZip exports a zip with an image per recipient and a CSV file
+	// listing which recipient each image belongs to
+	QRExportZip = "zip"
+)
+
+// Image formats for QR codes in zip exports
+const (
+	QRImagePNG = "png"
+	QRImageSVG = "svg"
+)
+
+// DefaultQRExportSize is the width, in pixels, of exported QR codes when
+// neither the export nor the campaign specify a size
+const DefaultQRExportSize = 600
+
+// MaxQRExportSize is the widest QR code which can be exported
+const MaxQRExportSize = 4000
+
+// qrExportManifestName is the name of the CSV file in zip exports
+const qrExportManifestName = "recipients.csv"
+
+// ErrInvalidQRExportFormat is thrown when an unknown export format is
+// requested
+var ErrInvalidQRExportFormat = errors.New("Invalid QR code export format. Must be pdf or zip")
+
+// ErrInvalidQRImageFormat is thrown when an unknown image format is
+// requested
+var ErrInvalidQRImageFormat = errors.New("Invalid QR code image format. Must be png or svg")
+
+// ErrInvalidQRExportSize is thrown when the export size is out of range
+var ErrInvalidQRExportSize = fmt.Errorf("QR code size must be between 1 and %d pixels", MaxQRExportSize)
+
+// ErrNoQRRecipients is thrown when exporting QR codes for a campaign without
+// any recipients
+var ErrNoQRRecipients = errors.New("Campaign has no recipients to export QR codes for")
+
+// unsafeQRFilenameRegex matches characters which shouldn't be used in the
+// names of exported files
+var unsafeQRFilenameRegex = regexp.MustCompile(`[^A-Za-z0-9@._-]+`)
+
+// QRExport describes how to export a campaign's QR codes
+type QRExport struct {
+	// Format is QRExportPDF or QRExportZip
+	Format string
+	// ImageFormat is the format of the images in zip exports, QRImagePNG
+	// (the default) or QRImageSVG
+	ImageFormat string
+	// Size is the width of each code in pixels. It defaults to the
+	// campaign's QR code size.
+	Size int
+}
+
+// qrRecipient is a recipient's QR code in an export
+type qrRecipient struct {
+	result Result
+	url    string
+	symbol *qrSymbol
+}
+
+// ExportCampaignQRCodes generates the QR code for every recipient of the
+// campaign, for use in physical simulations such as posters or desk drops.
+// The campaign must include its results.
+func ExportCampaignQRCodes(c *Campaign, e QRExport) ([]byte, error) {
+	switch e.Format {
+	case QRExportPDF, QRExportZip:
+	default:
+		return nil, ErrInvalidQRExportFormat
+	}
+	if e.ImageFormat == "" {
+		e.ImageFormat = QRImagePNG
+	}
+	if e.ImageFormat != QRImagePNG && e.ImageFormat != QRImageSVG {
+		return nil, ErrInvalidQRImageFormat
+	}
+	if e.Size == 0 {
+		e.Size = c.QRSize
+	}
+	if e.Size <= 0 {
+		e.Size = DefaultQRExportSize
+	}
+	if e.Size > MaxQRExportSize {
+		return nil, ErrInvalidQRExportSize
+	}
+	if len(c.Results) == 0 {
+		return nil, ErrNoQRRecipients
+	}
+	// The codes are generated here, so the template context doesn't need
+	// to generate its own
+	tc := *c
+	tc.QRSize = 0
+	recipients := []qrRecipient{}
+	for _, r := range c.Results {
+		ptx, err := NewPhishingTemplateContext(&tc, r.BaseRecipient, r.RId)
+		if err != nil {
+			return nil, err
+		}
+		symbol, err := newQRSymbol(ptx.URL, c.QROptions)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, qrRecipient{result: r, url: ptx.URL, symbol: symbol})
+	}
+	if e.Format == QRExportPDF {
+		return qrPDF(recipients, e.Size)
+	}
+	return qrZip(recipients, e)
+}
+
+// qrZip exports the QR codes as a zip of images
+func qrZip(recipients []qrRecipient, e QRExport) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	manifest := &bytes.Buffer{}
+	cw := csv.NewWriter(manifest)
+	cw.Write([]string{"file", "first_name", "last_name", "email", "position", "rid", "url"})
+	for i, qr := range recipients {
+		r := qr.result
+		name := r.Email
+		if name == "" {
+			name = r.Phone
+		}
+		filename := fmt.Sprintf("%04d_%s.%s", i+1, unsafeQRFilenameRegex.ReplaceAllString(name, "_"), e.ImageFormat)
+		var content []byte
+		var err error
+		if e.ImageFormat == QRImageSVG {
+			content, err = qr.symbol.SVG(e.Size)
+		} else {
+			content, err = qr.symbol.PNG(e.Size)
+		}
+		if err != nil {
+			return nil, err
+		}
+		f, err := zw.Create(filename)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = f.Write(content); err != nil {
+			return nil, err
+		}
+		cw.Write([]string{filename, r.FirstName, r.LastName, r.Email, r.Position, r.RId, qr.url})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return nil, err
+	}
+	f, err := zw.Create(qrExportManifestName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Write(manifest.Bytes()); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Page layout for PDF exports, in points on an A4 page
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfQRWidth    = 360
+	pdfMargin     = 100
+)
+
+// pdfWriter writes the objects of a PDF document, tracking their offsets
+// for the cross-reference table
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// object writes the next numbered object with the given body
+func (w *pdfWriter) object(body string) {
+	w.offsets = append(w.offsets, w.buf.Len())
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", len(w.offsets), body)
+}
+
+// stream writes the next numbered object as a stream
+func (w *pdfWriter) stream(dict string, data []byte) {
+	w.object(fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data))
+}
+
+// finish writes the cross-reference table and trailer, returning the
+// document
+func (w *pdfWriter) finish() []byte {
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, xref)
+	return w.buf.Bytes()
+}
+
+// pdfString encodes the text as a PDF string literal. Characters which
+// can't be shown with the standard fonts are replaced.
+func pdfString(s string) string {
+	b := &strings.Builder{}
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+		case r > 0xff:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// pdfImage encodes the image as compressed RGB data
+func pdfImage(img image.Image) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	b := img.Bounds()
+	row := make([]byte, 0, b.Dx()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row = row[:0]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			row = append(row, byte(r>>8), byte(g>>8), byte(bl>>8))
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	err := zw.Close()
+	return buf.Bytes(), err
+}
+
+// qrPDF exports the QR codes as a PDF with a page for each recipient,
+// labelled with the recipient's details so that each printout can be
+// delivered to the right person
+func qrPDF(recipients []qrRecipient, size int) ([]byte, error) {
+	w := &pdfWriter{}
+	w.buf.WriteString("%PDF-1.4\n")
+	// Objects 1-3 are the catalog, page tree and font, followed by the
+	// page, contents and image of each recipient
+	kids := []string{}
+	for i := range recipients {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+3*i))
+	}
+	w.object("<< /Type /Catalog /Pages 2 0 R >>")
+	w.object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(recipients)))
+	w.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	for i, qr := range recipients {
+		page := 4 + 3*i
+		w.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> /XObject << /QR %d 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, page+2, page+1))
+
+		x := (pdfPageWidth - pdfQRWidth) / 2
+		y := pdfPageHeight - pdfMargin - pdfQRWidth
+		contents := &bytes.Buffer{}
+		fmt.Fprintf(contents, "q %d 0 0 %d %d %d cm /QR Do Q\n", pdfQRWidth, pdfQRWidth, x, y)
+		r := qr.result
+		lines := []struct {
+			size int
+			text string
+		}{
+			{16, strings.TrimSpace(r.FirstName + " " + r.LastName)},
+			{12, r.Position},
+			{12, r.Email},
+			{9, r.RId},
+		}
+		y -= 20
+		for _, line := range lines {
+			if line.text == "" {
+				continue
+			}
+			y -= line.size + 8
+			fmt.Fprintf(contents, "BT /F1 %d Tf %d %d Td %s Tj ET\n", line.size, x, y, pdfString(line.text))
+		}
+		w.stream("", contents.Bytes())
+
+		img := qr.symbol.Image(size)
+		data, err := pdfImage(img)
+		if err != nil {
+			return nil, err
+		}
+		w.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode",
+			img.Bounds().Dx(), img.Bounds().Dy()), data)
+	}
+	return w.finish(), nil
+}
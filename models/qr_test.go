@@ -0,0 +1,124 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+// testQRLogo returns a base64 encoded PNG to use as a QR code logo
+func testQRLogo(ch *check.C) string {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 10; y++ {
+			img.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+	buf := &bytes.Buffer{}
+	ch.Assert(png.Encode(buf, img), check.Equals, nil)
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func (s *ModelsSuite) TestQROptionsValidate(ch *check.C) {
+	ch.Assert(QROptions{}.Validate(), check.Equals, nil)
+	ch.Assert(QROptions{ErrorCorrection: "extreme"}.Validate(), check.Equals, ErrInvalidQRErrorCorrection)
+	ch.Assert(QROptions{Foreground: "blue"}.Validate(), check.Equals, ErrInvalidQRColor)
+	ch.Assert(QROptions{Foreground: "#fff"}.Validate(), check.Equals, ErrQRLowContrast)
+	ch.Assert(QROptions{QuietZone: MaxQRQuietZone + 1}.Validate(), check.Equals, ErrInvalidQRQuietZone)
+	ch.Assert(QROptions{Logo: "not an image", ErrorCorrection: QRErrorCorrectionHigh}.Validate(), check.Equals, ErrInvalidQRLogo)
+
+	logo := testQRLogo(ch)
+	ch.Assert(QROptions{Logo: logo}.Validate(), check.Equals, nil)
+	ch.Assert(QROptions{Logo: logo, ErrorCorrection: QRErrorCorrectionMedium}.Validate(), check.Equals, ErrQRLogoErrorCorrection)
+
+	// Logos are rejected by their dimensions before being decoded
+	buf := &bytes.Buffer{}
+	ch.Assert(png.Encode(buf, image.NewGray(image.Rect(0, 0, MaxQRLogoDimension+1, 1))), check.Equals, nil)
+	wide := base64.StdEncoding.EncodeToString(buf.Bytes())
+	ch.Assert(QROptions{Logo: wide}.Validate(), check.Equals, ErrQRLogoDimensions)
+
+	// Logos are decoded once and reused for every code
+	first, err := QROptions{Logo: logo}.logo()
+	ch.Assert(err, check.Equals, nil)
+	second, err := QROptions{Logo: logo}.logo()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(first == second, check.Equals, true)
+
+	c := s.createCampaignDependencies(ch)
+	c.QROptions = QROptions{Background: "#zzzzzz"}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidQRColor)
+}
+
+func (s *ModelsSuite) TestQRSymbolCustomization(ch *check.C) {
+	o := QROptions{Foreground: "#1a2b3c", Background: "#ffeedd", QuietZone: 1}
+	symbol, err := newQRSymbol("https://example.com", o)
+	ch.Assert(err, check.Equals, nil)
+	def, err := newQRSymbol("https://example.com", QROptions{})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(def.size-symbol.size, check.Equals, 2*(DefaultQRQuietZone-1))
+
+	img := symbol.Image(symbol.size)
+	ch.Assert(color.RGBAModel.Convert(img.At(0, 0)), check.Equals, color.RGBA{0xff, 0xee, 0xdd, 0xff})
+	// The finder pattern starts after the quiet zone
+	ch.Assert(color.RGBAModel.Convert(img.At(1, 1)), check.Equals, color.RGBA{0x1a, 0x2b, 0x3c, 0xff})
+
+	svg, err := symbol.SVG(300)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.HasPrefix(string(svg), "<?xml"), check.Equals, true)
+	ch.Assert(strings.Contains(string(svg), `width="300"`), check.Equals, true)
+	ch.Assert(strings.Contains(string(svg), `fill="#1a2b3c"`), check.Equals, true)
+	ch.Assert(strings.Contains(string(svg), "<image"), check.Equals, false)
+
+	o = QROptions{Logo: testQRLogo(ch)}
+	symbol, err = newQRSymbol("https://example.com", o)
+	ch.Assert(err, check.Equals, nil)
+	img = symbol.Image(200)
+	ch.Assert(color.RGBAModel.Convert(img.At(100, 100)), check.Equals, color.RGBA{255, 0, 0, 255})
+	svg, err = symbol.SVG(200)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.Contains(string(svg), "<image"), check.Equals, true)
+}
+
+func (s *ModelsSuite) TestExportCampaignQRCodes(ch *check.C) {
+	c := s.createCampaign(ch)
+	ch.Assert(len(c.Results) > 0, check.Equals, true)
+
+	_, err := ExportCampaignQRCodes(&c, QRExport{Format: "docx"})
+	ch.Assert(err, check.Equals, ErrInvalidQRExportFormat)
+	_, err = ExportCampaignQRCodes(&c, QRExport{Format: QRExportZip, ImageFormat: "gif"})
+	ch.Assert(err, check.Equals, ErrInvalidQRImageFormat)
+	_, err = ExportCampaignQRCodes(&c, QRExport{Format: QRExportPDF, Size: MaxQRExportSize + 1})
+	ch.Assert(err, check.Equals, ErrInvalidQRExportSize)
+
+	pdf, err := ExportCampaignQRCodes(&c, QRExport{Format: QRExportPDF, Size: 100})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.HasPrefix(string(pdf), "%PDF-"), check.Equals, true)
+	ch.Assert(strings.HasSuffix(string(pdf), "%%EOF\n"), check.Equals, true)
+	ch.Assert(strings.Count(string(pdf), "/Type /Page "), check.Equals, len(c.Results))
+	ch.Assert(strings.Contains(string(pdf), "("+c.Results[0].RId+")"), check.Equals, true)
+
+	export, err := ExportCampaignQRCodes(&c, QRExport{Format: QRExportZip, ImageFormat: QRImageSVG})
+	ch.Assert(err, check.Equals, nil)
+	zr, err := zip.NewReader(bytes.NewReader(export), int64(len(export)))
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(zr.File), check.Equals, len(c.Results)+1)
+	ch.Assert(strings.HasSuffix(zr.File[0].Name, ".svg"), check.Equals, true)
+	f, err := zr.Open(qrExportManifestName)
+	ch.Assert(err, check.Equals, nil)
+	manifest, err := io.ReadAll(f)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.Contains(string(manifest), c.Results[0].RId), check.Equals, true)
+	ch.Assert(strings.Contains(string(manifest), zr.File[0].Name), check.Equals, true)
+}
+
+func (s *ModelsSuite) TestPDFString(ch *check.C) {
+	ch.Assert(pdfString(`a (b) \c`), check.Equals, `(a \(b\) \\c)`)
+	ch.Assert(pdfString("Zoë 日本\n"), check.Equals, "(Zo\xeb ??)")
+}
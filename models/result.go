@@ -35,6 +35,9 @@ type Result struct {
 	SendDate     time.Time `json:"send_date"`
 	Reported     bool      `json:"reported" sql:"not null"`
 	ModifiedDate time.Time `json:"modified_date"`
+	SMTPId       int64     `json:"smtp_id"`
+	MessageId    string    `json:"-"`
+	PageStep     int       `json:"page_step"`
 	BaseRecipient
 }
 
@@ -47,6 +50,9 @@ func (r *Result) createEvent(status string, details interface{}) (*Event, error)
 		}
 		e.Details = string(dj)
 	}
+	if d, ok := details.(EventDetails); ok {
+		e.Classification = d.Classification
+	}
 	AddEvent(e, r.CampaignId)
 	return e, nil
 }
@@ -102,13 +108,27 @@ func (r *Result) HandleEmailBackoff(err error, sendDate time.Time) error {
 	return db.Save(r).Error
 }
 
+// HandleEmailDeferred updates a Result to indicate that the email was held
+// back by the sending profile's rate limits and rescheduled
+func (r *Result) HandleEmailDeferred(sendDate time.Time) error {
+	r.Status = StatusScheduled
+	r.SendDate = sendDate
+	r.ModifiedDate = time.Now().UTC()
+	return db.Save(r).Error
+}
+
 // HandleEmailOpened updates a Result in the case where the recipient opened the
-// email.
+// email. Opens made by image proxies and scanners are recorded without
+// updating the status.
 func (r *Result) HandleEmailOpened(details EventDetails) error {
+	details.Classification, details.ClassificationReason = r.classifyEvent(EventOpened, details)
 	event, err := r.createEvent(EventOpened, details)
 	if err != nil {
 		return err
 	}
+	if details.Classification != ClassificationHuman {
+		return nil
+	}
 	// Don't update the status if the user already clicked the link
 	// or submitted data to the campaign
 	if r.Status == EventClicked || r.Status == EventDataSubmit {
@@ -120,12 +140,17 @@ func (r *Result) HandleEmailOpened(details EventDetails) error {
 }
 
 // HandleClickedLink updates a Result in the case where the recipient clicked
-// the link in an email.
+// the link in an email. Clicks made by scanners and link previews are
+// recorded without updating the status or assigning training.
 func (r *Result) HandleClickedLink(details EventDetails) error {
+	details.Classification, details.ClassificationReason = r.classifyEvent(EventClicked, details)
 	event, err := r.createEvent(EventClicked, details)
 	if err != nil {
 		return err
 	}
+	if details.Classification != ClassificationHuman {
+		return nil
+	}
 	// Don't update the status if the user has already submitted data via the
 	// landing page form.
 	if r.Status == EventDataSubmit {
@@ -133,7 +158,11 @@ func (r *Result) HandleClickedLink(details EventDetails) error {
 	}
 	r.Status = EventClicked
 	r.ModifiedDate = event.Time
-	return db.Save(r).Error
+	err = db.Save(r).Error
+	if err != nil {
+		return err
+	}
+	return r.assignTrainingModule(EventClicked)
 }
 
 // HandleFormSubmit updates a Result in the case where the recipient submitted
@@ -145,6 +174,43 @@ func (r *Result) HandleFormSubmit(details EventDetails) error {
 	}
 	r.Status = EventDataSubmit
 	r.ModifiedDate = event.Time
+	err = db.Save(r).Error
+	if err != nil {
+		return err
+	}
+	return r.assignTrainingModule(EventDataSubmit)
+}
+
+// HandlePageStep records that the recipient reached a step of a multi-step
+// landing page. Steps are only advanced, so the result keeps the furthest
+// step reached.
+func (r *Result) HandlePageStep(step int) error {
+	if step <= r.PageStep {
+		return nil
+	}
+	r.PageStep = step
+	r.ModifiedDate = time.Now().UTC()
+	return db.Save(r).Error
+}
+
+// HandleTrainingCompleted records that the recipient completed the training
+// page shown after they fell for the campaign. The status isn't changed, so
+// that it still reflects how far the recipient went. Completion is only
+// recorded once.
+func (r *Result) HandleTrainingCompleted(details EventDetails) error {
+	count := 0
+	err := db.Model(&Event{}).Where("campaign_id=? and email=? and message=?", r.CampaignId, r.Email, EventTraining).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	event, err := r.createEvent(EventTraining, details)
+	if err != nil {
+		return err
+	}
+	r.ModifiedDate = event.Time
 	return db.Save(r).Error
 }
 
@@ -163,6 +229,19 @@ func (r *Result) HandleEmailReport(details EventDetails) error {
 	return nil
 }
 
+// HandleEmailBounce updates a Result in the case where the recipient's mail
+// server returned a delivery status notification indicating that the email
+// couldn't be delivered.
+func (r *Result) HandleEmailBounce(details EventBounce) error {
+	event, err := r.createEvent(EventBounced, details)
+	if err != nil {
+		return err
+	}
+	r.Status = EventBounced
+	r.ModifiedDate = event.Time
+	return db.Save(r).Error
+}
+
 // UpdateGeo updates the latitude and longitude of the result in
 // the database given an IP address
 func (r *Result) UpdateGeo(addr string) error {
@@ -241,6 +320,14 @@ func (r *Result) GenerateId(tx *gorm.DB) error {
 	return nil
 }
 
+// GetResultByMessageId returns the Result object from the database given the
+// Message-Id of the email sent to the recipient
+func GetResultByMessageId(messageID string) (Result, error) {
+	r := Result{}
+	err := db.Where("message_id=?", messageID).First(&r).Error
+	return r, err
+}
+
 // GetResult returns the Result object from the database
 // given the ResultId
 func GetResult(rid string) (Result, error) {
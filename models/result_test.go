@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/jordan-wright/email"
 	"gopkg.in/check.v1"
 )
 
@@ -118,3 +119,38 @@ func (s *ModelsSuite) TestDuplicateResults(ch *check.C) {
 	ch.Assert(c.Results[0].Email, check.Equals, group.Targets[0].Email)
 	ch.Assert(c.Results[1].Email, check.Equals, group.Targets[2].Email)
 }
+
+func (s *ModelsSuite) TestResultEmailBounce(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	result := campaign.Results[0]
+	m := &MailLog{}
+	err := db.Where("r_id=? AND campaign_id=?", result.RId, campaign.Id).
+		Find(m).Error
+	ch.Assert(err, check.Equals, nil)
+
+	msg := email.NewEmail()
+	ch.Assert(m.Generate(msg), check.Equals, nil)
+	ch.Assert(m.Success(), check.Equals, nil)
+
+	// The Message-Id is stored so that bounces can be matched to the result
+	messageID := msg.Headers.Get("Message-Id")
+	result, err = GetResultByMessageId(messageID)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.RId, check.Equals, m.RId)
+
+	details := EventBounce{
+		Recipient:  result.Email,
+		Status:     "5.1.1",
+		Diagnostic: "550 5.1.1 User unknown",
+	}
+	ch.Assert(result.HandleEmailBounce(details), check.Equals, nil)
+	result, err = GetResult(result.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.Status, check.Equals, EventBounced)
+
+	stats, err := getCampaignStats(campaign.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(stats.Bounced, check.Equals, int64(1))
+	ch.Assert(stats.Delivered, check.Equals, stats.Total-1)
+	ch.Assert(stats.EmailsSent, check.Equals, int64(0))
+}
@@ -24,11 +24,21 @@ type SmsRequest struct {
 	BaseRecipient
 }
 
-// Validate ensures the SmsRequest structure is valid.
+// ErrPhoneNotSpecified is thrown when no phone number is specified for an
+// SMS recipient
+var ErrPhoneNotSpecified = errors.New("No phone number specified")
+
+// Validate ensures the SmsRequest structure is valid, normalizing the
+// recipient phone number to the E.164 format.
 func (s *SmsRequest) Validate() error {
-	if s.Email == "" { // Re-using Email field for phone number
-		return errors.New("No phone number specified")
+	if s.Phone == "" {
+		return ErrPhoneNotSpecified
 	}
+	phone, err := NormalizePhoneNumber(s.Phone, GetDefaultCountryCode())
+	if err != nil {
+		return err
+	}
+	s.Phone = phone
 	return nil
 }
 
@@ -74,15 +84,18 @@ func (s *SmsRequest) Generate(msg *smser.TwilioMessage) error {
 		}
 	}
 
-	if s.Template.Text != "" {
-		text, err := ExecuteTemplate(s.Template.Text, ptx)
+	if t := s.Template.Localize(s.Language); t.Text != "" {
+		text, err := ExecuteTemplate(t.Text, ptx)
+		if IsTemplateLimitError(err) {
+			return fmt.Errorf("Unable to render the SMS text: %w", err)
+		}
 		if err != nil {
 			log.Warn(err)
 		}
 
 		msg.Client = *twilio.NewRestClientWithParams(twilio.ClientParams{Username: s.SMS.TwilioAccountSid, Password: s.SMS.TwilioAuthToken})
 		msg.Params = openapi.CreateMessageParams{
-			To:   &s.Email, // Re-using Email field for phone number
+			To:   &s.Phone,
 			From: &s.SMS.SMSFrom,
 			Body: &text,
 		}
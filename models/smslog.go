@@ -144,9 +144,15 @@ func (s *SmsLog) Generate(msg *smser.TwilioMessage) error {
 	if err != nil {
 		return err
 	}
+	if !r.SendDate.IsZero() {
+		ptx.SendDate = r.SendDate
+	}
 
-	if c.Template.Text != "" {
-		text, err := ExecuteTemplate(c.Template.Text, ptx)
+	if t := c.Template.Localize(r.Language); t.Text != "" {
+		text, err := ExecuteTemplate(t.Text, ptx)
+		if IsTemplateLimitError(err) {
+			return fmt.Errorf("Unable to render the SMS text: %w", err)
+		}
 		if err != nil {
 			log.Warn(err)
 		}
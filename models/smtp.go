@@ -22,6 +22,9 @@ import (
 // between mailer and gomail.
 type Dialer struct {
 	*gomail.Dialer
+	throttleKey string
+	limits      mailer.Limits
+	signer      mailer.Signer
 }
 
 type sender struct {
@@ -41,6 +44,63 @@ func (d *Dialer) Dial() (mailer.Sender, error) {
 	return &sender{s}, nil
 }
 
+// ThrottleKey identifies the sending profile the dialer belongs to, so that
+// its limits are shared by every campaign using the profile.
+func (d *Dialer) ThrottleKey() string {
+	return d.throttleKey
+}
+
+// Limits returns the sending limits configured on the sending profile
+func (d *Dialer) Limits() mailer.Limits {
+	return d.limits
+}
+
+// Signer returns the signer for messages sent through the sending profile
+func (d *Dialer) Signer() mailer.Signer {
+	return d.signer
+}
+
+// throttledDialer applies a sending profile's limits and signing to a
+// dialer for one of the other interfaces.
+type throttledDialer struct {
+	mailer.Dialer
+	throttleKey string
+	limits      mailer.Limits
+	signer      mailer.Signer
+}
+
+// ThrottleKey identifies the sending profile the dialer belongs to
+func (d *throttledDialer) ThrottleKey() string {
+	return d.throttleKey
+}
+
+// Limits returns the sending limits configured on the sending profile
+func (d *throttledDialer) Limits() mailer.Limits {
+	return d.limits
+}
+
+// Signer returns the signer for messages sent through the sending profile
+func (d *throttledDialer) Signer() mailer.Signer {
+	return d.signer
+}
+
+const (
+	// InterfaceSMTP sends email by connecting to an SMTP server
+	InterfaceSMTP = "SMTP"
+	// InterfaceGraph sends email using the Microsoft Graph sendMail endpoint
+	InterfaceGraph = "MicrosoftGraph"
+	// InterfaceGmail sends email using the Gmail API
+	InterfaceGmail = "GmailAPI"
+	// InterfaceIMAP places email directly into the recipient's mailbox
+	// using IMAP APPEND, bypassing mail transport entirely
+	InterfaceIMAP = "IMAP"
+)
+
+// NewMailboxInjectionDialer returns the dialer used by sending profiles
+// with the IMAP interface. It's provided by the imap package, which can't
+// be imported here since it depends on models.
+var NewMailboxInjectionDialer func(s *SMTP) (mailer.Dialer, error)
+
 // SMTP contains the attributes needed to handle the sending of campaign emails
 type SMTP struct {
 	Id               int64     `json:"id" gorm:"column:id; primary_key:yes"`
@@ -55,6 +115,35 @@ type SMTP struct {
 	Headers          []Header  `json:"headers"`
 	ModifiedDate     time.Time `json:"modified_date"`
 	CreatedBy        string    `json:"created_by" sql:"-"`
+
+	// Sending limits enforced by the mailer. A value of 0 disables the limit.
+	MessagesPerMinute        int `json:"messages_per_minute"`
+	MessagesPerHour          int `json:"messages_per_hour"`
+	MaxConnections           int `json:"max_connections"`
+	MaxMessagesPerConnection int `json:"max_messages_per_connection"`
+
+	// OAuth2 credentials used by the HTTP API interfaces. For Microsoft
+	// Graph, these are the application's tenant, client ID and client
+	// secret. For the Gmail API, the client ID is the service account's
+	// email address and the client secret is its PEM encoded private key.
+	// Like the DKIM private key, the client secret is only accepted when
+	// creating or updating the profile, and is stored encrypted.
+	OAuthTenantId              string `json:"oauth_tenant_id" gorm:"column:oauth_tenant_id"`
+	OAuthClientId              string `json:"oauth_client_id" gorm:"column:oauth_client_id"`
+	OAuthClientSecret          string `json:"oauth_client_secret,omitempty" sql:"-"`
+	EncryptedOAuthClientSecret string `json:"-" gorm:"column:oauth_client_secret"`
+
+	// Settings used by the IMAP interface, which logs in with the profile's
+	// admin credentials on behalf of each recipient.
+	MailboxTLS    bool   `json:"mailbox_tls"`
+	MailboxFolder string `json:"mailbox_folder"`
+
+	// DKIM signing settings. The private key is only accepted when creating
+	// or updating the profile, and is stored encrypted.
+	DKIMDomain       string `json:"dkim_domain" gorm:"column:dkim_domain"`
+	DKIMSelector     string `json:"dkim_selector" gorm:"column:dkim_selector"`
+	DKIMPrivateKey   string `json:"dkim_private_key,omitempty" sql:"-"`
+	EncryptedDKIMKey string `json:"-" gorm:"column:dkim_private_key"`
 }
 
 // Header contains the fields and methods for a sending profile to have
@@ -81,6 +170,30 @@ var ErrHostNotSpecified = errors.New("No SMTP Host specified")
 // ErrInvalidHost indicates that the SMTP server string is invalid
 var ErrInvalidHost = errors.New("Invalid SMTP server address")
 
+// ErrInvalidInterface is thrown when the sending profile's interface type
+// is not supported
+var ErrInvalidInterface = errors.New("Invalid sending profile interface type")
+
+// ErrOAuthCredentialsNotSpecified is thrown when a sending profile using one
+// of the HTTP API interfaces doesn't have its OAuth2 credentials configured
+var ErrOAuthCredentialsNotSpecified = errors.New("No OAuth2 client credentials specified")
+
+// ErrTenantNotSpecified is thrown when a Microsoft Graph sending profile
+// doesn't specify the tenant the application is registered in
+var ErrTenantNotSpecified = errors.New("No Microsoft tenant specified")
+
+// ErrMailboxCredentialsNotSpecified is thrown when a sending profile using
+// the IMAP interface doesn't have its admin credentials configured
+var ErrMailboxCredentialsNotSpecified = errors.New("No IMAP admin credentials specified")
+
+// ErrInterfaceUnavailable is thrown when the dialer for a sending profile's
+// interface type has not been provided
+var ErrInterfaceUnavailable = errors.New("Sending profile interface type is unavailable")
+
+// ErrInvalidSendingLimit is thrown when a sending limit on the SMTP
+// configuration is negative
+var ErrInvalidSendingLimit = errors.New("Sending limits cannot be negative")
+
 // TableName specifies the database tablename for Gorm to use
 func (s SMTP) TableName() string {
 	return "smtp"
@@ -91,15 +204,70 @@ func (s *SMTP) Validate() error {
 	switch {
 	case s.FromAddress == "":
 		return ErrFromAddressNotSpecified
-	case s.Host == "":
-		return ErrHostNotSpecified
 	case !validateFromAddress(s.FromAddress):
 		return ErrInvalidFromAddress
+	case s.MessagesPerMinute < 0, s.MessagesPerHour < 0, s.MaxConnections < 0, s.MaxMessagesPerConnection < 0:
+		return ErrInvalidSendingLimit
 	}
 	_, err := mail.ParseAddress(s.FromAddress)
 	if err != nil {
 		return err
 	}
+	err = s.validateDKIM()
+	if err != nil {
+		return err
+	}
+	switch s.Interface {
+	case "", InterfaceSMTP:
+		return s.validateHost()
+	case InterfaceIMAP:
+		if s.Username == "" || s.Password == "" {
+			return ErrMailboxCredentialsNotSpecified
+		}
+		return s.validateHost()
+	case InterfaceGraph:
+		if s.OAuthTenantId == "" {
+			return ErrTenantNotSpecified
+		}
+		fallthrough
+	case InterfaceGmail:
+		if s.OAuthClientId == "" || (s.OAuthClientSecret == "" && s.EncryptedOAuthClientSecret == "") {
+			return ErrOAuthCredentialsNotSpecified
+		}
+		return nil
+	}
+	return ErrInvalidInterface
+}
+
+// setOAuthClientSecret encrypts a newly provided OAuth client secret for
+// storage. If no new secret was provided, the encrypted secret is unchanged.
+func (s *SMTP) setOAuthClientSecret() error {
+	if s.OAuthClientSecret == "" {
+		return nil
+	}
+	secret, err := encryptSecret(s.OAuthClientSecret)
+	if err != nil {
+		return err
+	}
+	s.EncryptedOAuthClientSecret = secret
+	s.OAuthClientSecret = ""
+	return nil
+}
+
+// getOAuthClientSecret returns the OAuth client secret, decrypting the
+// stored secret unless one was provided with the profile.
+func (s *SMTP) getOAuthClientSecret() (string, error) {
+	if s.OAuthClientSecret != "" {
+		return s.OAuthClientSecret, nil
+	}
+	return decryptSecret(s.EncryptedOAuthClientSecret)
+}
+
+// validateHost ensures the SMTP server is in host:port format
+func (s *SMTP) validateHost() error {
+	if s.Host == "" {
+		return ErrHostNotSpecified
+	}
 	// Make sure addr is in host:port format
 	hp := strings.Split(s.Host, ":")
 	if len(hp) > 2 {
@@ -107,11 +275,11 @@ func (s *SMTP) Validate() error {
 	} else if len(hp) < 2 {
 		hp = append(hp, "25")
 	}
-	_, err = strconv.Atoi(hp[1])
+	_, err := strconv.Atoi(hp[1])
 	if err != nil {
 		return ErrInvalidHost
 	}
-	return err
+	return nil
 }
 
 // validateFromAddress validates
@@ -120,8 +288,87 @@ func validateFromAddress(email string) bool {
 	return r.MatchString(email)
 }
 
-// GetDialer returns a dialer for the given SMTP profile
+// limits returns the sending limits configured on the profile
+func (s *SMTP) limits() mailer.Limits {
+	return mailer.Limits{
+		MessagesPerMinute:        s.MessagesPerMinute,
+		MessagesPerHour:          s.MessagesPerHour,
+		MaxConnections:           s.MaxConnections,
+		MaxMessagesPerConnection: s.MaxMessagesPerConnection,
+	}
+}
+
+// throttleKey identifies the profile so that its limits are shared by
+// every campaign using it. Unsaved profiles (e.g. when sending a test
+// email) don't have an id and so don't share limits with anything else.
+func (s *SMTP) throttleKey() string {
+	if s.Id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(s.Id, 10)
+}
+
+// GetDialer returns a dialer for the given sending profile, based on its
+// interface type
 func (s *SMTP) GetDialer() (mailer.Dialer, error) {
+	var d mailer.Dialer
+	switch s.Interface {
+	case InterfaceGraph:
+		secret, err := s.getOAuthClientSecret()
+		if err != nil {
+			return nil, err
+		}
+		d = &mailer.GraphDialer{
+			TenantID:     s.OAuthTenantId,
+			ClientID:     s.OAuthClientId,
+			ClientSecret: secret,
+		}
+	case InterfaceGmail:
+		secret, err := s.getOAuthClientSecret()
+		if err != nil {
+			return nil, err
+		}
+		d = &mailer.GmailDialer{
+			ClientEmail: s.OAuthClientId,
+			PrivateKey:  secret,
+			Subject:     s.FromAddress,
+		}
+	case InterfaceIMAP:
+		if NewMailboxInjectionDialer == nil {
+			return nil, ErrInterfaceUnavailable
+		}
+		var err error
+		d, err = NewMailboxInjectionDialer(s)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return s.getSMTPDialer()
+	}
+	signer, err := s.signer()
+	if err != nil {
+		return nil, err
+	}
+	return &throttledDialer{
+		Dialer:      d,
+		throttleKey: s.throttleKey(),
+		limits:      s.limits(),
+		signer:      signer,
+	}, nil
+}
+
+// signer returns the signer for messages sent through the profile, or nil
+// if they aren't signed
+func (s *SMTP) signer() (mailer.Signer, error) {
+	signer, err := s.getDKIMSigner()
+	if err != nil || signer == nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// getSMTPDialer returns a dialer which connects to the profile's SMTP server
+func (s *SMTP) getSMTPDialer() (mailer.Dialer, error) {
 	// Setup the message and dial
 	hp := strings.Split(s.Host, ":")
 	if len(hp) < 2 {
@@ -146,7 +393,17 @@ func (s *SMTP) GetDialer() (mailer.Dialer, error) {
 		hostname = "localhost"
 	}
 	d.LocalName = hostname
-	return &Dialer{d}, err
+	signer, err := s.signer()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &Dialer{
+		Dialer:      d,
+		throttleKey: s.throttleKey(),
+		limits:      s.limits(),
+		signer:      signer,
+	}, nil
 }
 
 // GetSMTPs returns the SMTPs owned by the given user.
@@ -211,13 +468,23 @@ func GetSMTPByName(n string, uid int64) (SMTP, error) {
 // PostSMTP creates a new SMTP in the database.
 func PostSMTP(s *SMTP) error {
 	if s.Interface == "" {
-		s.Interface = "SMTP"
+		s.Interface = InterfaceSMTP
 	}
 	err := s.Validate()
 	if err != nil {
 		log.Error(err)
 		return err
 	}
+	err = s.setDKIMKey("")
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = s.setOAuthClientSecret()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	// Insert into the DB
 	err = db.Save(s).Error
 	if err != nil {
@@ -247,13 +514,25 @@ func PutSMTP(s *SMTP) error {
 	}
 
 	if s.Interface == "" {
-		s.Interface = "SMTP"
+		s.Interface = InterfaceSMTP
 	}
+	// Keep the stored client secret unless a new one was provided
+	s.EncryptedOAuthClientSecret = existing.EncryptedOAuthClientSecret
 	err = s.Validate()
 	if err != nil {
 		log.Error(err)
 		return err
 	}
+	err = s.setDKIMKey(existing.EncryptedDKIMKey)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = s.setOAuthClientSecret()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	err = db.Where("id=?", s.Id).Save(s).Error
 	if err != nil {
 		log.Error(err)
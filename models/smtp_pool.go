@@ -0,0 +1,203 @@
+package models
+
+import (
+	"errors"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// SMTPStrategyRoundRobin distributes a campaign's emails evenly across
+	// its sending profiles, in order.
+	SMTPStrategyRoundRobin = "round-robin"
+	// SMTPStrategyWeighted distributes a campaign's emails across its
+	// sending profiles in proportion to their weights.
+	SMTPStrategyWeighted = "weighted"
+)
+
+// ErrInvalidSMTPStrategy indicates the campaign's sending profile
+// distribution strategy is not supported
+var ErrInvalidSMTPStrategy = errors.New("Invalid sending profile strategy")
+
+// ErrInvalidSMTPWeight indicates that a sending profile in the campaign's
+// pool has a negative weight, or that no profile can be used with the
+// weighted strategy
+var ErrInvalidSMTPWeight = errors.New("Sending profile weights must be positive")
+
+// ErrNoFailoverSMTP is thrown when every sending profile in a campaign's
+// pool has been tried
+var ErrNoFailoverSMTP = errors.New("No sending profile left to fail over to")
+
+// CampaignSMTP is a sending profile in a campaign's pool. The profiles are
+// used in the order given by Position, with Weight controlling the share of
+// emails sent through the profile when using the weighted strategy.
+type CampaignSMTP struct {
+	Id         int64 `json:"-"`
+	CampaignId int64 `json:"-"`
+	SMTPId     int64 `json:"-"`
+	SMTP       SMTP  `json:"smtp" sql:"-"`
+	Position   int   `json:"position"`
+	Weight     int   `json:"weight"`
+}
+
+// TableName specifies the database tablename for Gorm to use
+func (cs CampaignSMTP) TableName() string {
+	return "campaign_smtp"
+}
+
+// validateSMTPPool ensures the campaign's sending profile pool and strategy
+// are valid
+func (c *Campaign) validateSMTPPool() error {
+	switch c.SMTPStrategy {
+	case "", SMTPStrategyRoundRobin, SMTPStrategyWeighted:
+	default:
+		return ErrInvalidSMTPStrategy
+	}
+	total := 0
+	for _, cs := range c.SMTPPool {
+		if cs.SMTP.Name == "" {
+			return ErrSMTPNotSpecified
+		}
+		if cs.Weight < 0 {
+			return ErrInvalidSMTPWeight
+		}
+		total += cs.Weight
+	}
+	if len(c.SMTPPool) > 0 && c.SMTPStrategy == SMTPStrategyWeighted && total == 0 {
+		return ErrInvalidSMTPWeight
+	}
+	return nil
+}
+
+// resolveSMTPPool looks up the sending profiles in the campaign's pool by
+// name. The first profile in the pool becomes the campaign's primary
+// sending profile.
+func (c *Campaign) resolveSMTPPool(uid int64) error {
+	for i := range c.SMTPPool {
+		cs := &c.SMTPPool[i]
+		s, err := GetSMTPByName(cs.SMTP.Name, uid)
+		if err == gorm.ErrRecordNotFound {
+			log.WithFields(logrus.Fields{
+				"smtp": cs.SMTP.Name,
+			}).Error("Sending profile does not exist")
+			return ErrSMTPNotFound
+		} else if err != nil {
+			log.Error(err)
+			return err
+		}
+		cs.SMTP = s
+		cs.SMTPId = s.Id
+		cs.Position = i
+		// Round-robin treats every profile equally
+		if c.SMTPStrategy != SMTPStrategyWeighted {
+			cs.Weight = 1
+		}
+	}
+	if c.SMTPStrategy == "" {
+		c.SMTPStrategy = SMTPStrategyRoundRobin
+	}
+	if len(c.SMTPPool) > 0 {
+		c.SMTP = c.SMTPPool[0].SMTP
+	}
+	return nil
+}
+
+// getSMTPPool loads the sending profiles in the campaign's pool, including
+// their custom headers.
+func (c *Campaign) getSMTPPool() error {
+	err := db.Where("campaign_id=?", c.Id).Order("position asc").Find(&c.SMTPPool).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	for i := range c.SMTPPool {
+		cs := &c.SMTPPool[i]
+		err = db.Table("smtp").Where("id=?", cs.SMTPId).Find(&cs.SMTP).Error
+		if err != nil {
+			if err != gorm.ErrRecordNotFound {
+				return err
+			}
+			cs.SMTP = SMTP{Name: "[Deleted]"}
+			log.Warnf("%s: sending profile not found for campaign pool", err)
+			continue
+		}
+		err = db.Where("smtp_id=?", cs.SMTP.Id).Find(&cs.SMTP.Headers).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSMTP returns the sending profile with the given id, which is either
+// the campaign's primary sending profile or one from its pool.
+func (c *Campaign) getSMTP(id int64) (SMTP, error) {
+	if id == 0 || id == c.SMTP.Id {
+		return c.SMTP, nil
+	}
+	for _, cs := range c.SMTPPool {
+		if cs.SMTPId == id && cs.SMTP.Id == id {
+			return cs.SMTP, nil
+		}
+	}
+	return SMTP{}, ErrSMTPNotFound
+}
+
+// nextFailoverSMTP returns the sending profile to use once the given
+// profile is unreachable. Profiles are tried in pool order, wrapping around
+// to the start of the pool, and each profile is only tried once.
+func (c *Campaign) nextFailoverSMTP(current int64, attempts int) (int64, error) {
+	n := len(c.SMTPPool)
+	if attempts+1 >= n {
+		return 0, ErrNoFailoverSMTP
+	}
+	for i, cs := range c.SMTPPool {
+		if cs.SMTPId == current {
+			return c.SMTPPool[(i+1)%n].SMTPId, nil
+		}
+	}
+	return 0, ErrNoFailoverSMTP
+}
+
+// smtpSelector assigns sending profiles from a campaign's pool to emails
+// according to the campaign's strategy.
+type smtpSelector struct {
+	pool     []CampaignSMTP
+	strategy string
+	next     int
+	// current holds the running weights used by the smooth weighted
+	// round-robin algorithm.
+	current []int
+}
+
+func newSMTPSelector(c *Campaign) *smtpSelector {
+	return &smtpSelector{
+		pool:     c.SMTPPool,
+		strategy: c.SMTPStrategy,
+		current:  make([]int, len(c.SMTPPool)),
+	}
+}
+
+// pick returns the id of the sending profile to use for the next email.
+// Weighted selection interleaves the profiles rather than sending in runs,
+// so that a profile with weight 2 and another with weight 1 are used in the
+// order A, B, A instead of A, A, B.
+func (s *smtpSelector) pick() int64 {
+	if s.strategy != SMTPStrategyWeighted {
+		id := s.pool[s.next%len(s.pool)].SMTPId
+		s.next++
+		return id
+	}
+	total := 0
+	best := -1
+	for i, cs := range s.pool {
+		s.current[i] += cs.Weight
+		total += cs.Weight
+		if best == -1 || s.current[i] > s.current[best] {
+			best = i
+		}
+	}
+	s.current[best] -= total
+	return s.pool[best].SMTPId
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"gopkg.in/check.v1"
+)
+
+func TestSMTPSelectorWeighted(t *testing.T) {
+	c := &Campaign{
+		SMTPStrategy: SMTPStrategyWeighted,
+		SMTPPool: []CampaignSMTP{
+			CampaignSMTP{SMTPId: 1, Weight: 2},
+			CampaignSMTP{SMTPId: 2, Weight: 1},
+			CampaignSMTP{SMTPId: 3, Weight: 0},
+		},
+	}
+	selector := newSMTPSelector(c)
+	expected := []int64{1, 2, 1, 1, 2, 1}
+	for i, id := range expected {
+		got := selector.pick()
+		if got != id {
+			t.Fatalf("Unexpected sending profile for email %d. Expected %d Got %d", i, id, got)
+		}
+	}
+}
+
+func (s *ModelsSuite) createSMTPPoolCampaign(ch *check.C, strategy string) Campaign {
+	c := s.createCampaignDependencies(ch)
+	c.SMTPStrategy = strategy
+	c.SMTPPool = []CampaignSMTP{CampaignSMTP{SMTP: c.SMTP, Weight: 1}}
+	for _, name := range []string{"Backup Profile", "Other Profile"} {
+		smtp := SMTP{Name: name, UserId: 1, Host: "example.com", FromAddress: "backup@test.com"}
+		ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+		c.SMTPPool = append(c.SMTPPool, CampaignSMTP{SMTP: smtp, Weight: 1})
+	}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	return c
+}
+
+func (s *ModelsSuite) TestPostCampaignSMTPPool(ch *check.C) {
+	c := s.createSMTPPoolCampaign(ch, "")
+
+	campaign, err := GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(campaign.SMTPStrategy, check.Equals, SMTPStrategyRoundRobin)
+	ch.Assert(len(campaign.SMTPPool), check.Equals, 3)
+	ch.Assert(campaign.SMTP.Id, check.Equals, campaign.SMTPPool[0].SMTPId)
+	for i, cs := range campaign.SMTPPool {
+		ch.Assert(cs.Position, check.Equals, i)
+		ch.Assert(cs.SMTP.Id, check.Equals, cs.SMTPId)
+	}
+
+	// Emails should be distributed across the pool in order
+	ms, err := GetMailLogsByCampaign(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms), check.Equals, 4)
+	for i, m := range ms {
+		ch.Assert(m.SMTPId, check.Equals, campaign.SMTPPool[i%3].SMTPId)
+	}
+}
+
+func (s *ModelsSuite) TestPostCampaignInvalidSMTPPool(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.SMTPStrategy = "random"
+	c.SMTPPool = []CampaignSMTP{CampaignSMTP{SMTP: c.SMTP}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidSMTPStrategy)
+
+	c.SMTPStrategy = SMTPStrategyWeighted
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidSMTPWeight)
+
+	c.SMTPPool = []CampaignSMTP{CampaignSMTP{SMTP: SMTP{Name: "Missing"}, Weight: 1}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrSMTPNotFound)
+}
+
+func (s *ModelsSuite) TestMailLogFailover(ch *check.C) {
+	c := s.createSMTPPoolCampaign(ch, SMTPStrategyRoundRobin)
+	ms, err := GetMailLogsByCampaign(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	m := ms[2]
+	ch.Assert(m.SMTPId, check.Equals, c.SMTPPool[2].SMTPId)
+	sendDate := m.SendDate
+
+	// The last profile in the pool should fail over to the first
+	reason := errors.New("host unreachable")
+	ch.Assert(m.Lock(), check.Equals, nil)
+	ch.Assert(m.Failover(reason), check.Equals, nil)
+	ch.Assert(m.SMTPId, check.Equals, c.SMTPPool[0].SMTPId)
+	ch.Assert(m.SendDate, check.Equals, sendDate)
+	ch.Assert(m.SendAttempt, check.Equals, 0)
+	ch.Assert(m.Processing, check.Equals, false)
+
+	// The dialer and sender should use the new profile
+	dialer, err := m.GetDialer()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(dialer.(*Dialer).ThrottleKey(), check.Equals, fmt.Sprintf("%d", c.SMTPPool[0].SMTPId))
+
+	ch.Assert(m.Failover(reason), check.Equals, nil)
+	ch.Assert(m.SMTPId, check.Equals, c.SMTPPool[1].SMTPId)
+	smtpFrom, err := m.GetSmtpFrom()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(smtpFrom, check.Equals, "backup@test.com")
+
+	// Every profile has now been tried
+	ch.Assert(m.Failover(reason), check.Equals, ErrNoFailoverSMTP)
+
+	// The profile used should be recorded on the result
+	ch.Assert(m.Success(), check.Equals, nil)
+	r, err := GetResult(m.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.SMTPId, check.Equals, c.SMTPPool[1].SMTPId)
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"fmt"
 
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/mailer"
 	"github.com/jinzhu/gorm"
 
 	check "gopkg.in/check.v1"
@@ -98,6 +99,107 @@ func (s *ModelsSuite) TestSMTPGetDialer(ch *check.C) {
 	ch.Assert(dialer.TLSConfig.InsecureSkipVerify, check.Equals, smtp.IgnoreCertErrors)
 }
 
+func (s *ModelsSuite) TestPostSMTPAPIInterfaces(ch *check.C) {
+	conf.EncryptionKey = "test encryption key"
+	defer func() { conf.EncryptionKey = "" }()
+
+	smtp := SMTP{
+		Name:        "Test Graph",
+		Interface:   InterfaceGraph,
+		FromAddress: "foo@example.com",
+		UserId:      1,
+	}
+	ch.Assert(PostSMTP(&smtp), check.Equals, ErrTenantNotSpecified)
+	smtp.OAuthTenantId = "tenant"
+	ch.Assert(PostSMTP(&smtp), check.Equals, ErrOAuthCredentialsNotSpecified)
+	smtp.OAuthClientId = "client"
+	smtp.OAuthClientSecret = "secret"
+	ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+
+	// The client secret should only be stored encrypted
+	stored, err := GetSMTP(smtp.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(stored.OAuthClientSecret, check.Equals, "")
+	ch.Assert(stored.EncryptedOAuthClientSecret, check.Not(check.Equals), "")
+	ch.Assert(stored.EncryptedOAuthClientSecret, check.Not(check.Equals), "secret")
+
+	// Updating the profile without providing a secret keeps the existing one
+	stored.Name = "Updated Graph"
+	ch.Assert(PutSMTP(&stored), check.Equals, nil)
+	stored, err = GetSMTP(smtp.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	d, err := stored.GetDialer()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(d.(*throttledDialer).Dialer.(*mailer.GraphDialer).ClientSecret, check.Equals, "secret")
+
+	// A new secret replaces the existing one
+	stored.OAuthClientSecret = "new secret"
+	ch.Assert(PutSMTP(&stored), check.Equals, nil)
+	stored, err = GetSMTP(smtp.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	d, err = stored.GetDialer()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(d.(*throttledDialer).Dialer.(*mailer.GraphDialer).ClientSecret, check.Equals, "new secret")
+
+	smtp = SMTP{
+		Name:        "Test Gmail",
+		Interface:   InterfaceGmail,
+		FromAddress: "foo@example.com",
+		UserId:      1,
+	}
+	ch.Assert(PostSMTP(&smtp), check.Equals, ErrOAuthCredentialsNotSpecified)
+
+	smtp.Interface = "Carrier Pigeon"
+	ch.Assert(PostSMTP(&smtp), check.Equals, ErrInvalidInterface)
+}
+
+func (s *ModelsSuite) TestPostSMTPMailboxInjection(ch *check.C) {
+	smtp := SMTP{
+		Name:        "Test IMAP",
+		Interface:   InterfaceIMAP,
+		Host:        "imap.example.com:993",
+		FromAddress: "foo@example.com",
+		UserId:      1,
+	}
+	ch.Assert(PostSMTP(&smtp), check.Equals, ErrMailboxCredentialsNotSpecified)
+	smtp.Username = "admin"
+	smtp.Password = "password"
+	ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+
+	// The dialer is provided by the imap package, which isn't loaded here
+	_, err := smtp.GetDialer()
+	ch.Assert(err, check.Equals, ErrInterfaceUnavailable)
+}
+
+func (s *ModelsSuite) TestSMTPGetDialerAPIInterfaces(ch *check.C) {
+	smtp := SMTP{
+		Id:                1,
+		Interface:         InterfaceGraph,
+		FromAddress:       "foo@example.com",
+		OAuthTenantId:     "tenant",
+		OAuthClientId:     "client",
+		OAuthClientSecret: "secret",
+		MessagesPerMinute: 10,
+	}
+	d, err := smtp.GetDialer()
+	ch.Assert(err, check.Equals, nil)
+	td := d.(*throttledDialer)
+	ch.Assert(td.ThrottleKey(), check.Equals, "1")
+	ch.Assert(td.Limits().MessagesPerMinute, check.Equals, 10)
+	gd := td.Dialer.(*mailer.GraphDialer)
+	ch.Assert(gd.TenantID, check.Equals, smtp.OAuthTenantId)
+	ch.Assert(gd.ClientID, check.Equals, smtp.OAuthClientId)
+	ch.Assert(gd.ClientSecret, check.Equals, smtp.OAuthClientSecret)
+
+	smtp.Interface = InterfaceGmail
+	d, err = smtp.GetDialer()
+	ch.Assert(err, check.Equals, nil)
+	gmd := d.(*throttledDialer).Dialer.(*mailer.GmailDialer)
+	ch.Assert(gmd.ClientEmail, check.Equals, smtp.OAuthClientId)
+	ch.Assert(gmd.PrivateKey, check.Equals, smtp.OAuthClientSecret)
+	ch.Assert(gmd.Subject, check.Equals, smtp.FromAddress)
+}
+
 func (s *ModelsSuite) TestGetInvalidSMTP(ch *check.C) {
 	_, err := GetSMTP(-1, 1)
 	ch.Assert(err, check.Equals, gorm.ErrRecordNotFound)
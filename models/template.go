@@ -11,17 +11,26 @@ import (
 
 // Template models hold the attributes for an email template to be sent to targets
 type Template struct {
-	Id             int64        `json:"id" gorm:"column:id; primary_key:yes"`
-	UserId         int64        `json:"-" gorm:"column:user_id"`
-	Type           string       `json:"type" gorm:"column:type"`
-	Name           string       `json:"name"`
-	EnvelopeSender string       `json:"envelope_sender"`
-	Subject        string       `json:"subject"`
-	Text           string       `json:"text"`
-	HTML           string       `json:"html" gorm:"column:html"`
-	ModifiedDate   time.Time    `json:"modified_date"`
-	Attachments    []Attachment `json:"attachments"`
-	CreatedBy      string       `json:"created_by" sql:"-"`
+	Id             int64     `json:"id" gorm:"column:id; primary_key:yes"`
+	UserId         int64     `json:"-" gorm:"column:user_id"`
+	Type           string    `json:"type" gorm:"column:type"`
+	Name           string    `json:"name"`
+	EnvelopeSender string    `json:"envelope_sender"`
+	Subject        string    `json:"subject"`
+	Text           string    `json:"text"`
+	HTML           string    `json:"html" gorm:"column:html"`
+	ModifiedDate   time.Time `json:"modified_date"`
+	Version        int       `json:"version"`
+	Tags           TagList   `json:"tags"`
+	Difficulty     string    `json:"difficulty"`
+	Language       string    `json:"language"`
+	Category       string    `json:"category"`
+	// FallbackLanguage is the language whose content is sent to recipients
+	// whose language doesn't match the template or any of its variants
+	FallbackLanguage string            `json:"fallback_language"`
+	Variants         []TemplateVariant `json:"variants" sql:"-"`
+	Attachments      []Attachment      `json:"attachments"`
+	CreatedBy        string            `json:"created_by" sql:"-"`
 }
 
 // ErrTemplateNameNotSpecified is thrown when a template name is not specified
@@ -43,12 +52,18 @@ func (t *Template) Validate() error {
 			return err
 		}
 	}
+	if err := validateLibraryFields(t.Difficulty, t.Language); err != nil {
+		return err
+	}
 	if err := ValidateTemplate(t.HTML); err != nil {
 		return err
 	}
 	if err := ValidateTemplate(t.Text); err != nil {
 		return err
 	}
+	if err := t.validateVariants(); err != nil {
+		return err
+	}
 	for _, a := range t.Attachments {
 		if err := a.Validate(); err != nil {
 			return err
@@ -58,13 +73,15 @@ func (t *Template) Validate() error {
 	return nil
 }
 
-// GetTemplates returns the templates owned by the given user.
-func GetTemplates(uid int64) ([]Template, error) {
+// GetTemplates returns the templates owned by the given user which match
+// the filter.
+func GetTemplates(uid int64, f LibraryFilter) ([]Template, error) {
 	ts := []Template{}
 	query := db.Model(&Template{})
 	if uid != 0 {
 		query = query.Where("templates.user_id = ?", uid)
 	}
+	query = f.apply(query, "templates")
 
 	query = query.Select("templates.*, users.username as created_by").Joins("left join users on templates.user_id = users.id")
 	err := query.Find(&ts).Error
@@ -82,6 +99,10 @@ func GetTemplates(uid int64) ([]Template, error) {
 			log.Error(err)
 			return ts, err
 		}
+		err = ts[i].loadVariants()
+		if err != nil {
+			return ts, err
+		}
 	}
 	return ts, err
 }
@@ -109,6 +130,9 @@ func GetTemplate(id int64, uid int64) (Template, error) {
 	if err == nil && len(t.Attachments) == 0 {
 		t.Attachments = make([]Attachment, 0)
 	}
+	if err == nil {
+		err = t.loadVariants()
+	}
 	return t, err
 }
 
@@ -135,16 +159,35 @@ func GetTemplateByName(n string, uid int64) (Template, error) {
 	if err == nil && len(t.Attachments) == 0 {
 		t.Attachments = make([]Attachment, 0)
 	}
+	if err == nil {
+		err = t.loadVariants()
+	}
 	return t, err
 }
 
 // PostTemplate creates a new template in the database.
 func PostTemplate(t *Template) error {
-	// Insert into the DB
 	if err := t.Validate(); err != nil {
 		return err
 	}
-	err := db.Save(t).Error
+	tx := db.Begin()
+	err := insertTemplate(tx, t)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// insertTemplate saves a new template along with its attachments, variants
+// and first version
+func insertTemplate(tx *gorm.DB, t *Template) error {
+	t.Version = 1
+	err := tx.Save(t).Error
 	if err != nil {
 		log.Error(err)
 		return err
@@ -153,13 +196,17 @@ func PostTemplate(t *Template) error {
 	// Save every attachment
 	for i := range t.Attachments {
 		t.Attachments[i].TemplateId = t.Id
-		err := db.Save(&t.Attachments[i]).Error
+		err := tx.Save(&t.Attachments[i]).Error
 		if err != nil {
 			log.Error(err)
 			return err
 		}
 	}
-	return nil
+	err = t.saveVariants(tx)
+	if err != nil {
+		return err
+	}
+	return saveTemplateVersion(tx, t)
 }
 
 // PutTemplate edits an existing template in the database.
@@ -178,31 +225,55 @@ func PutTemplate(t *Template) error {
 	if err := t.Validate(); err != nil {
 		return err
 	}
+	tx := db.Begin()
+	err = updateTemplate(tx, t, &existing)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// updateTemplate replaces the contents of an existing template and saves
+// them as a new version
+func updateTemplate(tx *gorm.DB, t *Template, existing *Template) error {
+	// Keep the existing contents as a version before replacing them
+	err := ensureTemplateVersion(tx, existing)
+	if err != nil {
+		return err
+	}
+	t.Version = existing.Version + 1
 	// Delete all attachments, and replace with new ones
-	err = db.Where("template_id=?", t.Id).Delete(&Attachment{}).Error
+	err = tx.Where("template_id=?", t.Id).Delete(&Attachment{}).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		log.Error(err)
 		return err
 	}
-	if err == gorm.ErrRecordNotFound {
-		err = nil
-	}
 	for i := range t.Attachments {
 		t.Attachments[i].TemplateId = t.Id
-		err = db.Save(&t.Attachments[i]).Error
+		err = tx.Save(&t.Attachments[i]).Error
 		if err != nil {
 			log.Error(err)
 			return err
 		}
 	}
 
+	err = t.saveVariants(tx)
+	if err != nil {
+		return err
+	}
+
 	// Save final template
-	err = db.Where("id=?", t.Id).Save(t).Error
+	err = tx.Where("id=?", t.Id).Save(t).Error
 	if err != nil {
 		log.Error(err)
 		return err
 	}
-	return nil
+	return saveTemplateVersion(tx, t)
 }
 
 // DeleteTemplate deletes an existing template in the database.
@@ -225,6 +296,31 @@ func DeleteTemplate(id int64, uid int64) error {
 		return err
 	}
 
+	// Delete variants
+	err = db.Where("template_id=?", id).Delete(&TemplateVariant{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	// Delete versions, keeping any that campaigns are pinned to so that
+	// they can still be sent and displayed
+	var pinned []int
+	err = db.Table("campaigns").Where("template_id=? AND template_version > 0", id).Pluck("template_version", &pinned).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	query := db.Where("template_id=?", id)
+	if len(pinned) > 0 {
+		query = query.Where("version NOT IN (?)", pinned)
+	}
+	err = query.Delete(&TemplateVersion{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
 	// Finally, delete the template itself
 	err = db.Delete(Template{Id: id}).Error
 	if err != nil {
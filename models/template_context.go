@@ -1,7 +1,6 @@
 package models
 
 import (
-	"bytes"
 	"encoding/base64"
 	"fmt"
 	"net/mail"
@@ -11,7 +10,6 @@ import (
 	"time"
 
 	"github.com/7nikhilkamboj/TrustStrike-Simulation/simulation"
-	"github.com/skip2/go-qrcode"
 )
 
 // TemplateContext is an interface that allows both campaigns and email
@@ -20,6 +18,7 @@ type TemplateContext interface {
 	getFromAddress() string
 	getBaseURL() string
 	getQRSize() string
+	getQROptions() QROptions
 	getTrackingURL() string
 }
 
@@ -35,6 +34,9 @@ type PhishingTemplateContext struct {
 	TrackingURL string
 	RId         string
 	BaseURL     string
+	// SendDate is when the email is sent, for use with the date helper
+	// functions. It defaults to the time the context is created.
+	SendDate time.Time
 	BaseRecipient
 }
 
@@ -144,7 +146,7 @@ func NewPhishingTemplateContext(ctx TemplateContext, r BaseRecipient, rid string
 	qr := ""
 	qrSize := ctx.getQRSize()
 	if qrSize != "" {
-		qrBase64, qrName, err = generateQRCode(phishUrlString, qrSize)
+		qrBase64, qrName, err = generateQRCode(phishUrlString, qrSize, ctx.getQROptions())
 		if err != nil {
 			return PhishingTemplateContext{}, err
 		}
@@ -162,6 +164,7 @@ func NewPhishingTemplateContext(ctx TemplateContext, r BaseRecipient, rid string
 		QRBase64:      qrBase64,
 		QRName:        qrName,
 		QR:            qr,
+		SendDate:      time.Now().UTC(),
 	}, nil
 }
 
@@ -216,15 +219,20 @@ func NewPhishingTemplateContextSms(ctx TemplateContext, r BaseRecipient, rid str
 		URL:           phishUrlString,
 		RId:           rid,
 		From:          ctx.getFromAddress(),
+		SendDate:      time.Now().UTC(),
 	}, nil
 }
 
-func generateQRCode(text string, sizeStr string) (string, string, error) {
+func generateQRCode(text string, sizeStr string, opts QROptions) (string, string, error) {
 	size, err := strconv.Atoi(sizeStr)
 	if err != nil {
 		return "", "", err
 	}
-	qrPng, err := qrcode.Encode(text, qrcode.Medium, size)
+	symbol, err := newQRSymbol(text, opts)
+	if err != nil {
+		return "", "", err
+	}
+	qrPng, err := symbol.PNG(size)
 	if err != nil {
 		return "", "", err
 	}
@@ -234,15 +242,15 @@ func generateQRCode(text string, sizeStr string) (string, string, error) {
 }
 
 // ExecuteTemplate creates a templated string based on the provided
-// template body and data.
+// template body and data. The helper functions in templateFuncs are
+// available to the template, and rendering is subject to the limits in
+// template_sandbox.go.
 func ExecuteTemplate(text string, data interface{}) (string, error) {
-	buff := bytes.Buffer{}
-	tmpl, err := template.New("template").Parse(text)
+	tmpl, err := template.New("template").Funcs(templateFuncs).Parse(text)
 	if err != nil {
-		return buff.String(), err
+		return "", err
 	}
-	err = tmpl.Execute(&buff, data)
-	return buff.String(), err
+	return executeSandboxed(tmpl, data)
 }
 
 // ValidationContext is used for validating templates and pages
@@ -263,6 +271,10 @@ func (vc ValidationContext) getQRSize() string {
 	return "150" // Standard size for validation
 }
 
+func (vc ValidationContext) getQROptions() QROptions {
+	return QROptions{}
+}
+
 func (vc ValidationContext) getTrackingURL() string {
 	return vc.BaseURL
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	check "gopkg.in/check.v1"
 )
 
@@ -21,6 +23,10 @@ func (m mockTemplateContext) getQRSize() string {
 	return ""
 }
 
+func (m mockTemplateContext) getQROptions() QROptions {
+	return QROptions{}
+}
+
 func (m mockTemplateContext) getTrackingURL() string {
 	return m.URL
 }
@@ -65,6 +71,63 @@ func (s *ModelsSuite) TestNewTemplateContext(c *check.C) {
 	expected.QRName = got.QRName
 	expected.QRBase64 = got.QRBase64
 	expected.QR = got.QR
+	expected.SendDate = got.SendDate
 
 	c.Assert(got, check.DeepEquals, expected)
 }
+
+func (s *ModelsSuite) TestTemplateAttr(c *check.C) {
+	ptx := PhishingTemplateContext{
+		BaseRecipient: BaseRecipient{
+			FirstName:  "Foo",
+			Attributes: RecipientAttributes{"department": "Finance"},
+		},
+	}
+	got, err := ExecuteTemplate(`{{.Attr "Department"}}/{{.Attr "manager"}}`, ptx)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(got, check.Equals, "Finance/")
+}
+
+func (s *ModelsSuite) TestTemplateFuncs(c *check.C) {
+	ptx := PhishingTemplateContext{
+		BaseRecipient: BaseRecipient{FirstName: "foo", Position: "Manager"},
+		SendDate:      time.Date(2026, 3, 30, 9, 0, 0, 0, time.UTC),
+	}
+	tests := map[string]string{
+		`{{upper .FirstName}} {{lower "BAR"}} {{title .FirstName}}`:                  "FOO bar Foo",
+		`{{default "there" .LastName}} {{default "there" .FirstName}}`:               "there foo",
+		`{{coalesce .LastName (.Attr "nickname") "colleague"}}`:                      "colleague",
+		`{{ternary "Dear manager" "Hello" (eq .Position "Manager")}}`:                "Dear manager",
+		`{{if empty .LastName}}none{{end}}`:                                          "none",
+		`{{date "January 2, 2006" .SendDate}}`:                                       "March 30, 2026",
+		`{{date "Jan 2" (addDays 3 .SendDate)}}`:                                     "Apr 2",
+		`{{date "2006-01-02" (addDays -30 .SendDate)}}`:                              "2026-02-28",
+		`{{if contains "anag" .Position}}yes{{end}}`:                                 "yes",
+		`{{if and (hasPrefix "Man" .Position) (hasSuffix "er" .Position)}}ok{{end}}`: "ok",
+	}
+	for text, expected := range tests {
+		got, err := ExecuteTemplate(text, ptx)
+		c.Assert(err, check.Equals, nil, check.Commentf("template %s", text))
+		c.Assert(got, check.Equals, expected, check.Commentf("template %s", text))
+	}
+}
+
+func (s *ModelsSuite) TestValidateTemplateFuncs(c *check.C) {
+	valid := []string{
+		`{{.Attr "department"}}`,
+		`{{date "Jan 2" (addDays 7 .SendDate)}}`,
+		`{{default "there" .FirstName | upper}}`,
+	}
+	for _, text := range valid {
+		c.Assert(ValidateTemplate(text), check.Equals, nil, check.Commentf("template %s", text))
+	}
+	invalid := []string{
+		`{{.Attr}}`,
+		`{{exec "ls"}}`,
+		`{{date "Jan 2" .FirstName}}`,
+		`{{addDays "three" .SendDate}}`,
+	}
+	for _, text := range invalid {
+		c.Assert(ValidateTemplate(text), check.NotNil, check.Commentf("template %s", text))
+	}
+}
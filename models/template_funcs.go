@@ -0,0 +1,82 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the helper functions available to email templates and
+// landing pages. They only transform the values passed to them, so they
+// can't be used to access anything outside of the template context.
+var templateFuncs = template.FuncMap{
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"title":     strings.Title,
+	"trim":      strings.TrimSpace,
+	"contains":  func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix": func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix": func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"default":   defaultValue,
+	"coalesce":  coalesce,
+	"empty":     empty,
+	"ternary":   ternary,
+	"date":      formatDate,
+	"addDays":   addDays,
+}
+
+// empty returns whether the value is the zero value for its type, such as
+// an empty string
+func empty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// defaultValue returns the value, or the default if the value is empty, e.g.
+// {{default "there" .FirstName}}
+func defaultValue(def interface{}, value interface{}) interface{} {
+	if empty(value) {
+		return def
+	}
+	return value
+}
+
+// coalesce returns the first value which isn't empty, e.g.
+// {{coalesce .FirstName (.Attr "nickname") "there"}}
+func coalesce(values ...interface{}) interface{} {
+	for _, value := range values {
+		if !empty(value) {
+			return value
+		}
+	}
+	return nil
+}
+
+// ternary returns the first value if the condition is true and the second
+// otherwise, e.g. {{ternary "Dear manager" "Hello" (eq .Position "Manager")}}
+func ternary(a, b interface{}, condition bool) interface{} {
+	if condition {
+		return a
+	}
+	return b
+}
+
+// formatDate formats the time using a Go layout, e.g.
+// {{date "January 2, 2006" .SendDate}}
+func formatDate(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+// addDays returns the time moved by the given number of days, which may be
+// negative, e.g. {{date "Jan 2" (addDays 3 .SendDate)}}
+func addDays(days int, t time.Time) time.Time {
+	return t.AddDate(0, 0, days)
+}
@@ -0,0 +1,361 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// These limits are applied every time a user supplied template, such as an
+// email template or landing page, is rendered.
+var (
+	// TemplateExecutionTimeout is the longest a template may take to render
+	TemplateExecutionTimeout = 5 * time.Second
+	// MaxTemplateOutputSize is the largest output, in bytes, that a
+	// template may render
+	MaxTemplateOutputSize = 10 << 20
+	// MaxTemplateDepth is the deepest that templates defined with
+	// {{define}} or {{block}} may be nested using {{template}}
+	MaxTemplateDepth = 10
+	// MaxTemplateIterations is the most {{range}} iterations and
+	// {{template}} calls a template may make, counting those made by the
+	// templates it calls
+	MaxTemplateIterations int64 = 10000
+)
+
+// ErrTemplateTimeout is returned when a template takes longer than
+// TemplateExecutionTimeout to render
+var ErrTemplateTimeout = errors.New("Template took too long to render")
+
+// ErrTemplateOutputTooLarge is returned when a template renders more than
+// MaxTemplateOutputSize bytes
+var ErrTemplateOutputTooLarge = errors.New("Rendered template is too large")
+
+// ErrTemplateTooDeep is returned when a template nests {{template}} calls
+// deeper than MaxTemplateDepth, including when a template calls itself
+var ErrTemplateTooDeep = errors.New("Template calls are nested too deeply")
+
+// ErrTemplateTooManyIterations is returned when a template's loops could run
+// more than MaxTemplateIterations times
+var ErrTemplateTooManyIterations = errors.New("Template loops run too many times")
+
+// ErrTemplateUnboundedRange is returned when a template ranges over something
+// other than the template data or a number, since the number of iterations
+// can't be known before rendering
+var ErrTemplateUnboundedRange = errors.New("Templates may only range over the template data or a number")
+
+// IsTemplateLimitError returns whether the error was caused by a template
+// exceeding one of the execution limits. Unlike other rendering errors,
+// these mean that the template can't be rendered at all.
+func IsTemplateLimitError(err error) bool {
+	return errors.Is(err, ErrTemplateTimeout) ||
+		errors.Is(err, ErrTemplateOutputTooLarge) ||
+		errors.Is(err, ErrTemplateTooDeep) ||
+		errors.Is(err, ErrTemplateTooManyIterations) ||
+		errors.Is(err, ErrTemplateUnboundedRange)
+}
+
+// limitedBuffer is a buffer which fails writes once the output limit is
+// reached or rendering has been abandoned
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	abandoned <-chan struct{}
+}
+
+// Write implements the io.Writer interface
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	select {
+	case <-b.abandoned:
+		return 0, ErrTemplateTimeout
+	default:
+	}
+	if b.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("%w (the limit is %d bytes)", ErrTemplateOutputTooLarge, b.limit)
+	}
+	return b.Buffer.Write(p)
+}
+
+// checkTemplateDepth returns an error if the template's {{template}} calls
+// are nested deeper than MaxTemplateDepth. The depth of each template is
+// only worked out once, however many times it's called.
+func checkTemplateDepth(tmpl *template.Template) error {
+	calls := make(map[string][]string)
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			calls[t.Name()] = templateCalls(t.Tree.Root, nil)
+		}
+	}
+	tooDeep := fmt.Errorf("%w (the limit is %d)", ErrTemplateTooDeep, MaxTemplateDepth)
+	depths := make(map[string]int)
+	// calling holds the templates on the current chain of calls, so that
+	// recursive templates are caught without following them
+	calling := make(map[string]bool)
+	var depth func(name string) (int, error)
+	depth = func(name string) (int, error) {
+		if d, ok := depths[name]; ok {
+			return d, nil
+		}
+		if calling[name] {
+			return 0, tooDeep
+		}
+		calling[name] = true
+		defer delete(calling, name)
+		max := 0
+		for _, called := range calls[name] {
+			d, err := depth(called)
+			if err != nil {
+				return 0, err
+			}
+			if d+1 > max {
+				max = d + 1
+			}
+			if max > MaxTemplateDepth {
+				return 0, tooDeep
+			}
+		}
+		depths[name] = max
+		return max, nil
+	}
+	_, err := depth(tmpl.Name())
+	return err
+}
+
+// templateCalls returns the names of the templates called by the node
+func templateCalls(node parse.Node, names []string) []string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return names
+		}
+		for _, child := range n.Nodes {
+			names = templateCalls(child, names)
+		}
+	case *parse.IfNode:
+		names = templateCalls(n.List, names)
+		names = templateCalls(n.ElseList, names)
+	case *parse.RangeNode:
+		names = templateCalls(n.List, names)
+		names = templateCalls(n.ElseList, names)
+	case *parse.WithNode:
+		names = templateCalls(n.List, names)
+		names = templateCalls(n.ElseList, names)
+	case *parse.TemplateNode:
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+// iterationCounter counts the range iterations and template calls made by a
+// template. Since text/template can't be cancelled, this is checked before
+// rendering so that a template which never writes can't run indefinitely.
+type iterationCounter struct {
+	trees map[string]*parse.Tree
+	// counts holds the iterations made by each template which has been
+	// counted, keyed by its name and whether dot is the template data
+	counts map[templateDot]int64
+}
+
+// templateDot is a template called with dot either set to the template data
+// or to another value
+type templateDot struct {
+	name      string
+	dotIsData bool
+}
+
+// checkTemplateIterations returns an error if the template could make more
+// than MaxTemplateIterations range iterations and template calls. The
+// template's calls must already have been checked with checkTemplateDepth.
+func checkTemplateIterations(tmpl *template.Template) error {
+	c := iterationCounter{
+		trees:  make(map[string]*parse.Tree),
+		counts: make(map[templateDot]int64),
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			c.trees[t.Name()] = t.Tree
+		}
+	}
+	if tmpl.Tree == nil {
+		return nil
+	}
+	_, err := c.count(tmpl.Tree.Root, true)
+	return err
+}
+
+// count returns the iterations made by the node. dotIsData is whether dot
+// refers to the template data, rather than a value set by {{range}} or
+// {{with}}, which may be a number.
+func (c *iterationCounter) count(node parse.Node, dotIsData bool) (int64, error) {
+	var total int64
+	add := func(n int64) error {
+		total += n
+		if total > MaxTemplateIterations {
+			return fmt.Errorf("%w (the limit is %d)", ErrTemplateTooManyIterations, MaxTemplateIterations)
+		}
+		return nil
+	}
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return 0, nil
+		}
+		for _, child := range n.Nodes {
+			count, err := c.count(child, dotIsData)
+			if err != nil {
+				return 0, err
+			}
+			if err := add(count); err != nil {
+				return 0, err
+			}
+		}
+	case *parse.IfNode:
+		return c.countBranches(&n.BranchNode, dotIsData, dotIsData)
+	case *parse.WithNode:
+		return c.countBranches(&n.BranchNode, isDataPipe(n.Pipe, dotIsData), dotIsData)
+	case *parse.RangeNode:
+		bound, isData, err := rangeBound(n.Pipe, dotIsData)
+		if err != nil {
+			return 0, err
+		}
+		body, err := c.count(n.List, isData)
+		if err != nil {
+			return 0, err
+		}
+		// Each iteration counts, even when the body doesn't
+		if bound > 0 && body+1 > MaxTemplateIterations/bound {
+			return 0, fmt.Errorf("%w (the limit is %d)", ErrTemplateTooManyIterations, MaxTemplateIterations)
+		}
+		if err := add(bound * (body + 1)); err != nil {
+			return 0, err
+		}
+		elseCount, err := c.count(n.ElseList, dotIsData)
+		if err != nil {
+			return 0, err
+		}
+		if err := add(elseCount); err != nil {
+			return 0, err
+		}
+	case *parse.TemplateNode:
+		called, err := c.countTemplate(n.Name, n.Pipe == nil || isDataPipe(n.Pipe, dotIsData))
+		if err != nil {
+			return 0, err
+		}
+		if err := add(called + 1); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// countTemplate returns the iterations made by the named template, counting
+// each template only once
+func (c *iterationCounter) countTemplate(name string, dotIsData bool) (int64, error) {
+	key := templateDot{name: name, dotIsData: dotIsData}
+	if count, ok := c.counts[key]; ok {
+		return count, nil
+	}
+	t, ok := c.trees[name]
+	if !ok || t.Root == nil {
+		return 0, nil
+	}
+	count, err := c.count(t.Root, dotIsData)
+	if err != nil {
+		return 0, err
+	}
+	c.counts[key] = count
+	return count, nil
+}
+
+// countBranches returns the iterations made by the larger branch of an
+// {{if}} or {{with}}
+func (c *iterationCounter) countBranches(n *parse.BranchNode, listDotIsData, elseDotIsData bool) (int64, error) {
+	list, err := c.count(n.List, listDotIsData)
+	if err != nil {
+		return 0, err
+	}
+	elseCount, err := c.count(n.ElseList, elseDotIsData)
+	if err != nil {
+		return 0, err
+	}
+	if elseCount > list {
+		return elseCount, nil
+	}
+	return list, nil
+}
+
+// rangeBound returns the number of iterations of a {{range}} over the
+// pipeline and whether the values it ranges over are template data. Ranges
+// over the template data are bounded by its size, so they're counted once.
+func rangeBound(pipe *parse.PipeNode, dotIsData bool) (int64, bool, error) {
+	if pipe != nil && len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+		if num, ok := pipe.Cmds[0].Args[0].(*parse.NumberNode); ok && num.IsInt {
+			if num.Int64 < 0 {
+				return 0, false, nil
+			}
+			if num.Int64 > MaxTemplateIterations {
+				return 0, false, fmt.Errorf("%w (the limit is %d)", ErrTemplateTooManyIterations, MaxTemplateIterations)
+			}
+			return num.Int64, false, nil
+		}
+	}
+	if isDataPipe(pipe, dotIsData) {
+		return 1, true, nil
+	}
+	return 0, false, ErrTemplateUnboundedRange
+}
+
+// isDataPipe returns whether the pipeline only refers to the template data,
+// such as {{.Attributes}} or {{$.FirstName}}
+func isDataPipe(pipe *parse.PipeNode, dotIsData bool) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	var isData func(node parse.Node) bool
+	isData = func(node parse.Node) bool {
+		switch n := node.(type) {
+		case *parse.DotNode, *parse.FieldNode:
+			return dotIsData
+		case *parse.VariableNode:
+			return n.Ident[0] == "$"
+		case *parse.ChainNode:
+			return isData(n.Node)
+		}
+		return false
+	}
+	return isData(pipe.Cmds[0].Args[0])
+}
+
+// executeSandboxed renders the template within the execution limits. If the
+// timeout is reached, the render is abandoned and stops at its next write.
+func executeSandboxed(tmpl *template.Template, data interface{}) (string, error) {
+	if err := checkTemplateDepth(tmpl); err != nil {
+		return "", err
+	}
+	if err := checkTemplateIterations(tmpl); err != nil {
+		return "", err
+	}
+	abandoned := make(chan struct{})
+	buff := &limitedBuffer{limit: MaxTemplateOutputSize, abandoned: abandoned}
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("error rendering template: %v", r)
+			}
+		}()
+		done <- tmpl.Execute(buff, data)
+	}()
+	timer := time.NewTimer(TemplateExecutionTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return buff.String(), err
+	case <-timer.C:
+		close(abandoned)
+		return "", fmt.Errorf("%w (the limit is %s)", ErrTemplateTimeout, TemplateExecutionTimeout)
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestTemplateOutputLimit(ch *check.C) {
+	defer func(limit int) { MaxTemplateOutputSize = limit }(MaxTemplateOutputSize)
+	MaxTemplateOutputSize = 10
+	got, err := ExecuteTemplate("{{.FirstName}}", BaseRecipient{FirstName: "Foo"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got, check.Equals, "Foo")
+	_, err = ExecuteTemplate("{{range 100}}{{$.FirstName}}{{end}}", BaseRecipient{FirstName: "Foo"})
+	ch.Assert(errors.Is(err, ErrTemplateOutputTooLarge), check.Equals, true)
+	ch.Assert(IsTemplateLimitError(err), check.Equals, true)
+}
+
+func (s *ModelsSuite) TestTemplateTimeout(ch *check.C) {
+	defer func(timeout time.Duration) { TemplateExecutionTimeout = timeout }(TemplateExecutionTimeout)
+	defer func(limit int64) { MaxTemplateIterations = limit }(MaxTemplateIterations)
+	TemplateExecutionTimeout = 10 * time.Millisecond
+	MaxTemplateIterations = 1000000000
+	_, err := ExecuteTemplate(`{{range 1000000000}}{{""}}{{end}}`, nil)
+	ch.Assert(errors.Is(err, ErrTemplateTimeout), check.Equals, true)
+	ch.Assert(strings.Contains(err.Error(), "10ms"), check.Equals, true)
+}
+
+func (s *ModelsSuite) TestTemplateIterationLimit(ch *check.C) {
+	got, err := ExecuteTemplate(`{{range 3}}{{range 2}}x{{end}}{{end}}`, nil)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got, check.Equals, "xxxxxx")
+	got, err = ExecuteTemplate(`{{range $i, $v := .}}{{$v}}{{end}}`, []string{"a", "b"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got, check.Equals, "ab")
+
+	// Loops which never write are rejected before rendering, so no render
+	// is left running
+	goroutines := runtime.NumGoroutine()
+	_, err = ExecuteTemplate(`{{range 100000}}{{range 100000}}{{end}}{{end}}`, nil)
+	ch.Assert(errors.Is(err, ErrTemplateTooManyIterations), check.Equals, true)
+	ch.Assert(IsTemplateLimitError(err), check.Equals, true)
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	ch.Assert(runtime.NumGoroutine() <= goroutines, check.Equals, true)
+
+	tests := map[string]error{
+		`{{range 1000000000}}{{end}}`:              ErrTemplateTooManyIterations,
+		`{{range 200}}{{range 200}}{{end}}{{end}}`: ErrTemplateTooManyIterations,
+		`{{define "a"}}{{range 5000}}{{end}}{{end}}{{template "a"}}{{template "a"}}{{template "a"}}`: ErrTemplateTooManyIterations,
+		`{{$n := 1000000000}}{{range $n}}{{end}}`:                                                    ErrTemplateUnboundedRange,
+		`{{with 1000000000}}{{range .}}{{end}}{{end}}`:                                               ErrTemplateUnboundedRange,
+		`{{range 10}}{{range .}}{{end}}{{end}}`:                                                      ErrTemplateUnboundedRange,
+		`{{range (len "abc")}}{{end}}`:                                                               ErrTemplateUnboundedRange,
+	}
+	for text, expected := range tests {
+		_, err = ExecuteTemplate(text, nil)
+		ch.Assert(errors.Is(err, expected), check.Equals, true, check.Commentf("%s: %v", text, err))
+	}
+}
+
+func (s *ModelsSuite) TestTemplateDepthLimit(ch *check.C) {
+	nested := `{{define "a"}}A{{template "b"}}{{end}}{{define "b"}}B{{end}}{{template "a"}}`
+	got, err := ExecuteTemplate(nested, nil)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got, check.Equals, "AB")
+
+	recursive := `{{define "loop"}}{{if true}}{{template "loop"}}{{end}}{{end}}{{template "loop"}}`
+	_, err = ExecuteTemplate(recursive, nil)
+	ch.Assert(errors.Is(err, ErrTemplateTooDeep), check.Equals, true)
+
+	t := Template{Name: "Recursive Template", UserId: 1, HTML: recursive}
+	ch.Assert(errors.Is(PostTemplate(&t), ErrTemplateTooDeep), check.Equals, true)
+
+	// Templates which call the next one many times are checked without
+	// following every call
+	fanOut := func(levels int, calls int) string {
+		text := ""
+		for i := 0; i < levels; i++ {
+			text += fmt.Sprintf(`{{define "t%d"}}`, i)
+			for j := 0; j < calls; j++ {
+				text += fmt.Sprintf(`{{if true}}{{template "t%d"}}{{else}}{{template "t%d"}}{{end}}`, i+1, i+1)
+			}
+			text += `{{end}}`
+		}
+		return text + fmt.Sprintf(`{{define "t%d"}}x{{end}}{{template "t0"}}`, levels)
+	}
+	start := time.Now()
+	_, err = ExecuteTemplate(fanOut(9, 20), nil)
+	ch.Assert(errors.Is(err, ErrTemplateTooManyIterations), check.Equals, true, check.Commentf("%v", err))
+	_, err = ExecuteTemplate(fanOut(12, 20), nil)
+	ch.Assert(errors.Is(err, ErrTemplateTooDeep), check.Equals, true, check.Commentf("%v", err))
+	ch.Assert(time.Since(start) < time.Second, check.Equals, true)
+}
+
+func (s *ModelsSuite) TestCampaignTemplateLimit(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	defer func(limit int) { MaxTemplateOutputSize = limit }(MaxTemplateOutputSize)
+	MaxTemplateOutputSize = 10
+	report, err := PreflightCampaign(&c, c.UserId, 1, "")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(report.Issues), check.Equals, 1)
+	ch.Assert(report.Issues[0].Field, check.Equals, "email")
+	ch.Assert(strings.HasPrefix(report.Issues[0].Message, "Unable to render the email subject"), check.Equals, true)
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/jinzhu/gorm"
+)
+
+// TemplateVariant is a translation of a template's subject, text and HTML
+// into another language. The variant matching a recipient's language is
+// sent in place of the template's own content.
+type TemplateVariant struct {
+	Id         int64  `json:"-"`
+	TemplateId int64  `json:"-"`
+	Language   string `json:"language"`
+	Subject    string `json:"subject"`
+	Text       string `json:"text"`
+	HTML       string `json:"html" gorm:"column:html"`
+}
+
+// ErrVariantLanguageNotSpecified is thrown when a template variant doesn't
+// have a language
+var ErrVariantLanguageNotSpecified = errors.New("Template variant language not specified")
+
+// ErrDuplicateVariantLanguage is thrown when a template has more than one
+// variant for the same language
+var ErrDuplicateVariantLanguage = errors.New("Template variants must each have a different language")
+
+// ErrInvalidFallbackLanguage is thrown when a template's fallback language
+// is neither the template's own language nor the language of a variant
+var ErrInvalidFallbackLanguage = errors.New("Fallback language must be the template language or the language of a variant")
+
+// Validate checks that the variant has a valid language and content
+func (v *TemplateVariant) Validate() error {
+	switch {
+	case v.Language == "":
+		return ErrVariantLanguageNotSpecified
+	case !languageRegex.MatchString(v.Language):
+		return ErrInvalidLanguage
+	case v.Text == "" && v.HTML == "":
+		return ErrTemplateMissingParameter
+	}
+	if err := ValidateTemplate(v.Subject); err != nil {
+		return err
+	}
+	if err := ValidateTemplate(v.HTML); err != nil {
+		return err
+	}
+	return ValidateTemplate(v.Text)
+}
+
+// validateVariants validates the template's variants and fallback language
+func (t *Template) validateVariants() error {
+	languages := map[string]bool{strings.ToLower(t.Language): true}
+	for i := range t.Variants {
+		if err := t.Variants[i].Validate(); err != nil {
+			return err
+		}
+		language := strings.ToLower(t.Variants[i].Language)
+		if languages[language] {
+			return ErrDuplicateVariantLanguage
+		}
+		languages[language] = true
+	}
+	if t.FallbackLanguage != "" && !languages[strings.ToLower(t.FallbackLanguage)] {
+		return ErrInvalidFallbackLanguage
+	}
+	return nil
+}
+
+// loadVariants loads the template's variants from the database
+func (t *Template) loadVariants() error {
+	t.Variants = []TemplateVariant{}
+	err := db.Where("template_id=?", t.Id).Order("id asc").Find(&t.Variants).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// saveVariants replaces the template's stored variants with its current
+// variants
+func (t *Template) saveVariants(tx *gorm.DB) error {
+	err := tx.Where("template_id=?", t.Id).Delete(&TemplateVariant{}).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(err)
+		return err
+	}
+	for i := range t.Variants {
+		t.Variants[i].Id = 0
+		t.Variants[i].TemplateId = t.Id
+		err = tx.Save(&t.Variants[i]).Error
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// languageMatch scores how well a content language matches a recipient's
+// language. Exact matches score 2, and languages sharing a primary subtag
+// (e.g. "pt" and "pt-BR") score 1.
+func languageMatch(content, recipient string) int {
+	if content == "" || recipient == "" {
+		return 0
+	}
+	content = strings.ToLower(content)
+	recipient = strings.ToLower(recipient)
+	if content == recipient {
+		return 2
+	}
+	if strings.SplitN(content, "-", 2)[0] == strings.SplitN(recipient, "-", 2)[0] {
+		return 1
+	}
+	return 0
+}
+
+// Localize returns the template with the content best matching the given
+// language. Exact matches are preferred over matches of the language alone,
+// and the template's own content is preferred over a variant when both match
+// equally. If nothing matches, the fallback language's content is used.
+func (t Template) Localize(language string) Template {
+	best, score := -1, languageMatch(t.Language, language)
+	for i, v := range t.Variants {
+		if s := languageMatch(v.Language, language); s > score {
+			best, score = i, s
+		}
+	}
+	if score == 0 {
+		best = -1
+		for i, v := range t.Variants {
+			if t.FallbackLanguage != "" && strings.EqualFold(v.Language, t.FallbackLanguage) {
+				best = i
+			}
+		}
+	}
+	if best == -1 {
+		return t
+	}
+	v := t.Variants[best]
+	t.Language = v.Language
+	t.Subject = v.Subject
+	t.Text = v.Text
+	t.HTML = v.HTML
+	return t
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func localizedTemplate() Template {
+	return Template{
+		Name:     "Localized Template",
+		UserId:   1,
+		Language: "en",
+		Subject:  "Hello",
+		HTML:     "<p>Hello {{.FirstName}}</p>",
+		Variants: []TemplateVariant{
+			{Language: "de", Subject: "Hallo", HTML: "<p>Hallo {{.FirstName}}</p>"},
+			{Language: "pt-BR", Subject: "Ola", HTML: "<p>Ola {{.FirstName}}</p>"},
+			{Language: "pt-PT", Subject: "Ola PT", HTML: "<p>Ola {{.FirstName}}</p>"},
+		},
+	}
+}
+
+func (s *ModelsSuite) TestTemplateLocalize(ch *check.C) {
+	t := localizedTemplate()
+	tests := map[string]string{
+		"de":    "Hallo",
+		"DE-at": "Hallo",
+		"pt-PT": "Ola PT",
+		"pt":    "Ola",
+		"en-US": "Hello",
+		"fr":    "Hello",
+		"":      "Hello",
+	}
+	for language, subject := range tests {
+		ch.Assert(t.Localize(language).Subject, check.Equals, subject, check.Commentf("language %q", language))
+	}
+
+	// Unmatched languages use the fallback language's variant
+	t.FallbackLanguage = "de"
+	ch.Assert(t.Localize("fr").Subject, check.Equals, "Hallo")
+	ch.Assert(t.Localize("fr").Language, check.Equals, "de")
+	ch.Assert(t.Localize("en").Subject, check.Equals, "Hello")
+}
+
+func (s *ModelsSuite) TestTemplateVariantValidation(ch *check.C) {
+	t := localizedTemplate()
+	t.Variants[1].Language = "DE"
+	ch.Assert(t.Validate(), check.Equals, ErrDuplicateVariantLanguage)
+
+	t = localizedTemplate()
+	t.Variants[0].Language = ""
+	ch.Assert(t.Validate(), check.Equals, ErrVariantLanguageNotSpecified)
+
+	t = localizedTemplate()
+	t.Variants[0].HTML = ""
+	ch.Assert(t.Validate(), check.Equals, ErrTemplateMissingParameter)
+
+	t = localizedTemplate()
+	t.Variants[0].Subject = "{{.Unknown}}"
+	ch.Assert(t.Validate(), check.NotNil)
+
+	t = localizedTemplate()
+	t.FallbackLanguage = "fr"
+	ch.Assert(t.Validate(), check.Equals, ErrInvalidFallbackLanguage)
+}
+
+func (s *ModelsSuite) TestTemplateVariantsSaved(ch *check.C) {
+	t := localizedTemplate()
+	t.Name = "Saved Localized Template"
+	t.FallbackLanguage = "en"
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+
+	got, err := GetTemplate(t.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(got.Variants), check.Equals, 3)
+	ch.Assert(got.Variants[0].Language, check.Equals, "de")
+	ch.Assert(got.FallbackLanguage, check.Equals, "en")
+
+	got.Variants = got.Variants[:1]
+	got.Variants[0].Subject = "Guten Tag"
+	ch.Assert(PutTemplate(&got), check.Equals, nil)
+	got, err = GetTemplate(t.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(got.Variants), check.Equals, 1)
+	ch.Assert(got.Variants[0].Subject, check.Equals, "Guten Tag")
+
+	// Earlier versions keep their variants
+	tv, err := GetTemplateVersion(t.Id, 1, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(tv.Variants), check.Equals, 3)
+	tvs, err := GetTemplateVersions(t.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	fields := []string{}
+	for _, c := range tvs[0].Changes {
+		fields = append(fields, c.Field)
+	}
+	ch.Assert(fields, check.DeepEquals, []string{"subject (de)", "subject (pt-BR)", "html (pt-BR)", "subject (pt-PT)", "html (pt-PT)"})
+
+	ch.Assert(DeleteTemplate(t.Id, 1), check.Equals, nil)
+	var count int
+	ch.Assert(db.Model(&TemplateVariant{}).Where("template_id=?", t.Id).Count(&count).Error, check.Equals, nil)
+	ch.Assert(count, check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestCampaignLocalizedEmail(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.Template.Variants = []TemplateVariant{{Language: "de", Subject: "Hallo {{.FirstName}}", Text: "Hallo"}}
+	ch.Assert(PutTemplate(&c.Template), check.Equals, nil)
+	err := db.Model(&Target{}).Where("email=?", "test2@example.com").UpdateColumn("language", "de-DE").Error
+	ch.Assert(err, check.Equals, nil)
+
+	report, err := PreflightCampaign(&c, c.UserId, 0, "test2@example.com")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.Contains(report.Preview.MIME, "Subject: Hallo Second"), check.Equals, true)
+
+	report, err = PreflightCampaign(&c, c.UserId, 0, "test1@example.com")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.Contains(report.Preview.MIME, "- Subject"), check.Equals, true)
+}
+
+func (s *ModelsSuite) TestPutGroupKeepsLanguage(ch *check.C) {
+	g := Group{Name: "Localized Group", UserId: 1}
+	g.Targets = []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "first@example.com", Language: "de-DE"}},
+	}
+	ch.Assert(PostGroup(&g), check.Equals, nil)
+
+	// Clients which don't send the language don't remove it
+	g.Targets = []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "first@example.com", Position: "Manager"}},
+	}
+	ch.Assert(PutGroup(&g), check.Equals, nil)
+	ts, err := GetTargets(g.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ts), check.Equals, 1)
+	ch.Assert(ts[0].Position, check.Equals, "Manager")
+	ch.Assert(ts[0].Language, check.Equals, "de-DE")
+}
@@ -0,0 +1,366 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// ErrTemplateVersionNotFound is thrown when a template version doesn't exist
+var ErrTemplateVersionNotFound = errors.New("Template version not found")
+
+// TemplateVersion is an immutable snapshot of a template. A new version is
+// saved every time a template is created or edited, and campaigns are pinned
+// to the version of their template that was current when they launched.
+type TemplateVersion struct {
+	Id             int64        `json:"id"`
+	TemplateId     int64        `json:"template_id"`
+	Version        int          `json:"version"`
+	UserId         int64        `json:"-"`
+	Type           string       `json:"type"`
+	Name           string       `json:"name"`
+	EnvelopeSender string       `json:"envelope_sender"`
+	Subject        string       `json:"subject"`
+	Text           string       `json:"text"`
+	HTML           string       `json:"html" gorm:"column:html"`
+	Tags           TagList      `json:"tags"`
+	Difficulty     string       `json:"difficulty"`
+	Language       string       `json:"language"`
+	Category       string       `json:"category"`
+	AttachmentData string       `json:"-" gorm:"column:attachments"`
+	Attachments    []Attachment `json:"attachments" sql:"-"`
+	// FallbackLanguage and Variants hold the template's translations, with
+	// the variants stored as JSON
+	FallbackLanguage string            `json:"fallback_language"`
+	VariantData      string            `json:"-" gorm:"column:variants"`
+	Variants         []TemplateVariant `json:"variants" sql:"-"`
+	CreatedDate      time.Time         `json:"created_date"`
+	CreatedBy        string            `json:"created_by" sql:"-"`
+	Changes          []TemplateChange  `json:"changes" sql:"-"`
+}
+
+// TemplateChange is a unified diff of a single template field between a
+// version and the version before it
+type TemplateChange struct {
+	Field string `json:"field"`
+	Diff  string `json:"diff"`
+}
+
+// newTemplateVersion returns a snapshot of the template's current contents
+func newTemplateVersion(t *Template) (TemplateVersion, error) {
+	attachments := t.Attachments
+	if attachments == nil {
+		attachments = []Attachment{}
+	}
+	data, err := json.Marshal(attachments)
+	if err != nil {
+		return TemplateVersion{}, err
+	}
+	variants := t.Variants
+	if variants == nil {
+		variants = []TemplateVariant{}
+	}
+	variantData, err := json.Marshal(variants)
+	if err != nil {
+		return TemplateVersion{}, err
+	}
+	return TemplateVersion{
+		TemplateId:       t.Id,
+		Version:          t.Version,
+		UserId:           t.UserId,
+		Type:             t.Type,
+		Name:             t.Name,
+		EnvelopeSender:   t.EnvelopeSender,
+		Subject:          t.Subject,
+		Text:             t.Text,
+		HTML:             t.HTML,
+		Tags:             normalizeTags(t.Tags),
+		Difficulty:       t.Difficulty,
+		Language:         t.Language,
+		Category:         t.Category,
+		AttachmentData:   string(data),
+		Attachments:      attachments,
+		CreatedDate:      time.Now().UTC(),
+		FallbackLanguage: t.FallbackLanguage,
+		VariantData:      string(variantData),
+		Variants:         variants,
+	}, nil
+}
+
+// loadContents decodes the attachments and variants stored with the version
+func (tv *TemplateVersion) loadContents() error {
+	tv.Attachments = []Attachment{}
+	tv.Variants = []TemplateVariant{}
+	if tv.AttachmentData != "" {
+		err := json.Unmarshal([]byte(tv.AttachmentData), &tv.Attachments)
+		if err != nil {
+			return err
+		}
+	}
+	if tv.VariantData != "" {
+		return json.Unmarshal([]byte(tv.VariantData), &tv.Variants)
+	}
+	return nil
+}
+
+// Template returns the template as it was at this version
+func (tv *TemplateVersion) Template() Template {
+	t := Template{
+		Id:               tv.TemplateId,
+		UserId:           tv.UserId,
+		Type:             tv.Type,
+		Name:             tv.Name,
+		EnvelopeSender:   tv.EnvelopeSender,
+		Subject:          tv.Subject,
+		Text:             tv.Text,
+		HTML:             tv.HTML,
+		Tags:             tv.Tags,
+		Difficulty:       tv.Difficulty,
+		Language:         tv.Language,
+		Category:         tv.Category,
+		Version:          tv.Version,
+		ModifiedDate:     tv.CreatedDate,
+		Attachments:      make([]Attachment, len(tv.Attachments)),
+		FallbackLanguage: tv.FallbackLanguage,
+		Variants:         make([]TemplateVariant, len(tv.Variants)),
+	}
+	for i, v := range tv.Variants {
+		v.TemplateId = tv.TemplateId
+		t.Variants[i] = v
+	}
+	for i, a := range tv.Attachments {
+		a.TemplateId = tv.TemplateId
+		t.Attachments[i] = a
+	}
+	return t
+}
+
+// saveTemplateVersion stores a snapshot of the template at its current
+// version
+func saveTemplateVersion(tx *gorm.DB, t *Template) error {
+	tv, err := newTemplateVersion(t)
+	if err != nil {
+		return err
+	}
+	err = tx.Save(&tv).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// ensureTemplateVersion makes sure that a template created before versioning
+// was introduced has an initial version
+func ensureTemplateVersion(tx *gorm.DB, t *Template) error {
+	if t.Version != 0 {
+		return nil
+	}
+	t.Version = 1
+	err := tx.Model(&Template{}).Where("id=?", t.Id).UpdateColumn("version", t.Version).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return saveTemplateVersion(tx, t)
+}
+
+// getTemplateVersion returns the given version of a template
+func getTemplateVersion(tid int64, version int) (TemplateVersion, error) {
+	tv := TemplateVersion{}
+	err := db.Where("template_id=? AND version=?", tid, version).First(&tv).Error
+	if err == gorm.ErrRecordNotFound {
+		return tv, ErrTemplateVersionNotFound
+	}
+	if err != nil {
+		return tv, err
+	}
+	return tv, tv.loadContents()
+}
+
+// GetTemplateVersions returns every version of the template, newest first.
+// Each version includes the changes made since the version before it.
+func GetTemplateVersions(tid int64, uid int64) ([]TemplateVersion, error) {
+	tvs := []TemplateVersion{}
+	t, err := GetTemplate(tid, uid)
+	if err != nil {
+		return tvs, err
+	}
+	err = ensureTemplateVersion(db, &t)
+	if err != nil {
+		return tvs, err
+	}
+	err = db.Table("template_versions").
+		Select("template_versions.*, users.username as created_by").
+		Joins("left join users on template_versions.user_id = users.id").
+		Where("template_versions.template_id=?", tid).
+		Order("template_versions.version desc").
+		Find(&tvs).Error
+	if err != nil {
+		log.Error(err)
+		return tvs, err
+	}
+	for i := range tvs {
+		err = tvs[i].loadContents()
+		if err != nil {
+			return tvs, err
+		}
+	}
+	for i := range tvs {
+		previous := TemplateVersion{Attachments: []Attachment{}}
+		if i+1 < len(tvs) {
+			previous = tvs[i+1]
+		}
+		tvs[i].Changes = diffTemplateVersions(previous, tvs[i])
+	}
+	return tvs, nil
+}
+
+// GetTemplateVersion returns the given version of the template, if the user
+// has access to the template
+func GetTemplateVersion(tid int64, version int, uid int64) (TemplateVersion, error) {
+	t, err := GetTemplate(tid, uid)
+	if err != nil {
+		return TemplateVersion{}, err
+	}
+	err = ensureTemplateVersion(db, &t)
+	if err != nil {
+		return TemplateVersion{}, err
+	}
+	return getTemplateVersion(tid, version)
+}
+
+// RollbackTemplate restores the template's contents to the given version.
+// Since versions are immutable, this saves the restored contents as a new
+// version. The template keeps its current owner.
+func RollbackTemplate(tid int64, version int, uid int64) (Template, error) {
+	existing, err := GetTemplate(tid, uid)
+	if err != nil {
+		return Template{}, err
+	}
+	tv, err := GetTemplateVersion(tid, version, uid)
+	if err != nil {
+		return Template{}, err
+	}
+	t := tv.Template()
+	t.UserId = existing.UserId
+	t.ModifiedDate = time.Now().UTC()
+	err = PutTemplate(&t)
+	return t, err
+}
+
+// diffTemplateVersions returns the fields which changed between two
+// versions as unified diffs
+func diffTemplateVersions(a, b TemplateVersion) []TemplateChange {
+	type field struct {
+		name string
+		a, b string
+	}
+	fields := []field{
+		{"name", a.Name, b.Name},
+		{"type", a.Type, b.Type},
+		{"envelope_sender", a.EnvelopeSender, b.EnvelopeSender},
+		{"subject", a.Subject, b.Subject},
+		{"text", a.Text, b.Text},
+		{"html", a.HTML, b.HTML},
+		{"attachments", attachmentSummary(a.Attachments), attachmentSummary(b.Attachments)},
+		{"tags", strings.Join(a.Tags, "\n"), strings.Join(b.Tags, "\n")},
+		{"difficulty", a.Difficulty, b.Difficulty},
+		{"language", a.Language, b.Language},
+		{"category", a.Category, b.Category},
+		{"fallback_language", a.FallbackLanguage, b.FallbackLanguage},
+	}
+	for _, language := range variantLanguages(a.Variants, b.Variants) {
+		va, vb := findVariant(a.Variants, language), findVariant(b.Variants, language)
+		fields = append(fields,
+			field{fmt.Sprintf("subject (%s)", language), va.Subject, vb.Subject},
+			field{fmt.Sprintf("text (%s)", language), va.Text, vb.Text},
+			field{fmt.Sprintf("html (%s)", language), va.HTML, vb.HTML},
+		)
+	}
+	changes := []TemplateChange{}
+	for _, f := range fields {
+		if f.a == f.b {
+			continue
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(f.a),
+			B:        difflib.SplitLines(f.b),
+			FromFile: fmt.Sprintf("version %d", a.Version),
+			ToFile:   fmt.Sprintf("version %d", b.Version),
+			Context:  3,
+		})
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		changes = append(changes, TemplateChange{Field: f.name, Diff: diff})
+	}
+	return changes
+}
+
+// variantLanguages returns the languages of the variants in either version,
+// in the order they appear
+func variantLanguages(a, b []TemplateVariant) []string {
+	seen := make(map[string]bool)
+	languages := []string{}
+	for _, v := range append(append([]TemplateVariant{}, a...), b...) {
+		if !seen[v.Language] {
+			seen[v.Language] = true
+			languages = append(languages, v.Language)
+		}
+	}
+	return languages
+}
+
+// findVariant returns the variant for the language, or an empty variant if
+// there isn't one
+func findVariant(vs []TemplateVariant, language string) TemplateVariant {
+	for _, v := range vs {
+		if v.Language == language {
+			return v
+		}
+	}
+	return TemplateVariant{}
+}
+
+// attachmentSummary describes each attachment on its own line, using a hash
+// of the content so that changed files show up in diffs
+func attachmentSummary(as []Attachment) string {
+	lines := []string{}
+	for _, a := range as {
+		lines = append(lines, fmt.Sprintf("%s (%s) sha256:%x", a.Name, a.Type, sha256.Sum256([]byte(a.Content))))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// getTemplate loads the campaign's template, using the version the campaign
+// was pinned to when it launched if there is one.
+func (c *Campaign) getTemplate() error {
+	if c.TemplateVersion != 0 {
+		tv, err := getTemplateVersion(c.TemplateId, c.TemplateVersion)
+		if err == ErrTemplateVersionNotFound {
+			return gorm.ErrRecordNotFound
+		}
+		if err != nil {
+			return err
+		}
+		c.Template = tv.Template()
+		return nil
+	}
+	err := db.Table("templates").Where("id=?", c.TemplateId).Find(&c.Template).Error
+	if err != nil {
+		return err
+	}
+	err = db.Where("template_id=?", c.Template.Id).Find(&c.Template.Attachments).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) createVersionedTemplate(ch *check.C) Template {
+	t := Template{Name: "Versioned Template"}
+	t.Subject = "Original subject"
+	t.HTML = "<p>Hello {{.FirstName}}</p>"
+	t.UserId = 1
+	t.Attachments = []Attachment{{Name: "test.txt", Type: "text/plain", Content: "VGVzdA=="}}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+	ch.Assert(t.Version, check.Equals, 1)
+	return t
+}
+
+func (s *ModelsSuite) TestTemplateVersions(ch *check.C) {
+	t := s.createVersionedTemplate(ch)
+
+	edited := t
+	edited.Subject = "Edited subject"
+	edited.Attachments = []Attachment{}
+	ch.Assert(PutTemplate(&edited), check.Equals, nil)
+	ch.Assert(edited.Version, check.Equals, 2)
+
+	tvs, err := GetTemplateVersions(t.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(tvs), check.Equals, 2)
+	ch.Assert(tvs[0].Version, check.Equals, 2)
+	ch.Assert(tvs[0].CreatedBy, check.Equals, "admin")
+	ch.Assert(tvs[1].Version, check.Equals, 1)
+	ch.Assert(tvs[1].Subject, check.Equals, "Original subject")
+	ch.Assert(len(tvs[1].Attachments), check.Equals, 1)
+
+	fields := []string{}
+	for _, c := range tvs[0].Changes {
+		fields = append(fields, c.Field)
+	}
+	ch.Assert(fields, check.DeepEquals, []string{"subject", "attachments"})
+	ch.Assert(strings.Contains(tvs[0].Changes[0].Diff, "-Original subject"), check.Equals, true)
+	ch.Assert(strings.Contains(tvs[0].Changes[0].Diff, "+Edited subject"), check.Equals, true)
+}
+
+func (s *ModelsSuite) TestTemplateRollback(ch *check.C) {
+	t := s.createVersionedTemplate(ch)
+	edited := t
+	edited.Subject = "Edited subject"
+	ch.Assert(PutTemplate(&edited), check.Equals, nil)
+
+	rolledBack, err := RollbackTemplate(t.Id, 1, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(rolledBack.Version, check.Equals, 3)
+
+	got, err := GetTemplate(t.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Subject, check.Equals, "Original subject")
+	ch.Assert(got.Version, check.Equals, 3)
+	ch.Assert(len(got.Attachments), check.Equals, 1)
+
+	_, err = RollbackTemplate(t.Id, 10, 1)
+	ch.Assert(err, check.Equals, ErrTemplateVersionNotFound)
+
+	// Administrators roll back other users' templates without taking them
+	// over
+	ch.Assert(db.Model(&Template{}).Where("id=?", t.Id).UpdateColumn("user_id", 2).Error, check.Equals, nil)
+	rolledBack, err = RollbackTemplate(t.Id, 2, 0)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(rolledBack.UserId, check.Equals, int64(2))
+	got, err = GetTemplate(t.Id, 2)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Subject, check.Equals, "Edited subject")
+	ch.Assert(got.Version, check.Equals, 4)
+	_, err = RollbackTemplate(t.Id, 1, 1)
+	ch.Assert(err, check.NotNil)
+}
+
+func (s *ModelsSuite) TestPutTemplateRollsBack(ch *check.C) {
+	t := s.createVersionedTemplate(ch)
+	// Saving the new version fails if it already exists, which leaves the
+	// template unchanged
+	ch.Assert(db.Save(&TemplateVersion{TemplateId: t.Id, Version: 2}).Error, check.Equals, nil)
+	edited := t
+	edited.Subject = "Edited subject"
+	edited.Attachments = []Attachment{}
+	ch.Assert(PutTemplate(&edited), check.NotNil)
+
+	got, err := GetTemplate(t.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Subject, check.Equals, "Original subject")
+	ch.Assert(got.Version, check.Equals, 1)
+	ch.Assert(len(got.Attachments), check.Equals, 1)
+}
+
+func (s *ModelsSuite) TestCampaignPinnedTemplateVersion(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(c.TemplateVersion, check.Equals, c.Template.Version)
+	pinned := c.Template.Subject
+
+	edited := c.Template
+	edited.Subject = "Edited after launch"
+	ch.Assert(PutTemplate(&edited), check.Equals, nil)
+
+	mc, err := GetCampaignMailContext(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(mc.Template.Subject, check.Equals, pinned)
+	ch.Assert(mc.Template.Version, check.Equals, c.TemplateVersion)
+}
+
+func (s *ModelsSuite) TestLegacyTemplateVersion(ch *check.C) {
+	t := s.createVersionedTemplate(ch)
+	// Simulate a template created before versioning
+	ch.Assert(db.Model(&Template{}).Where("id=?", t.Id).UpdateColumn("version", 0).Error, check.Equals, nil)
+	ch.Assert(db.Where("template_id=?", t.Id).Delete(&TemplateVersion{}).Error, check.Equals, nil)
+
+	tvs, err := GetTemplateVersions(t.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(tvs), check.Equals, 1)
+	ch.Assert(tvs[0].Version, check.Equals, 1)
+}
@@ -0,0 +1,402 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// Triggers which show a campaign's training page
+const (
+	// TrainingTriggerClick shows the training page instead of the landing
+	// page when the recipient clicks the link
+	TrainingTriggerClick = "click"
+	// TrainingTriggerSubmit shows the training page after the recipient
+	// submits the landing page form, instead of redirecting them
+	TrainingTriggerSubmit = "submit"
+)
+
+// TrainingPath is the path, relative to the landing page, where training
+// pages are served and completed
+const TrainingPath = "training"
+
+// ErrTrainingPageNameNotSpecified is thrown if the name of the training page
+// is blank
+var ErrTrainingPageNameNotSpecified = errors.New("Training page name not specified")
+
+// ErrTrainingPageNotFound indicates the training page specified for a
+// campaign does not exist in the database
+var ErrTrainingPageNotFound = errors.New("Training page not found")
+
+// ErrInvalidTrainingTrigger is thrown when a campaign's training trigger
+// isn't click or submit
+var ErrInvalidTrainingTrigger = errors.New("Invalid training trigger. Must be click or submit")
+
+// ErrTrainingPageNotSpecified is thrown when a campaign has a training
+// trigger but no training page
+var ErrTrainingPageNotSpecified = errors.New("No training page specified")
+
+// ErrTrainingIndicatorTextNotSpecified is thrown when a training indicator
+// doesn't have any text to highlight
+var ErrTrainingIndicatorTextNotSpecified = errors.New("Training indicator text not specified")
+
+// TrainingIndicator is a sign that the email was a phish, such as a
+// lookalike sender domain or an urgent request. The text is highlighted
+// wherever it appears in the lure shown on the training page.
+type TrainingIndicator struct {
+	Text        string `json:"text"`
+	Explanation string `json:"explanation"`
+}
+
+// TrainingIndicators are the indicators for a training page. They are stored
+// as a JSON array.
+type TrainingIndicators []TrainingIndicator
+
+// Value implements the driver.Valuer interface
+func (ti TrainingIndicators) Value() (driver.Value, error) {
+	if len(ti) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(ti)
+	return string(data), err
+}
+
+// Scan implements the sql.Scanner interface
+func (ti *TrainingIndicators) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("unable to scan %T into TrainingIndicators", value)
+	}
+	*ti = TrainingIndicators{}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, ti)
+}
+
+// TrainingPage is the "teachable moment" content shown to recipients who
+// fall for a campaign. The HTML is a template which has access to the lure
+// the recipient received, with the indicators highlighted.
+type TrainingPage struct {
+	Id           int64              `json:"id" gorm:"column:id; primary_key:yes"`
+	UserId       int64              `json:"-" gorm:"column:user_id"`
+	Name         string             `json:"name"`
+	HTML         string             `json:"html" gorm:"column:html"`
+	Indicators   TrainingIndicators `json:"indicators" gorm:"column:indicators;type:text"`
+	ModifiedDate time.Time          `json:"modified_date"`
+}
+
+// TrainingLure is the email the recipient received, rendered for the
+// training page. Each field is HTML with the training indicators wrapped in
+// <mark class="phish-indicator"> elements.
+type TrainingLure struct {
+	From    string
+	Subject string
+	HTML    string
+}
+
+// TrainingTemplateContext is the context sent to training page templates
+type TrainingTemplateContext struct {
+	PhishingTemplateContext
+	Lure       TrainingLure
+	Indicators TrainingIndicators
+	// CompleteURL is where the training page should POST to when the
+	// recipient finishes the training
+	CompleteURL string
+	// Completed is whether the page is being shown after the recipient
+	// completed the training
+	Completed bool
+	// ModuleURL is the link to the training module assigned to the
+	// recipient, if they have one
+	ModuleURL string
+}
+
+// Validate ensures that a training page contains the appropriate details
+func (tp *TrainingPage) Validate() error {
+	if tp.Name == "" {
+		return ErrTrainingPageNameNotSpecified
+	}
+	for _, i := range tp.Indicators {
+		if strings.TrimSpace(i.Text) == "" {
+			return ErrTrainingIndicatorTextNotSpecified
+		}
+	}
+	vc := ValidationContext{
+		FromAddress: "foo@bar.com",
+		BaseURL:     "http://example.com",
+	}
+	ptx, err := NewPhishingTemplateContext(vc, BaseRecipient{Email: "foo@bar.com", FirstName: "Foo", LastName: "Bar"}, "123456")
+	if err != nil {
+		return err
+	}
+	tc := TrainingTemplateContext{
+		PhishingTemplateContext: ptx,
+		Lure:                    TrainingLure{From: ptx.From, Subject: "Subject", HTML: "<p>Body</p>"},
+		Indicators:              tp.Indicators,
+		CompleteURL:             "/" + TrainingPath,
+		ModuleURL:               "http://example.com/" + TrainingModulePath,
+	}
+	_, err = ExecuteTemplate(tp.HTML, tc)
+	return err
+}
+
+// highlightIndicators wraps each occurrence of the indicators' text in the
+// HTML with a <mark> element. Only text outside of tags is highlighted, so
+// the markup itself isn't changed.
+func highlightIndicators(s string, indicators TrainingIndicators) string {
+	for _, i := range indicators {
+		needle := html.EscapeString(i.Text)
+		if needle == "" {
+			continue
+		}
+		mark := fmt.Sprintf(`<mark class="phish-indicator" title="%s">`, html.EscapeString(i.Explanation))
+		b := &strings.Builder{}
+		for s != "" {
+			// Copy any tags through as-is
+			if s[0] == '<' {
+				end := strings.IndexByte(s, '>')
+				if end == -1 {
+					end = len(s) - 1
+				}
+				b.WriteString(s[:end+1])
+				s = s[end+1:]
+				continue
+			}
+			end := strings.IndexByte(s, '<')
+			if end == -1 {
+				end = len(s)
+			}
+			text := s[:end]
+			s = s[end:]
+			lower, target := strings.ToLower(text), strings.ToLower(needle)
+			// Fall back to matching case if lowering changes the length
+			if len(lower) != len(text) || len(target) != len(needle) {
+				lower, target = text, needle
+			}
+			for {
+				idx := strings.Index(lower, target)
+				if idx == -1 {
+					break
+				}
+				b.WriteString(text[:idx])
+				b.WriteString(mark)
+				b.WriteString(text[idx : idx+len(needle)])
+				b.WriteString("</mark>")
+				text = text[idx+len(needle):]
+				lower = lower[idx+len(needle):]
+			}
+			b.WriteString(text)
+		}
+		s = b.String()
+	}
+	return s
+}
+
+// NewTrainingTemplateContext returns the context used to render the
+// campaign's training page for the given result. The lure is rendered from
+// the campaign's template, localized for the recipient.
+func NewTrainingTemplateContext(c *Campaign, r Result) (TrainingTemplateContext, error) {
+	ptx, err := NewPhishingTemplateContext(c, r.BaseRecipient, r.RId)
+	if err != nil {
+		return TrainingTemplateContext{}, err
+	}
+	if !r.SendDate.IsZero() {
+		ptx.SendDate = r.SendDate
+	}
+	t := c.Template.Localize(r.Language)
+	subject, err := ExecuteTemplate(t.Subject, ptx)
+	if err != nil {
+		return TrainingTemplateContext{}, err
+	}
+	body := t.HTML
+	if body == "" {
+		body = t.Text
+	}
+	body, err = ExecuteTemplate(body, ptx)
+	if err != nil {
+		return TrainingTemplateContext{}, err
+	}
+	if t.HTML == "" {
+		body = strings.Replace(html.EscapeString(body), "\n", "<br>", -1)
+	}
+	indicators := c.TrainingPage.Indicators
+	moduleURL := ""
+	if _, err := GetTrainingAssignment(r.RId); err == nil {
+		moduleURL, err = c.TrainingModuleURL(r.RId)
+		if err != nil {
+			return TrainingTemplateContext{}, err
+		}
+	}
+	return TrainingTemplateContext{
+		PhishingTemplateContext: ptx,
+		Lure: TrainingLure{
+			From:    highlightIndicators(html.EscapeString(c.getFromAddress()), indicators),
+			Subject: highlightIndicators(html.EscapeString(subject), indicators),
+			HTML:    highlightIndicators(body, indicators),
+		},
+		Indicators:  indicators,
+		CompleteURL: fmt.Sprintf("%s?%s=%s", TrainingPath, RecipientParameter, r.RId),
+		ModuleURL:   moduleURL,
+	}, nil
+}
+
+// ShowsTraining returns whether the campaign's training page should be shown
+// for the given trigger
+func (c *Campaign) ShowsTraining(trigger string) bool {
+	return c.TrainingPageId != 0 && c.TrainingPage.Id != 0 && c.TrainingTrigger == trigger
+}
+
+// validateTraining checks the campaign's training settings, defaulting the
+// trigger to showing the training when the link is clicked
+func (c *Campaign) validateTraining() error {
+	if c.TrainingPage.Name == "" {
+		if c.TrainingTrigger != "" {
+			return ErrTrainingPageNotSpecified
+		}
+		return nil
+	}
+	switch c.TrainingTrigger {
+	case "":
+		c.TrainingTrigger = TrainingTriggerClick
+	case TrainingTriggerClick, TrainingTriggerSubmit:
+	default:
+		return ErrInvalidTrainingTrigger
+	}
+	return nil
+}
+
+// resolveTrainingPage looks up the campaign's training page by name
+func (c *Campaign) resolveTrainingPage(uid int64) error {
+	if c.TrainingPage.Name == "" {
+		return nil
+	}
+	tp, err := GetTrainingPageByName(c.TrainingPage.Name, uid)
+	if err == gorm.ErrRecordNotFound {
+		log.WithFields(logrus.Fields{
+			"training_page": c.TrainingPage.Name,
+		}).Error("Training page does not exist")
+		return ErrTrainingPageNotFound
+	} else if err != nil {
+		log.Error(err)
+		return err
+	}
+	c.TrainingPage = tp
+	c.TrainingPageId = tp.Id
+	return nil
+}
+
+// getTrainingPage loads the campaign's training page, if it has one
+func (c *Campaign) getTrainingPage() error {
+	if c.TrainingPageId == 0 {
+		return nil
+	}
+	err := db.Where("id=?", c.TrainingPageId).Find(&c.TrainingPage).Error
+	if err == gorm.ErrRecordNotFound {
+		c.TrainingPage = TrainingPage{Name: "[Deleted]"}
+		log.Warnf("%s: training page not found for campaign", err)
+		return nil
+	}
+	return err
+}
+
+// GetTrainingPages returns the training pages owned by the given user.
+func GetTrainingPages(uid int64) ([]TrainingPage, error) {
+	tps := []TrainingPage{}
+	query := db.Model(&TrainingPage{})
+	if uid != 0 {
+		query = query.Where("user_id = ?", uid)
+	}
+	err := query.Find(&tps).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return tps, err
+}
+
+// GetTrainingPage returns the training page, if it exists, specified by the
+// given id and user_id.
+func GetTrainingPage(id int64, uid int64) (TrainingPage, error) {
+	tp := TrainingPage{}
+	query := db.Where("id=?", id)
+	if uid != 0 {
+		query = query.Where("user_id = ?", uid)
+	}
+	err := query.Find(&tp).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return tp, err
+}
+
+// GetTrainingPageByName returns the training page, if it exists, specified
+// by the given name and user_id.
+func GetTrainingPageByName(n string, uid int64) (TrainingPage, error) {
+	tp := TrainingPage{}
+	query := db.Where("name=?", n)
+	if uid != 0 {
+		query = query.Where("user_id = ?", uid)
+	}
+	err := query.Find(&tp).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return tp, err
+}
+
+// PostTrainingPage creates a new training page in the database.
+func PostTrainingPage(tp *TrainingPage) error {
+	err := tp.Validate()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = db.Save(tp).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// PutTrainingPage edits an existing training page in the database.
+// Per the PUT Method RFC, it presumes all data for a training page is
+// provided.
+func PutTrainingPage(tp *TrainingPage) error {
+	err := tp.Validate()
+	if err != nil {
+		return err
+	}
+	err = db.Where("id=?", tp.Id).Save(tp).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// DeleteTrainingPage deletes an existing training page in the database.
+// An error is returned if a training page with the given user id and id is
+// not found.
+func DeleteTrainingPage(id int64, uid int64) error {
+	_, err := GetTrainingPage(id, uid)
+	if err != nil {
+		return err
+	}
+	err = db.Delete(TrainingPage{Id: id}).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
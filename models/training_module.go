@@ -0,0 +1,552 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// Events recorded on a result's timeline as the recipient works through an
+// assigned training module
+const (
+	EventModuleStarted   string = "Started Training Module"
+	EventModuleQuiz      string = "Submitted Training Quiz"
+	EventModuleCompleted string = "Completed Training Module"
+)
+
+// TrainingModulePath is the path on the phishing server where assigned
+// training modules are served
+const TrainingModulePath = "training/module"
+
+// DefaultTrainingPassScore is the percentage of quiz questions which must be
+// answered correctly when a module doesn't specify a pass score
+const DefaultTrainingPassScore = 80
+
+// DefaultTrainingDueDays is the number of days recipients have to complete
+// an assigned module when the module doesn't specify a due date
+const DefaultTrainingDueDays = 14
+
+// ErrTrainingModuleNameNotSpecified is thrown if the name of the training
+// module is blank
+var ErrTrainingModuleNameNotSpecified = errors.New("Training module name not specified")
+
+// ErrTrainingModuleNoSteps is thrown if the training module has no steps
+var ErrTrainingModuleNoSteps = errors.New("Training module must have at least one step")
+
+// ErrInvalidQuizQuestion is thrown if a quiz question doesn't have a
+// question, has fewer than two options or its answer isn't one of the
+// options
+var ErrInvalidQuizQuestion = errors.New("Quiz questions must have a question, at least two options and the index of the correct option")
+
+// ErrInvalidTrainingPassScore is thrown if the pass score isn't a percentage
+var ErrInvalidTrainingPassScore = errors.New("Pass score must be between 0 and 100")
+
+// ErrInvalidTrainingDueDays is thrown if the due days is negative
+var ErrInvalidTrainingDueDays = errors.New("Due days must not be negative")
+
+// ErrTrainingModuleNotFound indicates the training module specified for a
+// campaign does not exist in the database
+var ErrTrainingModuleNotFound = errors.New("Training module not found")
+
+// ErrInvalidTrainingAssignOn is thrown if a campaign's modules aren't
+// assigned on clicking the link or submitting data
+var ErrInvalidTrainingAssignOn = fmt.Errorf("Training modules must be assigned on %q or %q", EventClicked, EventDataSubmit)
+
+// ErrTrainingModuleNotSpecified is thrown when a campaign sets when to
+// assign training but doesn't have a training module
+var ErrTrainingModuleNotSpecified = errors.New("No training module specified")
+
+// ErrTrainingAssignmentNotFound is thrown when a recipient doesn't have a
+// training module assigned
+var ErrTrainingAssignmentNotFound = errors.New("Training assignment not found")
+
+// TrainingStep is a single slide of a training module
+type TrainingStep struct {
+	Title string `json:"title"`
+	HTML  string `json:"html"`
+}
+
+// TrainingSteps are the steps of a training module, stored as a JSON array
+type TrainingSteps []TrainingStep
+
+// Value implements the driver.Valuer interface
+func (ts TrainingSteps) Value() (driver.Value, error) {
+	return jsonValue(ts, len(ts))
+}
+
+// Scan implements the sql.Scanner interface
+func (ts *TrainingSteps) Scan(value interface{}) error {
+	*ts = TrainingSteps{}
+	return jsonScan(value, ts)
+}
+
+// QuizQuestion is a multiple choice question asked at the end of a
+// training module. Answer is the index of the correct option.
+type QuizQuestion struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	Answer   int      `json:"answer"`
+}
+
+// QuizQuestions are the questions of a training module's quiz, stored as a
+// JSON array
+type QuizQuestions []QuizQuestion
+
+// Value implements the driver.Valuer interface
+func (qq QuizQuestions) Value() (driver.Value, error) {
+	return jsonValue(qq, len(qq))
+}
+
+// Scan implements the sql.Scanner interface
+func (qq *QuizQuestions) Scan(value interface{}) error {
+	*qq = QuizQuestions{}
+	return jsonScan(value, qq)
+}
+
+// jsonValue stores the value as JSON, or as an empty string if it has no
+// items
+func jsonValue(v interface{}, items int) (driver.Value, error) {
+	if items == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// jsonScan reads a JSON column into the value
+func jsonScan(value interface{}, v interface{}) error {
+	var data []byte
+	switch d := value.(type) {
+	case nil:
+	case string:
+		data = []byte(d)
+	case []byte:
+		data = d
+	default:
+		return fmt.Errorf("unable to scan %T into %T", value, v)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// TrainingModule is a short training course served from the phishing
+// server, made up of HTML steps followed by a multiple choice quiz. Modules
+// are assigned to recipients who fall for a campaign.
+type TrainingModule struct {
+	Id          int64  `json:"id" gorm:"column:id; primary_key:yes"`
+	UserId      int64  `json:"-" gorm:"column:user_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Steps are shown in order before the quiz
+	Steps     TrainingSteps `json:"steps" gorm:"column:steps;type:text"`
+	Questions QuizQuestions `json:"questions" gorm:"column:questions;type:text"`
+	// PassScore is the percentage of questions which must be answered
+	// correctly to complete the module. 0 uses DefaultTrainingPassScore.
+	PassScore int `json:"pass_score"`
+	// DueDays is how many days recipients have to complete the module once
+	// it's assigned. 0 uses DefaultTrainingDueDays.
+	DueDays      int       `json:"due_days"`
+	ModifiedDate time.Time `json:"modified_date"`
+}
+
+// TrainingAssignment is a training module assigned to a campaign recipient
+type TrainingAssignment struct {
+	Id            int64     `json:"id"`
+	UserId        int64     `json:"-"`
+	CampaignId    int64     `json:"campaign_id"`
+	ModuleId      int64     `json:"module_id"`
+	RId           string    `json:"rid"`
+	Email         string    `json:"email"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	AssignedDate  time.Time `json:"assigned_date"`
+	DueDate       time.Time `json:"due_date"`
+	StartedDate   time.Time `json:"started_date"`
+	CompletedDate time.Time `json:"completed_date"`
+	Score         int       `json:"score"`
+	Attempts      int       `json:"attempts"`
+	// ModuleName and CampaignName are included in reports
+	ModuleName   string `json:"module_name" sql:"-"`
+	CampaignName string `json:"campaign_name" sql:"-"`
+}
+
+// EventQuizScore is the details of a quiz attempt recorded on a result's
+// timeline
+type EventQuizScore struct {
+	Score   int  `json:"score"`
+	Passed  bool `json:"passed"`
+	Attempt int  `json:"attempt"`
+}
+
+// TrainingComplianceReport summarizes the training assignments, listing
+// the ones which weren't completed by their due date
+type TrainingComplianceReport struct {
+	Total     int                  `json:"total"`
+	Completed int                  `json:"completed"`
+	Overdue   int                  `json:"overdue"`
+	Pending   int                  `json:"pending"`
+	Overdues  []TrainingAssignment `json:"overdue_assignments"`
+}
+
+// Validate ensures that a training module contains the appropriate details
+func (tm *TrainingModule) Validate() error {
+	if tm.Name == "" {
+		return ErrTrainingModuleNameNotSpecified
+	}
+	if len(tm.Steps) == 0 {
+		return ErrTrainingModuleNoSteps
+	}
+	for _, q := range tm.Questions {
+		if q.Question == "" || len(q.Options) < 2 || q.Answer < 0 || q.Answer >= len(q.Options) {
+			return ErrInvalidQuizQuestion
+		}
+	}
+	if tm.PassScore < 0 || tm.PassScore > 100 {
+		return ErrInvalidTrainingPassScore
+	}
+	if tm.DueDays < 0 {
+		return ErrInvalidTrainingDueDays
+	}
+	return nil
+}
+
+// PassingScore returns the score needed to pass the module's quiz
+func (tm *TrainingModule) PassingScore() int {
+	if tm.PassScore == 0 {
+		return DefaultTrainingPassScore
+	}
+	return tm.PassScore
+}
+
+// dueDays returns the number of days recipients have to complete the module
+func (tm *TrainingModule) dueDays() int {
+	if tm.DueDays == 0 {
+		return DefaultTrainingDueDays
+	}
+	return tm.DueDays
+}
+
+// Grade returns the percentage of questions answered correctly, given the
+// index of the chosen option for each question, and whether that passes
+// the quiz. A module without questions is always passed.
+func (tm *TrainingModule) Grade(answers []int) (int, bool) {
+	if len(tm.Questions) == 0 {
+		return 100, true
+	}
+	correct := 0
+	for i, q := range tm.Questions {
+		if i < len(answers) && answers[i] == q.Answer {
+			correct++
+		}
+	}
+	score := correct * 100 / len(tm.Questions)
+	return score, score >= tm.PassingScore()
+}
+
+// Overdue returns whether the assignment wasn't completed by its due date
+func (a *TrainingAssignment) Overdue(now time.Time) bool {
+	return a.CompletedDate.IsZero() && now.After(a.DueDate)
+}
+
+// Start records that the recipient started the training module
+func (a *TrainingAssignment) Start(r *Result) error {
+	if !a.StartedDate.IsZero() {
+		return nil
+	}
+	event, err := r.createEvent(EventModuleStarted, nil)
+	if err != nil {
+		return err
+	}
+	a.StartedDate = event.Time
+	return db.Save(a).Error
+}
+
+// SubmitQuiz grades the recipient's quiz answers, recording the score on the
+// result's timeline. If the quiz is passed, the module is completed.
+func (a *TrainingAssignment) SubmitQuiz(r *Result, tm *TrainingModule, answers []int) (int, bool, error) {
+	score, passed := tm.Grade(answers)
+	a.Attempts++
+	if score > a.Score {
+		a.Score = score
+	}
+	if len(tm.Questions) > 0 {
+		_, err := r.createEvent(EventModuleQuiz, EventQuizScore{Score: score, Passed: passed, Attempt: a.Attempts})
+		if err != nil {
+			return score, passed, err
+		}
+	}
+	if passed && a.CompletedDate.IsZero() {
+		event, err := r.createEvent(EventModuleCompleted, EventQuizScore{Score: score, Passed: passed, Attempt: a.Attempts})
+		if err != nil {
+			return score, passed, err
+		}
+		a.CompletedDate = event.Time
+	}
+	return score, passed, db.Save(a).Error
+}
+
+// assignTrainingModule assigns the campaign's training module to the
+// recipient once they reach the given status, if the campaign assigns
+// training at that point. Recipients are only assigned a module once.
+func (r *Result) assignTrainingModule(reached string) error {
+	c := Campaign{}
+	err := db.Select("id, user_id, training_module_id, training_assign_on").Where("id=?", r.CampaignId).Find(&c).Error
+	if err != nil {
+		return err
+	}
+	if c.TrainingModuleId == 0 {
+		return nil
+	}
+	if c.TrainingAssignOn == EventDataSubmit && reached != EventDataSubmit {
+		return nil
+	}
+	count := 0
+	err = db.Model(&TrainingAssignment{}).Where("r_id=?", r.RId).Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+	tm, err := GetTrainingModule(c.TrainingModuleId, 0)
+	if err == gorm.ErrRecordNotFound {
+		log.Warnf("%s: training module not found for campaign", err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	a := &TrainingAssignment{
+		UserId:       c.UserId,
+		CampaignId:   c.Id,
+		ModuleId:     tm.Id,
+		RId:          r.RId,
+		Email:        r.Email,
+		FirstName:    r.FirstName,
+		LastName:     r.LastName,
+		AssignedDate: now,
+		DueDate:      now.AddDate(0, 0, tm.dueDays()),
+	}
+	return db.Save(a).Error
+}
+
+// validateTrainingModule checks the campaign's training module settings,
+// defaulting to assigning the module when the link is clicked
+func (c *Campaign) validateTrainingModule() error {
+	if c.TrainingModule.Name == "" {
+		if c.TrainingAssignOn != "" {
+			return ErrTrainingModuleNotSpecified
+		}
+		return nil
+	}
+	switch c.TrainingAssignOn {
+	case "":
+		c.TrainingAssignOn = EventClicked
+	case EventClicked, EventDataSubmit:
+	default:
+		return ErrInvalidTrainingAssignOn
+	}
+	return nil
+}
+
+// resolveTrainingModule looks up the campaign's training module by name
+func (c *Campaign) resolveTrainingModule(uid int64) error {
+	if c.TrainingModule.Name == "" {
+		return nil
+	}
+	tm, err := GetTrainingModuleByName(c.TrainingModule.Name, uid)
+	if err == gorm.ErrRecordNotFound {
+		log.WithFields(logrus.Fields{
+			"training_module": c.TrainingModule.Name,
+		}).Error("Training module does not exist")
+		return ErrTrainingModuleNotFound
+	} else if err != nil {
+		log.Error(err)
+		return err
+	}
+	c.TrainingModule = tm
+	c.TrainingModuleId = tm.Id
+	return nil
+}
+
+// getTrainingModule loads the campaign's training module, if it has one
+func (c *Campaign) getTrainingModule() error {
+	if c.TrainingModuleId == 0 {
+		return nil
+	}
+	err := db.Where("id=?", c.TrainingModuleId).Find(&c.TrainingModule).Error
+	if err == gorm.ErrRecordNotFound {
+		c.TrainingModule = TrainingModule{Name: "[Deleted]"}
+		log.Warnf("%s: training module not found for campaign", err)
+		return nil
+	}
+	return err
+}
+
+// TrainingModuleURL returns the URL where the recipient can take the
+// campaign's training module
+func (c *Campaign) TrainingModuleURL(rid string) (string, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = "/" + TrainingModulePath
+	u.RawQuery = url.Values{RecipientParameter: {rid}}.Encode()
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// GetTrainingAssignment returns the training module assigned to the
+// recipient with the given result id
+func GetTrainingAssignment(rid string) (TrainingAssignment, error) {
+	a := TrainingAssignment{}
+	err := db.Where("r_id=?", rid).Find(&a).Error
+	if err == gorm.ErrRecordNotFound {
+		return a, ErrTrainingAssignmentNotFound
+	}
+	return a, err
+}
+
+// GetTrainingAssignments returns the training assignments owned by the given
+// user, optionally limited to a single campaign
+func GetTrainingAssignments(uid int64, cid int64) ([]TrainingAssignment, error) {
+	as := []TrainingAssignment{}
+	query := db.Table("training_assignments").
+		Select("training_assignments.*, training_modules.name as module_name, campaigns.name as campaign_name").
+		Joins("left join training_modules on training_assignments.module_id = training_modules.id").
+		Joins("left join campaigns on training_assignments.campaign_id = campaigns.id")
+	if uid != 0 {
+		query = query.Where("training_assignments.user_id = ?", uid)
+	}
+	if cid != 0 {
+		query = query.Where("training_assignments.campaign_id = ?", cid)
+	}
+	err := query.Order("training_assignments.due_date asc").Scan(&as).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return as, err
+}
+
+// GetTrainingComplianceReport returns the state of the training assignments
+// owned by the given user, optionally limited to a single campaign, as of
+// the given time
+func GetTrainingComplianceReport(uid int64, cid int64, now time.Time) (TrainingComplianceReport, error) {
+	report := TrainingComplianceReport{Overdues: []TrainingAssignment{}}
+	as, err := GetTrainingAssignments(uid, cid)
+	if err != nil {
+		return report, err
+	}
+	report.Total = len(as)
+	for _, a := range as {
+		switch {
+		case !a.CompletedDate.IsZero():
+			report.Completed++
+		case a.Overdue(now):
+			report.Overdue++
+			report.Overdues = append(report.Overdues, a)
+		default:
+			report.Pending++
+		}
+	}
+	return report, nil
+}
+
+// GetTrainingModules returns the training modules owned by the given user.
+func GetTrainingModules(uid int64) ([]TrainingModule, error) {
+	tms := []TrainingModule{}
+	query := db.Model(&TrainingModule{})
+	if uid != 0 {
+		query = query.Where("user_id = ?", uid)
+	}
+	err := query.Find(&tms).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return tms, err
+}
+
+// GetTrainingModule returns the training module, if it exists, specified by
+// the given id and user_id.
+func GetTrainingModule(id int64, uid int64) (TrainingModule, error) {
+	tm := TrainingModule{}
+	query := db.Where("id=?", id)
+	if uid != 0 {
+		query = query.Where("user_id = ?", uid)
+	}
+	err := query.Find(&tm).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return tm, err
+}
+
+// GetTrainingModuleByName returns the training module, if it exists,
+// specified by the given name and user_id.
+func GetTrainingModuleByName(n string, uid int64) (TrainingModule, error) {
+	tm := TrainingModule{}
+	query := db.Where("name=?", n)
+	if uid != 0 {
+		query = query.Where("user_id = ?", uid)
+	}
+	err := query.Find(&tm).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return tm, err
+}
+
+// PostTrainingModule creates a new training module in the database.
+func PostTrainingModule(tm *TrainingModule) error {
+	err := tm.Validate()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = db.Save(tm).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// PutTrainingModule edits an existing training module in the database.
+// Per the PUT Method RFC, it presumes all data for a training module is
+// provided.
+func PutTrainingModule(tm *TrainingModule) error {
+	err := tm.Validate()
+	if err != nil {
+		return err
+	}
+	err = db.Where("id=?", tm.Id).Save(tm).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// DeleteTrainingModule deletes an existing training module and its
+// assignments from the database. An error is returned if a training module
+// with the given user id and id is not found.
+func DeleteTrainingModule(id int64, uid int64) error {
+	_, err := GetTrainingModule(id, uid)
+	if err != nil {
+		return err
+	}
+	err = db.Where("module_id=?", id).Delete(&TrainingAssignment{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = db.Delete(TrainingModule{Id: id}).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func newTestTrainingModule(ch *check.C, name string) TrainingModule {
+	tm := TrainingModule{
+		Name:   name,
+		UserId: 1,
+		Steps:  TrainingSteps{{Title: "Spotting phishing", HTML: "<p>Check the sender</p>"}},
+		Questions: QuizQuestions{
+			{Question: "Should you check the sender?", Options: []string{"Yes", "No"}, Answer: 0},
+			{Question: "Should you enter your password?", Options: []string{"Yes", "No"}, Answer: 1},
+		},
+		PassScore: 100,
+		DueDays:   7,
+	}
+	ch.Assert(PostTrainingModule(&tm), check.Equals, nil)
+	return tm
+}
+
+func (s *ModelsSuite) TestTrainingModuleValidation(ch *check.C) {
+	tm := TrainingModule{UserId: 1}
+	ch.Assert(tm.Validate(), check.Equals, ErrTrainingModuleNameNotSpecified)
+	tm.Name = "Module"
+	ch.Assert(tm.Validate(), check.Equals, ErrTrainingModuleNoSteps)
+	tm.Steps = TrainingSteps{{Title: "Step", HTML: "<p>Step</p>"}}
+	tm.Questions = QuizQuestions{{Question: "Question", Options: []string{"Only"}}}
+	ch.Assert(tm.Validate(), check.Equals, ErrInvalidQuizQuestion)
+	tm.Questions = QuizQuestions{{Question: "Question", Options: []string{"A", "B"}, Answer: 2}}
+	ch.Assert(tm.Validate(), check.Equals, ErrInvalidQuizQuestion)
+	tm.Questions[0].Answer = 1
+	tm.PassScore = 101
+	ch.Assert(tm.Validate(), check.Equals, ErrInvalidTrainingPassScore)
+	tm.PassScore = 0
+	tm.DueDays = -1
+	ch.Assert(tm.Validate(), check.Equals, ErrInvalidTrainingDueDays)
+	tm.DueDays = 0
+	ch.Assert(tm.Validate(), check.Equals, nil)
+
+	score, passed := tm.Grade([]int{1})
+	ch.Assert(score, check.Equals, 100)
+	ch.Assert(passed, check.Equals, true)
+	score, passed = tm.Grade(nil)
+	ch.Assert(score, check.Equals, 0)
+	ch.Assert(passed, check.Equals, false)
+}
+
+func (s *ModelsSuite) TestTrainingModuleAssignment(ch *check.C) {
+	tm := newTestTrainingModule(ch, "Assigned Module")
+	c := s.createCampaignDependencies(ch)
+	c.TrainingAssignOn = EventDataSubmit
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrTrainingModuleNotSpecified)
+	c.TrainingModule = TrainingModule{Name: tm.Name}
+	c.TrainingAssignOn = EventOpened
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidTrainingAssignOn)
+	c.TrainingAssignOn = EventDataSubmit
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+
+	// Clicking isn't enough to be assigned the module
+	r := c.Results[0]
+	ch.Assert(r.HandleClickedLink(newHumanEventDetails()), check.Equals, nil)
+	_, err := GetTrainingAssignment(r.RId)
+	ch.Assert(err, check.Equals, ErrTrainingAssignmentNotFound)
+
+	ch.Assert(r.HandleFormSubmit(EventDetails{}), check.Equals, nil)
+	ch.Assert(r.HandleFormSubmit(EventDetails{}), check.Equals, nil)
+	a, err := GetTrainingAssignment(r.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(a.ModuleId, check.Equals, tm.Id)
+	ch.Assert(a.Email, check.Equals, r.Email)
+	ch.Assert(a.DueDate.Sub(a.AssignedDate), check.Equals, 7*24*time.Hour)
+	count := 0
+	db.Model(&TrainingAssignment{}).Where("r_id=?", r.RId).Count(&count)
+	ch.Assert(count, check.Equals, 1)
+
+	c, err = GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(c.TrainingModule.Id, check.Equals, tm.Id)
+	url, err := c.TrainingModuleURL(r.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(url, check.Equals, c.URL+"/"+TrainingModulePath+"?rid="+r.RId)
+}
+
+func (s *ModelsSuite) TestTrainingModuleQuiz(ch *check.C) {
+	tm := newTestTrainingModule(ch, "Quiz Module")
+	c := s.createCampaignDependencies(ch)
+	c.TrainingModule = TrainingModule{Name: tm.Name}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(c.TrainingAssignOn, check.Equals, EventClicked)
+	r := c.Results[0]
+	ch.Assert(r.HandleClickedLink(newHumanEventDetails()), check.Equals, nil)
+	a, err := GetTrainingAssignment(r.RId)
+	ch.Assert(err, check.Equals, nil)
+
+	ch.Assert(a.Start(&r), check.Equals, nil)
+	ch.Assert(a.Start(&r), check.Equals, nil)
+	score, passed, err := a.SubmitQuiz(&r, &tm, []int{0, 0})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(score, check.Equals, 50)
+	ch.Assert(passed, check.Equals, false)
+	ch.Assert(a.CompletedDate.IsZero(), check.Equals, true)
+	score, passed, err = a.SubmitQuiz(&r, &tm, []int{0, 1})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(score, check.Equals, 100)
+	ch.Assert(passed, check.Equals, true)
+
+	a, err = GetTrainingAssignment(r.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(a.Attempts, check.Equals, 2)
+	ch.Assert(a.Score, check.Equals, 100)
+	ch.Assert(a.CompletedDate.IsZero(), check.Equals, false)
+
+	events := map[string]int{}
+	c, err = GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	for _, e := range c.Events {
+		events[e.Message]++
+	}
+	ch.Assert(events[EventModuleStarted], check.Equals, 1)
+	ch.Assert(events[EventModuleQuiz], check.Equals, 2)
+	ch.Assert(events[EventModuleCompleted], check.Equals, 1)
+}
+
+func (s *ModelsSuite) TestTrainingComplianceReport(ch *check.C) {
+	tm := newTestTrainingModule(ch, "Compliance Module")
+	c := s.createCampaignDependencies(ch)
+	c.TrainingModule = TrainingModule{Name: tm.Name}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(len(c.Results) >= 2, check.Equals, true)
+	for i := range c.Results {
+		ch.Assert(c.Results[i].HandleClickedLink(newHumanEventDetails()), check.Equals, nil)
+	}
+	a, err := GetTrainingAssignment(c.Results[0].RId)
+	ch.Assert(err, check.Equals, nil)
+	_, _, err = a.SubmitQuiz(&c.Results[0], &tm, []int{0, 1})
+	ch.Assert(err, check.Equals, nil)
+
+	report, err := GetTrainingComplianceReport(1, c.Id, time.Now().UTC())
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(report.Total, check.Equals, len(c.Results))
+	ch.Assert(report.Completed, check.Equals, 1)
+	ch.Assert(report.Overdue, check.Equals, 0)
+	ch.Assert(report.Pending, check.Equals, len(c.Results)-1)
+
+	report, err = GetTrainingComplianceReport(0, 0, time.Now().UTC().AddDate(0, 0, 8))
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(report.Overdue, check.Equals, len(c.Results)-1)
+	ch.Assert(report.Overdues[0].ModuleName, check.Equals, tm.Name)
+	ch.Assert(report.Overdues[0].CampaignName, check.Equals, c.Name)
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestTrainingPageValidation(ch *check.C) {
+	tp := TrainingPage{UserId: 1, HTML: "{{.Lure.HTML}}"}
+	ch.Assert(PostTrainingPage(&tp), check.Equals, ErrTrainingPageNameNotSpecified)
+	tp.Name = "Training"
+	tp.Indicators = TrainingIndicators{{Text: " "}}
+	ch.Assert(PostTrainingPage(&tp), check.Equals, ErrTrainingIndicatorTextNotSpecified)
+	tp.Indicators = TrainingIndicators{{Text: "urgent", Explanation: "Creates a sense of urgency"}}
+	tp.HTML = "{{.Lure.Missing}}"
+	ch.Assert(PostTrainingPage(&tp), check.NotNil)
+	tp.HTML = `{{.FirstName}} {{.Lure.Subject}} {{range .Indicators}}{{.Explanation}}{{end}}`
+	ch.Assert(PostTrainingPage(&tp), check.Equals, nil)
+
+	got, err := GetTrainingPageByName("Training", 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Indicators, check.DeepEquals, tp.Indicators)
+}
+
+func (s *ModelsSuite) TestHighlightIndicators(ch *check.C) {
+	indicators := TrainingIndicators{
+		{Text: "example.com", Explanation: "Lookalike <domain>"},
+		{Text: "Urgent", Explanation: "Pressure"},
+	}
+	got := highlightIndicators(`<a href="http://example.com">URGENT: visit example.com</a>`, indicators)
+	expected := `<a href="http://example.com"><mark class="phish-indicator" title="Pressure">URGENT</mark>: visit ` +
+		`<mark class="phish-indicator" title="Lookalike &lt;domain&gt;">example.com</mark></a>`
+	ch.Assert(got, check.Equals, expected)
+	ch.Assert(highlightIndicators("Tom &amp; Jerry", TrainingIndicators{{Text: "Tom & Jerry"}}), check.Equals,
+		`<mark class="phish-indicator" title="">Tom &amp; Jerry</mark>`)
+}
+
+func (s *ModelsSuite) TestCampaignTraining(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.TrainingTrigger = TrainingTriggerSubmit
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrTrainingPageNotSpecified)
+	c.TrainingPage = TrainingPage{Name: "Missing Training"}
+	c.TrainingTrigger = "open"
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidTrainingTrigger)
+	c.TrainingTrigger = TrainingTriggerSubmit
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrTrainingPageNotFound)
+
+	tp := TrainingPage{
+		Name:       "Campaign Training",
+		UserId:     1,
+		HTML:       "{{.Lure.From}} {{.Lure.HTML}}",
+		Indicators: TrainingIndicators{{Text: "Test", Explanation: "Generic"}},
+	}
+	ch.Assert(PostTrainingPage(&tp), check.Equals, nil)
+	c.Template.Text = "Test\n{{.FirstName}}"
+	c.Template.HTML = ""
+	ch.Assert(PutTemplate(&c.Template), check.Equals, nil)
+	c.TrainingPage = TrainingPage{Name: tp.Name}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+
+	c, err := GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(c.TrainingPage.Id, check.Equals, tp.Id)
+	ch.Assert(c.ShowsTraining(TrainingTriggerSubmit), check.Equals, true)
+	ch.Assert(c.ShowsTraining(TrainingTriggerClick), check.Equals, false)
+
+	r := c.Results[0]
+	tc, err := NewTrainingTemplateContext(&c, r)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(tc.Lure.HTML, check.Equals, `<mark class="phish-indicator" title="Generic">Test</mark><br>`+r.FirstName)
+	ch.Assert(strings.HasPrefix(tc.CompleteURL, TrainingPath+"?rid="), check.Equals, true)
+
+	ch.Assert(r.HandleTrainingCompleted(EventDetails{}), check.Equals, nil)
+	ch.Assert(r.HandleTrainingCompleted(EventDetails{}), check.Equals, nil)
+	count := 0
+	db.Model(&Event{}).Where("campaign_id=? and message=?", c.Id, EventTraining).Count(&count)
+	ch.Assert(count, check.Equals, 1)
+}
@@ -133,7 +133,7 @@ func DeleteUser(id int64) error {
 	}
 	log.Infof("Deleting pages for user ID %d", id)
 	// Delete the landing pages
-	pages, err := GetPages(id)
+	pages, err := GetPages(id, LibraryFilter{})
 	if err != nil {
 		return err
 	}
@@ -143,9 +143,31 @@ func DeleteUser(id int64) error {
 			return err
 		}
 	}
+	log.Infof("Deleting training pages for user ID %d", id)
+	trainingPages, err := GetTrainingPages(id)
+	if err != nil {
+		return err
+	}
+	for _, tp := range trainingPages {
+		err = DeleteTrainingPage(tp.Id, 0)
+		if err != nil {
+			return err
+		}
+	}
+	log.Infof("Deleting training modules for user ID %d", id)
+	trainingModules, err := GetTrainingModules(id)
+	if err != nil {
+		return err
+	}
+	for _, tm := range trainingModules {
+		err = DeleteTrainingModule(tm.Id, 0)
+		if err != nil {
+			return err
+		}
+	}
 	// Delete the templates
 	log.Infof("Deleting templates for user ID %d", id)
-	templates, err := GetTemplates(id)
+	templates, err := GetTemplates(id, LibraryFilter{})
 	if err != nil {
 		return err
 	}
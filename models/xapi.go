@@ -0,0 +1,369 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/xapi"
+)
+
+// MaxXAPIAttempts is the number of times a statement is sent to an LRS
+// before giving up on it
+const MaxXAPIAttempts = 10
+
+// MaxXAPIBatchSize is the number of statements sent to an LRS per request
+const MaxXAPIBatchSize = 100
+
+// The delay before retrying a statement starts at xapiRetryDelay and doubles
+// after every failed attempt, up to xapiMaxRetryDelay
+const (
+	xapiRetryDelay    = time.Minute
+	xapiMaxRetryDelay = time.Hour
+)
+
+// ErrActivityIRINotSpecified indicates there was no activity IRI specified
+var ErrActivityIRINotSpecified = errors.New("Activity IRI can't be empty")
+
+// LRS is a Learning Record Store which simulation events are sent to as
+// xAPI statements. The password is only accepted when creating or updating
+// the LRS, and is stored encrypted.
+type LRS struct {
+	Id                int64  `json:"id" gorm:"column:id; primary_key:yes"`
+	Name              string `json:"name"`
+	URL               string `json:"url"`
+	Username          string `json:"username"`
+	Password          string `json:"password,omitempty" sql:"-"`
+	EncryptedPassword string `json:"-" gorm:"column:password"`
+	// ActivityIRI is the base IRI of the activities statements are about,
+	// such as https://lms.example.com/phishing
+	ActivityIRI string `json:"activity_iri"`
+	IsActive    bool   `json:"is_active"`
+}
+
+// TableName specifies the database tablename for Gorm to use
+func (l LRS) TableName() string {
+	return "learning_record_stores"
+}
+
+// XAPIStatement is a statement queued to be sent to an LRS
+type XAPIStatement struct {
+	Id          int64     `json:"id"`
+	LRSId       int64     `json:"lrs_id"`
+	StatementId string    `json:"statement_id"`
+	Statement   string    `json:"statement"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error"`
+	CreatedDate time.Time `json:"created_date"`
+}
+
+// TableName specifies the database tablename for Gorm to use
+func (s XAPIStatement) TableName() string {
+	return "xapi_statements"
+}
+
+// Validate ensures that an LRS contains the appropriate details
+func (l *LRS) Validate() error {
+	if l.Name == "" {
+		return ErrNameNotSpecified
+	}
+	if l.URL == "" {
+		return ErrURLNotSpecified
+	}
+	if l.ActivityIRI == "" {
+		return ErrActivityIRINotSpecified
+	}
+	return nil
+}
+
+// setPassword encrypts a newly provided password for storage. If no new
+// password was provided, the existing encrypted password is kept.
+func (l *LRS) setPassword(existing string) error {
+	if l.Password == "" {
+		l.EncryptedPassword = existing
+		return nil
+	}
+	password, err := encryptSecret(l.Password)
+	if err != nil {
+		return err
+	}
+	l.EncryptedPassword = password
+	l.Password = ""
+	return nil
+}
+
+// EndPoint returns the endpoint used to send statements to the LRS
+func (l *LRS) EndPoint() (xapi.EndPoint, error) {
+	e := xapi.EndPoint{URL: l.URL, Username: l.Username}
+	if l.EncryptedPassword == "" {
+		return e, nil
+	}
+	var err error
+	e.Password, err = decryptSecret(l.EncryptedPassword)
+	return e, err
+}
+
+// GetLRSs returns the learning record stores
+func GetLRSs() ([]LRS, error) {
+	ls := []LRS{}
+	err := db.Find(&ls).Error
+	return ls, err
+}
+
+// GetActiveLRSs returns the active learning record stores
+func GetActiveLRSs() ([]LRS, error) {
+	ls := []LRS{}
+	err := db.Where("is_active=?", true).Find(&ls).Error
+	return ls, err
+}
+
+// GetLRS returns the learning record store that the given id corresponds to.
+// If no LRS is found, an error is returned.
+func GetLRS(id int64) (LRS, error) {
+	l := LRS{}
+	err := db.Where("id=?", id).First(&l).Error
+	return l, err
+}
+
+// PostLRS creates a new learning record store in the database.
+func PostLRS(l *LRS) error {
+	err := l.Validate()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = l.setPassword("")
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = db.Save(l).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// PutLRS edits an existing learning record store in the database. The
+// stored password is kept unless a new one is provided.
+func PutLRS(l *LRS) error {
+	existing, err := GetLRS(l.Id)
+	if err != nil {
+		return err
+	}
+	err = l.Validate()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = l.setPassword(existing.EncryptedPassword)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return db.Save(l).Error
+}
+
+// DeleteLRS deletes an existing learning record store and the statements
+// still queued for it.
+func DeleteLRS(id int64) error {
+	err := db.Where("lrs_id=?", id).Delete(&XAPIStatement{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return db.Where("id=?", id).Delete(&LRS{}).Error
+}
+
+// GetXAPIStatements returns the statements queued for the given LRS,
+// including the ones which were given up on after MaxXAPIAttempts.
+func GetXAPIStatements(lrsId int64) ([]XAPIStatement, error) {
+	ss := []XAPIStatement{}
+	err := db.Where("lrs_id=?", lrsId).Order("id asc").Find(&ss).Error
+	return ss, err
+}
+
+// xapiActivity returns the activity under the LRS's activity IRI with the
+// given path
+func (l *LRS) xapiActivity(name string, path ...string) xapi.Activity {
+	iri := strings.TrimSuffix(l.ActivityIRI, "/") + "/" + strings.Join(path, "/")
+	return xapi.NewActivity(iri, name)
+}
+
+// xapiStatement converts a timeline event into the statement sent to the
+// LRS. Events which aren't exported return false.
+func (l *LRS) xapiStatement(e *Event, c *Campaign, r *Result) (xapi.Statement, bool) {
+	yes, no := true, false
+	campaign := l.xapiActivity(c.Name, "campaigns", c.Rid)
+	s := xapi.Statement{
+		Actor:     xapi.NewActor(strings.TrimSpace(r.FirstName+" "+r.LastName), e.Email),
+		Object:    campaign,
+		Timestamp: e.Time,
+	}
+	switch e.Message {
+	case EventClicked, EventDataSubmit:
+		s.Verb = xapi.VerbFailed
+		s.Result = &xapi.Result{Success: &no, Response: e.Message}
+	case EventReported:
+		s.Verb = xapi.VerbPassed
+		s.Result = &xapi.Result{Success: &yes, Response: e.Message}
+	case EventTraining:
+		s.Verb = xapi.VerbCompleted
+		s.Object = l.xapiActivity(c.Name+" Training", "campaigns", c.Rid, TrainingPath)
+		s.Result = &xapi.Result{Completion: &yes}
+		s.Context = &xapi.Context{ContextActivities: xapi.ContextActivities{Parent: []xapi.Activity{campaign}}}
+	case EventModuleCompleted:
+		a, err := GetTrainingAssignment(r.RId)
+		if err != nil {
+			return s, false
+		}
+		tm, err := GetTrainingModule(a.ModuleId, 0)
+		if err != nil {
+			return s, false
+		}
+		details := EventQuizScore{}
+		json.Unmarshal([]byte(e.Details), &details)
+		s.Verb = xapi.VerbCompleted
+		s.Object = l.xapiActivity(tm.Name, "training", "modules", fmt.Sprintf("%d", tm.Id))
+		s.Result = &xapi.Result{
+			Score:      &xapi.Score{Scaled: float64(details.Score) / 100, Raw: details.Score, Min: 0, Max: 100},
+			Success:    &details.Passed,
+			Completion: &yes,
+		}
+		s.Context = &xapi.Context{ContextActivities: xapi.ContextActivities{Parent: []xapi.Activity{campaign}}}
+	default:
+		return s, false
+	}
+	return s, true
+}
+
+// queueXAPIStatements queues the statement for an event to be sent to each
+// active LRS by the worker.
+func queueXAPIStatements(e *Event) error {
+	ls, err := GetActiveLRSs()
+	if err != nil || len(ls) == 0 {
+		return err
+	}
+	switch e.Message {
+	case EventClicked, EventDataSubmit, EventReported, EventTraining, EventModuleCompleted:
+	default:
+		return nil
+	}
+	// Automated clicks aren't learning experiences
+	if e.Classification != "" && e.Classification != ClassificationHuman {
+		return nil
+	}
+	c := Campaign{}
+	err = db.Where("id=?", e.CampaignId).First(&c).Error
+	if err != nil {
+		return err
+	}
+	r := Result{}
+	err = db.Where("campaign_id=? and email=?", e.CampaignId, e.Email).First(&r).Error
+	if err != nil {
+		return err
+	}
+	for _, l := range ls {
+		s, ok := l.xapiStatement(e, &c, &r)
+		if !ok {
+			continue
+		}
+		s.ID, err = xapi.NewStatementID()
+		if err != nil {
+			return err
+		}
+		sj, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		err = db.Save(&XAPIStatement{
+			LRSId:       l.Id,
+			StatementId: s.ID,
+			Statement:   string(sj),
+			NextAttempt: e.Time,
+			CreatedDate: e.Time,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xapiRetryBackoff returns how long to wait before retrying a statement
+// which has failed the given number of times
+func xapiRetryBackoff(attempts int) time.Duration {
+	delay := xapiRetryDelay
+	for i := 1; i < attempts && delay < xapiMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > xapiMaxRetryDelay {
+		delay = xapiMaxRetryDelay
+	}
+	return delay
+}
+
+// SendQueuedXAPIStatements sends the statements which are due to their LRS.
+// Statements are removed from the queue once the LRS accepts them, otherwise
+// they're retried with an exponential backoff until MaxXAPIAttempts is
+// reached.
+func SendQueuedXAPIStatements(t time.Time) error {
+	ls, err := GetActiveLRSs()
+	if err != nil {
+		return err
+	}
+	for _, l := range ls {
+		ss := []XAPIStatement{}
+		err = db.Where("lrs_id=? and next_attempt <= ? and attempts < ?", l.Id, t, MaxXAPIAttempts).
+			Order("id asc").Limit(MaxXAPIBatchSize).Find(&ss).Error
+		if err != nil {
+			return err
+		}
+		statements := []xapi.Statement{}
+		queued := []XAPIStatement{}
+		for _, s := range ss {
+			statement := xapi.Statement{}
+			err = json.Unmarshal([]byte(s.Statement), &statement)
+			if err != nil {
+				// A statement which can't be decoded will never be accepted
+				log.Error(err)
+				s.Attempts = MaxXAPIAttempts
+				s.LastError = err.Error()
+				db.Save(&s)
+				continue
+			}
+			statements = append(statements, statement)
+			queued = append(queued, s)
+		}
+		if len(statements) == 0 {
+			continue
+		}
+		var endPoint xapi.EndPoint
+		endPoint, err = l.EndPoint()
+		if err == nil {
+			err = xapi.Send(endPoint, statements)
+		}
+		if err == nil {
+			for _, s := range queued {
+				if dberr := db.Delete(&s).Error; dberr != nil {
+					return dberr
+				}
+			}
+			continue
+		}
+		log.Errorf("error sending xAPI statements to LRS %s: %v", l.Name, err)
+		for _, s := range queued {
+			s.Attempts++
+			s.LastError = err.Error()
+			s.NextAttempt = t.Add(xapiRetryBackoff(s.Attempts))
+			if dberr := db.Save(&s).Error; dberr != nil {
+				return dberr
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/xapi"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestLRSValidation(ch *check.C) {
+	l := LRS{}
+	ch.Assert(l.Validate(), check.Equals, ErrNameNotSpecified)
+	l.Name = "LMS"
+	ch.Assert(l.Validate(), check.Equals, ErrURLNotSpecified)
+	l.URL = "https://lms.example.com/xapi"
+	ch.Assert(l.Validate(), check.Equals, ErrActivityIRINotSpecified)
+	l.ActivityIRI = "https://lms.example.com/phishing"
+	ch.Assert(l.Validate(), check.Equals, nil)
+}
+
+func (s *ModelsSuite) TestLRSPassword(ch *check.C) {
+	l := LRS{Name: "LMS", URL: "https://lms.example.com/xapi", ActivityIRI: "https://lms.example.com/phishing", Username: "key", Password: "secret"}
+	ch.Assert(PostLRS(&l), check.Equals, ErrEncryptionKeyNotSet)
+
+	conf.EncryptionKey = "test encryption key"
+	defer func() { conf.EncryptionKey = "" }()
+	ch.Assert(PostLRS(&l), check.Equals, nil)
+
+	// The password should only be stored encrypted
+	stored, err := GetLRS(l.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(stored.Password, check.Equals, "")
+	ch.Assert(stored.EncryptedPassword, check.Not(check.Equals), "")
+	ch.Assert(stored.EncryptedPassword, check.Not(check.Equals), "secret")
+	content, err := json.Marshal(stored)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.Contains(string(content), "password"), check.Equals, false)
+
+	// Updating the LRS without providing a password keeps the existing one
+	stored.Name = "Updated LMS"
+	ch.Assert(PutLRS(&stored), check.Equals, nil)
+	stored, err = GetLRS(l.Id)
+	ch.Assert(err, check.Equals, nil)
+	e, err := stored.EndPoint()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(e.Username, check.Equals, "key")
+	ch.Assert(e.Password, check.Equals, "secret")
+
+	stored.Password = "new secret"
+	ch.Assert(PutLRS(&stored), check.Equals, nil)
+	stored, err = GetLRS(l.Id)
+	ch.Assert(err, check.Equals, nil)
+	e, err = stored.EndPoint()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(e.Password, check.Equals, "new secret")
+}
+
+func (s *ModelsSuite) TestXAPIStatements(ch *check.C) {
+	received := []xapi.Statement{}
+	available := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		statements := []xapi.Statement{}
+		json.NewDecoder(r.Body).Decode(&statements)
+		received = append(received, statements...)
+	}))
+	defer ts.Close()
+	l := LRS{Name: "LMS", URL: ts.URL, ActivityIRI: "https://lms.example.com/phishing/", IsActive: true}
+	ch.Assert(PostLRS(&l), check.Equals, nil)
+
+	c := s.createCampaignDependencies(ch)
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	r := c.Results[0]
+	ch.Assert(r.HandleEmailOpened(newHumanEventDetails()), check.Equals, nil)
+	ch.Assert(r.HandleClickedLink(newHumanEventDetails()), check.Equals, nil)
+	ch.Assert(r.HandleFormSubmit(EventDetails{}), check.Equals, nil)
+	ss, err := GetXAPIStatements(l.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ss), check.Equals, 2)
+
+	// The LRS is unavailable, so the statements are retried later
+	now := time.Now().UTC()
+	ch.Assert(SendQueuedXAPIStatements(now), check.Equals, nil)
+	ss, err = GetXAPIStatements(l.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ss), check.Equals, 2)
+	ch.Assert(ss[0].Attempts, check.Equals, 1)
+	ch.Assert(ss[0].NextAttempt.Sub(now), check.Equals, time.Minute)
+	ch.Assert(ss[0].LastError != "", check.Equals, true)
+	ch.Assert(SendQueuedXAPIStatements(now), check.Equals, nil)
+	ss, _ = GetXAPIStatements(l.Id)
+	ch.Assert(ss[0].Attempts, check.Equals, 1)
+
+	available = true
+	ch.Assert(SendQueuedXAPIStatements(now.Add(time.Minute)), check.Equals, nil)
+	ss, err = GetXAPIStatements(l.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ss), check.Equals, 0)
+	ch.Assert(len(received), check.Equals, 2)
+	ch.Assert(received[0].Verb.ID, check.Equals, xapi.VerbFailed.ID)
+	ch.Assert(received[0].Actor.Mbox, check.Equals, "mailto:"+r.Email)
+	ch.Assert(received[0].Object.ID, check.Equals, "https://lms.example.com/phishing/campaigns/"+c.Rid)
+	ch.Assert(*received[0].Result.Success, check.Equals, false)
+	ch.Assert(received[1].Result.Response, check.Equals, EventDataSubmit)
+
+	// Reporting the email passes the simulation
+	statement, ok := l.xapiStatement(&Event{Email: r.Email, Message: EventReported}, &c, &r)
+	ch.Assert(ok, check.Equals, true)
+	ch.Assert(statement.Verb.ID, check.Equals, xapi.VerbPassed.ID)
+	ch.Assert(*statement.Result.Success, check.Equals, true)
+	_, ok = l.xapiStatement(&Event{Email: r.Email, Message: EventOpened}, &c, &r)
+	ch.Assert(ok, check.Equals, false)
+}
+
+func (s *ModelsSuite) TestXAPIModuleCompleted(ch *check.C) {
+	l := LRS{Name: "LMS", URL: "http://127.0.0.1:1", ActivityIRI: "https://lms.example.com/phishing", IsActive: true}
+	ch.Assert(PostLRS(&l), check.Equals, nil)
+	tm := newTestTrainingModule(ch, "xAPI Module")
+	c := s.createCampaignDependencies(ch)
+	c.TrainingModule = TrainingModule{Name: tm.Name}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	r := c.Results[0]
+	ch.Assert(r.HandleClickedLink(newHumanEventDetails()), check.Equals, nil)
+	a, err := GetTrainingAssignment(r.RId)
+	ch.Assert(err, check.Equals, nil)
+	_, _, err = a.SubmitQuiz(&r, &tm, []int{0, 1})
+	ch.Assert(err, check.Equals, nil)
+
+	ss, err := GetXAPIStatements(l.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ss), check.Equals, 2)
+	statement := xapi.Statement{}
+	ch.Assert(json.Unmarshal([]byte(ss[1].Statement), &statement), check.Equals, nil)
+	ch.Assert(statement.ID, check.Equals, ss[1].StatementId)
+	ch.Assert(statement.Verb.ID, check.Equals, xapi.VerbCompleted.ID)
+	ch.Assert(statement.Object.ID, check.Equals, "https://lms.example.com/phishing/training/modules/"+fmt.Sprintf("%d", tm.Id))
+	ch.Assert(statement.Result.Score.Scaled, check.Equals, 1.0)
+	ch.Assert(*statement.Result.Success, check.Equals, true)
+	ch.Assert(statement.Context.ContextActivities.Parent[0].ID, check.Equals, "https://lms.example.com/phishing/campaigns/"+c.Rid)
+
+	// Statements are given up on after MaxXAPIAttempts
+	now := time.Now().UTC()
+	for i := 0; i < MaxXAPIAttempts+1; i++ {
+		ch.Assert(SendQueuedXAPIStatements(now.Add(time.Duration(i)*time.Hour)), check.Equals, nil)
+	}
+	ss, _ = GetXAPIStatements(l.Id)
+	ch.Assert(ss[0].Attempts, check.Equals, MaxXAPIAttempts)
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"io"
 )
 
@@ -33,3 +34,29 @@ func EncryptRD(text string, keyString string) (string, error) {
 	// return base64 encoded string
 	return base64.RawURLEncoding.EncodeToString(ciphertext), nil
 }
+
+// DecryptRD decrypts a base64 encoded string produced by EncryptRD using the
+// same key.
+func DecryptRD(text string, keyString string) (string, error) {
+	key := []byte(keyString)
+	ciphertext, err := base64.RawURLEncoding.DecodeString(text)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(ciphertext) < aes.BlockSize {
+		return "", errors.New("ciphertext too short")
+	}
+	iv := ciphertext[:aes.BlockSize]
+	plaintext := make([]byte, len(ciphertext)-aes.BlockSize)
+
+	stream := cipher.NewCFBDecrypter(block, iv)
+	stream.XORKeyStream(plaintext, ciphertext[aes.BlockSize:])
+
+	return string(plaintext), nil
+}
@@ -475,6 +475,11 @@ function renderTimeline(data) {
             if (event.details) {
                 details = JSON.parse(event.details)
 
+                // Opens and clicks made by scanners, image proxies and link
+                // previews aren't counted in the campaign statistics
+                if (details.classification && details.classification != "human") {
+                    results += ' <span class="label label-default">' + escapeHtml(details.classification.replace("_", " ")) + '</span>'
+                }
                 if (event.message == "Clicked Link" || event.message == "Submitted Data") {
                     deviceView = renderDevice(details)
                     if (deviceView) {
@@ -207,6 +207,8 @@ function generateStatsPieCharts(campaigns) {
     var stats_data = []
     var stats_series_data = {}
     var total = 0
+    // Bounced emails were never received, so they're excluded from rates
+    var delivered = 0
 
     // Dynamic Stats Mapping
     var mapping = $.extend({}, statsMapping);
@@ -221,6 +223,10 @@ function generateStatsPieCharts(campaigns) {
                 total += count
                 return true
             }
+            if (status == "delivered") {
+                delivered += count
+                return true
+            }
             if (!stats_series_data[status]) {
                 stats_series_data[status] = count;
             } else {
@@ -228,6 +234,9 @@ function generateStatsPieCharts(campaigns) {
             }
         })
     })
+    if (delivered > 0) {
+        total = delivered
+    }
     $.each(stats_series_data, function (status, count) {
         // I don't like this, but I guess it'll have to work.
         // Turns submitted_data into Submitted Data
@@ -278,7 +287,7 @@ function generateTimelineChart(campaigns) {
         campaign.y = 0
         // Clicked events also contain our data submitted events
         campaign.y += campaign.stats.clicked
-        campaign.y = Math.floor((campaign.y / campaign.stats.total) * 100)
+        campaign.y = Math.floor((campaign.y / (campaign.stats.delivered || campaign.stats.total)) * 100)
         // Add the data to the overview chart
         overview_data.push({
             campaign_id: campaign.id,
@@ -1,4 +1,8 @@
 var group = {}
+// targetDetails holds the fields of the group's targets which aren't shown in
+// the table, keyed by email address or phone number, so that saving the group
+// keeps them
+var targetDetails = {}
 var groupId = window.location.pathname.split('/').pop()
 if (groupId == "group" || groupId == "new" || isNaN(parseInt(groupId))) {
     groupId = null
@@ -43,11 +47,17 @@ function save() {
     // Standard Save Flow
     var targets = []
     $.each($("#targetsTable").DataTable().rows().data(), function (i, target) {
+        var email = unescapeHtml(target[2])
+        var phone = unescapeHtml(target[3])
+        var details = targetDetails[email || phone] || {}
         targets.push({
             first_name: unescapeHtml(target[0]),
             last_name: unescapeHtml(target[1]),
-            email: unescapeHtml(target[2]),
-            position: unescapeHtml(target[3])
+            email: email,
+            phone: phone,
+            position: unescapeHtml(target[4]),
+            language: details.language || "",
+            attributes: details.attributes
         })
     })
     var groupData = {
@@ -76,25 +86,28 @@ function save() {
         })
 }
 
-function addTarget(firstNameInput, lastNameInput, emailInput, positionInput) {
+function addTarget(firstNameInput, lastNameInput, emailInput, phoneInput, positionInput) {
     // Create new data row.
     var email = escapeHtml(emailInput).toLowerCase();
+    var phone = escapeHtml(phoneInput);
     var newRow = [
         escapeHtml(firstNameInput),
         escapeHtml(lastNameInput),
         email,
+        phone,
         escapeHtml(positionInput),
         '<span style="cursor:pointer;"><i class="fa fa-trash-o"></i></span>'
     ];
 
-    // Check table to see if email already exists.
+    // Check table to see if the recipient already exists. Targets are
+    // identified by email address, or by phone number if they have none.
     var targetsTable = $("#targetsTable").DataTable();
     var existingRowIndex = targetsTable
-        .column(2, {
+        .column(email ? 2 : 3, {
             order: "index"
-        }) // Email column has index of 2
+        }) // Email and phone columns have indexes of 2 and 3
         .data()
-        .indexOf(email);
+        .indexOf(email || phone);
     // Update or add new row as necessary.
     if (existingRowIndex >= 0) {
         targetsTable
@@ -112,6 +125,7 @@ var downloadCSVTemplate = function () {
         'First Name': 'Example',
         'Last Name': 'User',
         'Email': 'foobar@example.com',
+        'Phone': '+14155550100',
         'Position': 'Systems Administrator'
     }]
     var filename = 'group_template.csv'
@@ -246,10 +260,15 @@ function loadGroup(id) {
             targetsTable.clear();
             var targetRows = []
             $.each(group.targets, function (i, record) {
+                targetDetails[record.email || record.phone] = {
+                    language: record.language,
+                    attributes: record.attributes
+                }
                 targetRows.push([
                     escapeHtml(record.first_name),
                     escapeHtml(record.last_name),
                     escapeHtml(record.email),
+                    escapeHtml(record.phone),
                     escapeHtml(record.position),
                     '<span style="cursor:pointer;"><i class="fa fa-trash-o"></i></span>'
                 ])
@@ -280,10 +299,15 @@ $(document).ready(function () {
             targetForm.reportValidity()
             return
         }
+        if (!$("#email").val() && !$("#phone").val()) {
+            modalError("An email address or phone number is required")
+            return false
+        }
         addTarget(
             $("#firstName").val(),
             $("#lastName").val(),
             $("#email").val(),
+            $("#phone").val(),
             $("#position").val());
         targetsTable.draw();
 
@@ -340,6 +364,7 @@ $(document).ready(function () {
                             record.first_name || "",
                             record.last_name || "",
                             record.email || "",
+                            record.phone || "",
                             record.position || "");
                     });
                     targetsTable.draw();
@@ -65,6 +65,36 @@ function importSite() {
     }
 }
 
+// preview renders the page being edited for the recipient entered in the
+// preview modal. The page is shown in a sandboxed frame, and submitted forms
+// are reported back by the preview instead of being sent.
+function preview() {
+    var data = {
+        html: CKEDITOR.instances["html_editor"].getData(),
+        first_name: $("#preview_first_name").val(),
+        last_name: $("#preview_last_name").val(),
+        email: $("#preview_email").val()
+    };
+    var request;
+    if (page.id) {
+        request = api.pageId.preview(page.id, data);
+    } else {
+        request = api.pages.preview(data);
+    }
+    request
+        .done(function (result) {
+            $("#preview_submission").hide();
+            $("#preview_frame").attr("srcdoc", result.html);
+        })
+        .fail(function (data) {
+            var message = "An error occurred";
+            if (data.responseJSON && data.responseJSON.message) {
+                message = data.responseJSON.message;
+            }
+            Swal.fire("Error", message, "error");
+        });
+}
+
 $(document).ready(function () {
     $("#html_editor").ckeditor();
     // Use the autocomplete plugin
@@ -79,6 +109,22 @@ $(document).ready(function () {
 
     $("#submitButton").click(save);
     $("#importSubmitButton").click(importSite);
+    $("#previewButton").click(function () {
+        $("#previewModal").modal("show");
+        preview();
+    });
+    $("#previewRefreshButton").click(preview);
+    window.addEventListener("message", function (e) {
+        // The sandboxed frame has an opaque origin, so messages are matched
+        // by their source instead
+        if (e.source !== $("#preview_frame")[0].contentWindow || !e.data || e.data.type !== "page-preview-submit") {
+            return;
+        }
+        var fields = $.map(e.data.fields, function (value, name) {
+            return "<b>" + escapeHtml(name) + ":</b> " + escapeHtml(value);
+        });
+        $("#preview_submission").html("The form was submitted with:<br>" + fields.join("<br>")).show();
+    });
 
     // Check if we are editing an existing page or copying one
     var path = window.location.pathname;
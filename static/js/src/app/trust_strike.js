@@ -216,6 +216,10 @@ var api = {
         // post() - Posts a page to POST /pages
         post: function (page) {
             return query("/pages/", "POST", page)
+        },
+        // preview() - Renders unsaved page HTML at POST /pages/preview
+        preview: function (data) {
+            return query("/pages/preview", "POST", data)
         }
     },
     // pageId contains the endpoints for /pages/:id
@@ -231,6 +235,10 @@ var api = {
         // delete() - Deletes a page at DELETE /pages/:id
         delete: function (id) {
             return query("/pages/" + id, "DELETE")
+        },
+        // preview() - Renders a page at POST /pages/:id/preview
+        preview: function (id, data) {
+            return query("/pages/" + id + "/preview", "POST", data)
         }
     },
     // SMTP contains the endpoints for /smtp
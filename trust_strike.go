@@ -18,6 +18,7 @@ import (
 	"github.com/7nikhilkamboj/TrustStrike-Simulation/middleware"
 	"github.com/7nikhilkamboj/TrustStrike-Simulation/models"
 	"github.com/7nikhilkamboj/TrustStrike-Simulation/webhook"
+	"github.com/7nikhilkamboj/TrustStrike-Simulation/xapi"
 )
 
 const (
@@ -64,6 +65,9 @@ func main() {
 	webhook.SetTransport(&http.Transport{
 		DialContext: dialer.Dialer().DialContext,
 	})
+	xapi.SetTransport(&http.Transport{
+		DialContext: dialer.Dialer().DialContext,
+	})
 
 	err = log.Setup(conf.Logging)
 	if err != nil {
@@ -84,7 +88,14 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// Start the server
+	// Start the servers
+	startPhishingServer := func(currentConf *config.Config) *controllers.PhishingServer {
+		server := controllers.NewPhishingServer(currentConf.PhishConf, controllers.PhishingServerOptions(currentConf)...)
+		if *mode == modePhish || *mode == modeAll {
+			go server.Start()
+		}
+		return server
+	}
 	startServer := func(currentConf *config.Config) (*controllers.AdminServer, *imap.Monitor) {
 		adminOptions := []controllers.AdminServerOption{}
 		if *disableMailer {
@@ -107,6 +118,7 @@ func main() {
 	}
 
 	adminServer, imapMonitor := startServer(conf)
+	phishServer := startPhishingServer(conf)
 
 	// Start the config watcher
 	go func() {
@@ -141,9 +153,13 @@ func main() {
 					adminServer.Shutdown()
 					imapMonitor.Shutdown()
 				}
+				if *mode == modePhish || *mode == modeAll {
+					phishServer.Shutdown()
+				}
 
 				// Start new server
 				adminServer, imapMonitor = startServer(conf)
+				phishServer = startPhishingServer(conf)
 				log.Info("Server restarted with new configuration")
 			}
 		}
@@ -158,4 +174,7 @@ func main() {
 		adminServer.Shutdown()
 		imapMonitor.Shutdown()
 	}
+	if *mode == modePhish || *mode == modeAll {
+		phishServer.Shutdown()
+	}
 }
@@ -17,6 +17,7 @@ import (
 	"net/mail"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	log "github.com/7nikhilkamboj/TrustStrike-Simulation/logger"
@@ -29,6 +30,8 @@ var (
 	lastNameRegex  = regexp.MustCompile(`(?i)last[\s_-]*name`)
 	emailRegex     = regexp.MustCompile(`(?i)email`)
 	positionRegex  = regexp.MustCompile(`(?i)position`)
+	phoneRegex     = regexp.MustCompile(`(?i)phone|mobile`)
+	languageRegex  = regexp.MustCompile(`(?i)language|locale`)
 )
 
 // ParseMail takes in an HTTP Request and returns an Email object
@@ -50,13 +53,44 @@ func ParseJSON(r *http.Request, v interface{}) error {
 	return json.NewDecoder(r.Body).Decode(v)
 }
 
-// ParseCSV contains the logic to parse the user provided csv file containing Target entries
+// CSVRowError is an error for a single row of a CSV file
+type CSVRowError struct {
+	Row int
+	Err error
+}
+
+// Error implements the error interface
+func (e *CSVRowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// CSVErrors is returned by ParseCSV when some rows couldn't be imported.
+// The targets from the other rows are still returned.
+type CSVErrors []*CSVRowError
+
+// Error implements the error interface
+func (e CSVErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ParseCSV contains the logic to parse the user provided csv file containing Target entries.
+// Phone numbers are normalized to the E.164 format using the configured default
+// country code. Rows with an invalid email address or phone number aren't
+// imported, and are returned as CSVErrors along with the other targets. Any
+// other columns are imported as custom attributes named after the column
+// header.
 func ParseCSV(r *http.Request) ([]models.Target, error) {
+	countryCode := models.GetDefaultCountryCode()
 	mr, err := r.MultipartReader()
 	ts := []models.Target{}
 	if err != nil {
 		return ts, err
 	}
+	var rowErrors CSVErrors
 	for {
 		part, err := mr.NextPart()
 		if err == io.EOF {
@@ -77,10 +111,15 @@ func ParseCSV(r *http.Request) ([]models.Target, error) {
 		li := -1
 		ei := -1
 		pi := -1
+		phi := -1
+		lai := -1
+		attributes := map[int]string{}
 		fn := ""
 		ln := ""
 		ea := ""
 		ps := ""
+		ph := ""
+		la := ""
 		for i, v := range record {
 			switch {
 			case firstNameRegex.MatchString(v):
@@ -91,16 +130,25 @@ func ParseCSV(r *http.Request) ([]models.Target, error) {
 				ei = i
 			case positionRegex.MatchString(v):
 				pi = i
+			case phoneRegex.MatchString(v):
+				phi = i
+			case languageRegex.MatchString(v):
+				lai = i
+			case strings.TrimSpace(v) != "":
+				attributes[i] = strings.ToLower(strings.TrimSpace(v))
 			}
 		}
-		if fi == -1 && li == -1 && ei == -1 && pi == -1 {
+		if fi == -1 && li == -1 && ei == -1 && pi == -1 && phi == -1 {
 			continue
 		}
+		// The header occupies the first row
+		row := 1
 		for {
 			record, err := reader.Read()
 			if err == io.EOF {
 				break
 			}
+			row++
 			if fi != -1 && len(record) > fi {
 				fn = record[fi]
 			}
@@ -108,26 +156,62 @@ func ParseCSV(r *http.Request) ([]models.Target, error) {
 				ln = record[li]
 			}
 			if ei != -1 && len(record) > ei {
-				csvEmail, err := mail.ParseAddress(record[ei])
-				if err != nil {
-					continue
+				ea = ""
+				// SMS targets may leave the email address empty
+				if record[ei] != "" || phi == -1 {
+					csvEmail, err := mail.ParseAddress(record[ei])
+					if err != nil {
+						rowErrors = append(rowErrors, &CSVRowError{Row: row, Err: fmt.Errorf("invalid email address %q", record[ei])})
+						continue
+					}
+					ea = csvEmail.Address
 				}
-				ea = csvEmail.Address
 			}
 			if pi != -1 && len(record) > pi {
 				ps = record[pi]
 			}
+			if phi != -1 && len(record) > phi {
+				ph = ""
+				if record[phi] != "" {
+					ph, err = models.NormalizePhoneNumber(record[phi], countryCode)
+					if err != nil {
+						rowErrors = append(rowErrors, &CSVRowError{Row: row, Err: fmt.Errorf("invalid phone number %q", record[phi])})
+						continue
+					}
+				}
+			}
+			if lai != -1 && len(record) > lai {
+				la = record[lai]
+			}
+			var attrs models.RecipientAttributes
+			for i, name := range attributes {
+				if len(record) > i && record[i] != "" {
+					if attrs == nil {
+						attrs = models.RecipientAttributes{}
+					}
+					attrs[name] = record[i]
+				}
+			}
+			if ea == "" && ph == "" {
+				continue
+			}
 			t := models.Target{
 				BaseRecipient: models.BaseRecipient{
-					FirstName: fn,
-					LastName:  ln,
-					Email:     ea,
-					Position:  ps,
+					FirstName:  fn,
+					LastName:   ln,
+					Email:      ea,
+					Position:   ps,
+					Phone:      ph,
+					Language:   la,
+					Attributes: attrs,
 				},
 			}
 			ts = append(ts, t)
 		}
 	}
+	if len(rowErrors) > 0 {
+		return ts, rowErrors
+	}
 	return ts, nil
 }
 
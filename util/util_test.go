@@ -60,3 +60,68 @@ func TestParseCSVEmail(t *testing.T) {
 		t.Fatalf("Incorrect targets received. Expected: %#v\nGot: %#v", expected, got)
 	}
 }
+
+func TestParseCSVPhone(t *testing.T) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("files[]", "example.csv")
+	if err != nil {
+		t.Fatalf("error creating form file: %v", err)
+	}
+	part.Write([]byte("First Name,Last Name,Email,Phone\n"))
+	part.Write([]byte("John,Doe,,(415) 555-0100\n"))
+	part.Write([]byte("Jane,Doe,janedoe@example.com,not a number\n"))
+	writer.Close()
+	r, err := http.NewRequest("POST", "http://127.0.0.1", body)
+	if err != nil {
+		t.Fatalf("error building CSV request: %v", err)
+	}
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	// The row with an invalid phone number is reported rather than dropped
+	got, err := ParseCSV(r)
+	rowErrors, ok := err.(CSVErrors)
+	if !ok || len(rowErrors) != 1 {
+		t.Fatalf("expected an error for the invalid phone number. got %v", err)
+	}
+	if rowErrors[0].Row != 3 {
+		t.Fatalf("incorrect row for invalid phone number. expected 3 got %d", rowErrors[0].Row)
+	}
+	expectedLength := 1
+	if len(got) != expectedLength {
+		t.Fatalf("invalid number of results received from CSV. expected %d got %d", expectedLength, len(got))
+	}
+	expected := "+14155550100"
+	if got[0].Phone != expected {
+		t.Fatalf("incorrect phone number received. expected %s got %s", expected, got[0].Phone)
+	}
+}
+
+func TestParseCSVLanguage(t *testing.T) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("files[]", "example.csv")
+	if err != nil {
+		t.Fatalf("error creating form file: %v", err)
+	}
+	part.Write([]byte("First Name,Last Name,Email,Language\n"))
+	part.Write([]byte("John,Doe,johndoe@example.com,de-DE\n"))
+	writer.Close()
+	r, err := http.NewRequest("POST", "http://127.0.0.1", body)
+	if err != nil {
+		t.Fatalf("error building CSV request: %v", err)
+	}
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	got, err := ParseCSV(r)
+	if err != nil {
+		t.Fatalf("error parsing CSV: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("invalid number of results received from CSV. expected 1 got %d", len(got))
+	}
+	expected := "de-DE"
+	if got[0].Language != expected {
+		t.Fatalf("incorrect language received. expected %s got %s", expected, got[0].Language)
+	}
+}
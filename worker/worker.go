@@ -63,11 +63,14 @@ func (w *DefaultWorker) processCampaigns(t time.Time) error {
 		return err
 	}
 	campaignCache := make(map[int64]models.Campaign)
-	// We'll group the maillogs by campaign ID to (roughly) group
-	// them by sending profile. This lets the mailer re-use the Sender
-	// instead of having to re-connect to the SMTP server for every
-	// email.
-	msg := make(map[int64][]mailer.Mail)
+	// We'll group the maillogs by campaign ID and sending profile. This lets
+	// the mailer re-use the Sender instead of having to re-connect to the
+	// SMTP server for every email.
+	type mailGroup struct {
+		campaignID int64
+		smtpID     int64
+	}
+	msg := make(map[mailGroup][]mailer.Mail)
 	for _, m := range ms {
 		// We cache the campaign here to greatly reduce the time it takes to
 		// generate the message (ref #1726)
@@ -80,11 +83,12 @@ func (w *DefaultWorker) processCampaigns(t time.Time) error {
 			campaignCache[c.Id] = c
 		}
 		m.CacheCampaign(&c)
-		msg[m.CampaignId] = append(msg[m.CampaignId], m)
+		g := mailGroup{campaignID: m.CampaignId, smtpID: m.SMTPId}
+		msg[g] = append(msg[g], m)
 	}
 
 	// Next, we process each group of maillogs in parallel
-	for cid, msc := range msg {
+	for g, msc := range msg {
 		go func(cid int64, msc []mailer.Mail) {
 			c := campaignCache[cid]
 			if c.Status == models.CampaignQueued {
@@ -98,7 +102,7 @@ func (w *DefaultWorker) processCampaigns(t time.Time) error {
 				"num_emails": len(msc),
 			}).Info("Sending emails to mailer for processing")
 			w.mailer.Queue(msc)
-		}(cid, msc)
+		}(g.campaignID, msc)
 	}
 	return nil
 }
@@ -173,6 +177,12 @@ func stopEC2() error {
 	return nil
 }
 
+// processXAPIStatements sends the xAPI statements which are due to their
+// Learning Record Store, retrying the ones which previously failed.
+func (w *DefaultWorker) processXAPIStatements(t time.Time) error {
+	return models.SendQueuedXAPIStatements(t.UTC())
+}
+
 // Start launches the worker to poll the database every minute for any pending maillogs
 // that need to be processed.
 func (w *DefaultWorker) Start() {
@@ -189,6 +199,10 @@ func (w *DefaultWorker) Start() {
 		if err != nil {
 			log.Error(err)
 		}
+		err = w.processXAPIStatements(t)
+		if err != nil {
+			log.Error(err)
+		}
 	}
 }
 
@@ -202,7 +216,9 @@ func (w *DefaultWorker) LaunchCampaign(c models.Campaign) {
 	models.LockMailLogs(ms, true)
 	// This is required since you cannot pass a slice of values
 	// that implements an interface as a slice of that interface.
-	mailEntries := []mailer.Mail{}
+	// Entries are grouped by sending profile, since the mailer expects
+	// every email in a batch to be sent through the same server.
+	mailEntries := make(map[int64][]mailer.Mail)
 	currentTime := time.Now().UTC()
 	campaignMailCtx, err := models.GetCampaignMailContext(c.Id, c.UserId)
 	if err != nil {
@@ -221,9 +237,11 @@ func (w *DefaultWorker) LaunchCampaign(c models.Campaign) {
 			log.Error(err)
 			return
 		}
-		mailEntries = append(mailEntries, m)
+		mailEntries[m.SMTPId] = append(mailEntries[m.SMTPId], m)
+	}
+	for _, me := range mailEntries {
+		w.mailer.Queue(me)
 	}
-	w.mailer.Queue(mailEntries)
 }
 
 // SendTestEmail sends a test email
@@ -0,0 +1,204 @@
+package xapi
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeoutSeconds is the number of seconds before a timeout occurs
+	// when sending statements to an LRS
+	DefaultTimeoutSeconds = 10
+
+	// Version is the version of the xAPI specification the statements
+	// conform to
+	Version = "1.0.3"
+
+	// VersionHeader is the name of the HTTP header which contains the xAPI
+	// version
+	VersionHeader = "X-Experience-API-Version"
+
+	// MaxErrorBodySize is the number of bytes of an error response included
+	// in the returned error
+	MaxErrorBodySize = 512
+)
+
+// The ADL verbs used in statements
+var (
+	VerbFailed = Verb{
+		ID:      "http://adlnet.gov/expapi/verbs/failed",
+		Display: map[string]string{"en-US": "failed"},
+	}
+	VerbPassed = Verb{
+		ID:      "http://adlnet.gov/expapi/verbs/passed",
+		Display: map[string]string{"en-US": "passed"},
+	}
+	VerbCompleted = Verb{
+		ID:      "http://adlnet.gov/expapi/verbs/completed",
+		Display: map[string]string{"en-US": "completed"},
+	}
+)
+
+// ActivityType is the type of the activities the statements are about
+const ActivityType = "http://adlnet.gov/expapi/activities/simulation"
+
+// ErrURLNotSpecified indicates the LRS endpoint has no URL
+var ErrURLNotSpecified = errors.New("LRS URL can't be empty")
+
+// Statement is an xAPI statement describing something a learner did
+type Statement struct {
+	ID        string    `json:"id"`
+	Actor     Actor     `json:"actor"`
+	Verb      Verb      `json:"verb"`
+	Object    Activity  `json:"object"`
+	Result    *Result   `json:"result,omitempty"`
+	Context   *Context  `json:"context,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Actor is the learner a statement is about, identified by email address
+type Actor struct {
+	ObjectType string `json:"objectType"`
+	Name       string `json:"name,omitempty"`
+	Mbox       string `json:"mbox"`
+}
+
+// NewActor returns the actor for the learner with the given email address
+func NewActor(name string, email string) Actor {
+	return Actor{ObjectType: "Agent", Name: name, Mbox: "mailto:" + email}
+}
+
+// Verb is the action a learner took
+type Verb struct {
+	ID      string            `json:"id"`
+	Display map[string]string `json:"display"`
+}
+
+// Activity is the thing a learner interacted with
+type Activity struct {
+	ObjectType string             `json:"objectType"`
+	ID         string             `json:"id"`
+	Definition ActivityDefinition `json:"definition"`
+}
+
+// ActivityDefinition describes an activity
+type ActivityDefinition struct {
+	Name map[string]string `json:"name"`
+	Type string            `json:"type,omitempty"`
+}
+
+// NewActivity returns the activity with the given IRI and name
+func NewActivity(id string, name string) Activity {
+	return Activity{
+		ObjectType: "Activity",
+		ID:         id,
+		Definition: ActivityDefinition{
+			Name: map[string]string{"en-US": name},
+			Type: ActivityType,
+		},
+	}
+}
+
+// Result is the outcome of a statement
+type Result struct {
+	Score      *Score `json:"score,omitempty"`
+	Success    *bool  `json:"success,omitempty"`
+	Completion *bool  `json:"completion,omitempty"`
+	Response   string `json:"response,omitempty"`
+}
+
+// Score is a learner's score, scaled between 0 and 1
+type Score struct {
+	Scaled float64 `json:"scaled"`
+	Raw    int     `json:"raw"`
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+}
+
+// Context gives the activity a statement took place within
+type Context struct {
+	ContextActivities ContextActivities `json:"contextActivities"`
+}
+
+// ContextActivities are the activities related to a statement
+type ContextActivities struct {
+	Parent []Activity `json:"parent,omitempty"`
+}
+
+// NewStatementID returns a random (version 4) UUID to identify a
+// statement. Sending a statement again with the same ID is idempotent.
+func NewStatementID() (string, error) {
+	b := make([]byte, 16)
+	_, err := io.ReadFull(rand.Reader, b)
+	if err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// EndPoint is a Learning Record Store and the credentials used to
+// authenticate to it
+type EndPoint struct {
+	URL      string
+	Username string
+	Password string
+}
+
+var client = &http.Client{
+	Timeout: time.Second * DefaultTimeoutSeconds,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// SetTransport sets the underlying transport for the xAPI client.
+func SetTransport(tr *http.Transport) {
+	client.Transport = tr
+}
+
+// Send posts the statements to the statements resource of the LRS. An error
+// is returned if the LRS doesn't accept them.
+func Send(endPoint EndPoint, statements []Statement) error {
+	if endPoint.URL == "" {
+		return ErrURLNotSpecified
+	}
+	data, err := json.Marshal(statements)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(endPoint.URL, "/") + "/statements"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(VersionHeader, Version)
+	req.Header.Set("Content-Type", "application/json")
+	if endPoint.Username != "" || endPoint.Password != "" {
+		req.SetBasicAuth(endPoint.Username, endPoint.Password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// A conflict means the LRS already has statements with these IDs, which
+	// happens when a previous attempt was stored but its response was lost
+	if resp.StatusCode == http.StatusConflict {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, MaxErrorBodySize))
+		return fmt.Errorf("http status of response: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
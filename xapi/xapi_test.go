@@ -0,0 +1,76 @@
+package xapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNewStatementID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	id, err := NewStatementID()
+	if err != nil {
+		t.Fatalf("error generating statement id: %v", err)
+	}
+	if !uuid.MatchString(id) {
+		t.Fatalf("invalid statement id. expected a version 4 uuid got %s", id)
+	}
+	other, _ := NewStatementID()
+	if id == other {
+		t.Fatalf("statement ids aren't unique: %s", id)
+	}
+}
+
+func TestSend(t *testing.T) {
+	statement := Statement{
+		ID:        "6f0c2a4e-3c4b-4d1e-9a3f-2b1c0d9e8f7a",
+		Actor:     NewActor("Jane Doe", "jane@example.com"),
+		Verb:      VerbFailed,
+		Object:    NewActivity("https://lms.example.com/campaigns/abc", "Campaign"),
+		Timestamp: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xapi/statements" {
+			t.Fatalf("invalid path. expected /xapi/statements got %s", r.URL.Path)
+		}
+		if r.Header.Get(VersionHeader) != Version {
+			t.Fatalf("invalid version header. expected %s got %s", Version, r.Header.Get(VersionHeader))
+		}
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "key" || password != "secret" {
+			t.Fatalf("invalid credentials received: %s %s", username, password)
+		}
+		got := []Statement{}
+		err := json.NewDecoder(r.Body).Decode(&got)
+		if err != nil {
+			t.Fatalf("error decoding statements: %v", err)
+		}
+		if len(got) != 1 || got[0].Actor.Mbox != "mailto:jane@example.com" || got[0].Verb.ID != VerbFailed.ID {
+			t.Fatalf("invalid statements received: %#v", got)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	err := Send(EndPoint{URL: ts.URL + "/xapi/", Username: "key", Password: "secret"}, []Statement{statement})
+	if err != nil {
+		t.Fatalf("error sending statements: %v", err)
+	}
+}
+
+func TestSendRejected(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid statement", http.StatusBadRequest)
+	}))
+	defer ts.Close()
+	err := Send(EndPoint{URL: ts.URL}, []Statement{})
+	if err == nil {
+		t.Fatalf("expected an error when the LRS rejects the statements")
+	}
+	err = Send(EndPoint{}, []Statement{})
+	if err != ErrURLNotSpecified {
+		t.Fatalf("invalid error. expected %v got %v", ErrURLNotSpecified, err)
+	}
+}